@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+// tokenCookie is the cookie the web UI keeps the token of the account in.
+const tokenCookie = "tripitcalb0t_token"
+
+// account is a read-only user of the web UI and API. An account sees the
+// trips listed by ID, the trips tagged with any of its tags in TripIt, and the
+// trips of any of its kinds, like business, or every trip if All is set. Users sign in with the token, or with OpenID
+// Connect as the email.
+type account struct {
+	Name  string   `json:"name"`
+	Token string   `json:"token,omitempty"`
+	Email string   `json:"email,omitempty"`
+	All   bool     `json:"all,omitempty"`
+	Trips []string `json:"trips,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+	Kinds []string `json:"kinds,omitempty"`
+}
+
+// loadAccounts reads the accounts from the JSON file at path.
+func loadAccounts(path string) ([]account, error) {
+	if len(path) < 1 {
+		return nil, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading users file %s failed: %v", path, err)
+	}
+	var accounts []account
+	if err := json.Unmarshal(b, &accounts); err != nil {
+		return nil, fmt.Errorf("parsing users file %s failed: %v", path, err)
+	}
+
+	names := map[string]bool{}
+	tokens := map[string]bool{}
+	for _, a := range accounts {
+		if len(a.Name) < 1 {
+			return nil, fmt.Errorf("every user in %s needs a name", path)
+		}
+		if names[a.Name] {
+			return nil, fmt.Errorf("user %s is in %s more than once", a.Name, path)
+		}
+		names[a.Name] = true
+		if len(a.Token) < 1 && len(a.Email) < 1 {
+			return nil, fmt.Errorf("user %s needs a token or an email to sign in with", a.Name)
+		}
+		if len(a.Token) > 0 {
+			if len(a.Token) < 16 {
+				return nil, fmt.Errorf("the token of user %s must be at least 16 characters long", a.Name)
+			}
+			if tokens[a.Token] {
+				return nil, fmt.Errorf("user %s has the same token as another user", a.Name)
+			}
+			tokens[a.Token] = true
+		}
+		if !a.All && len(a.Trips) < 1 && len(a.Tags) < 1 && len(a.Kinds) < 1 {
+			return nil, fmt.Errorf("user %s can see no trips, give them trips, tags, kinds, or all", a.Name)
+		}
+	}
+	if len(accounts) < 1 {
+		return nil, errors.New("the users file has no users")
+	}
+
+	return accounts, nil
+}
+
+// canSee returns true if the account may see the event.
+func (a *account) canSee(e tripit.Event) bool {
+	if a.All {
+		return true
+	}
+	for _, id := range a.Trips {
+		if id == e.ID {
+			return true
+		}
+	}
+	for _, tag := range a.Tags {
+		for _, t := range e.Tags {
+			if strings.EqualFold(tag, t) {
+				return true
+			}
+		}
+	}
+	for _, kind := range a.Kinds {
+		if strings.EqualFold(kind, e.Kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// visible returns the events the account may see.
+func (a *account) visible(events []tripit.Event) []tripit.Event {
+	var out []tripit.Event
+	for _, e := range events {
+		if a.canSee(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// findAccount returns the account with the token, or nil if there is none.
+func findAccount(accounts []account, token string) *account {
+	if len(token) < 1 {
+		return nil
+	}
+	var found *account
+	for i := range accounts {
+		// Compare every token, so how long this takes does not tell
+		// anything about them.
+		if subtle.ConstantTimeCompare([]byte(accounts[i].Token), []byte(token)) == 1 {
+			found = &accounts[i]
+		}
+	}
+	return found
+}
+
+// findAccountByEmail returns the account with the email, or nil if there is
+// none.
+func findAccountByEmail(accounts []account, email string) *account {
+	for i := range accounts {
+		if len(accounts[i].Email) > 0 && strings.EqualFold(accounts[i].Email, email) {
+			return &accounts[i]
+		}
+	}
+	return nil
+}
+
+// requestToken returns the token the request was made with, from the
+// Authorization header or the cookie of the web UI.
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if c, err := r.Cookie(tokenCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// withAccount only lets requests made by an account through to h. Users
+// sign in with their token, or with OpenID Connect if oidc is set. Opening
+// the web UI with ?token= once keeps the token in a cookie, so the link can
+// be bookmarked without it. The API takes ?token= on every request instead,
+// for devices like e-ink displays that cannot set headers or keep cookies.
+func withAccount(accounts []account, oidc *oidcProvider, h func(http.ResponseWriter, *http.Request, *account)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("token"); len(token) > 0 && strings.HasPrefix(r.URL.Path, "/api/") {
+			a := findAccount(accounts, token)
+			if a == nil {
+				http.Error(w, "the token is not valid", http.StatusUnauthorized)
+				return
+			}
+			h(w, r, a)
+			return
+		}
+		if token := r.URL.Query().Get("token"); len(token) > 0 {
+			if findAccount(accounts, token) == nil {
+				http.Error(w, "the token is not valid", http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     tokenCookie,
+				Value:    token,
+				Path:     "/",
+				MaxAge:   365 * 24 * 60 * 60,
+				HttpOnly: true,
+				Secure:   secureRequest(r),
+				SameSite: http.SameSiteLaxMode,
+			})
+			u := *r.URL
+			q := u.Query()
+			q.Del("token")
+			u.RawQuery = q.Encode()
+			http.Redirect(w, r, u.RequestURI(), http.StatusFound)
+			return
+		}
+
+		if a := findAccount(accounts, requestToken(r)); a != nil {
+			h(w, r, a)
+			return
+		}
+
+		if oidc == nil {
+			http.Error(w, "sign in by opening the link with your token", http.StatusUnauthorized)
+			return
+		}
+		if email := oidc.session(r); len(email) > 0 {
+			a := findAccountByEmail(accounts, email)
+			if a == nil {
+				http.Error(w, email+" has no access", http.StatusForbidden)
+				return
+			}
+			h(w, r, a)
+			return
+		}
+		// Send people opening a page to sign in, but not programs calling
+		// the API or browsers fetching icons.
+		if r.Method != http.MethodGet || strings.HasPrefix(r.URL.Path, "/api/") || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+			http.Error(w, "sign in first", http.StatusUnauthorized)
+			return
+		}
+		oidc.login(w, r)
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+)
+
+// The changes to the itinerary that need approval with --approve.
+const (
+	proposalAdd    = "add"
+	proposalChange = "change"
+	proposalRemove = "remove"
+)
+
+// approvals holds the version of every segment approved for the calendars
+// and the changes to them waiting for approval, keyed by segment ID.
+type approvals struct {
+	Approved  map[string]tripit.Event `json:"approved"`
+	Proposals map[string]*proposal    `json:"proposals,omitempty"`
+}
+
+// proposal is a change to a segment waiting for approval.
+type proposal struct {
+	Action   string    `json:"action"`
+	Title    string    `json:"title"`
+	Start    time.Time `json:"start,omitempty"`
+	Hash     string    `json:"hash"`
+	Proposed time.Time `json:"proposed"`
+	Approved bool      `json:"approved,omitempty"`
+	Rejected bool      `json:"rejected,omitempty"`
+}
+
+// decisions holds the changes approved or rejected through chat while the
+// bot runs, by segment ID, until the next sync applies them. The running sync
+// owns the state file, so they are not written to it directly.
+var decisions = struct {
+	sync.Mutex
+	approved map[string]bool
+}{approved: map[string]bool{}}
+
+// queueDecision approves or rejects the change to the segment on the next
+// sync.
+func queueDecision(segmentID string, approved bool) {
+	decisions.Lock()
+	defer decisions.Unlock()
+
+	decisions.approved[segmentID] = approved
+}
+
+// applyDecisions approves or rejects the proposals decided on through chat
+// since the last sync.
+func (a *approvals) applyDecisions() {
+	decisions.Lock()
+	defer decisions.Unlock()
+
+	for id, approved := range decisions.approved {
+		if p, ok := a.Proposals[id]; ok {
+			p.Approved, p.Rejected = approved, !approved
+		}
+		delete(decisions.approved, id)
+	}
+}
+
+// decision is an approval or rejection made with the approve command, as
+// written to the decisions file.
+type decision struct {
+	Segment  string `json:"segment"`
+	Approved bool   `json:"approved"`
+}
+
+// decisionsFile returns the file the approve command writes its decisions
+// to, next to the state file at path, like state-decisions.jsonl. A running
+// bot holds the state in memory and saves it at the end of every sync, so
+// the approve command must not write to the state file itself.
+func decisionsFile(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "-decisions.jsonl"
+}
+
+// writeDecision appends the decision to the decisions file for the next
+// sync to apply.
+func writeDecision(path string, d decision) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("opening decisions file %s failed: %v", path, err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("writing decisions file %s failed: %v", path, err)
+	}
+	return f.Close()
+}
+
+// readDecisions returns the decisions in the file, oldest first. A missing
+// file has none.
+func readDecisions(path string) ([]decision, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading decisions file %s failed: %v", path, err)
+	}
+	var ds []decision
+	for n, line := range strings.Split(string(b), "\n") {
+		if len(strings.TrimSpace(line)) < 1 {
+			continue
+		}
+		var d decision
+		if err := json.Unmarshal([]byte(line), &d); err != nil {
+			return nil, fmt.Errorf("line %d of decisions file %s is not valid: %v", n+1, path, err)
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// takeDecisions moves the decisions file aside, so the approve command
+// starts a new one, and returns the decisions in it and the file it moved it
+// to. The moved file is only removed once the state they were applied to is
+// saved, so a sync that dies before then, or a dry run, leaves them for the
+// next sync.
+func takeDecisions(path string) ([]decision, string, error) {
+	taken := path + ".taken"
+	if _, err := os.Stat(taken); os.IsNotExist(err) {
+		if err := os.Rename(path, taken); os.IsNotExist(err) {
+			return nil, "", nil
+		} else if err != nil {
+			return nil, "", fmt.Errorf("taking decisions file %s failed: %v", path, err)
+		}
+	} else {
+		// A sync took decisions before but never saved the state, so
+		// add the new ones after them.
+		b, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, "", fmt.Errorf("reading decisions file %s failed: %v", path, err)
+		}
+		if len(b) > 0 {
+			f, err := os.OpenFile(taken, os.O_WRONLY|os.O_APPEND, 0600)
+			if err != nil {
+				return nil, "", fmt.Errorf("opening decisions file %s failed: %v", taken, err)
+			}
+			_, err = f.Write(b)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return nil, "", fmt.Errorf("writing decisions file %s failed: %v", taken, err)
+			}
+			os.Remove(path)
+		}
+	}
+
+	ds, err := readDecisions(taken)
+	return ds, taken, err
+}
+
+// approvalHash returns a hash of the TripIt event, to tell whether it
+// changed since it was approved.
+func approvalHash(e tripit.Event) string {
+	b, _ := json.Marshal(e)
+	return fmt.Sprintf("%x", sha256.Sum256(b))
+}
+
+// gateChanges returns the events as they were last approved, holding back
+// every change TripIt made since until it is approved, and the
+// announcements of the changes that newly need approval. The events
+// already on the calendar when --approve is turned on count as approved.
+// Approved changes are let through, and rejected ones held back until
+// TripIt changes the segment again.
+func gateChanges(st *syncState, trips []tripit.Event, now time.Time) ([]tripit.Event, []string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.Approvals == nil {
+		st.Approvals = &approvals{Approved: map[string]tripit.Event{}}
+		for _, e := range trips {
+			if _, ok := st.Events[e.SegmentID]; ok {
+				st.Approvals.Approved[e.SegmentID] = e
+			}
+		}
+	}
+	a := st.Approvals
+	if a.Approved == nil {
+		a.Approved = map[string]tripit.Event{}
+	}
+	if a.Proposals == nil {
+		a.Proposals = map[string]*proposal{}
+	}
+	if len(st.path) > 0 {
+		ds, taken, err := takeDecisions(decisionsFile(st.path))
+		if err != nil {
+			logrus.Warnf("applying approvals failed: %v", err)
+		}
+		for _, d := range ds {
+			if p, ok := a.Proposals[d.Segment]; ok {
+				p.Approved, p.Rejected = d.Approved, !d.Approved
+			}
+		}
+		st.decided = taken
+	}
+	a.applyDecisions()
+
+	var (
+		gated         []tripit.Event
+		announcements []string
+		current       = map[string]bool{}
+	)
+	for _, e := range trips {
+		current[e.SegmentID] = true
+		hash := approvalHash(e)
+		prev, ok := a.Approved[e.SegmentID]
+		if ok && approvalHash(prev) == hash {
+			delete(a.Proposals, e.SegmentID)
+			gated = append(gated, prev)
+			continue
+		}
+
+		action := proposalChange
+		if !ok {
+			action = proposalAdd
+		}
+		approved, announcement := a.decide(e.SegmentID, action, e.Title, eventTime(e.Start), hash, now)
+		if len(announcement) > 0 {
+			announcements = append(announcements, announcement)
+		}
+		switch {
+		case approved:
+			a.Approved[e.SegmentID] = e
+			gated = append(gated, e)
+		case ok:
+			gated = append(gated, prev)
+		}
+	}
+
+	var gone []string
+	for id := range a.Approved {
+		if !current[id] {
+			gone = append(gone, id)
+		}
+	}
+	sort.Strings(gone)
+	for _, id := range gone {
+		prev := a.Approved[id]
+		// TripIt stops listing trips once they are over, which is not
+		// a change anyone needs to approve.
+		if end := eventTime(prev.End); !end.IsZero() && end.Before(now) {
+			delete(a.Approved, id)
+			delete(a.Proposals, id)
+			continue
+		}
+
+		approved, announcement := a.decide(id, proposalRemove, prev.Title, eventTime(prev.Start), proposalRemove, now)
+		if len(announcement) > 0 {
+			announcements = append(announcements, announcement)
+		}
+		if approved {
+			delete(a.Approved, id)
+			continue
+		}
+		gated = append(gated, prev)
+	}
+
+	// Forget the proposals to add segments TripIt no longer has.
+	for id := range a.Proposals {
+		if _, ok := a.Approved[id]; !ok && !current[id] {
+			delete(a.Proposals, id)
+		}
+	}
+
+	return gated, announcements
+}
+
+// decide returns true if the change to the segment with the hash was
+// approved, or proposes it and returns the announcement of it if it is new.
+func (a *approvals) decide(segmentID, action, title string, start time.Time, hash string, now time.Time) (bool, string) {
+	if p, ok := a.Proposals[segmentID]; ok && p.Hash == hash {
+		if p.Approved {
+			delete(a.Proposals, segmentID)
+			return true, ""
+		}
+		return false, ""
+	}
+
+	a.Proposals[segmentID] = &proposal{
+		Action:   action,
+		Title:    title,
+		Start:    start.UTC(),
+		Hash:     hash,
+		Proposed: now.UTC(),
+	}
+	return false, fmt.Sprintf("Waiting for approval to %s %s (%s), approve it with: tripitcalb0t approve %s", action, title, segmentID, segmentID)
+}
+
+// pendingProposals returns the segment IDs of the proposals nobody has
+// approved or rejected yet, in the order they start.
+func (a *approvals) pendingProposals() []string {
+	if a == nil {
+		return nil
+	}
+	var ids []string
+	for id, p := range a.Proposals {
+		if !p.Approved && !p.Rejected {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return a.Proposals[ids[i]].Start.Before(a.Proposals[ids[j]].Start)
+	})
+	return ids
+}
+
+const approveHelp = `List, approve, or reject the changes waiting for approval with --approve.`
+
+const approveLongHelp = `List, approve, or reject the changes waiting for approval with --approve.
+
+With --approve, the changes TripIt makes to your itinerary are held back from
+the calendars until you approve them. Approved changes are written on the
+next sync. Rejected changes stay held back until TripIt changes the segment
+again. The decisions are kept in a file next to the state file until the next
+sync applies them, so this is safe to run while the bot is running.
+
+  tripitcalb0t approve
+  tripitcalb0t approve 123456789
+  tripitcalb0t approve --reject 123456789
+  tripitcalb0t approve --all`
+
+func (cmd *approveCommand) Name() string      { return "approve" }
+func (cmd *approveCommand) Args() string      { return "[segment-id...]" }
+func (cmd *approveCommand) ShortHelp() string { return approveHelp }
+func (cmd *approveCommand) LongHelp() string  { return approveLongHelp }
+func (cmd *approveCommand) Hidden() bool      { return false }
+
+func (cmd *approveCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.all, "all", false, "Approve or reject every change waiting for approval")
+	fs.BoolVar(&cmd.reject, "reject", false, "Reject the changes instead of approving them")
+}
+
+type approveCommand struct {
+	all    bool
+	reject bool
+}
+
+func (cmd *approveCommand) Run(ctx context.Context, args []string) error {
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+
+	// Leave out the changes already decided on, which the next sync
+	// applies.
+	path := decisionsFile(stateFile)
+	decided := map[string]bool{}
+	for _, f := range []string{path + ".taken", path} {
+		ds, err := readDecisions(f)
+		if err != nil {
+			return err
+		}
+		for _, d := range ds {
+			decided[d.Segment] = true
+		}
+	}
+	var pending []string
+	for _, id := range st.Approvals.pendingProposals() {
+		if !decided[id] {
+			pending = append(pending, id)
+		}
+	}
+
+	if len(args) < 1 && !cmd.all {
+		if len(pending) < 1 {
+			fmt.Println("There are no changes waiting for approval.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 1, 2, ' ', 0)
+		fmt.Fprintln(w, "SEGMENT\tCHANGE\tSTARTS\tTITLE\tPROPOSED")
+		for _, id := range pending {
+			p := st.Approvals.Proposals[id]
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", id, p.Action, p.Start.Local().Format("2006-01-02 15:04"), p.Title, p.Proposed.Local().Format("2006-01-02 15:04"))
+		}
+		return w.Flush()
+	}
+
+	if cmd.all {
+		args = pending
+		if len(args) < 1 {
+			return errors.New("there are no changes waiting for approval")
+		}
+	}
+	for _, id := range args {
+		p, ok := st.Approvals.proposal(id)
+		if !ok {
+			return fmt.Errorf("there is no change to segment %s waiting for approval", id)
+		}
+		if err := writeDecision(path, decision{Segment: id, Approved: !cmd.reject}); err != nil {
+			return err
+		}
+		verb := "Approved"
+		if cmd.reject {
+			verb = "Rejected"
+		}
+		fmt.Printf("%s the change to %s %s (%s)\n", verb, p.Action, p.Title, id)
+	}
+	if !cmd.reject {
+		fmt.Println("The approved changes are written to the calendars on the next sync.")
+	}
+	return nil
+}
+
+// proposal returns the proposal for the segment.
+func (a *approvals) proposal(segmentID string) (*proposal, bool) {
+	if a == nil {
+		return nil, false
+	}
+	p, ok := a.Proposals[segmentID]
+	return p, ok
+}
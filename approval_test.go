@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// approvalEvent returns a flight segment departing at the time.
+func approvalEvent(segmentID string, departs time.Time) tripit.Event {
+	return tripit.Event{
+		ID:        "trip",
+		SegmentID: segmentID,
+		Title:     "Flight to Newark (UA 123)",
+		Start:     calendar.EventDateTime{DateTime: departs.Format(time.RFC3339)},
+		End:       calendar.EventDateTime{DateTime: departs.Add(5 * time.Hour).Format(time.RFC3339)},
+	}
+}
+
+func TestApprovalHash(t *testing.T) {
+	departs := time.Date(2030, time.July, 10, 9, 0, 0, 0, time.UTC)
+	e := approvalEvent("1", departs)
+
+	if approvalHash(e) != approvalHash(approvalEvent("1", departs)) {
+		t.Error("approvalHash differs for the same event")
+	}
+	if approvalHash(e) == approvalHash(approvalEvent("1", departs.Add(time.Hour))) {
+		t.Error("approvalHash is the same for an event that moved")
+	}
+	moved := e
+	moved.Title = "Flight to Boston (UA 123)"
+	if approvalHash(e) == approvalHash(moved) {
+		t.Error("approvalHash is the same for an event that changed its title")
+	}
+}
+
+func TestGateChanges(t *testing.T) {
+	now := time.Date(2030, time.July, 1, 12, 0, 0, 0, time.UTC)
+	departs := now.AddDate(0, 0, 9)
+	st := newState(filepath.Join(t.TempDir(), "state.json"))
+	st.Events["1"] = &stateEvent{SegmentID: "1"}
+
+	// The events already on the calendar count as approved, new ones are
+	// held back.
+	synced, added := approvalEvent("1", departs), approvalEvent("2", departs.AddDate(0, 0, 2))
+	gated, announcements := gateChanges(st, []tripit.Event{synced, added}, now)
+	if !reflect.DeepEqual(gated, []tripit.Event{synced}) {
+		t.Errorf("gated = %v, want only the synced event", gated)
+	}
+	if len(announcements) != 1 {
+		t.Errorf("announcements = %v, want the added event proposed", announcements)
+	}
+
+	// A change is held back, and the approved version kept.
+	moved := approvalEvent("1", departs.Add(time.Hour))
+	gated, announcements = gateChanges(st, []tripit.Event{moved, added}, now)
+	if !reflect.DeepEqual(gated, []tripit.Event{synced}) {
+		t.Errorf("gated = %v, want the approved version of the moved event", gated)
+	}
+	if len(announcements) != 1 {
+		t.Errorf("announcements = %v, want the move proposed", announcements)
+	}
+
+	// Approving the move with the approve command lets it through on the
+	// next sync, and rejecting the addition keeps it held back.
+	path := decisionsFile(st.path)
+	if err := writeDecision(path, decision{Segment: "1", Approved: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeDecision(path, decision{Segment: "2", Approved: false}); err != nil {
+		t.Fatal(err)
+	}
+	gated, announcements = gateChanges(st, []tripit.Event{moved, added}, now)
+	if !reflect.DeepEqual(gated, []tripit.Event{moved}) {
+		t.Errorf("gated = %v, want the approved move", gated)
+	}
+	if len(announcements) != 0 {
+		t.Errorf("announcements = %v, want none", announcements)
+	}
+	if ids := st.Approvals.pendingProposals(); len(ids) != 0 {
+		t.Errorf("pending proposals = %v, want none after deciding them", ids)
+	}
+
+	// The decisions are only removed once the state is saved.
+	if _, err := os.Stat(st.decided); err != nil {
+		t.Fatalf("the applied decisions are gone before the state was saved: %v", err)
+	}
+	taken := st.decided
+	if err := st.save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(taken); !os.IsNotExist(err) {
+		t.Errorf("the applied decisions are left after saving the state: %v", err)
+	}
+}
+
+func TestTakeDecisions(t *testing.T) {
+	path := decisionsFile(filepath.Join(t.TempDir(), "state.json"))
+	if filepath.Base(path) != "state-decisions.jsonl" {
+		t.Errorf("decisionsFile = %s, want state-decisions.jsonl", path)
+	}
+
+	ds, taken, err := takeDecisions(path)
+	if err != nil || ds != nil || len(taken) > 0 {
+		t.Fatalf("takeDecisions without decisions = %v, %q, %v, want none", ds, taken, err)
+	}
+
+	if err := writeDecision(path, decision{Segment: "1", Approved: true}); err != nil {
+		t.Fatal(err)
+	}
+	ds, taken, err = takeDecisions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []decision{{Segment: "1", Approved: true}}; !reflect.DeepEqual(ds, want) {
+		t.Errorf("takeDecisions = %v, want %v", ds, want)
+	}
+
+	// A sync that took decisions but never saved leaves them for the
+	// next, which takes the new ones after them.
+	if err := writeDecision(path, decision{Segment: "2", Approved: false}); err != nil {
+		t.Fatal(err)
+	}
+	ds, again, err := takeDecisions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []decision{{Segment: "1", Approved: true}, {Segment: "2", Approved: false}}; !reflect.DeepEqual(ds, want) {
+		t.Errorf("takeDecisions after an unsaved sync = %v, want %v", ds, want)
+	}
+	if again != taken {
+		t.Errorf("takeDecisions took them to %s, want %s", again, taken)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the decisions file is left after taking it: %v", err)
+	}
+}
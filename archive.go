@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// archiveEndedTrips stops tracking the trips whose every segment has ended.
+// Their state is compacted to what we need to search our travel history, and
+// their events are moved to the archive calendar if there is one. It returns
+// the number of events archived.
+func archiveEndedTrips(ctx context.Context, gcalClient *calendar.Service, calendarName string, st *syncState) int {
+	st.mu.Lock()
+	ended := map[string]bool{}
+	byTrip := map[string][]*stateEvent{}
+	for _, se := range st.Events {
+		if se.Archived {
+			continue
+		}
+		if _, ok := ended[se.TripID]; !ok {
+			ended[se.TripID] = true
+		}
+		if se.End.IsZero() || se.End.After(time.Now()) {
+			ended[se.TripID] = false
+		}
+		byTrip[se.TripID] = append(byTrip[se.TripID], se)
+	}
+	st.mu.Unlock()
+
+	var archived int
+	for tripID, events := range byTrip {
+		if !ended[tripID] {
+			continue
+		}
+
+		for _, se := range events {
+			if len(archiveCalendar) > 0 {
+				moved, err := gcalClient.Events.Move(calendarName, se.EventID, archiveCalendar).Context(ctx).Do()
+				if err != nil && !isNotFound(err) {
+					logrus.Errorf("moving google calendar event %s to archive calendar %s failed: %v", se.EventID, archiveCalendar, err)
+					continue
+				}
+				if moved != nil {
+					se.EventID = moved.Id
+				}
+			}
+
+			st.mu.Lock()
+			se.Archived = true
+			se.Hash = ""
+			se.Missing = 0
+			st.mu.Unlock()
+			archived++
+		}
+		logrus.Infof("archived trip %s", tripID)
+	}
+
+	return archived
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// calendarBackend is a calendar the bot syncs events to. Events are keyed by
+// the TripIt segment they are for, which is unique and stable, so syncing a
+// segment again updates its one event instead of making another. Events are
+// passed as Google Calendar events, which have every field the bot writes,
+// and other backends convert them.
+type calendarBackend interface {
+	// String names the calendar for logs.
+	String() string
+	// List returns the events in the calendar the bot manages.
+	List(ctx context.Context) ([]*calendar.Event, error)
+	// Create adds the event for the key and returns it with the ID the
+	// calendar gave it.
+	Create(ctx context.Context, key string, e *calendar.Event) (*calendar.Event, error)
+	// Update sets the fields of the event for the key, with the ID Create
+	// returned, to those in the patch, leaving the rest alone.
+	Update(ctx context.Context, key, id string, patch *calendar.Event) error
+	// Delete removes the event for the key, with the ID Create returned.
+	// Deleting an event that is already gone is not an error.
+	Delete(ctx context.Context, key, id string) error
+}
+
+// googleBackend is a Google Calendar.
+type googleBackend struct {
+	service    *calendar.Service
+	calendarID string
+}
+
+// newGoogleBackend returns the backend for the Google Calendar with the ID.
+func newGoogleBackend(service *calendar.Service, calendarID string) *googleBackend {
+	return &googleBackend{service: service, calendarID: calendarID}
+}
+
+func (g *googleBackend) String() string {
+	return "google calendar " + g.calendarID
+}
+
+// List returns the events in the calendar the bot manages from the last four
+// years on.
+func (g *googleBackend) List(ctx context.Context) ([]*calendar.Event, error) {
+	return listCalendarEvents(ctx, g.service, g.calendarID)
+}
+
+// Create inserts the event, notifying the guests as --send-updates-create
+// says.
+func (g *googleBackend) Create(ctx context.Context, key string, e *calendar.Event) (*calendar.Event, error) {
+	payloads.dump("inserting google calendar event", e)
+	created, err := g.service.Events.Insert(g.calendarID, e).Context(ctx).Do(sendUpdates(sendUpdatesCreate))
+	if err != nil {
+		return nil, fmt.Errorf("inserting google calendar event for segment %s failed: %w", key, err)
+	}
+	return created, nil
+}
+
+// Update patches the event, notifying the guests as --send-updates-update
+// says.
+func (g *googleBackend) Update(ctx context.Context, key, id string, patch *calendar.Event) error {
+	payloads.dump("patching google calendar event", patch)
+	if _, err := g.service.Events.Patch(g.calendarID, id, patch).Context(ctx).Do(sendUpdates(sendUpdatesUpdate)); err != nil {
+		return fmt.Errorf("updating google calendar event %s failed: %w", id, err)
+	}
+	return nil
+}
+
+// Delete deletes the event, notifying the guests as --send-updates-update
+// says.
+func (g *googleBackend) Delete(ctx context.Context, key, id string) error {
+	if err := g.service.Events.Delete(g.calendarID, id).Context(ctx).Do(sendUpdates(sendUpdatesUpdate)); err != nil && !isNotFound(err) {
+		return fmt.Errorf("removing google calendar event %s failed: %w", id, err)
+	}
+	return nil
+}
+
+// listAllEvents returns every event in the calendar the bot manages. Google
+// Calendar lists them however old they are, the others the ones List does.
+func listAllEvents(ctx context.Context, backend calendarBackend) ([]*calendar.Event, error) {
+	if google, ok := backend.(*googleBackend); ok {
+		return listManagedEvents(ctx, google.service, google.calendarID)
+	}
+	return backend.List(ctx)
+}
+
+// mergeEventPatch returns the event with the fields set in the patch, the
+// way Google Calendar patches an event, for backends that can only replace
+// an event. Extended properties are merged.
+func mergeEventPatch(e, patch *calendar.Event) *calendar.Event {
+	merged := *e
+	set := func(field, value string) bool {
+		if len(value) > 0 {
+			return true
+		}
+		for _, f := range patch.ForceSendFields {
+			if f == field {
+				return true
+			}
+		}
+		return false
+	}
+	if set("Summary", patch.Summary) {
+		merged.Summary = patch.Summary
+	}
+	if set("Description", patch.Description) {
+		merged.Description = patch.Description
+	}
+	if set("Location", patch.Location) {
+		merged.Location = patch.Location
+	}
+	if set("Visibility", patch.Visibility) {
+		merged.Visibility = patch.Visibility
+	}
+	if set("Transparency", patch.Transparency) {
+		merged.Transparency = patch.Transparency
+	}
+	if patch.Start != nil {
+		merged.Start = patch.Start
+	}
+	if patch.End != nil {
+		merged.End = patch.End
+	}
+	if patch.Source != nil {
+		merged.Source = patch.Source
+	}
+	if patch.ExtendedProperties != nil {
+		props := &calendar.EventExtendedProperties{}
+		if e.ExtendedProperties != nil {
+			props.Private, props.Shared = e.ExtendedProperties.Private, e.ExtendedProperties.Shared
+		}
+		props.Private = mergeProperties(props.Private, patch.ExtendedProperties.Private)
+		props.Shared = mergeProperties(props.Shared, patch.ExtendedProperties.Shared)
+		merged.ExtendedProperties = props
+	}
+	return &merged
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+)
+
+// baggageAllowance is what you may bring on the flights of an airline, or of
+// one of its classes of service.
+type baggageAllowance struct {
+	// Airline is the IATA code or the name of the airline.
+	Airline string `json:"airline"`
+	// Class is the class of service, like economy, or empty for every
+	// class without an allowance of its own.
+	Class   string `json:"class,omitempty"`
+	Checked string `json:"checked,omitempty"`
+	CarryOn string `json:"carryOn,omitempty"`
+	Note    string `json:"note,omitempty"`
+}
+
+// String returns the allowance as a line of the event description.
+func (a baggageAllowance) String() string {
+	var parts []string
+	if len(a.Checked) > 0 {
+		parts = append(parts, "checked "+a.Checked)
+	}
+	if len(a.CarryOn) > 0 {
+		parts = append(parts, "carry-on "+a.CarryOn)
+	}
+	if len(a.Note) > 0 {
+		parts = append(parts, a.Note)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// loadBaggageAllowances reads the baggage allowances from the JSON file at
+// path.
+func loadBaggageAllowances(path string) ([]baggageAllowance, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading baggage file %s failed: %v", path, err)
+	}
+	var allowances []baggageAllowance
+	if err := json.Unmarshal(b, &allowances); err != nil {
+		return nil, fmt.Errorf("parsing baggage file %s failed: %v", path, err)
+	}
+	for _, a := range allowances {
+		if len(a.Airline) < 1 {
+			return nil, fmt.Errorf("every allowance in baggage file %s needs an airline", path)
+		}
+		if len(a.String()) < 1 {
+			return nil, fmt.Errorf("the allowance for %s in baggage file %s needs a checked or carry-on allowance or a note", a.Airline, path)
+		}
+	}
+	return allowances, nil
+}
+
+// flightAllowance returns the allowance for the flight, preferring one for
+// its class of service over one for the whole airline.
+func flightAllowance(allowances []baggageAllowance, e tripit.Event) (baggageAllowance, bool) {
+	var (
+		found baggageAllowance
+		ok    bool
+	)
+	for _, a := range allowances {
+		if !strings.EqualFold(a.Airline, e.AirlineCode) && !strings.EqualFold(a.Airline, e.Airline) {
+			continue
+		}
+		switch {
+		case len(a.Class) < 1:
+			if !ok {
+				found, ok = a, true
+			}
+		case strings.Contains(strings.ToLower(e.ServiceClass), strings.ToLower(a.Class)):
+			return a, true
+		}
+	}
+	return found, ok
+}
+
+// annotateBaggage adds the --baggage-file allowance of every flight to its
+// description.
+func annotateBaggage(events []tripit.Event) {
+	allowances, err := loadBaggageAllowances(baggageFile)
+	if err != nil {
+		logrus.Warn(err)
+		return
+	}
+
+	for i := range events {
+		e := &events[i]
+		if !e.IsFlight() {
+			continue
+		}
+		a, ok := flightAllowance(allowances, *e)
+		if !ok {
+			continue
+		}
+		on := firstNonEmpty(e.Airline, e.AirlineCode)
+		if len(a.Class) > 0 {
+			on += " " + a.Class
+		}
+		e.Description += fmt.Sprintf("\n\nBaggage allowance on %s: %s", on, a)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"golang.org/x/oauth2/google"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// busyCalendarIDs returns the IDs of the other calendars to check for
+// meetings that new flights collide with.
+func busyCalendarIDs() []string {
+	var ids []string
+	for _, id := range strings.Split(busyCalendars, ",") {
+		if id = strings.TrimSpace(id); len(id) > 0 && id != calendarName {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// busyMeeting is a meeting in one of the busy calendars.
+type busyMeeting struct {
+	CalendarID string
+	Event      *calendar.Event
+}
+
+// busyConflicts returns the meetings in the busy calendars that the flight
+// collides with. Only meetings that make us busy count, so free and all-day
+// events, and meetings we declined, are left out.
+func busyConflicts(ctx context.Context, gcalClient *calendar.Service, trip tripit.Event) ([]busyMeeting, error) {
+	start, end := eventTime(trip.Start), eventTime(trip.End)
+	if start.IsZero() || end.IsZero() {
+		return nil, nil
+	}
+
+	var meetings []busyMeeting
+	for _, id := range busyCalendarIDs() {
+		events, err := gcalClient.Events.List(id).
+			SingleEvents(true).
+			ShowDeleted(false).
+			TimeMin(start.Format(time.RFC3339)).
+			TimeMax(end.Format(time.RFC3339)).
+			Context(ctx).
+			Do()
+		if err != nil {
+			return meetings, fmt.Errorf("checking google calendar %s for conflicts failed: %v", id, err)
+		}
+		for _, e := range events.Items {
+			if isBusy(e, id) {
+				meetings = append(meetings, busyMeeting{CalendarID: id, Event: e})
+			}
+		}
+	}
+	return meetings, nil
+}
+
+// isBusy returns true if the event in the calendar is a meeting that makes
+// us busy.
+func isBusy(e *calendar.Event, calendarID string) bool {
+	if e.Status == "cancelled" || e.Transparency == "transparent" || e.Start == nil || len(e.Start.DateTime) < 1 {
+		return false
+	}
+	if a := ownAttendee(e, calendarID); a != nil && a.ResponseStatus == "declined" {
+		return false
+	}
+	return true
+}
+
+// ownAttendee returns the attendee of the event that is the owner of the
+// calendar, or nil if they are not invited. The bot is usually not the owner
+// of the calendars it checks, so the owner is found by the calendar ID,
+// which is their email address for primary calendars.
+func ownAttendee(e *calendar.Event, calendarID string) *calendar.EventAttendee {
+	for _, a := range e.Attendees {
+		if a.Self || strings.EqualFold(a.Email, calendarID) {
+			return a
+		}
+	}
+	return nil
+}
+
+// meetingStart returns when the meeting starts, in the time zone the flight
+// departs in.
+func meetingStart(trip tripit.Event, m busyMeeting) string {
+	return eventTime(*m.Event.Start).In(eventTime(trip.Start).Location()).Format("Mon Jan 2 3:04pm")
+}
+
+// conflictWarning returns the warning about the flight colliding with the
+// meeting.
+func conflictWarning(trip tripit.Event, m busyMeeting) string {
+	return fmt.Sprintf("Conflict: %s collides with %q at %s in %s", trip.Title, m.Event.Summary, meetingStart(trip, m), m.CalendarID)
+}
+
+// declineMeeting declines the meeting for the owner of its calendar with the
+// decline message for the flight, and lets the people --decline-send-updates
+// says know. Meetings they organize or are not invited to are left alone.
+//
+// Only attendees can change their own response, so the bot acts as the owner
+// of the calendar, which needs domain-wide delegation for the calendar events
+// scope. Google Calendar has no API to propose a new time, so meetings are
+// only declined.
+func declineMeeting(ctx context.Context, trip tripit.Event, m busyMeeting) (bool, error) {
+	if m.Event.Organizer != nil && (m.Event.Organizer.Self || strings.EqualFold(m.Event.Organizer.Email, m.CalendarID)) {
+		return false, nil
+	}
+	own := ownAttendee(m.Event, m.CalendarID)
+	if own == nil {
+		return false, nil
+	}
+
+	gcalClient, err := newDelegatedCalendarClient(ctx, m.CalendarID)
+	if err != nil {
+		return false, err
+	}
+
+	own.ResponseStatus = "declined"
+	own.Comment = declineText(trip)
+	patch := &calendar.Event{Attendees: m.Event.Attendees}
+	if _, err := gcalClient.Events.Patch(m.CalendarID, m.Event.Id, patch).Context(ctx).Do(sendUpdates(declineSendUpdates)); err != nil {
+		return false, fmt.Errorf("declining %q in google calendar %s failed: %v", m.Event.Summary, m.CalendarID, err)
+	}
+	return true, nil
+}
+
+// newDelegatedCalendarClient returns a Google Calendar API client that acts
+// as the user, with the domain-wide delegation of the service account.
+func newDelegatedCalendarClient(ctx context.Context, user string) (*calendar.Service, error) {
+	data, err := readGoogleKeyfile()
+	if err != nil {
+		return nil, err
+	}
+	defer zero(data)
+
+	conf, err := google.JWTConfigFromJSON(data, googleScopes()...)
+	if err != nil {
+		return nil, fmt.Errorf("creating google calendar token source from file %s failed: %v", googleCalendarKeyfile, err)
+	}
+	conf.Subject = user
+
+	client, err := calendar.New(instrumentClient(conf.Client(ctx), "google"))
+	if err != nil {
+		return nil, fmt.Errorf("creating google calendar client for %s failed: %v", user, err)
+	}
+	return client, nil
+}
+
+// declineText returns the decline message for the flight, with {flight},
+// {from}, {to}, {departs}, {arrives}, and {kind} filled in.
+func declineText(trip tripit.Event) string {
+	return strings.NewReplacer(
+		"{flight}", firstNonEmpty(trip.FlightNumber, trip.Title),
+		"{from}", trip.AirportCode,
+		"{to}", trip.DestinationCode,
+		"{departs}", formatZoned(trip.Start),
+		"{arrives}", formatZoned(trip.End),
+		"{kind}", trip.Kind,
+	).Replace(declineMessage)
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const (
+	// icsPrivateProperty and icsSharedProperty hold the extended properties
+	// of an event in iCalendar, one per property, with the name in the
+	// X-KEY parameter.
+	icsPrivateProperty = "X-TRIPITCALB0T-PRIVATE"
+	icsSharedProperty  = "X-TRIPITCALB0T-SHARED"
+
+	// caldavCalendarQuery asks for the data of every event in a calendar.
+	caldavCalendarQuery = `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <d:getetag/>
+    <c:calendar-data/>
+  </d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT"/>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`
+)
+
+// caldavBackend is a calendar on a CalDAV server, like Nextcloud, Radicale,
+// Fastmail, or iCloud. Every event is its own resource in the calendar
+// collection, named after the key, so writing a segment again replaces its
+// one event.
+type caldavBackend struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+// caldavError is a response from the CalDAV server we did not expect.
+type caldavError struct {
+	method string
+	url    string
+	code   int
+	status string
+}
+
+func (e *caldavError) Error() string {
+	return fmt.Sprintf("%s %s returned %s", e.method, e.url, e.status)
+}
+
+// newCalDAVBackend returns the backend for the calendar collection at the
+// URL, authenticating with the username and password, which is an app
+// password for most hosted servers.
+func newCalDAVBackend(collection, username, password string) *caldavBackend {
+	if !strings.HasSuffix(collection, "/") {
+		collection += "/"
+	}
+	return &caldavBackend{
+		url:      collection,
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: time.Minute},
+	}
+}
+
+func (c *caldavBackend) String() string {
+	return "caldav calendar " + c.url
+}
+
+// resource returns the URL of the event with the ID.
+func (c *caldavBackend) resource(id string) string {
+	return c.url + url.PathEscape(id) + ".ics"
+}
+
+// do sends the request to the server and returns the response if it has one
+// of the statuses, closing it otherwise.
+func (c *caldavBackend) do(ctx context.Context, method, u string, body []byte, header http.Header, statuses ...int) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if len(c.username) > 0 {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range statuses {
+		if resp.StatusCode == s {
+			return resp, nil
+		}
+	}
+	resp.Body.Close()
+	return nil, &caldavError{method: method, url: u, code: resp.StatusCode, status: resp.Status}
+}
+
+// multistatus is the response to a CalDAV REPORT. Elements are matched by
+// their local names, whatever prefix the server gives the namespaces.
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ETag string `xml:"getetag"`
+				Data string `xml:"calendar-data"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// List returns the events in the calendar the bot manages.
+func (c *caldavBackend) List(ctx context.Context) ([]*calendar.Event, error) {
+	header := http.Header{}
+	header.Set("Depth", "1")
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := c.do(ctx, "REPORT", c.url, []byte(caldavCalendarQuery), header, http.StatusMultiStatus)
+	if err != nil {
+		return nil, fmt.Errorf("getting events from %s failed: %w", c, err)
+	}
+	defer resp.Body.Close()
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("decoding events from %s failed: %v", c, err)
+	}
+
+	var events []*calendar.Event
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstat {
+			if len(ps.Prop.Data) < 1 {
+				continue
+			}
+			for _, e := range parseICS(ps.Prop.Data) {
+				if privateProperty(e, propertyManaged) != "true" {
+					continue
+				}
+				e.Id = resourceID(r.Href)
+				e.Etag = ps.Prop.ETag
+				events = append(events, e)
+			}
+		}
+	}
+	payloads.dump("caldav calendar events", events)
+	return events, nil
+}
+
+// Create writes the event for the key. Writing it again, like when a run
+// failed before it saved the state, replaces it instead of making another.
+func (c *caldavBackend) Create(ctx context.Context, key string, e *calendar.Event) (*calendar.Event, error) {
+	payloads.dump("putting caldav calendar event", e)
+	if err := c.put(ctx, key, e, ""); err != nil {
+		return nil, fmt.Errorf("putting caldav calendar event for segment %s failed: %w", key, err)
+	}
+	created := *e
+	created.Id = key
+	return &created, nil
+}
+
+// Update reads the event, sets the fields in the patch, and writes it back
+// if nobody changed it in between.
+func (c *caldavBackend) Update(ctx context.Context, key, id string, patch *calendar.Event) error {
+	payloads.dump("patching caldav calendar event", patch)
+	resp, err := c.do(ctx, http.MethodGet, c.resource(id), nil, nil, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("updating caldav calendar event %s failed: %w", id, err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("reading caldav calendar event %s failed: %v", id, err)
+	}
+	events := parseICS(string(data))
+	if len(events) < 1 {
+		return fmt.Errorf("caldav calendar event %s has no VEVENT", id)
+	}
+
+	e := mergeEventPatch(events[0], patch)
+	if err := c.put(ctx, id, e, resp.Header.Get("ETag")); err != nil {
+		return fmt.Errorf("updating caldav calendar event %s failed: %w", id, err)
+	}
+	return nil
+}
+
+// Delete deletes the event.
+func (c *caldavBackend) Delete(ctx context.Context, key, id string) error {
+	resp, err := c.do(ctx, http.MethodDelete, c.resource(id), nil, nil, http.StatusOK, http.StatusNoContent)
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("removing caldav calendar event %s failed: %w", id, err)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// put writes the event to the resource with the ID. If etag is set, the
+// write fails if the event changed since it was read.
+func (c *caldavBackend) put(ctx context.Context, id string, e *calendar.Event, etag string) error {
+	var buf bytes.Buffer
+	if err := writeCalDAVEvent(&buf, e, time.Now()); err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "text/calendar; charset=utf-8")
+	if len(etag) > 0 {
+		header.Set("If-Match", etag)
+	}
+	resp, err := c.do(ctx, http.MethodPut, c.resource(id), buf.Bytes(), header, http.StatusOK, http.StatusCreated, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// resourceID returns the ID of the event at the href, which is the name of
+// the resource without the .ics.
+func resourceID(href string) string {
+	name := path.Base(href)
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	return strings.TrimSuffix(name, ".ics")
+}
+
+// writeCalDAVEvent writes the event to w as an iCalendar object for a CalDAV
+// server.
+func writeCalDAVEvent(w io.Writer, e *calendar.Event, now time.Time) error {
+	var b strings.Builder
+	for _, l := range append(append([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//tripitcalb0t//EN",
+		"CALSCALE:GREGORIAN",
+	}, icsEventLines(e, now)...), "END:VCALENDAR") {
+		b.WriteString(foldICS(l))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// icsEventLines returns the content lines of the event as an iCalendar
+// VEVENT, unfolded, with the extended properties and visibility the bot
+// needs to read it back.
+func icsEventLines(e *calendar.Event, now time.Time) []string {
+	lines := veventLines(e, now)
+	// Keep END:VEVENT last.
+	end := lines[len(lines)-1]
+	lines = lines[:len(lines)-1]
+	switch e.Visibility {
+	case "private", "confidential":
+		lines = append(lines, "CLASS:PRIVATE")
+	case "public":
+		lines = append(lines, "CLASS:PUBLIC")
+	}
+	if e.ExtendedProperties != nil {
+		for _, p := range []struct {
+			name  string
+			props map[string]string
+		}{
+			{icsPrivateProperty, e.ExtendedProperties.Private},
+			{icsSharedProperty, e.ExtendedProperties.Shared},
+		} {
+			keys := make([]string, 0, len(p.props))
+			for k := range p.props {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				lines = append(lines, fmt.Sprintf("%s;X-KEY=\"%s\":%s", p.name, k, escapeICS(p.props[k])))
+			}
+		}
+	}
+	return append(lines, end)
+}
+
+// parseICS returns the events in the iCalendar object. It reads the fields
+// the bot writes, and skips the rest.
+func parseICS(data string) []*calendar.Event {
+	// Unfold the lines first.
+	data = strings.NewReplacer("\r\n ", "", "\r\n\t", "", "\n ", "", "\n\t", "").Replace(data)
+
+	var (
+		events []*calendar.Event
+		e      *calendar.Event
+	)
+	for _, l := range strings.Split(data, "\n") {
+		l = strings.TrimRight(l, "\r")
+		name, params, value, ok := parseICSLine(l)
+		if !ok {
+			continue
+		}
+		if name == "BEGIN" && value == "VEVENT" {
+			e = &calendar.Event{}
+			continue
+		}
+		if e == nil {
+			continue
+		}
+		switch name {
+		case "END":
+			if value == "VEVENT" {
+				events = append(events, e)
+				e = nil
+			}
+		case "UID":
+			e.ICalUID = unescapeICS(value)
+		case "DTSTAMP":
+			if t, err := time.Parse(icsTimeFormat, value); err == nil {
+				e.Updated = t.Format(time.RFC3339)
+			}
+		case "SUMMARY":
+			e.Summary = unescapeICS(value)
+		case "DESCRIPTION":
+			e.Description = unescapeICS(value)
+		case "LOCATION":
+			e.Location = unescapeICS(value)
+		case "URL":
+			e.Source = &calendar.EventSource{Title: "TripIt", Url: value}
+		case "TRANSP":
+			if value == "TRANSPARENT" {
+				e.Transparency = "transparent"
+			}
+		case "CLASS":
+			e.Visibility = strings.ToLower(value)
+		case "DTSTART":
+			e.Start = parseICSTime(params, value)
+		case "DTEND":
+			e.End = parseICSTime(params, value)
+		case icsPrivateProperty, icsSharedProperty:
+			if e.ExtendedProperties == nil {
+				e.ExtendedProperties = &calendar.EventExtendedProperties{Private: map[string]string{}, Shared: map[string]string{}}
+			}
+			props := e.ExtendedProperties.Private
+			if name == icsSharedProperty {
+				props = e.ExtendedProperties.Shared
+			}
+			props[params["X-KEY"]] = unescapeICS(value)
+		}
+	}
+	return events
+}
+
+// parseICSLine splits an unfolded content line into its name, parameters, and
+// value.
+func parseICSLine(l string) (string, map[string]string, string, bool) {
+	// The value starts at the first colon that is not quoted in a
+	// parameter.
+	quoted := false
+	i := -1
+	for j, r := range l {
+		if r == '"' {
+			quoted = !quoted
+		}
+		if r == ':' && !quoted {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		return "", nil, "", false
+	}
+
+	parts := strings.Split(l[:i], ";")
+	params := map[string]string{}
+	for _, p := range parts[1:] {
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, l[i+1:], true
+}
+
+// parseICSTime returns the start or end of an event from its iCalendar
+// value, which is a date, a UTC time, or a time in the TZID parameter.
+func parseICSTime(params map[string]string, value string) *calendar.EventDateTime {
+	if params["VALUE"] == "DATE" || len(value) == len(icsDateFormat) {
+		t, err := time.Parse(icsDateFormat, value)
+		if err != nil {
+			return nil
+		}
+		return &calendar.EventDateTime{Date: t.Format("2006-01-02")}
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icsTimeFormat, value)
+		if err != nil {
+			return nil
+		}
+		return &calendar.EventDateTime{DateTime: t.Format(time.RFC3339)}
+	}
+
+	loc := time.Local
+	if tz, ok := params["TZID"]; ok {
+		if l, err := time.LoadLocation(tz); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation(strings.TrimSuffix(icsTimeFormat, "Z"), value, loc)
+	if err != nil {
+		return nil
+	}
+	return &calendar.EventDateTime{DateTime: t.Format(time.RFC3339), TimeZone: loc.String()}
+}
+
+// unescapeICS undoes escapeICS.
+func unescapeICS(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// check checks that the credentials work and that the URL is a calendar we
+// can read.
+func (c *caldavBackend) check(ctx context.Context) error {
+	header := http.Header{}
+	header.Set("Depth", "0")
+	resp, err := c.do(ctx, "PROPFIND", c.url, nil, header, http.StatusMultiStatus)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
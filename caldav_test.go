@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// fakeCalDAV is a CalDAV calendar collection in memory, with just enough of
+// the protocol for caldavBackend.
+type fakeCalDAV struct {
+	mu        sync.Mutex
+	resources map[string]string
+	etags     map[string]string
+	version   int
+}
+
+func (f *fakeCalDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if user, pass, ok := r.BasicAuth(); !ok || user != "jess" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	name := path.Base(r.URL.Path)
+
+	switch r.Method {
+	case "REPORT":
+		if r.Header.Get("Depth") != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		names := make([]string, 0, len(f.resources))
+		for n := range f.resources {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">`)
+		for _, n := range names {
+			fmt.Fprintf(w, `<D:response><D:href>/calendars/jess/trips/%s</D:href><D:propstat><D:prop><D:getetag>%s</D:getetag><C:calendar-data>%s</C:calendar-data></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`,
+				n, html.EscapeString(f.etags[n]), html.EscapeString(f.resources[n]))
+		}
+		fmt.Fprint(w, `</D:multistatus>`)
+	case http.MethodGet:
+		data, ok := f.resources[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", f.etags[name])
+		fmt.Fprint(w, data)
+	case http.MethodPut:
+		if match := r.Header.Get("If-Match"); len(match) > 0 && match != f.etags[name] {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		status := http.StatusCreated
+		if _, ok := f.resources[name]; ok {
+			status = http.StatusNoContent
+		}
+		f.version++
+		f.resources[name] = string(b)
+		f.etags[name] = fmt.Sprintf(`"%d"`, f.version)
+		w.WriteHeader(status)
+	case http.MethodDelete:
+		if _, ok := f.resources[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.resources, name)
+		delete(f.etags, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestCalDAVBackend(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeCalDAV{resources: map[string]string{
+		// An event the bot does not manage is left out.
+		"dentist.ics": "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:dentist\r\nSUMMARY:Dentist\r\nDTSTART:20300709T090000Z\r\nDTEND:20300709T100000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	}, etags: map[string]string{"dentist.ics": `"0"`}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	c := newCalDAVBackend(srv.URL+"/calendars/jess/trips", "jess", "secret")
+
+	departs := time.Date(2030, time.July, 10, 9, 0, 0, 0, time.UTC)
+	e := newCalendarEvent(hashEvent(departs), "San Francisco International Airport")
+	created, err := c.Create(ctx, "segment", e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Id != "segment" {
+		t.Errorf("created event %q, want it named after the key", created.Id)
+	}
+	if _, ok := fake.resources["segment.ics"]; !ok {
+		t.Fatalf("resources = %v, want segment.ics", fake.resources)
+	}
+
+	// Creating it again replaces it.
+	if _, err := c.Create(ctx, "segment", e); err != nil {
+		t.Fatal(err)
+	}
+	events, err := c.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("List = %d events, want the one the bot manages", len(events))
+	}
+	got := events[0]
+	if got.Id != "segment" || got.Etag != fake.etags["segment.ics"] {
+		t.Errorf("listed event %q with etag %s, want segment with %s", got.Id, got.Etag, fake.etags["segment.ics"])
+	}
+	if got.Summary != e.Summary || got.Location != e.Location || privateProperty(got, propertySegmentID) != "segment" {
+		t.Errorf("listed %+v, want it read back as written", got)
+	}
+	if !eventTime(*got.Start).Equal(departs) {
+		t.Errorf("listed event starts %s, want %s", got.Start.DateTime, departs)
+	}
+
+	if err := c.Update(ctx, "segment", "segment", &calendar.Event{Summary: "Flight to Boston (UA 123)"}); err != nil {
+		t.Fatal(err)
+	}
+	events, err = c.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Summary != "Flight to Boston (UA 123)" || events[0].Location != e.Location {
+		t.Errorf("after Update listed %+v, want only the summary changed", events[0])
+	}
+
+	if err := c.Delete(ctx, "segment", "segment"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.resources["segment.ics"]; ok {
+		t.Error("Delete left the event")
+	}
+	// Deleting an event that is already gone is not an error.
+	if err := c.Delete(ctx, "segment", "segment"); err != nil {
+		t.Errorf("Delete of a missing event failed: %v", err)
+	}
+	if err := c.Update(ctx, "segment", "segment", &calendar.Event{Summary: "Gone"}); !isNotFound(err) {
+		t.Errorf("Update of a missing event = %v, want not found", err)
+	}
+}
+
+func TestCalDAVBackendErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(&fakeCalDAV{resources: map[string]string{}, etags: map[string]string{}})
+	defer srv.Close()
+	c := newCalDAVBackend(srv.URL+"/calendars/jess/trips/", "jess", "wrong")
+
+	if _, err := c.List(ctx); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("List with the wrong password = %v, want unauthorized", err)
+	}
+	if _, err := c.Create(ctx, "segment", newCalendarEvent(hashEvent(time.Now()), "")); err == nil {
+		t.Error("Create with the wrong password succeeded, want an error")
+	}
+}
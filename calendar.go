@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	calendar "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// propertyManaged marks an event as created and managed by the bot.
+	propertyManaged = "tripitcalb0t"
+	// propertyTripID holds the TripIt trip ID for the event.
+	propertyTripID = "tripitTripID"
+	// propertySegmentID holds the TripIt segment ID for the event.
+	propertySegmentID = "tripitSegmentID"
+	// propertyTags holds the comma separated TripIt trip tags for the event.
+	// It is a shared property so other tools can filter on it.
+	propertyTags = "tripitTags"
+	// propertyHash holds a hash of the content we last wrote to the event.
+	propertyHash = "tripitcalb0tHash"
+	// propertyMissing holds the runs the segment of the event has been
+	// missing from TripIt for, when there is no state file to keep them.
+	propertyMissing = "tripitcalb0tMissing"
+
+	// footerSeparator separates the sync footer from the rest of the description.
+	footerSeparator = "\n\n---\n"
+)
+
+// newCalendarEvent returns the calendar event we want for the given TripIt event.
+// The event carries private extended properties that mark it as ours, so the
+// calendar itself holds all the bookkeeping we need to match events and the
+// state file is only used to notice when the two disagree.
+func newCalendarEvent(trip tripit.Event, location string) *calendar.Event {
+	start := trip.Start
+	end := trip.End
+
+	e := &calendar.Event{
+		Summary:     trip.Title,
+		Description: trip.Description,
+		Start:       &start,
+		End:         &end,
+		Location:    location,
+		// Link back to the trip so the full itinerary is a click away.
+		Source: &calendar.EventSource{
+			Title: "TripIt",
+			Url:   tripit.TripURL(trip.ID),
+		},
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{
+				propertyManaged:   "true",
+				propertyTripID:    trip.ID,
+				propertySegmentID: trip.SegmentID,
+			},
+		},
+	}
+	e.Visibility = eventVisibility(trip)
+	// Stays span whole days, and should not make us look busy all day. Nor
+	// should the nights of a jet lag plan.
+	if trip.AllDay || trip.Type == eventTypeJetLag {
+		e.Transparency = "transparent"
+	}
+	if len(trip.Tags) > 0 {
+		e.ExtendedProperties.Shared = map[string]string{
+			propertyTags: strings.Join(trip.Tags, ","),
+		}
+		if hashtags {
+			e.Description += "\n\n#" + strings.Join(trip.Tags, " #")
+		}
+	}
+	e.ExtendedProperties.Private[propertyHash] = eventHash(e)
+
+	return e
+}
+
+// eventLocation returns the location of the calendar event for the TripIt
+// event: the departure airport for flights, and the address for the rest.
+func eventLocation(trip tripit.Event) (string, error) {
+	if !trip.IsFlight() {
+		return trip.Location, nil
+	}
+	airport := getAirportName(trip.AirportCode)
+	if airport == "" {
+		return "", fmt.Errorf("getting airport information from iata database for %s returned no match", trip.AirportCode)
+	}
+	return airport, nil
+}
+
+// eventVisibility returns the visibility for the event. Private trips get
+// private events unless the visibility flag says otherwise.
+func eventVisibility(trip tripit.Event) string {
+	if len(visibility) > 0 {
+		return visibility
+	}
+	if trip.Private {
+		return "private"
+	}
+	return "default"
+}
+
+// eventHash returns a hash of the content of an event that the bot manages.
+// Comparing it to the hash stored on an existing event tells us if the event
+// needs to be updated without having to compare every field. The footer is
+// left out since it changes every time we write the event.
+func eventHash(e *calendar.Event) string {
+	h := sha256.New()
+	fmt.Fprintln(h, e.Summary)
+	fmt.Fprintln(h, stripFooter(e.Description))
+	fmt.Fprintln(h, e.Location)
+	fmt.Fprintln(h, e.Visibility)
+	if len(e.Transparency) > 0 {
+		fmt.Fprintln(h, e.Transparency)
+	}
+	if e.ExtendedProperties != nil && len(e.ExtendedProperties.Shared[propertyTags]) > 0 {
+		fmt.Fprintln(h, e.ExtendedProperties.Shared[propertyTags])
+	}
+	if e.Source != nil {
+		fmt.Fprintln(h, e.Source.Title, e.Source.Url)
+	}
+	for _, t := range []*calendar.EventDateTime{e.Start, e.End} {
+		if t == nil {
+			fmt.Fprintln(h)
+			continue
+		}
+		fmt.Fprintln(h, t.Date, t.DateTime, t.TimeZone)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// addFooter returns the description with the sync footer for time t appended.
+func addFooter(description string, t time.Time) string {
+	footer := strings.Replace(descriptionFooterText, "{time}", t.Format(time.RFC1123), -1)
+	return stripFooter(description) + footerSeparator + footer
+}
+
+// stripFooter returns the description without the sync footer.
+func stripFooter(description string) string {
+	if i := strings.LastIndex(description, footerSeparator); i >= 0 {
+		return description[:i]
+	}
+	return description
+}
+
+// privateProperty returns the value of the private extended property key for
+// the event or an empty string if it is not set.
+func privateProperty(e *calendar.Event, key string) string {
+	if e.ExtendedProperties == nil || e.ExtendedProperties.Private == nil {
+		return ""
+	}
+	return e.ExtendedProperties.Private[key]
+}
+
+// findMatchingEvent returns the calendar event for the given TripIt segment.
+// Events created before we stored extended properties are matched by the
+// segment ID in their description. Events we manage are only matched by
+// their extended properties, so one whose description mentions another
+// segment is not taken for it.
+func findMatchingEvent(events []*calendar.Event, segmentID string) *calendar.Event {
+	for _, e := range events {
+		if privateProperty(e, propertySegmentID) == segmentID {
+			return e
+		}
+	}
+
+	for _, e := range events {
+		if len(privateProperty(e, propertyManaged)) > 0 {
+			continue
+		}
+		// We only care about TripIt events that match our tripID or segmentID.
+		if (strings.Contains(strings.ToLower(e.Description), "tripit") ||
+			strings.Contains(strings.ToLower(e.Summary), "flight")) &&
+			strings.Contains(e.Description, segmentID) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// listManagedEvents returns every event in the calendar that the bot manages.
+func listManagedEvents(ctx context.Context, gcalClient *calendar.Service, calendarName string) ([]*calendar.Event, error) {
+	var events []*calendar.Event
+	call := gcalClient.Events.List(calendarName).
+		PrivateExtendedProperty(propertyManaged + "=true").
+		MaxResults(2500).
+		Context(ctx)
+	err := call.Pages(ctx, func(page *calendar.Events) error {
+		events = append(events, page.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing managed events in google calendar %s failed: %v", calendarName, err)
+	}
+	return events, nil
+}
+
+// isNotFound returns true if the error from the Google Calendar API, a CalDAV
+// server, the Graph API, or an .ics file means the event does not exist, or
+// has already been deleted.
+func isNotFound(err error) bool {
+	if errors.Is(err, errICSEventNotFound) {
+		return true
+	}
+	var e *googleapi.Error
+	if errors.As(err, &e) {
+		return e.Code == http.StatusNotFound || e.Code == http.StatusGone
+	}
+	var c *caldavError
+	if errors.As(err, &c) {
+		return c.code == http.StatusNotFound || c.code == http.StatusGone
+	}
+	var g *graphError
+	return errors.As(err, &g) && (g.code == http.StatusNotFound || g.code == http.StatusGone)
+}
+
+// isQuotaExceeded returns true if the error from the Google Calendar API means
+// we ran out of quota or hit a rate limit.
+func isQuotaExceeded(err error) bool {
+	var e *googleapi.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	if e.Code == http.StatusTooManyRequests {
+		return true
+	}
+	for _, item := range e.Errors {
+		switch item.Reason {
+		case "quotaExceeded", "rateLimitExceeded", "userRateLimitExceeded", "dailyLimitExceeded":
+			return true
+		}
+	}
+	return false
+}
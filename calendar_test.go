@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// countingBackend is a calendar in memory that counts the writes to it.
+type countingBackend struct {
+	memoryBackend
+	creates, updates int
+}
+
+func (c *countingBackend) Create(ctx context.Context, key string, e *calendar.Event) (*calendar.Event, error) {
+	c.creates++
+	return c.memoryBackend.Create(ctx, key, e)
+}
+
+func (c *countingBackend) Update(ctx context.Context, key, id string, patch *calendar.Event) error {
+	c.updates++
+	return c.memoryBackend.Update(ctx, key, id, patch)
+}
+
+// hashEvent returns a flight segment departing at the time.
+func hashEvent(departs time.Time) tripit.Event {
+	return tripit.Event{
+		ID:                 "trip",
+		SegmentID:          "segment",
+		Title:              "Flight to Newark (UA 123)",
+		Description:        "Confirmation: ABC123",
+		ConfirmationNumber: "ABC123",
+		AirportCode:        "SFO",
+		DestinationCode:    "EWR",
+		Start:              calendar.EventDateTime{DateTime: departs.Format(time.RFC3339)},
+		End:                calendar.EventDateTime{DateTime: departs.Add(5 * time.Hour).Format(time.RFC3339)},
+	}
+}
+
+func TestEventHash(t *testing.T) {
+	departs := time.Date(2030, time.July, 10, 9, 0, 0, 0, time.UTC)
+	e := newCalendarEvent(hashEvent(departs), "")
+	hash := privateProperty(e, propertyHash)
+	if len(hash) < 1 || hash != eventHash(e) {
+		t.Fatalf("newCalendarEvent stored hash %q, want eventHash %q", hash, eventHash(e))
+	}
+
+	// The sync footer changes every write, so it must not change the hash.
+	footered := *e
+	footered.Description = addFooter(e.Description, departs)
+	if eventHash(&footered) != hash {
+		t.Error("eventHash changed with the sync footer")
+	}
+
+	moved := newCalendarEvent(hashEvent(departs.Add(time.Hour)), "")
+	if eventHash(moved) == hash {
+		t.Error("eventHash is the same for an event that moved")
+	}
+	renamed := *e
+	renamed.Summary = "Flight to Boston (UA 123)"
+	if eventHash(&renamed) == hash {
+		t.Error("eventHash is the same for an event that was renamed")
+	}
+}
+
+func TestSyncEventSkipsUnchanged(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingBackend{}
+	st := newState("")
+	departs := time.Date(2030, time.July, 10, 9, 0, 0, 0, time.UTC)
+
+	sync := func(trip tripit.Event) syncAction {
+		t.Helper()
+		existing, err := backend.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		action, err := syncEvent(ctx, backend, st, existing, trip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return action
+	}
+
+	if action := sync(hashEvent(departs)); action != syncCreated || backend.creates != 1 {
+		t.Fatalf("first sync = %v with %d creates, want the event created", action, backend.creates)
+	}
+	if action := sync(hashEvent(departs)); action != syncUnchanged || backend.updates != 0 {
+		t.Errorf("sync of an unchanged event = %v with %d updates, want it left alone", action, backend.updates)
+	}
+	if action := sync(hashEvent(departs.Add(time.Hour))); action != syncUpdated || backend.updates != 1 {
+		t.Errorf("sync of a moved event = %v with %d updates, want it patched once", action, backend.updates)
+	}
+	if action := sync(hashEvent(departs.Add(time.Hour))); action != syncUnchanged || backend.updates != 1 {
+		t.Errorf("sync after the patch = %v with %d updates, want it left alone", action, backend.updates)
+	}
+	if backend.creates != 1 {
+		t.Errorf("%d events created, want the segment to keep its one event", backend.creates)
+	}
+}
+
+func TestFindMatchingEvent(t *testing.T) {
+	managed := &calendar.Event{
+		Id:          "managed",
+		Summary:     "Flight to Newark (UA 123)",
+		Description: "Connecting to segment 456 on TripIt",
+		ExtendedProperties: &calendar.EventExtendedProperties{Private: map[string]string{
+			propertyManaged:   "true",
+			propertySegmentID: "123",
+		}},
+	}
+	legacy := &calendar.Event{
+		Id:          "legacy",
+		Summary:     "Flight to Boston (UA 789)",
+		Description: "View on TripIt: segment 789",
+	}
+	events := []*calendar.Event{managed, legacy}
+
+	testcases := []struct {
+		segmentID string
+		want      *calendar.Event
+	}{
+		{segmentID: "123", want: managed},
+		{segmentID: "789", want: legacy},
+		// A managed event is not matched by the segments in its
+		// description.
+		{segmentID: "456"},
+		{segmentID: "000"},
+	}
+	for _, tc := range testcases {
+		if got := findMatchingEvent(events, tc.segmentID); got != tc.want {
+			t.Errorf("findMatchingEvent(%s) = %v, want %v", tc.segmentID, got, tc.want)
+		}
+	}
+}
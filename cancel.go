@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// What to do with the events of cancelled flights.
+const (
+	cancelDelete = "delete"
+	cancelMark   = "mark"
+)
+
+// cancelledPrefix starts the title of events marked as cancelled.
+const cancelledPrefix = "Cancelled: "
+
+// cancelEvent deletes the event of a flight that was cancelled or removed
+// from TripIt, or with --cancelled-events=mark keeps it marked as cancelled
+// and free, so the calendar still shows what happened. A marked event is no
+// longer managed by the bot, so it is left alone from then on.
+func cancelEvent(ctx context.Context, backend calendarBackend, segmentID, eventID, title string) error {
+	if cancelledEvents != cancelMark {
+		return backend.Delete(ctx, segmentID, eventID)
+	}
+
+	if !strings.HasPrefix(title, cancelledPrefix) {
+		title = cancelledPrefix + title
+	}
+	patch := &calendar.Event{
+		Summary:      title,
+		Transparency: "transparent",
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{propertyManaged: "false"},
+		},
+	}
+	if err := backend.Update(ctx, segmentID, eventID, patch); err != nil && !isNotFound(err) {
+		return fmt.Errorf("marking event %s as cancelled failed: %v", eventID, err)
+	}
+	return nil
+}
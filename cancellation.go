@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// eventTypeCancelBy is the type of the events reminding us of the last day
+// to cancel a booking for free. They are made by the bot, not TripIt.
+const eventTypeCancelBy = "cancel-by"
+
+// cancelWindowRegex matches the notice a cancellation policy needs, like
+// "Free cancellation until 48 hours before arrival".
+var cancelWindowRegex = regexp.MustCompile(`(?i)(\d+)\s*(hours?|hrs?|days?|nights?)\s+(?:before|prior to|in advance)`)
+
+// cancellable is a booking that may be cancelled for free until a deadline.
+type cancellable struct {
+	tripID, id   string
+	name         string
+	confirmation string
+	deadline     tripit.DateTime
+	restrictions string
+	start        tripit.DateTime
+}
+
+// cancellationEvents returns an all-day event --cancellation-reminder before
+// the last day to cancel every stay, rental car, restaurant reservation,
+// and activity that has one for free. The deadline is TripIt's cancellation
+// date, or worked out from a policy like "cancel up to 48 hours before
+// arrival" in the restrictions.
+func cancellationEvents(resp *tripit.Response) []tripit.Event {
+	var bookings []cancellable
+	for _, l := range resp.Lodging {
+		bookings = append(bookings, cancellable{
+			tripID: l.TripID, id: l.ID, name: firstNonEmpty(l.SupplierName, l.DisplayName),
+			confirmation: firstNonEmpty(l.SupplierConfNum, l.BookingSiteConfNum),
+			deadline:     l.CancellationDateTime, restrictions: l.Restrictions, start: l.StartDateTime,
+		})
+	}
+	for _, c := range resp.Cars {
+		bookings = append(bookings, cancellable{
+			tripID: c.TripID, id: c.ID, name: firstNonEmpty(c.SupplierName, c.DisplayName) + " rental car",
+			confirmation: firstNonEmpty(c.SupplierConfNum, c.BookingSiteConfNum),
+			deadline:     c.CancellationDateTime, restrictions: c.Restrictions, start: c.StartDateTime,
+		})
+	}
+	if activities {
+		for _, r := range resp.Restaurants {
+			bookings = append(bookings, cancellable{
+				tripID: r.TripID, id: r.ID, name: firstNonEmpty(r.SupplierName, r.DisplayName),
+				confirmation: firstNonEmpty(r.SupplierConfNum, r.BookingSiteConfNum),
+				deadline:     r.CancellationDateTime, restrictions: r.Restrictions, start: r.DateTime,
+			})
+		}
+		for _, a := range resp.Activities {
+			bookings = append(bookings, cancellable{
+				tripID: a.TripID, id: a.ID, name: firstNonEmpty(a.DisplayName, a.SupplierName),
+				confirmation: firstNonEmpty(a.SupplierConfNum, a.BookingSiteConfNum),
+				deadline:     a.CancellationDateTime, restrictions: a.Restrictions, start: a.StartDateTime,
+			})
+		}
+	}
+
+	var events []tripit.Event
+	for _, b := range bookings {
+		deadline, ok := cancelDeadline(b)
+		if !ok || len(b.name) < 1 {
+			continue
+		}
+
+		day := localDate(deadline.Add(-cancellationReminder))
+		description := fmt.Sprintf("You can cancel %s free of charge until %s.", b.name, deadline.Format("Mon Jan 2, 2006 3:04pm"))
+		if len(b.confirmation) > 0 {
+			description += "\nConfirmation # " + b.confirmation
+		}
+		if len(b.restrictions) > 0 {
+			description += "\n\nCancellation policy: " + b.restrictions
+		}
+		events = append(events, tripit.Event{
+			Type:        eventTypeCancelBy,
+			Title:       "Last day to cancel " + b.name + " free of charge",
+			Description: description,
+			// All-day events end the day after their last day.
+			Start:              calendar.EventDateTime{Date: day.Format("2006-01-02")},
+			End:                calendar.EventDateTime{Date: day.AddDate(0, 0, 1).Format("2006-01-02")},
+			ID:                 b.tripID,
+			SegmentID:          b.id + "-cancel-by",
+			ConfirmationNumber: b.confirmation,
+			AllDay:             true,
+		})
+	}
+	return events
+}
+
+// cancelDeadline returns when the booking can no longer be cancelled for
+// free, in the timezone of the booking if TripIt knows it.
+func cancelDeadline(b cancellable) (time.Time, bool) {
+	if len(b.deadline.Date) > 0 {
+		return parseTripItDateTime(b.deadline)
+	}
+
+	m := cancelWindowRegex.FindStringSubmatch(b.restrictions)
+	if m == nil || len(b.start.Date) < 1 {
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	start, ok := parseTripItDateTime(b.start)
+	if !ok {
+		return time.Time{}, false
+	}
+	if strings.HasPrefix(strings.ToLower(m[2]), "h") {
+		return start.Add(-time.Duration(n) * time.Hour), true
+	}
+	return start.AddDate(0, 0, -n), true
+}
+
+// parseTripItDateTime parses the date and time, taking a date without a time
+// to mean the end of the day.
+func parseTripItDateTime(d tripit.DateTime) (time.Time, bool) {
+	if len(d.Time) < 1 {
+		day, err := time.Parse("2006-01-02", d.Date)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return day.Add(24*time.Hour - time.Minute), true
+	}
+	t, err := d.Parse()
+	return t, err == nil
+}
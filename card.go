@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/jessfraz/tripitcalb0t/pdf"
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+const cardHelp = `Write a wallet card summary of a trip as a PDF.`
+
+const cardLongHelp = `Write a wallet card summary of a trip as a PDF.
+
+The card is the size of a credit card and lists the flight numbers and
+confirmation codes of the trip and the addresses and phone numbers of where
+you are staying. Print it at 100% and cut it out. A long trip takes more
+than one card.
+
+  tripitcalb0t card --out card.pdf 123456789`
+
+func (cmd *cardCommand) Name() string      { return "card" }
+func (cmd *cardCommand) Args() string      { return "<trip-id>" }
+func (cmd *cardCommand) ShortHelp() string { return cardHelp }
+func (cmd *cardCommand) LongHelp() string  { return cardLongHelp }
+func (cmd *cardCommand) Hidden() bool      { return false }
+
+func (cmd *cardCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.out, "out", "", "File to write the PDF to, - for stdout (defaults to card-<trip-id>.pdf)")
+}
+
+type cardCommand struct {
+	out string
+}
+
+func (cmd *cardCommand) Run(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return errors.New("pass the id of the trip")
+	}
+	id := args[0]
+
+	tripitClient, err := newTripItClient()
+	if err != nil {
+		fatal(exitCodeConfig, err)
+	}
+
+	resp, err := tripitClient.GetTripWithObjects(ctx, id)
+	if err != nil {
+		return fmt.Errorf("getting trip %s from TripIt failed: %v", id, err)
+	}
+
+	return writePDF(walletCardPDF(resp), cmd.out, "card-"+id+".pdf")
+}
+
+// walletCardPDF renders the flights and stays of the trip in the response on
+// credit card sized pages.
+func walletCardPDF(resp *tripit.Response) *pdf.Document {
+	trip := resp.Trips[0]
+	doc := pdf.NewSize(trip.DisplayName, pdf.Card)
+	doc.Subheading(trip.DisplayName)
+
+	var flights []itineraryItem
+	for _, f := range resp.Flights {
+		for _, s := range f.Segments {
+			flights = append(flights, itineraryItem{
+				start: sortableTime(s.StartDateTime),
+				title: joinNonEmpty("  ",
+					formatDateTime(s.StartDateTime),
+					firstNonEmpty(s.MarketingAirlineCode, s.OperatingAirlineCode)+" "+firstNonEmpty(s.MarketingFlightNumber, s.OperatingFlightNumber),
+					s.StartAirportCode+"-"+s.EndAirportCode,
+					firstNonEmpty(f.SupplierConfNum, f.BookingSiteConfNum, f.RecordLocator)),
+			})
+		}
+	}
+	sort.SliceStable(flights, func(i, j int) bool {
+		return flights[i].start < flights[j].start
+	})
+	for _, f := range flights {
+		doc.Text(f.title)
+	}
+
+	for _, l := range resp.Lodging {
+		doc.Space()
+		doc.Text(joinNonEmpty("  ", firstNonEmpty(l.SupplierName, l.DisplayName), firstNonEmpty(l.SupplierConfNum, l.BookingSiteConfNum)))
+		doc.Text(formatAddress(l.Address))
+		if len(l.SupplierPhone) > 0 {
+			doc.Text(l.SupplierPhone)
+		}
+	}
+
+	return doc
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+)
+
+// checklistNagEvery is how often we remind about a checklist item that is
+// due and not done.
+const checklistNagEvery = 24 * time.Hour
+
+// checklistTemplate is a thing to do before the trips of some kinds, or all
+// of them, due some days before they depart.
+type checklistTemplate struct {
+	Item  string   `json:"item"`
+	Kinds []string `json:"kinds,omitempty"`
+	// Abroad limits the item to trips with a flight to another country.
+	Abroad bool `json:"abroad,omitempty"`
+	Days   int  `json:"days"`
+}
+
+// defaultChecklist is the checklist without a --checklist-file.
+var defaultChecklist = []checklistTemplate{
+	{Item: "Check your passport is valid for six months after the trip", Abroad: true, Days: 30},
+	{Item: "Buy travel insurance", Kinds: []string{tripKindPersonal, tripKindWeekend}, Abroad: true, Days: 14},
+	{Item: "Choose seats", Days: 3},
+	{Item: "Check in", Days: 1},
+}
+
+// loadChecklistTemplates reads the checklist templates from the JSON file at
+// path, or returns the default checklist without one.
+func loadChecklistTemplates(path string) ([]checklistTemplate, error) {
+	if len(path) < 1 {
+		return defaultChecklist, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading checklist file %s failed: %v", path, err)
+	}
+	var templates []checklistTemplate
+	if err := json.Unmarshal(b, &templates); err != nil {
+		return nil, fmt.Errorf("parsing checklist file %s failed: %v", path, err)
+	}
+	for _, t := range templates {
+		if len(t.Item) < 1 {
+			return nil, fmt.Errorf("every item in checklist file %s needs a name", path)
+		}
+		if t.Days < 0 {
+			return nil, fmt.Errorf("item %q in checklist file %s is due a negative number of days before the trip", t.Item, path)
+		}
+	}
+	return templates, nil
+}
+
+// checklistItem is a thing to do before a trip.
+type checklistItem struct {
+	Item string    `json:"item"`
+	Due  time.Time `json:"due"`
+	Done bool      `json:"done"`
+}
+
+// tripChecklist returns the checklist of the trip, soonest due first. Trips
+// that need visas get an item to apply for them, due when the visa reminder
+// is.
+func tripChecklist(trip itineraryTrip, events []tripit.Event, templates []checklistTemplate, st *syncState) []checklistItem {
+	kind := ""
+	if len(trip.Events) > 0 {
+		kind = trip.Events[0].Kind
+	}
+
+	var items []checklistItem
+	for _, e := range events {
+		if e.Type == eventTypeVisa && e.ID == trip.ID {
+			items = append(items, checklistItem{Item: "Apply for visas and travel authorizations", Due: eventTime(e.Start)})
+		}
+	}
+	for _, t := range templates {
+		if len(t.Kinds) > 0 && !contains(t.Kinds, kind) {
+			continue
+		}
+		if t.Abroad && !isAbroad(trip.Events) {
+			continue
+		}
+		items = append(items, checklistItem{Item: t.Item, Due: trip.Start.AddDate(0, 0, -t.Days)})
+	}
+
+	for i := range items {
+		items[i].Done = st.checked(trip.ID, items[i].Item)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Due.Before(items[j].Due) })
+	return items
+}
+
+// isAbroad returns true if any of the flights goes to another country.
+func isAbroad(flights []tripit.Event) bool {
+	for _, f := range flights {
+		from, ok := getAirport(f.AirportCode)
+		if !ok {
+			continue
+		}
+		to, ok := getAirport(f.DestinationCode)
+		if ok && from.Country != to.Country {
+			return true
+		}
+	}
+	return false
+}
+
+// contains returns true if the list has the string.
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checklistReminders returns a reminder for every item of the checklists of
+// the upcoming trips that is due and not done, at most once a day for each.
+func checklistReminders(st *syncState, events []tripit.Event, now time.Time) []string {
+	templates, err := loadChecklistTemplates(checklistFile)
+	if err != nil {
+		logrus.Warn(err)
+		return nil
+	}
+
+	var reminders []string
+	for _, trip := range upcomingTrips(events, now) {
+		if trip.Start.Before(now) {
+			continue
+		}
+		for _, item := range tripChecklist(trip, events, templates, st) {
+			if item.Done || item.Due.After(now) {
+				continue
+			}
+			if !st.warnEvery("checklist:"+trip.ID+":"+item.Item, checklistNagEvery, now) {
+				continue
+			}
+			reminders = append(reminders, fmt.Sprintf("To do before %s on %s: %s", trip.Name, trip.Start.Format("Mon Jan 2"), item.Item))
+		}
+	}
+	return reminders
+}
+
+const checklistHelp = `Show and check off the checklists of upcoming trips.`
+
+const checklistLongHelp = `Show and check off the checklists of upcoming trips.
+
+Every upcoming trip gets the items of the checklist for its kind, like
+travel insurance for personal trips abroad, each due some days before the
+trip. Check an item off by its number or name:
+
+  tripitcalb0t checklist
+  tripitcalb0t checklist --trip 123456789 --done 2
+  tripitcalb0t checklist --trip 123456789 --undo "Buy travel insurance"
+
+This only reads the state file, so it does not need any credentials.`
+
+func (cmd *checklistCommand) Name() string      { return "checklist" }
+func (cmd *checklistCommand) Args() string      { return "" }
+func (cmd *checklistCommand) ShortHelp() string { return checklistHelp }
+func (cmd *checklistCommand) LongHelp() string  { return checklistLongHelp }
+func (cmd *checklistCommand) Hidden() bool      { return false }
+
+func (cmd *checklistCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.trip, "trip", "", "ID of the trip to show or check items off for")
+	fs.StringVar(&cmd.done, "done", "", "Number or name of the item to check off")
+	fs.StringVar(&cmd.undo, "undo", "", "Number or name of the item to uncheck")
+}
+
+type checklistCommand struct {
+	trip string
+	done string
+	undo string
+}
+
+func (cmd *checklistCommand) Run(ctx context.Context, args []string) error {
+	if (len(cmd.done) > 0 || len(cmd.undo) > 0) && len(cmd.trip) < 1 {
+		return errors.New("pass the id of the trip to check items off for with --trip")
+	}
+
+	templates, err := loadChecklistTemplates(checklistFile)
+	if err != nil {
+		return err
+	}
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+	if st.Snapshot == nil {
+		return errNoSnapshot
+	}
+
+	now := time.Now()
+	found := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 1, 2, ' ', 0)
+	for _, trip := range upcomingTrips(st.Snapshot.Events, now) {
+		if len(cmd.trip) > 0 && trip.ID != cmd.trip {
+			continue
+		}
+		found = true
+
+		items := tripChecklist(trip, st.Snapshot.Events, templates, st)
+		for _, change := range []struct {
+			which string
+			done  bool
+		}{{cmd.done, true}, {cmd.undo, false}} {
+			if len(change.which) < 1 {
+				continue
+			}
+			i, err := findChecklistItem(items, change.which)
+			if err != nil {
+				return err
+			}
+			st.check(trip.ID, items[i].Item, change.done, now)
+			items[i].Done = change.done
+		}
+
+		fmt.Fprintf(w, "%s, %s (%s)\n", trip.Name, trip.Start.Format("Mon Jan 2"), trip.ID)
+		for i, item := range items {
+			box := "[ ]"
+			if item.Done {
+				box = "[x]"
+			}
+			fmt.Fprintf(w, "  %d.\t%s\t%s\tdue %s\n", i+1, box, item.Item, item.Due.Format("Mon Jan 2"))
+		}
+	}
+	if len(cmd.trip) > 0 && !found {
+		return fmt.Errorf("trip %s is not an upcoming trip with flights", cmd.trip)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(cmd.done) > 0 || len(cmd.undo) > 0 {
+		return st.save()
+	}
+	return nil
+}
+
+// findChecklistItem returns the index of the item with the number, counting
+// from one, or the name.
+func findChecklistItem(items []checklistItem, which string) (int, error) {
+	if n, err := strconv.Atoi(which); err == nil {
+		if n < 1 || n > len(items) {
+			return 0, fmt.Errorf("the checklist has no item %d", n)
+		}
+		return n - 1, nil
+	}
+	for i, item := range items {
+		if strings.EqualFold(item.Item, which) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("the checklist has no item %q", which)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+// The kinds of trips.
+const (
+	tripKindBusiness = "business"
+	tripKindPersonal = "personal"
+	// tripKindWeekend is a short personal trip over a weekend.
+	tripKindWeekend = "weekend"
+)
+
+// tagKinds are the tags that say what kind a trip is, including the purposes
+// TripIt sets.
+var tagKinds = map[string]string{
+	"business": tripKindBusiness,
+	"work":     tripKindBusiness,
+	"leisure":  tripKindPersonal,
+	"personal": tripKindPersonal,
+	"vacation": tripKindPersonal,
+	"holiday":  tripKindPersonal,
+	"weekend":  tripKindWeekend,
+}
+
+// parseTripKinds parses the --trip-kinds overrides, like
+// 123456789=personal,987654321=business, into a map of trip IDs to kinds.
+func parseTripKinds(s string) (map[string]string, error) {
+	kinds := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) < 1 {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) < 1 {
+			return nil, fmt.Errorf("trip-kinds must be trip ID=kind pairs, got %q", pair)
+		}
+		kind := strings.ToLower(strings.TrimSpace(kv[1]))
+		switch kind {
+		case tripKindBusiness, tripKindPersonal, tripKindWeekend:
+		default:
+			return nil, fmt.Errorf("trip-kinds kind must be one of %s, %s, or %s, got %q", tripKindBusiness, tripKindPersonal, tripKindWeekend, kv[1])
+		}
+		kinds[strings.TrimSpace(kv[0])] = kind
+	}
+	return kinds, nil
+}
+
+// classifyTrip returns whether the trip is for business, personal, or a
+// weekend away. A --trip-kinds override wins, then the trip's tags and the
+// purpose set in TripIt, then whether it goes to one of the
+// --business-destinations, then a purpose TripIt guessed. Failing all of
+// those, a trip over a weekend of at most four days is a weekend trip, a
+// trip within the working week is for business, and anything else is
+// personal. Personal trips over a short weekend are weekend trips however
+// they were found to be personal.
+func classifyTrip(trip tripit.Trip) string {
+	// The flag was checked when it was parsed.
+	overrides, _ := parseTripKinds(tripKinds)
+	if kind, ok := overrides[trip.ID]; ok {
+		return kind
+	}
+
+	// A purpose TripIt guessed is only a hint.
+	guessed := ""
+	if trip.Purposes.IsAutoGenerated {
+		switch trip.Purposes.PurposeTypeCode {
+		case "B":
+			guessed = tripKindBusiness
+		case "L":
+			guessed = tripKindPersonal
+		}
+		trip.Purposes.PurposeTypeCode = ""
+	}
+
+	kind := ""
+	for _, tag := range trip.Tags() {
+		if k, ok := tagKinds[tag]; ok {
+			kind = k
+			break
+		}
+	}
+	if len(kind) < 1 && isBusinessDestination(trip.PrimaryLocation) {
+		kind = tripKindBusiness
+	}
+	if len(kind) < 1 {
+		kind = guessed
+	}
+
+	start, err := time.Parse("2006-01-02", trip.StartDate)
+	if err != nil {
+		if len(kind) < 1 {
+			return tripKindPersonal
+		}
+		return kind
+	}
+	end, err := time.Parse("2006-01-02", trip.EndDate)
+	if err != nil {
+		end = start
+	}
+
+	switch {
+	case kind == tripKindBusiness || kind == tripKindWeekend:
+		return kind
+	case isWeekendTrip(start, end):
+		return tripKindWeekend
+	case len(kind) < 1 && isWorkweekTrip(start, end):
+		return tripKindBusiness
+	}
+	return tripKindPersonal
+}
+
+// isBusinessDestination returns true if the location is one of the
+// --business-destinations.
+func isBusinessDestination(location string) bool {
+	location = strings.ToLower(location)
+	for _, d := range strings.Split(businessDestinations, ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); len(d) > 0 && strings.Contains(location, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWeekendTrip returns true if the trip from start to end, both dates,
+// leaves on a Friday or Saturday and is back by the Monday.
+func isWeekendTrip(start, end time.Time) bool {
+	if start.Weekday() != time.Friday && start.Weekday() != time.Saturday {
+		return false
+	}
+	days := int(end.Sub(start).Hours()/24) + 1
+	switch end.Weekday() {
+	case time.Saturday, time.Sunday, time.Monday:
+		return days <= 4
+	}
+	return false
+}
+
+// isWorkweekTrip returns true if the trip from start to end, both dates, is
+// within a single Monday to Friday.
+func isWorkweekTrip(start, end time.Time) bool {
+	weekday := func(t time.Time) bool { return t.Weekday() >= time.Monday && t.Weekday() <= time.Friday }
+	days := int(end.Sub(start).Hours()/24) + 1
+	return weekday(start) && weekday(end) && days <= 5 && start.Weekday() <= end.Weekday()
+}
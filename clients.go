@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/notify"
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"golang.org/x/oauth2/google"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// Clients and notifiers are only constructed when a command actually needs
+// them, so that commands which only talk to TripIt do not need Google
+// credentials.
+var (
+	gcalMu      sync.Mutex
+	gcalService *calendar.Service
+
+	notifierOnce sync.Once
+	notifier     notify.Notifier
+)
+
+// validateTripItFlags checks the flags needed to talk to TripIt.
+func validateTripItFlags() error {
+	if len(tripitUsername) < 1 {
+		return errors.New("tripit username cannot be empty")
+	}
+
+	if len(tripitPassword) < 1 {
+		return errors.New("tripit password cannot be empty")
+	}
+
+	return nil
+}
+
+// validateGoogleFlags checks the flags needed to talk to Google Calendar.
+func validateGoogleFlags() error {
+	if _, err := os.Stat(googleCalendarKeyfile); os.IsNotExist(err) && len(os.Getenv("GOOGLE_KEYFILE_JSON")) < 1 {
+		return fmt.Errorf("Google Calendar keyfile %q does not exist", googleCalendarKeyfile)
+	}
+
+	if len(calendarName) < 1 {
+		return errors.New("calendar name cannot be empty")
+	}
+
+	return nil
+}
+
+// The calendars we can sync to, as named in --backends.
+const (
+	backendGoogle  = "google"
+	backendCalDAV  = "caldav"
+	backendOutlook = "outlook"
+	backendICS     = "ics"
+)
+
+// calendarBackendNames returns the calendars we sync to, the ones in
+// --backends, or else the CalDAV or Outlook calendar or .ics file if there is
+// one, and Google Calendar otherwise.
+func calendarBackendNames() []string {
+	if len(calendarBackends) < 1 {
+		switch {
+		case len(icsFile) > 0:
+			return []string{backendICS}
+		case len(caldavURL) > 0:
+			return []string{backendCalDAV}
+		case len(outlookClientID) > 0:
+			return []string{backendOutlook}
+		}
+		return []string{backendGoogle}
+	}
+
+	var names []string
+	for _, name := range strings.Split(calendarBackends, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// validateCalendarFlags checks the flags needed to talk to the calendars we
+// sync to.
+func validateCalendarFlags() error {
+	if len(calendarBackends) < 1 {
+		n := 0
+		for _, s := range []string{caldavURL, outlookClientID, icsFile} {
+			if len(s) > 0 {
+				n++
+			}
+		}
+		if n > 1 {
+			return errors.New("pass only one of caldav-url, outlook-client-id, or ics-file, or list the calendars to sync to in backends")
+		}
+	}
+
+	names := calendarBackendNames()
+	if len(names) < 1 {
+		return errors.New("backends cannot be empty")
+	}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			return fmt.Errorf("backends lists %s more than once", name)
+		}
+		seen[name] = true
+		if err := validateBackendFlags(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateBackendFlags checks the flags needed to talk to the calendar with
+// the name.
+func validateBackendFlags(name string) error {
+	switch name {
+	case backendGoogle:
+		return validateGoogleFlags()
+	case backendCalDAV:
+		return validateCalDAVFlags()
+	case backendOutlook:
+		if len(outlookClientID) < 1 {
+			return errors.New("outlook client id cannot be empty")
+		}
+		return validateOutlookFlags()
+	case backendICS:
+		if len(icsFile) < 1 {
+			return errors.New("ics file cannot be empty")
+		}
+		if len(icsName) < 1 {
+			return errors.New("ics name cannot be empty")
+		}
+		return nil
+	}
+	return fmt.Errorf("backends must be some of %s, %s, %s, or %s, got %q", backendGoogle, backendCalDAV, backendOutlook, backendICS, name)
+}
+
+// validateCalDAVFlags checks the flags needed to talk to a CalDAV calendar.
+func validateCalDAVFlags() error {
+	u, err := url.Parse(caldavURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) < 1 {
+		return fmt.Errorf("caldav-url must be an http or https URL, got %q", caldavURL)
+	}
+
+	if len(caldavUsername) > 0 && len(caldavPassword) < 1 {
+		return errors.New("caldav password cannot be empty when a caldav username is set")
+	}
+
+	return nil
+}
+
+// validateOutlookFlags checks the flags needed to talk to an Outlook
+// calendar.
+func validateOutlookFlags() error {
+	if len(outlookClientSecret) < 1 {
+		if _, err := os.Stat(outlookTokenFile); os.IsNotExist(err) {
+			return fmt.Errorf("outlook token file %q does not exist, sign in with outlook login or pass outlook-client-secret", outlookTokenFile)
+		}
+		return nil
+	}
+
+	switch outlookTenant {
+	case "", "common", "organizations", "consumers":
+		return errors.New("outlook-tenant must be the ID or domain of your tenant with outlook-client-secret")
+	}
+	if len(outlookUser) < 1 {
+		return errors.New("outlook-user cannot be empty with outlook-client-secret, the app has no calendar of its own")
+	}
+
+	return nil
+}
+
+// getCalendarBackends returns the calendars we sync to, in the order of
+// --backends.
+func getCalendarBackends(ctx context.Context) ([]calendarBackend, error) {
+	var backends []calendarBackend
+	for _, name := range calendarBackendNames() {
+		backend, err := newCalendarBackend(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+	return backends, nil
+}
+
+// getPrimaryBackend returns the first calendar we sync to, the one the state
+// file is for, for the commands that work on a single calendar. It exits on
+// bad flags or credentials.
+func getPrimaryBackend(ctx context.Context) calendarBackend {
+	if err := validateCalendarFlags(); err != nil {
+		fatal(exitCodeConfig, err)
+	}
+	backend, err := newCalendarBackend(ctx, calendarBackendNames()[0])
+	if err != nil {
+		fatal(exitCodeCalendarAuth, err)
+	}
+	return backend
+}
+
+// requireGoogle returns the calendar as a Google Calendar, or exits if it is
+// not one, for the commands only Google Calendar supports.
+func requireGoogle(backend calendarBackend, command string) *googleBackend {
+	google, ok := backend.(*googleBackend)
+	if !ok {
+		fatal(exitCodeConfig, fmt.Errorf("%s is only supported with the google backend, not %s", command, backend))
+	}
+	return google
+}
+
+// newCalendarBackend returns the calendar with the name.
+func newCalendarBackend(ctx context.Context, name string) (calendarBackend, error) {
+	switch name {
+	case backendICS:
+		return newICSFileBackend(icsFile, icsName), nil
+	case backendCalDAV:
+		return newCalDAVBackend(caldavURL, caldavUsername, caldavPassword), nil
+	case backendOutlook:
+		client, err := newGraphClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newGraphBackend(client, outlookUser, outlookCalendar), nil
+	}
+
+	gcalClient, err := getGoogleCalendarClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newGoogleBackend(gcalClient, calendarName), nil
+}
+
+// backendStateFile returns the state file of the ith calendar we sync to.
+// The first keeps the state file, and the others one named after them next
+// to it, like state-ics.json.
+func backendStateFile(i int) string {
+	if i < 1 || len(stateFile) < 1 {
+		return stateFile
+	}
+	ext := filepath.Ext(stateFile)
+	return strings.TrimSuffix(stateFile, ext) + "-" + calendarBackendNames()[i] + ext
+}
+
+// newTripItClient returns a TripIt API client after checking its flags.
+func newTripItClient() (*tripit.Client, error) {
+	if err := validateTripItFlags(); err != nil {
+		return nil, err
+	}
+
+	client := tripit.New(tripitUsername, tripitPassword)
+	client.SetRetryPolicy(tripit.RetryPolicy{
+		MaxAttempts: tripitRetries + 1,
+		Backoff:     tripitRetryBackoff,
+		MaxBackoff:  tripit.DefaultRetryPolicy.MaxBackoff,
+		Jitter:      tripitRetryJitter,
+	})
+	client.OnThrottle(func(time.Duration) {
+		metricThrottled.Inc("tripit")
+	})
+	client.OnRequest(func(method string, statusCode int, took time.Duration) {
+		observeRequest("tripit", statusCode, took)
+	})
+	return client, nil
+}
+
+// getGoogleCalendarClient returns the Google Calendar API client, creating it
+// the first time it is called. A client that could not be created is tried
+// again on the next call, so a keyfile that was missing or a token request
+// that failed does not break every sync after it.
+func getGoogleCalendarClient(ctx context.Context) (*calendar.Service, error) {
+	gcalMu.Lock()
+	defer gcalMu.Unlock()
+
+	if gcalService != nil {
+		return gcalService, nil
+	}
+	service, err := newGoogleCalendarClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gcalService = service
+	return gcalService, nil
+}
+
+func newGoogleCalendarClient(ctx context.Context) (*calendar.Service, error) {
+	gcalData, err := readGoogleKeyfile()
+	if err != nil {
+		return nil, err
+	}
+	// The config keeps its own copy of the private key.
+	defer zero(gcalData)
+
+	gcalTokenSource, err := google.JWTConfigFromJSON(gcalData, googleScopes()...)
+	if err != nil {
+		return nil, fmt.Errorf("creating google calendar token source from file %s failed: %v", googleCalendarKeyfile, err)
+	}
+
+	gcalClient, err := calendar.New(instrumentClient(gcalTokenSource.Client(ctx), "google"))
+	if err != nil {
+		return nil, fmt.Errorf("creating google calendar client failed: %v", err)
+	}
+
+	return gcalClient, nil
+}
+
+// getNotifier returns the notifiers for the chat tools that are configured,
+// or nil if there are none.
+func getNotifier() notify.Notifier {
+	notifierOnce.Do(func() {
+		var notifiers notify.Multi
+		if len(googleChatWebhook) > 0 {
+			notifiers = append(notifiers, notify.NewGoogleChat(googleChatWebhook))
+		}
+		if len(teamsWebhook) > 0 {
+			notifiers = append(notifiers, notify.NewTeams(teamsWebhook))
+		}
+		if len(notifiers) > 0 {
+			notifier = notifiers
+		}
+	})
+	return notifier
+}
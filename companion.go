@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2/google"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+var (
+	companionOnce    sync.Once
+	companionBackend calendarBackend
+	companionErr     error
+)
+
+// getCompanionBackend returns the Google calendar of the companion we mirror
+// trips to, creating its client the first time it is called. Without a
+// --companion-keyfile it uses our own credentials, for a calendar the
+// companion shared with our service account.
+func getCompanionBackend(ctx context.Context) (calendarBackend, error) {
+	companionOnce.Do(func() {
+		if len(companionKeyfile) < 1 {
+			var service *calendar.Service
+			service, companionErr = getGoogleCalendarClient(ctx)
+			companionBackend = newGoogleBackend(service, companionCalendar)
+			return
+		}
+
+		b, err := ioutil.ReadFile(companionKeyfile)
+		if err != nil {
+			companionErr = fmt.Errorf("reading file %s failed: %v", companionKeyfile, err)
+			return
+		}
+		defer zero(b)
+
+		ts, err := google.JWTConfigFromJSON(b, googleScopes()...)
+		if err != nil {
+			companionErr = fmt.Errorf("creating google calendar token source from file %s failed: %v", companionKeyfile, err)
+			return
+		}
+		service, err := calendar.New(instrumentClient(ts.Client(ctx), "google"))
+		if err != nil {
+			companionErr = fmt.Errorf("creating google calendar client for the companion failed: %v", err)
+			return
+		}
+		companionBackend = newGoogleBackend(service, companionCalendar)
+	})
+	return companionBackend, companionErr
+}
+
+// companionStateFile returns the state file of the companion's calendar,
+// next to ours, like state-companion.json.
+func companionStateFile() string {
+	if len(stateFile) < 1 {
+		return ""
+	}
+	ext := filepath.Ext(stateFile)
+	return strings.TrimSuffix(stateFile, ext) + "-companion" + ext
+}
+
+// companionEvents returns the events of the trips tagged with one of the
+// --companion-tags.
+func companionEvents(events []tripit.Event) []tripit.Event {
+	var out []tripit.Event
+	for _, e := range events {
+		if hasCompanionTag(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// hasCompanionTag returns true if the trip of the event has one of the
+// --companion-tags.
+func hasCompanionTag(e tripit.Event) bool {
+	for _, tag := range strings.Split(companionTags, ",") {
+		tag = strings.TrimSpace(tag)
+		for _, t := range e.Tags {
+			if len(tag) > 0 && strings.EqualFold(tag, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// syncCompanion mirrors the trips with a companion tag to the companion's
+// calendar, with its own state. Like the other calendars we sync to, a
+// companion calendar we could not sync to counts as a single failure.
+func syncCompanion(ctx context.Context, trips []tripit.Event, complete bool, res *syncResult) {
+	backend, err := getCompanionBackend(ctx)
+	if err == nil {
+		err = syncMirror(ctx, backend, companionStateFile(), companionEvents(trips), complete, res)
+	}
+	if err != nil {
+		err = fmt.Errorf("syncing to the companion's calendar failed: %w", err)
+		logrus.Error(err)
+		res.Failed++
+		res.Errors = append(res.Errors, syncEventError{Error: err.Error()})
+	}
+}
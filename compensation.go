@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+// Passenger rights regimes we know about.
+const (
+	regimeEU261 = "EU261"
+	regimeUK261 = "UK261"
+	regimeDOT   = "DOT"
+)
+
+// euCountries are the countries EU261 applies to: the EU, the rest of the
+// EEA, and Switzerland, as the OpenFlights dataset names them.
+var euCountries = map[string]bool{
+	"Austria": true, "Belgium": true, "Bulgaria": true, "Croatia": true,
+	"Cyprus": true, "Czech Republic": true, "Denmark": true, "Estonia": true,
+	"Finland": true, "France": true, "Germany": true, "Greece": true,
+	"Hungary": true, "Ireland": true, "Italy": true, "Latvia": true,
+	"Lithuania": true, "Luxembourg": true, "Malta": true, "Netherlands": true,
+	"Poland": true, "Portugal": true, "Romania": true, "Slovakia": true,
+	"Slovenia": true, "Spain": true, "Sweden": true, "Iceland": true,
+	"Norway": true, "Liechtenstein": true, "Switzerland": true,
+}
+
+// usCountries are the places DOT refund rules apply to.
+var usCountries = map[string]bool{
+	"United States": true, "Puerto Rico": true, "Guam": true,
+	"Virgin Islands": true, "Northern Mariana Islands": true, "American Samoa": true,
+}
+
+// compensationHint is a flight whose disruption likely qualifies for
+// compensation or a refund, with the details needed to claim it.
+type compensationHint struct {
+	Regime           string    `json:"regime"`
+	Flight           string    `json:"flight"`
+	Title            string    `json:"title"`
+	SegmentID        string    `json:"segmentID"`
+	Confirmation     string    `json:"confirmation"`
+	From             string    `json:"from"`
+	To               string    `json:"to"`
+	Departs          time.Time `json:"departs"`
+	ScheduledArrival time.Time `json:"scheduledArrival,omitempty"`
+	ActualArrival    time.Time `json:"actualArrival,omitempty"`
+	DistanceKm       int       `json:"distanceKm,omitempty"`
+	Disruption       string    `json:"disruption"`
+	Estimate         string    `json:"estimate"`
+	Note             string    `json:"note,omitempty"`
+}
+
+// segmentVersions is the first and last version of a segment we have seen.
+type segmentVersions struct {
+	first tripit.Event
+	last  tripit.Event
+}
+
+// segmentHistory returns the first and last version of every segment in the
+// snapshots, which must be oldest first.
+func segmentHistory(snapshots []*snapshot) map[string]*segmentVersions {
+	segments := map[string]*segmentVersions{}
+	for _, snap := range snapshots {
+		for _, e := range snap.Events {
+			if v, ok := segments[e.SegmentID]; ok {
+				v.last = e
+				continue
+			}
+			segments[e.SegmentID] = &segmentVersions{first: e, last: e}
+		}
+	}
+	return segments
+}
+
+// arrived returns true if the flight status says the flight has landed.
+func arrived(status tripit.FlightStatusCode) bool {
+	switch status {
+	case tripit.FlightStatusArrivedOnTime, tripit.FlightStatusArrivedLate, tripit.FlightStatusArrivedPossiblyLate:
+		return true
+	}
+	return false
+}
+
+// compensationHints returns the hints for a single segment, one per regime
+// that likely applies.
+func compensationHints(v *segmentVersions) []compensationHint {
+	e := v.last
+	origin, ok := getAirport(e.AirportCode)
+	if !ok {
+		return nil
+	}
+	dest, ok := getAirport(e.DestinationCode)
+	if !ok {
+		return nil
+	}
+	airlineCountry := getAirlineCountry(e.AirlineCode)
+
+	base := compensationHint{
+		Flight:           e.FlightNumber,
+		Title:            e.Title,
+		SegmentID:        e.SegmentID,
+		Confirmation:     e.ConfirmationNumber,
+		From:             e.AirportCode,
+		To:               e.DestinationCode,
+		Departs:          eventTime(e.Start),
+		ScheduledArrival: e.ScheduledArrival,
+		DistanceKm:       int(greatCircleKm(origin.Latitude, origin.Longitude, dest.Latitude, dest.Longitude)),
+	}
+
+	// Work out how the flight was disrupted.
+	var delay time.Duration
+	cancelled := e.Status == tripit.FlightStatusCancelled
+	diverted := e.Status == tripit.FlightStatusDiverted || len(e.DivertedTo) > 0
+	if arrived(e.Status) && !e.ScheduledArrival.IsZero() && !e.EstimatedArrival.IsZero() {
+		delay = e.EstimatedArrival.Sub(e.ScheduledArrival)
+		base.ActualArrival = e.EstimatedArrival
+	}
+	rescheduled := eventTime(v.last.Start).Sub(eventTime(v.first.Start))
+	if rescheduled < 0 {
+		rescheduled = -rescheduled
+	}
+
+	var hints []compensationHint
+
+	// EU261 covers flights departing the EU, and flights arriving in the EU
+	// on an EU airline. UK261 is the same rule after Brexit.
+	regime := ""
+	switch {
+	case euCountries[origin.Country]:
+		regime = regimeEU261
+	case origin.Country == "United Kingdom":
+		regime = regimeUK261
+	case euCountries[dest.Country] && euCountries[airlineCountry]:
+		regime = regimeEU261
+	case dest.Country == "United Kingdom" && (airlineCountry == "United Kingdom" || euCountries[airlineCountry]):
+		regime = regimeUK261
+	}
+	if len(regime) > 0 {
+		h := base
+		h.Regime = regime
+		switch {
+		case cancelled:
+			h.Disruption = "cancelled"
+			h.Note = "unless you were told at least 14 days before departure or it was caused by extraordinary circumstances"
+		case diverted:
+			h.Disruption = "diverted"
+			if len(e.DivertedTo) > 0 {
+				h.Disruption += " to " + e.DivertedTo
+			}
+			h.Note = "if you reached your destination 3 hours or more late"
+		case delay >= 3*time.Hour:
+			h.Disruption = "arrived " + strings.TrimPrefix(formatShift(delay), "+") + " late"
+			h.Note = "unless it was caused by extraordinary circumstances"
+			if base.DistanceKm > 3500 && delay < 4*time.Hour {
+				h.Note += ", the airline may halve it since the delay was under 4 hours"
+			}
+		}
+		if len(h.Disruption) > 0 {
+			intraEU := euCountries[origin.Country] && euCountries[dest.Country]
+			h.Estimate = compensationAmount(regime, h.DistanceKm, intraEU)
+			hints = append(hints, h)
+		}
+	}
+
+	// The DOT requires a refund for cancelled flights and significant
+	// schedule changes to, from, or within the US: 3 hours for domestic
+	// flights and 6 hours for international ones.
+	if usCountries[origin.Country] || usCountries[dest.Country] {
+		significant := 6 * time.Hour
+		if usCountries[origin.Country] && usCountries[dest.Country] {
+			significant = 3 * time.Hour
+		}
+
+		h := base
+		h.Regime = regimeDOT
+		h.Estimate = "refund"
+		switch {
+		case cancelled:
+			h.Disruption = "cancelled"
+			h.Note = "if you did not accept a rebooking"
+		case rescheduled >= significant:
+			h.Disruption = "rescheduled by " + formatShift(eventTime(v.last.Start).Sub(eventTime(v.first.Start)))
+			h.Note = "if you did not accept the change"
+		case delay >= significant:
+			h.Disruption = "arrived " + strings.TrimPrefix(formatShift(delay), "+") + " late"
+			h.Note = "if you did not take the flight"
+		case diverted:
+			h.Disruption = "diverted"
+			if len(e.DivertedTo) > 0 {
+				h.Disruption += " to " + e.DivertedTo
+			}
+			h.Note = "if you did not take the flight"
+		}
+		if len(h.Disruption) > 0 {
+			hints = append(hints, h)
+		}
+	}
+
+	return hints
+}
+
+// compensationAmount returns the compensation EU261 or UK261 sets for a flight
+// of the given distance. Flights within the EU never get more than the middle
+// band.
+func compensationAmount(regime string, km int, intraEU bool) string {
+	bands := []string{"€250", "€400", "€600"}
+	if regime == regimeUK261 {
+		bands = []string{"£220", "£350", "£520"}
+	}
+
+	switch {
+	case km <= 1500:
+		return bands[0]
+	case km <= 3500 || intraEU:
+		return bands[1]
+	}
+	return bands[2]
+}
+
+// greatCircleKm returns the distance between two points in kilometres.
+func greatCircleKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	rad := func(d float64) float64 { return d * math.Pi / 180 }
+
+	dLat := rad(lat2 - lat1)
+	dLon := rad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+const compensationHelp = `Report flights that likely qualify for EU261, UK261, or DOT compensation.`
+
+const compensationLongHelp = `Report flights that likely qualify for EU261, UK261, or DOT compensation.
+
+Goes through the itinerary history for flights that were cancelled, diverted,
+arrived 3 hours or more late, or were rescheduled, and reports the ones that
+likely qualify for compensation under EU261 or UK261 or for a refund under
+the US DOT rules, with the details needed for a claim.
+
+Delays, cancellations, and diversions come from the flight status, which
+TripIt only has for flights it monitors with TripIt Pro. Schedule changes
+come from the itinerary history, see history.
+
+These are hints, not legal advice: whether a claim succeeds depends on
+things we cannot see, like the cause of the disruption.`
+
+func (cmd *compensationCommand) Name() string      { return "compensation" }
+func (cmd *compensationCommand) Args() string      { return "" }
+func (cmd *compensationCommand) ShortHelp() string { return compensationHelp }
+func (cmd *compensationCommand) LongHelp() string  { return compensationLongHelp }
+func (cmd *compensationCommand) Hidden() bool      { return false }
+
+func (cmd *compensationCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.since, "since", "", "Only report flights departing on or after this year or date (ex. 2024, 2024-06-01)")
+}
+
+type compensationCommand struct {
+	since string
+}
+
+func (cmd *compensationCommand) Run(ctx context.Context, args []string) error {
+	var since time.Time
+	if len(cmd.since) > 0 {
+		var err error
+		since, err = parseSince(cmd.since)
+		if err != nil {
+			fatal(exitCodeConfig, err)
+		}
+	}
+
+	snapshots, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+	if st.Snapshot != nil {
+		snapshots = append(snapshots, st.Snapshot)
+	}
+	if len(snapshots) < 1 {
+		return errors.New("there is no itinerary history yet, it is kept by syncs and fetch")
+	}
+
+	var hints []compensationHint
+	for _, v := range segmentHistory(snapshots) {
+		if eventTime(v.last.Start).Before(since) {
+			continue
+		}
+		hints = append(hints, compensationHints(v)...)
+	}
+	sort.SliceStable(hints, func(i, j int) bool {
+		return hints[i].Departs.Before(hints[j].Departs)
+	})
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(hints)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "DEPARTS\tFLIGHT\tROUTE\tCONFIRMATION\tREGIME\tDISRUPTION\tESTIMATE\tNOTE")
+	for _, h := range hints {
+		fmt.Fprintf(w, "%s\t%s\t%s-%s\t%s\t%s\t%s\t%s\t%s\n", h.Departs.Format("2006-01-02 15:04"), h.Flight, h.From, h.To, h.Confirmation, h.Regime, h.Disruption, h.Estimate, h.Note)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// configEnvRegex matches the environment variables a config file value can
+// refer to, like ${TRIPIT_PASSWORD}.
+var configEnvRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// configNameRegex matches the names a config file can set, which are flag
+// names.
+var configNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// flagEnv returns the flags that can also be set with an environment
+// variable, by name, and the variables, so a flag set that way wins over the
+// config file like one passed on the command line.
+func flagEnv() map[string]string {
+	return map[string]string{
+		"config":                "TRIPITCALB0T_CONFIG",
+		"calendar":              "GOOGLE_CALENDAR_ID",
+		"caldav-url":            "CALDAV_URL",
+		"caldav-username":       "CALDAV_USERNAME",
+		"caldav-password":       "CALDAV_PASSWORD",
+		"outlook-client-id":     "OUTLOOK_CLIENT_ID",
+		"outlook-client-secret": "OUTLOOK_CLIENT_SECRET",
+		"outlook-tenant":        "OUTLOOK_TENANT_ID",
+		"outlook-user":          "OUTLOOK_USER",
+		"ics-file":              "ICS_FILE",
+		"backends":              "CALENDAR_BACKENDS",
+		"companion-calendar":    "COMPANION_CALENDAR",
+		"companion-keyfile":     "COMPANION_KEYFILE",
+		"tripit-username":       "TRIPIT_USERNAME",
+		"tripit-password":       "TRIPIT_PASSWORD",
+		"passport":              "PASSPORT",
+		"refdata-dir":           "REFDATA_DIR",
+		"checklist-file":        "CHECKLIST_FILE",
+		"insurance-file":        "INSURANCE_FILE",
+		"refundable":            "REFUNDABLE",
+		"baggage-file":          "BAGGAGE_FILE",
+		"mqtt-username":         "MQTT_USERNAME",
+		"mqtt-password":         "MQTT_PASSWORD",
+		"emergency-contacts":    "EMERGENCY_CONTACTS",
+		"slack-token":           "SLACK_TOKEN",
+		"slack-signing-secret":  "SLACK_SIGNING_SECRET",
+		"slack-users":           "SLACK_USERS",
+		"google-chat-webhook":   "GOOGLE_CHAT_WEBHOOK",
+		"teams-webhook":         "TEAMS_WEBHOOK",
+		"metrics-addr":          "METRICS_ADDR",
+		"share-secret":          "SHARE_SECRET",
+		"share-url":             "SHARE_URL",
+		"oidc-issuer":           "OIDC_ISSUER",
+		"oidc-client-id":        "OIDC_CLIENT_ID",
+		"oidc-client-secret":    "OIDC_CLIENT_SECRET",
+		"oidc-redirect-url":     "OIDC_REDIRECT_URL",
+	}
+}
+
+// parseConfig parses a config file of flag names and values, one per line
+// like the YAML
+//
+//	calendar: primary
+//	companion-tags: [family, kids]
+//	tripit-password: ${TRIPIT_PASSWORD}
+//
+// Only this flat subset of YAML is supported: lines starting with # are
+// comments, values may be quoted, lists are written on one line in brackets
+// and joined with commas, and ${VAR} is replaced with the environment
+// variable. Anything else, like nested or indented values, block lists,
+// multi-line strings, anchors, or tags, is an error rather than being read
+// differently than YAML would.
+func parseConfig(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if len(line) < 1 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "---" && len(config) < 1 {
+			continue
+		}
+
+		switch {
+		case raw[0] == ' ' || raw[0] == '\t':
+			return nil, fmt.Errorf("line %d of config file %s is indented, nested values are not supported", n, path)
+		case strings.HasPrefix(line, "- "), line == "-":
+			return nil, fmt.Errorf("line %d of config file %s is a block list item, write lists on one line like [a, b]", n, path)
+		}
+
+		i := strings.Index(line, ":")
+		if i < 1 {
+			return nil, fmt.Errorf("line %d of config file %s is not like name: value", n, path)
+		}
+		name, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if !configNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("line %d of config file %s sets %q, which is not a flag name", n, path, name)
+		}
+		if _, ok := config[name]; ok {
+			return nil, fmt.Errorf("config file %s sets %s more than once", path, name)
+		}
+
+		value, err := parseConfigValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of config file %s: %v", n, path, err)
+		}
+		config[name] = configEnvRegex.ReplaceAllStringFunc(value, func(s string) string {
+			return os.Getenv(configEnvRegex.FindStringSubmatch(s)[1])
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading config file %s failed: %v", path, err)
+	}
+	return config, nil
+}
+
+// parseConfigValue unquotes the value, joins a list with commas, and drops a
+// trailing comment. It returns an error for the YAML parseConfig does not
+// support.
+func parseConfigValue(value string) (string, error) {
+	if len(value) > 0 && (value[0] == '"' || value[0] == '\'') {
+		end := strings.IndexByte(value[1:], value[0])
+		if end < 0 {
+			return "", fmt.Errorf("%s has no closing quote", value)
+		}
+		if rest := strings.TrimSpace(value[end+2:]); len(rest) > 0 && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("%s has more after its closing quote", value)
+		}
+		return value[1 : end+1], nil
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	if len(value) > 0 {
+		switch value[0] {
+		case '|', '>':
+			return "", fmt.Errorf("%s is a multi-line string, which is not supported", value)
+		case '&', '*', '!':
+			return "", fmt.Errorf("%s is an anchor, alias, or tag, which is not supported", value)
+		case '{':
+			return "", fmt.Errorf("%s is a map, which is not supported", value)
+		}
+	}
+	if strings.HasPrefix(value, "[") {
+		if !strings.HasSuffix(value, "]") {
+			return "", fmt.Errorf("%s is a list that is not closed on the same line", value)
+		}
+		var items []string
+		for _, item := range strings.Split(value[1:len(value)-1], ",") {
+			item = strings.Trim(strings.TrimSpace(item), `"'`)
+			if len(item) > 0 {
+				items = append(items, item)
+			}
+		}
+		return strings.Join(items, ","), nil
+	}
+	return value, nil
+}
+
+// applyConfig sets the flags from the config file at path. A flag passed on
+// the command line, or set with its environment variable, wins over the
+// file. A missing file is only an error if it was asked for with --config.
+func applyConfig(fs *flag.FlagSet, path string, required bool) error {
+	config, err := parseConfig(path)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	passed := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		passed[f.Name] = true
+	})
+
+	for name, value := range config {
+		f := fs.Lookup(name)
+		if f == nil || name == "config" {
+			logrus.Warnf("config file %s sets %s, which is not a flag of this command", path, name)
+			continue
+		}
+		if passed[name] {
+			continue
+		}
+		if env, ok := flagEnv()[name]; ok && len(os.Getenv(env)) > 0 {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("config file %s sets %s to %q: %v", path, name, value, err)
+		}
+	}
+	return nil
+}
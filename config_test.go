@@ -0,0 +1,203 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// writeConfig writes the config file to a temporary directory and returns
+// its path.
+func writeConfig(t *testing.T, config string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseConfigValue(t *testing.T) {
+	testcases := []struct {
+		value string
+		want  string
+	}{
+		{value: "primary", want: "primary"},
+		{value: "", want: ""},
+		{value: "5m # every five minutes", want: "5m"},
+		{value: "a#b", want: "a#b"},
+		{value: `"quoted # not a comment"`, want: "quoted # not a comment"},
+		{value: `'single'`, want: "single"},
+		{value: `"quoted" # a comment`, want: "quoted"},
+		{value: "[google, ics]", want: "google,ics"},
+		{value: `["family", 'kids', ]`, want: "family,kids"},
+		{value: "[]", want: ""},
+		{value: "[a, b] # a list", want: "a,b"},
+	}
+
+	for _, tc := range testcases {
+		got, err := parseConfigValue(tc.value)
+		if err != nil {
+			t.Errorf("parseConfigValue(%q) failed: %v", tc.value, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseConfigValue(%q) = %q, want %q", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestParseConfigValueInvalid(t *testing.T) {
+	for _, value := range []string{
+		`"no closing quote`,
+		`"quoted" and more`,
+		"[a, b",
+		"|",
+		">-",
+		"&anchor value",
+		"*alias",
+		"!!str value",
+		"{a: b}",
+	} {
+		if got, err := parseConfigValue(value); err == nil {
+			t.Errorf("parseConfigValue(%q) = %q, want an error", value, got)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	t.Setenv("TRIPITCALB0T_TEST_WEBHOOK", "https://example.com/hook")
+
+	path := writeConfig(t, `---
+# Sync to two calendars.
+backends: [google, ics]
+calendar: primary
+
+interval: 5m # every five minutes
+teams-webhook: ${TRIPITCALB0T_TEST_WEBHOOK}
+decline-message: "Sorry, I'm flying: {flight}"
+`)
+	got, err := parseConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"backends":        "google,ics",
+		"calendar":        "primary",
+		"interval":        "5m",
+		"teams-webhook":   "https://example.com/hook",
+		"decline-message": "Sorry, I'm flying: {flight}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseConfig = %v, want %v", got, want)
+	}
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	testcases := []struct {
+		name   string
+		config string
+	}{
+		{name: "not name: value", config: "calendar primary\n"},
+		{name: "set twice", config: "calendar: a\ncalendar: b\n"},
+		{name: "indented", config: "google:\n  calendar: primary\n"},
+		{name: "block list", config: "backends:\n- google\n- ics\n"},
+		{name: "bad name", config: "Calendar Name: primary\n"},
+		{name: "second document", config: "calendar: a\n---\ninterval: 5m\n"},
+		{name: "multi-line string", config: "decline-message: |\n"},
+	}
+
+	for _, tc := range testcases {
+		if got, err := parseConfig(writeConfig(t, tc.config)); err == nil {
+			t.Errorf("%s: parseConfig = %v, want an error", tc.name, got)
+		}
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	path := writeConfig(t, `calendar: from-file
+interval: 5m
+once: true
+tripit-username: from-file
+not-a-flag: ignored
+`)
+	t.Setenv("TRIPIT_USERNAME", "from-env")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	calendar := fs.String("calendar", "", "")
+	interval := fs.String("interval", "1m", "")
+	once := fs.Bool("once", false, "")
+	username := fs.String("tripit-username", "", "")
+	if err := fs.Parse([]string{"--calendar", "from-flag"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyConfig(fs, path, true); err != nil {
+		t.Fatal(err)
+	}
+	if *calendar != "from-flag" {
+		t.Errorf("calendar = %q, want the flag passed to win over the file", *calendar)
+	}
+	if *interval != "5m" || !*once {
+		t.Errorf("interval, once = %q, %t, want them set from the file", *interval, *once)
+	}
+	// The environment variable is read when the flag is defined, so the
+	// file must not overwrite it.
+	if *username != "" {
+		t.Errorf("tripit-username = %q, want the file to leave a flag set with its env var alone", *username)
+	}
+}
+
+func TestApplyConfigInvalidValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("once", false, "")
+
+	if err := applyConfig(fs, writeConfig(t, "once: sometimes\n"), true); err == nil {
+		t.Error("applyConfig with a bad value for a flag succeeded, want an error")
+	}
+}
+
+func TestApplyConfigMissing(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	if err := applyConfig(fs, path, false); err != nil {
+		t.Errorf("applyConfig with the default file missing failed: %v", err)
+	}
+	if err := applyConfig(fs, path, true); err == nil {
+		t.Error("applyConfig with the file passed with --config missing succeeded, want an error")
+	}
+}
+
+// TestFlagEnv checks that every flag whose help says it can be set with an
+// environment variable is in flagEnv with that variable, so the config file
+// does not overwrite it.
+func TestFlagEnv(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`Var\(&\w+, "([a-z0-9-]+)",.*\(or env var ([A-Z0-9_]+)\)"\)`)
+
+	want := map[string]string{}
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range re.FindAllStringSubmatch(string(b), -1) {
+			want[m[1]] = m[2]
+		}
+	}
+	if len(want) < 1 {
+		t.Fatal("found no flags that can be set with an environment variable")
+	}
+
+	if got := flagEnv(); !reflect.DeepEqual(got, want) {
+		t.Errorf("flagEnv() = %v, want the flags from their help %v", got, want)
+	}
+}
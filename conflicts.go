@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+const conflictsHelp = `Report upcoming flights that look booked twice.`
+
+const conflictsLongHelp = `Report upcoming flights that look booked twice.
+
+Two flights on the same route that depart within --duplicate-window of each
+other but have different confirmation numbers are probably a double booking,
+for example a refundable fare that was never cancelled.`
+
+func (cmd *conflictsCommand) Name() string      { return "conflicts" }
+func (cmd *conflictsCommand) Args() string      { return "" }
+func (cmd *conflictsCommand) ShortHelp() string { return conflictsHelp }
+func (cmd *conflictsCommand) LongHelp() string  { return conflictsLongHelp }
+func (cmd *conflictsCommand) Hidden() bool      { return false }
+
+func (cmd *conflictsCommand) Register(fs *flag.FlagSet) {}
+
+type conflictsCommand struct{}
+
+func (cmd *conflictsCommand) Run(ctx context.Context, args []string) error {
+	tripitClient, err := newTripItClient()
+	if err != nil {
+		fatal(exitCodeConfig, err)
+	}
+
+	trips, _, err := getTripItEvents(ctx, tripitClient, "false")
+	if err != nil {
+		return fmt.Errorf("getting tripit events failed: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "DEPARTS\tFROM\tTO\tFLIGHT\tCONFIRMATION\tOTHER FLIGHT\tOTHER CONFIRMATION")
+	for _, d := range findDuplicateBookings(trips, duplicateWindow) {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			eventTime(d[0].Start).Format("2006-01-02 15:04"), d[0].AirportCode, d[0].DestinationCode,
+			d[0].FlightNumber, d[0].ConfirmationNumber, d[1].FlightNumber, d[1].ConfirmationNumber)
+	}
+	return w.Flush()
+}
+
+// findDuplicateBookings returns the pairs of flights on the same route that
+// depart within window of each other but have different confirmation numbers.
+// The earlier flight is first in each pair.
+func findDuplicateBookings(trips []tripit.Event, window time.Duration) [][2]tripit.Event {
+	sorted := make([]tripit.Event, len(trips))
+	copy(sorted, trips)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return eventTime(sorted[i].Start).Before(eventTime(sorted[j].Start))
+	})
+
+	var dups [][2]tripit.Event
+	for i, a := range sorted {
+		for _, b := range sorted[i+1:] {
+			if eventTime(b.Start).Sub(eventTime(a.Start)) > window {
+				break
+			}
+			if !a.IsFlight() || !b.IsFlight() || a.AirportCode != b.AirportCode || a.DestinationCode != b.DestinationCode {
+				continue
+			}
+			if len(a.ConfirmationNumber) < 1 || len(b.ConfirmationNumber) < 1 || a.ConfirmationNumber == b.ConfirmationNumber {
+				continue
+			}
+			dups = append(dups, [2]tripit.Event{a, b})
+		}
+	}
+
+	return dups
+}
+
+// warnDuplicateBookings warns once about every double booking that departs
+// within the next week.
+func warnDuplicateBookings(st *syncState, trips []tripit.Event) []string {
+	var warnings []string
+	now := time.Now()
+	for _, d := range findDuplicateBookings(trips, duplicateWindow) {
+		start := eventTime(d[0].Start)
+		if start.Before(now) || start.Sub(now) > 7*24*time.Hour {
+			continue
+		}
+		if !st.warnOnce("duplicate:" + d[0].SegmentID + ":" + d[1].SegmentID) {
+			continue
+		}
+
+		warnings = append(warnings, fmt.Sprintf("Possible double booking: the flight from %s to %s on %s is booked as both %s (%s) and %s (%s)",
+			d[0].AirportCode, d[0].DestinationCode, start.Format("Mon Jan 2 3:04pm"),
+			d[0].ConfirmationNumber, d[0].FlightNumber, d[1].ConfirmationNumber, d[1].FlightNumber))
+	}
+	return warnings
+}
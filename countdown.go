@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+)
+
+// countdown is how long until the next flight of an upcoming trip, small
+// enough for e-ink displays and smart mirrors to show.
+type countdown struct {
+	TripID string `json:"tripID"`
+	Trip   string `json:"trip"`
+	Flight string `json:"flight"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	// InAir is true if the flight has departed, and the countdown is to
+	// when it lands.
+	InAir   bool      `json:"inAir"`
+	At      time.Time `json:"at"`
+	Seconds int64     `json:"seconds"`
+	Text    string    `json:"text"`
+}
+
+// tripCountdowns returns the countdown for every upcoming trip in the events
+// that has a flight still to depart or land, soonest first.
+func tripCountdowns(events []tripit.Event, now time.Time) []countdown {
+	countdowns := []countdown{}
+	for _, t := range upcomingTrips(events, now) {
+		current, next := nextDeparture(t.Events, now)
+		c := countdown{TripID: t.ID, Trip: t.Name}
+		switch {
+		case current != nil:
+			c.InAir = true
+			c.At = eventTime(current.End)
+			c.Flight, c.From, c.To = current.FlightNumber, current.AirportCode, current.DestinationCode
+		case next != nil:
+			c.At = eventTime(next.Start)
+			c.Flight, c.From, c.To = next.FlightNumber, next.AirportCode, next.DestinationCode
+		default:
+			continue
+		}
+		d := c.At.Sub(now)
+		c.Seconds = int64(d.Seconds())
+		c.Text = fmt.Sprintf("%s→%s in %s", c.From, c.To, shortCountdown(d))
+		if c.InAir {
+			c.Text = fmt.Sprintf("%s→%s lands in %s", c.From, c.To, shortCountdown(d))
+		}
+		countdowns = append(countdowns, c)
+	}
+	return countdowns
+}
+
+// shortCountdown formats the duration to the two largest units, like 2d 14h.
+func shortCountdown(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	days := d / (24 * time.Hour)
+	h := (d % (24 * time.Hour)) / time.Hour
+	m := (d % time.Hour) / time.Minute
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, h)
+	case h > 0:
+		return fmt.Sprintf("%dh %dm", h, m)
+	}
+	return fmt.Sprintf("%dm", m)
+}
+
+// apiCountdownHandler serves the countdowns of the upcoming trips the account
+// may see, or of the one trip in the path, like /api/countdown/123456789. It
+// serves JSON, or plain text lines with ?format=text. The countdowns are made
+// from the itinerary of the last sync on every request.
+func apiCountdownHandler(w http.ResponseWriter, r *http.Request, a *account) {
+	st, err := loadState(stateFile)
+	if err != nil {
+		logrus.Errorf("reading state for the countdown failed: %v", err)
+		http.Error(w, "the trips are not available right now", http.StatusInternalServerError)
+		return
+	}
+	var events []tripit.Event
+	if st.Snapshot != nil {
+		events = a.visible(st.Snapshot.Events)
+	}
+	countdowns := tripCountdowns(events, time.Now())
+
+	var body interface{} = countdowns
+	if id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/countdown"), "/"); len(id) > 0 {
+		var found *countdown
+		for i := range countdowns {
+			if countdowns[i].TripID == id {
+				found = &countdowns[i]
+			}
+		}
+		if found == nil {
+			http.Error(w, "no upcoming trip "+id, http.StatusNotFound)
+			return
+		}
+		countdowns = []countdown{*found}
+		body = found
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, c := range countdowns {
+			fmt.Fprintln(w, c.Text)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logrus.Errorf("writing the countdown failed: %v", err)
+	}
+}
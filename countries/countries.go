@@ -0,0 +1,52 @@
+// Package countries has the facts travelers need about countries, like their
+// currency, plugs, emergency numbers, and what passports need to visit them.
+// Countries are named like the OpenFlights dataset names them, or by their
+// ISO 3166 codes.
+package countries
+
+import "strings"
+
+// Country is what to know about a country before going there.
+type Country struct {
+	Name string
+	// Code is the ISO 3166 code of the country.
+	Code string
+	// Currency is the ISO 4217 code of the currency, and CurrencyName
+	// what it is called in English.
+	Currency     string
+	CurrencyName string
+	// Plugs are the IEC letters of the plug types in use.
+	Plugs   []string
+	Voltage string
+	// Emergency is the number, or the numbers for each service, to call in
+	// an emergency.
+	Emergency string
+	// Tipping is how much to tip, if at all.
+	Tipping string
+}
+
+// Lookup returns the country with the name or ISO 3166 code.
+func Lookup(country string) (Country, bool) {
+	c, ok := countries[Name(country)]
+	return c, ok
+}
+
+// Name returns the name of the country with the ISO 3166 code, or the
+// country as it is if it is not a known code.
+func Name(country string) string {
+	code := strings.ToUpper(strings.TrimSpace(country))
+	for name, c := range countries {
+		if c.Code == code {
+			return name
+		}
+	}
+	return country
+}
+
+// Set adds the country, or replaces the one with its name, like to keep the
+// facts current without a new release. It is not safe to call while the
+// countries are being looked up.
+func Set(c Country) {
+	c.Code = strings.ToUpper(strings.TrimSpace(c.Code))
+	countries[c.Name] = c
+}
@@ -0,0 +1,223 @@
+package countries
+
+// countries are the countries, by name.
+var countries = map[string]Country{
+	"Australia": {
+		Code: "AU", Currency: "AUD", CurrencyName: "Australian dollar",
+		Plugs: []string{"I"}, Voltage: "230 V",
+		Emergency: "000",
+		Tipping:   "Not expected; round up or leave 10% for good service at restaurants.",
+	},
+	"Austria": {
+		Code: "AT", Currency: "EUR", CurrencyName: "euro",
+		Plugs: []string{"C", "F"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "Round up or about 10% at restaurants, said when paying.",
+	},
+	"Belgium": {
+		Code: "BE", Currency: "EUR", CurrencyName: "euro",
+		Plugs: []string{"C", "E"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "Service is included; round up if you like.",
+	},
+	"Brazil": {
+		Code: "BR", Currency: "BRL", CurrencyName: "Brazilian real",
+		Plugs: []string{"C", "N"}, Voltage: "127 V or 220 V",
+		Emergency: "190 (police), 192 (ambulance), 193 (fire)",
+		Tipping:   "A 10% service charge is usually added at restaurants.",
+	},
+	"Canada": {
+		Code: "CA", Currency: "CAD", CurrencyName: "Canadian dollar",
+		Plugs: []string{"A", "B"}, Voltage: "120 V",
+		Emergency: "911",
+		Tipping:   "15-20% at restaurants, and for taxis and hairdressers.",
+	},
+	"China": {
+		Code: "CN", Currency: "CNY", CurrencyName: "Chinese yuan",
+		Plugs: []string{"A", "C", "I"}, Voltage: "220 V",
+		Emergency: "110 (police), 120 (ambulance), 119 (fire)",
+		Tipping:   "Not expected.",
+	},
+	"Czech Republic": {
+		Code: "CZ", Currency: "CZK", CurrencyName: "Czech koruna",
+		Plugs: []string{"C", "E"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "About 10% at restaurants.",
+	},
+	"Denmark": {
+		Code: "DK", Currency: "DKK", CurrencyName: "Danish krone",
+		Plugs: []string{"C", "E", "F", "K"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "Service is included; rounding up is appreciated.",
+	},
+	"Finland": {
+		Code: "FI", Currency: "EUR", CurrencyName: "euro",
+		Plugs: []string{"C", "F"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "Not expected.",
+	},
+	"France": {
+		Code: "FR", Currency: "EUR", CurrencyName: "euro",
+		Plugs: []string{"C", "E"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "Service is included (service compris); leave small change for good service.",
+	},
+	"Germany": {
+		Code: "DE", Currency: "EUR", CurrencyName: "euro",
+		Plugs: []string{"C", "F"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "Round up or 5-10% at restaurants, said when paying.",
+	},
+	"Greece": {
+		Code: "GR", Currency: "EUR", CurrencyName: "euro",
+		Plugs: []string{"C", "F"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "Round up or 5-10% at restaurants.",
+	},
+	"Hong Kong": {
+		Code: "HK", Currency: "HKD", CurrencyName: "Hong Kong dollar",
+		Plugs: []string{"G"}, Voltage: "220 V",
+		Emergency: "999",
+		Tipping:   "A 10% service charge is usually added at restaurants.",
+	},
+	"India": {
+		Code: "IN", Currency: "INR", CurrencyName: "Indian rupee",
+		Plugs: []string{"C", "D", "M"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "About 10% at restaurants if no service charge is added.",
+	},
+	"Ireland": {
+		Code: "IE", Currency: "EUR", CurrencyName: "euro",
+		Plugs: []string{"G"}, Voltage: "230 V",
+		Emergency: "112 or 999",
+		Tipping:   "10-15% at restaurants if service is not included.",
+	},
+	"Israel": {
+		Code: "IL", Currency: "ILS", CurrencyName: "Israeli new shekel",
+		Plugs: []string{"C", "H"}, Voltage: "230 V",
+		Emergency: "100 (police), 101 (ambulance), 102 (fire)",
+		Tipping:   "10-15% at restaurants.",
+	},
+	"Italy": {
+		Code: "IT", Currency: "EUR", CurrencyName: "euro",
+		Plugs: []string{"C", "F", "L"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "A cover charge (coperto) is common; rounding up is enough.",
+	},
+	"Japan": {
+		Code: "JP", Currency: "JPY", CurrencyName: "Japanese yen",
+		Plugs: []string{"A", "B"}, Voltage: "100 V",
+		Emergency: "110 (police), 119 (ambulance and fire)",
+		Tipping:   "Not expected, and can cause confusion.",
+	},
+	"Mexico": {
+		Code: "MX", Currency: "MXN", CurrencyName: "Mexican peso",
+		Plugs: []string{"A", "B"}, Voltage: "127 V",
+		Emergency: "911",
+		Tipping:   "10-15% at restaurants.",
+	},
+	"Netherlands": {
+		Code: "NL", Currency: "EUR", CurrencyName: "euro",
+		Plugs: []string{"C", "F"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "Service is included; round up or 5-10% for good service.",
+	},
+	"New Zealand": {
+		Code: "NZ", Currency: "NZD", CurrencyName: "New Zealand dollar",
+		Plugs: []string{"I"}, Voltage: "230 V",
+		Emergency: "111",
+		Tipping:   "Not expected.",
+	},
+	"Norway": {
+		Code: "NO", Currency: "NOK", CurrencyName: "Norwegian krone",
+		Plugs: []string{"C", "F"}, Voltage: "230 V",
+		Emergency: "112 (police), 113 (ambulance), 110 (fire)",
+		Tipping:   "Service is included; rounding up is appreciated.",
+	},
+	"Poland": {
+		Code: "PL", Currency: "PLN", CurrencyName: "Polish złoty",
+		Plugs: []string{"C", "E"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "About 10% at restaurants.",
+	},
+	"Portugal": {
+		Code: "PT", Currency: "EUR", CurrencyName: "euro",
+		Plugs: []string{"C", "F"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "5-10% at restaurants.",
+	},
+	"Singapore": {
+		Code: "SG", Currency: "SGD", CurrencyName: "Singapore dollar",
+		Plugs: []string{"G"}, Voltage: "230 V",
+		Emergency: "999 (police), 995 (ambulance and fire)",
+		Tipping:   "Not expected; a 10% service charge is usually added.",
+	},
+	"South Africa": {
+		Code: "ZA", Currency: "ZAR", CurrencyName: "South African rand",
+		Plugs: []string{"C", "D", "M", "N"}, Voltage: "230 V",
+		Emergency: "10111 (police), 10177 (ambulance), 112 from mobile phones",
+		Tipping:   "10-15% at restaurants.",
+	},
+	"South Korea": {
+		Code: "KR", Currency: "KRW", CurrencyName: "South Korean won",
+		Plugs: []string{"C", "F"}, Voltage: "220 V",
+		Emergency: "112 (police), 119 (ambulance and fire)",
+		Tipping:   "Not expected.",
+	},
+	"Spain": {
+		Code: "ES", Currency: "EUR", CurrencyName: "euro",
+		Plugs: []string{"C", "F"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "Not expected; round up or leave small change.",
+	},
+	"Sweden": {
+		Code: "SE", Currency: "SEK", CurrencyName: "Swedish krona",
+		Plugs: []string{"C", "F"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "Service is included; round up if you like.",
+	},
+	"Switzerland": {
+		Code: "CH", Currency: "CHF", CurrencyName: "Swiss franc",
+		Plugs: []string{"C", "J"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "Service is included; round up if you like.",
+	},
+	"Thailand": {
+		Code: "TH", Currency: "THB", CurrencyName: "Thai baht",
+		Plugs: []string{"A", "B", "C", "O"}, Voltage: "220 V",
+		Emergency: "191 (police), 1669 (ambulance), 199 (fire)",
+		Tipping:   "Small tips are appreciated; round up.",
+	},
+	"Turkey": {
+		Code: "TR", Currency: "TRY", CurrencyName: "Turkish lira",
+		Plugs: []string{"C", "F"}, Voltage: "230 V",
+		Emergency: "112",
+		Tipping:   "5-10% at restaurants.",
+	},
+	"United Arab Emirates": {
+		Code: "AE", Currency: "AED", CurrencyName: "UAE dirham",
+		Plugs: []string{"G"}, Voltage: "230 V",
+		Emergency: "999 (police), 998 (ambulance), 997 (fire)",
+		Tipping:   "About 10% if no service charge is added.",
+	},
+	"United Kingdom": {
+		Code: "GB", Currency: "GBP", CurrencyName: "pound sterling",
+		Plugs: []string{"G"}, Voltage: "230 V",
+		Emergency: "999 or 112",
+		Tipping:   "10-12.5% at restaurants if service is not included.",
+	},
+	"United States": {
+		Code: "US", Currency: "USD", CurrencyName: "US dollar",
+		Plugs: []string{"A", "B"}, Voltage: "120 V",
+		Emergency: "911",
+		Tipping:   "15-20% at restaurants and bars, and for taxis.",
+	},
+}
+
+func init() {
+	// Keep the names in the countries too, so callers get them back.
+	for name, c := range countries {
+		c.Name = name
+		countries[name] = c
+	}
+}
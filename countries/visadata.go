@@ -0,0 +1,133 @@
+package countries
+
+// visaRule is the requirement for the passports of some countries.
+type visaRule struct {
+	passports   []string
+	requirement Requirement
+}
+
+var (
+	// eu are the countries whose citizens can live and work in each
+	// other, the EU, the EEA, and Switzerland.
+	eu = []string{"AT", "BE", "CH", "CZ", "DE", "DK", "ES", "FI", "FR", "GR", "IE", "IT", "NL", "NO", "PL", "PT", "SE"}
+
+	// visaPassports are the countries whose passports the requirements
+	// are known for.
+	visaPassports = with(eu, "AU", "CA", "GB", "JP", "NZ", "US")
+)
+
+// with returns the country codes with more added.
+func with(codes []string, more ...string) []string {
+	return append(append([]string{}, codes...), more...)
+}
+
+// except returns the country codes without some.
+func except(codes []string, without ...string) []string {
+	var out []string
+	for _, c := range codes {
+		keep := true
+		for _, w := range without {
+			if c == w {
+				keep = false
+			}
+		}
+		if keep {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// schengen are the rules to visit a country of the Schengen area.
+var schengen = []visaRule{
+	{eu, Requirement{Kind: EntryFreeMovement}},
+	{[]string{"AU", "CA", "GB", "JP", "NZ", "US"}, Requirement{Kind: EntryVisaFree, Days: 90, Note: "counting every day in the Schengen area in the last 180"}},
+}
+
+// visaRules are the rules to visit a country, by the code of the country.
+// The first rule for a passport wins.
+var visaRules = map[string][]visaRule{
+	"AT": schengen,
+	"BE": schengen,
+	"CH": schengen,
+	"CZ": schengen,
+	"DE": schengen,
+	"DK": schengen,
+	"ES": schengen,
+	"FI": schengen,
+	"FR": schengen,
+	"GR": schengen,
+	"IT": schengen,
+	"NL": schengen,
+	"NO": schengen,
+	"PL": schengen,
+	"PT": schengen,
+	"SE": schengen,
+
+	"IE": {
+		{with(eu, "GB"), Requirement{Kind: EntryFreeMovement}},
+		{visaPassports, Requirement{Kind: EntryVisaFree, Days: 90}},
+	},
+	"GB": {
+		{[]string{"IE"}, Requirement{Kind: EntryFreeMovement}},
+		{visaPassports, Requirement{Kind: EntryETA, Days: 180, Note: "a UK ETA"}},
+	},
+	"US": {
+		{[]string{"CA"}, Requirement{Kind: EntryVisaFree, Days: 180}},
+		{visaPassports, Requirement{Kind: EntryETA, Days: 90, Note: "an ESTA under the Visa Waiver Program"}},
+	},
+	"CA": {
+		{[]string{"US"}, Requirement{Kind: EntryVisaFree, Days: 180}},
+		{visaPassports, Requirement{Kind: EntryETA, Days: 180, Note: "an eTA when flying in"}},
+	},
+	"MX": {
+		{visaPassports, Requirement{Kind: EntryVisaFree, Days: 180}},
+	},
+	"AU": {
+		{[]string{"NZ"}, Requirement{Kind: EntryVisaFree}},
+		{with(eu, "GB"), Requirement{Kind: EntryETA, Days: 90, Note: "an eVisitor visa, which is free"}},
+		{visaPassports, Requirement{Kind: EntryETA, Days: 90, Note: "an Australian ETA"}},
+	},
+	"NZ": {
+		{[]string{"AU"}, Requirement{Kind: EntryVisaFree}},
+		{[]string{"GB"}, Requirement{Kind: EntryETA, Days: 180, Note: "an NZeTA"}},
+		{visaPassports, Requirement{Kind: EntryETA, Days: 90, Note: "an NZeTA"}},
+	},
+	"JP": {
+		{visaPassports, Requirement{Kind: EntryVisaFree, Days: 90}},
+	},
+	"KR": {
+		{visaPassports, Requirement{Kind: EntryETA, Days: 90, Note: "a K-ETA, unless your passport is exempt for now"}},
+	},
+	"CN": {
+		{except(with(eu, "AU", "JP", "NZ"), "CZ", "SE"), Requirement{Kind: EntryVisaFree, Days: 30}},
+		{[]string{"CA", "GB", "US"}, Requirement{Kind: EntryVisa, Note: "or visa free transit of up to 240 hours on the way to another country"}},
+	},
+	"HK": {
+		{[]string{"GB"}, Requirement{Kind: EntryVisaFree, Days: 180}},
+		{visaPassports, Requirement{Kind: EntryVisaFree, Days: 90}},
+	},
+	"SG": {
+		{visaPassports, Requirement{Kind: EntryVisaFree, Days: 30, Note: "with the SG Arrival Card filled in before arriving"}},
+	},
+	"TH": {
+		{visaPassports, Requirement{Kind: EntryVisaFree, Days: 60, Note: "with the Thailand Digital Arrival Card filled in before arriving"}},
+	},
+	"IN": {
+		{visaPassports, Requirement{Kind: EntryEVisa}},
+	},
+	"AE": {
+		{eu, Requirement{Kind: EntryOnArrival, Days: 90, Note: "which is free"}},
+		{visaPassports, Requirement{Kind: EntryOnArrival, Days: 30, Note: "which is free"}},
+	},
+	"IL": {
+		{visaPassports, Requirement{Kind: EntryETA, Days: 90, Note: "an ETA-IL"}},
+	},
+	"BR": {
+		{[]string{"AU", "CA", "US"}, Requirement{Kind: EntryEVisa, Days: 90}},
+		{visaPassports, Requirement{Kind: EntryVisaFree, Days: 90}},
+	},
+	"ZA": {
+		{visaPassports, Requirement{Kind: EntryVisaFree, Days: 90}},
+	},
+}
@@ -0,0 +1,87 @@
+package countries
+
+import "fmt"
+
+// The kinds of entry requirements, from the least to the most work.
+const (
+	// EntryFreeMovement is for citizens of the country, or of a country
+	// whose citizens can live and work there.
+	EntryFreeMovement = "free movement"
+	EntryVisaFree     = "visa free"
+	EntryOnArrival    = "visa on arrival"
+	// EntryETA is an electronic travel authorization, like an ESTA, that
+	// visa free visitors have to get before they travel.
+	EntryETA   = "electronic travel authorization"
+	EntryEVisa = "eVisa"
+	EntryVisa  = "visa"
+)
+
+// entryOrder ranks the kinds of entry requirements by how much work they
+// are.
+var entryOrder = map[string]int{
+	EntryFreeMovement: 0,
+	EntryVisaFree:     1,
+	EntryOnArrival:    2,
+	EntryETA:          3,
+	EntryEVisa:        4,
+	EntryVisa:         5,
+}
+
+// Requirement is what a passport needs to visit a country.
+type Requirement struct {
+	Kind string
+	// Days is the longest stay allowed, if there is one.
+	Days int
+	// Note is what else to know, like the name of the authorization.
+	Note string
+}
+
+// NeedsApplying returns true if the requirement has to be applied for
+// before traveling.
+func (r Requirement) NeedsApplying() bool {
+	return r.Kind == EntryETA || r.Kind == EntryEVisa || r.Kind == EntryVisa
+}
+
+// Easier returns true if the requirement is less work than the other one.
+func (r Requirement) Easier(other Requirement) bool {
+	return entryOrder[r.Kind] < entryOrder[other.Kind]
+}
+
+func (r Requirement) String() string {
+	s := r.Kind
+	if r.Days > 0 {
+		s = fmt.Sprintf("%s for up to %d days", s, r.Days)
+	}
+	if len(r.Note) > 0 {
+		s += ", " + r.Note
+	}
+	return s
+}
+
+// VisaRequirement returns what a passport of the country needs to visit the
+// destination, both named or ISO 3166 codes. It is only known for tourist
+// and business visits on the passports of the EU, the EEA, Switzerland, the
+// United Kingdom, the United States, Canada, Australia, New Zealand, and
+// Japan, and is a summary to check against the destination's government
+// before traveling.
+func VisaRequirement(passport, destination string) (Requirement, bool) {
+	p, ok := Lookup(passport)
+	if !ok {
+		return Requirement{}, false
+	}
+	d, ok := Lookup(destination)
+	if !ok {
+		return Requirement{}, false
+	}
+	if p.Code == d.Code {
+		return Requirement{Kind: EntryFreeMovement}, true
+	}
+	for _, r := range visaRules[d.Code] {
+		for _, code := range r.passports {
+			if code == p.Code {
+				return r.requirement, true
+			}
+		}
+	}
+	return Requirement{}, false
+}
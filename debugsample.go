@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// payloadSampler dumps the full payloads of a sync run at debug level, the
+// TripIt itinerary and the calendar events read and written, but only for 1
+// in every runs. The payloads of the other runs are held until the run is
+// over and only dumped if it failed, so debug logging can be left on in a
+// long running bot without writing gigabytes of logs.
+type payloadSampler struct {
+	mu       sync.Mutex
+	every    int
+	runs     int
+	skip     bool
+	buffered []string
+}
+
+// payloads samples the payloads of the sync runs of the bot.
+var payloads = &payloadSampler{}
+
+// begin starts a run. The first run is always dumped, as are the payloads
+// of commands that do not sync.
+func (s *payloadSampler) begin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skip = s.every > 1 && s.runs%s.every != 0
+	s.runs++
+	s.buffered = nil
+}
+
+// dump logs the payload v, or holds on to it until the end of the run if the
+// run is not sampled.
+func (s *payloadSampler) dump(what string, v interface{}) {
+	if logrus.GetLevel() < logrus.DebugLevel {
+		return
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		logrus.Debugf("encoding %s payload failed: %v", what, err)
+		return
+	}
+	msg := what + ": " + string(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.skip {
+		s.buffered = append(s.buffered, msg)
+		return
+	}
+	logrus.Debug(msg)
+}
+
+// end finishes the run, dumping the payloads held back if it failed.
+func (s *payloadSampler) end(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil && len(s.buffered) > 0 {
+		logrus.Debugf("sync run failed, dumping its %d payloads", len(s.buffered))
+		for _, msg := range s.buffered {
+			logrus.Debug(msg)
+		}
+	}
+	s.skip = false
+	s.buffered = nil
+}
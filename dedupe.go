@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const dedupeHelp = `Delete duplicate events for the same TripIt segment.`
+
+const dedupeLongHelp = `Delete duplicate events for the same TripIt segment.
+
+Duplicates are left behind by bugs or by running more than one bot against
+the same calendar. For each segment the event that still matches what the
+bot last wrote is kept, preferring the most recently updated one, and the
+rest are deleted.`
+
+func (cmd *dedupeCommand) Name() string      { return "dedupe" }
+func (cmd *dedupeCommand) Args() string      { return "" }
+func (cmd *dedupeCommand) ShortHelp() string { return dedupeHelp }
+func (cmd *dedupeCommand) LongHelp() string  { return dedupeLongHelp }
+func (cmd *dedupeCommand) Hidden() bool      { return false }
+
+func (cmd *dedupeCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "Only report the duplicates, do not delete them (also set by the global --dry-run)")
+}
+
+type dedupeCommand struct {
+	dryRun bool
+}
+
+func (cmd *dedupeCommand) Run(ctx context.Context, args []string) error {
+	backend := getPrimaryBackend(ctx)
+
+	cmd.dryRun = cmd.dryRun || dryRun
+
+	events, err := listAllEvents(ctx, backend)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "SEGMENT\tACTION\tEVENT\tUPDATED\tSUMMARY")
+
+	var deleted int
+	for _, group := range findDuplicateEvents(events) {
+		for i, e := range group {
+			action := "keep"
+			if i > 0 {
+				action = "delete"
+				if !cmd.dryRun {
+					if err := backend.Delete(ctx, privateProperty(e, propertySegmentID), e.Id); err != nil {
+						return fmt.Errorf("deleting duplicate event %s failed: %v", e.Id, err)
+					}
+					deleted++
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", privateProperty(e, propertySegmentID), action, e.Id, e.Updated, e.Summary)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if cmd.dryRun {
+		fmt.Println("Dry run, no events were deleted.")
+		return nil
+	}
+	fmt.Printf("Deleted %d duplicate events.\n", deleted)
+	return nil
+}
+
+// findDuplicateEvents groups the events by their TripIt segment ID and returns
+// the groups with more than one event. The event to keep is first in each group.
+func findDuplicateEvents(events []*calendar.Event) [][]*calendar.Event {
+	bySegment := map[string][]*calendar.Event{}
+	var segments []string
+	for _, e := range events {
+		id := privateProperty(e, propertySegmentID)
+		if len(id) < 1 {
+			continue
+		}
+		if _, ok := bySegment[id]; !ok {
+			segments = append(segments, id)
+		}
+		bySegment[id] = append(bySegment[id], e)
+	}
+
+	var groups [][]*calendar.Event
+	for _, id := range segments {
+		group := bySegment[id]
+		if len(group) < 2 {
+			continue
+		}
+
+		sort.SliceStable(group, func(i, j int) bool {
+			// Prefer events whose content still matches what we wrote.
+			iok := privateProperty(group[i], propertyHash) == eventHash(group[i])
+			jok := privateProperty(group[j], propertyHash) == eventHash(group[j])
+			if iok != jok {
+				return iok
+			}
+			// Then the most recently updated event. The timestamps are
+			// RFC3339 in UTC so they sort as strings.
+			return group[i].Updated > group[j].Updated
+		})
+		groups = append(groups, group)
+	}
+
+	return groups
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// memoryBackend is a calendar that only lives in memory, for --demo.
+type memoryBackend struct {
+	mu     sync.Mutex
+	events []*calendar.Event
+	nextID int
+}
+
+func (m *memoryBackend) String() string {
+	return "the demo calendar"
+}
+
+// List returns the events in the calendar.
+func (m *memoryBackend) List(ctx context.Context) ([]*calendar.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := make([]*calendar.Event, 0, len(m.events))
+	for _, e := range m.events {
+		copied := *e
+		events = append(events, &copied)
+	}
+	return events, nil
+}
+
+// Create adds the event with the next free ID.
+func (m *memoryBackend) Create(ctx context.Context, key string, e *calendar.Event) (*calendar.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	created := *e
+	created.Id = fmt.Sprintf("demo%d", m.nextID)
+	m.events = append(m.events, &created)
+	return &created, nil
+}
+
+// Update sets the fields in the patch on the event.
+func (m *memoryBackend) Update(ctx context.Context, key, id string, patch *calendar.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, e := range m.events {
+		if e.Id == id {
+			m.events[i] = mergeEventPatch(e, patch)
+			return nil
+		}
+	}
+	return fmt.Errorf("updating demo event %s failed: the event does not exist", id)
+}
+
+// Delete removes the event.
+func (m *memoryBackend) Delete(ctx context.Context, key, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, e := range m.events {
+		if e.Id == id {
+			m.events = append(m.events[:i], m.events[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// runDemo syncs the made-up itinerary as it was a week ago to a calendar in
+// memory, then prints to w what syncing the itinerary as it is now would
+// change, like --dry-run does. Nothing is read from TripIt or written to a
+// calendar.
+func runDemo(ctx context.Context, w io.Writer) error {
+	now := time.Now()
+	backend := &memoryBackend{}
+	st := newState("")
+
+	// The first sync is only there to fill the calendar, so keep it quiet.
+	level := logrus.GetLevel()
+	logrus.SetLevel(logrus.WarnLevel)
+	writePhase(ctx, backend, st, nil, responseEvents(demoItinerary(now, false)), nil, true, newSyncResult())
+	logrus.SetLevel(level)
+
+	trips := responseEvents(demoItinerary(now, true))
+	sort.SliceStable(trips, func(i, j int) bool {
+		return eventTime(trips[i].Start).Before(eventTime(trips[j].Start))
+	})
+
+	fmt.Fprintln(w, "This is a demo with made-up trips, nothing is read from TripIt or written to")
+	fmt.Fprintln(w, "a calendar. The demo calendar has the itinerary as it was synced a week ago.")
+	fmt.Fprintln(w, "Since then a connection was moved, a hotel was booked, and a flight was")
+	fmt.Fprintln(w, "cancelled.")
+	fmt.Fprintln(w)
+	return dryRunState(ctx, w, backend, st, trips, true)
+}
+
+// demoItinerary returns a made-up TripIt itinerary, with a business trip in
+// two weeks and a weekend away in five, as it is now or as it was before the
+// last changes to it.
+func demoItinerary(now time.Time, current bool) *tripit.Response {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	munich, portland := today.AddDate(0, 0, 14), today.AddDate(0, 0, 35)
+	date := func(day time.Time, days int) string {
+		return day.AddDate(0, 0, days).Format("2006-01-02")
+	}
+
+	// The connection to Munich was moved an hour later.
+	connection := "10:35"
+	if !current {
+		connection = "09:35"
+	}
+
+	resp := &tripit.Response{
+		Trips: tripit.Trips{
+			{
+				ID:              "demo-munich",
+				DisplayName:     "Conference in Munich",
+				StartDate:       date(munich, 0),
+				EndDate:         date(munich, 5),
+				PrimaryLocation: "Munich, Germany",
+				Purposes:        tripit.TripPurposes{PurposeTypeCode: "B"},
+			},
+			{
+				ID:              "demo-portland",
+				DisplayName:     "Weekend in Portland",
+				StartDate:       date(portland, 0),
+				EndDate:         date(portland, 2),
+				PrimaryLocation: "Portland, OR",
+				Purposes:        tripit.TripPurposes{PurposeTypeCode: "L"},
+			},
+		},
+		Flights: tripit.Flights{
+			{
+				ID:              "demo-munich-air",
+				TripID:          "demo-munich",
+				SupplierName:    "Lufthansa",
+				SupplierConfNum: "LH4K2Q",
+				Segments: tripit.FlightSegments{
+					demoSegment("demo-1", "LH", "Lufthansa", "455", "SFO", "San Francisco", "America/Los_Angeles", "FRA", "Frankfurt", "Europe/Berlin", munich, "15:40", 1, "11:20"),
+					demoSegment("demo-2", "LH", "Lufthansa", "100", "FRA", "Frankfurt", "Europe/Berlin", "MUC", "Munich", "Europe/Berlin", munich.AddDate(0, 0, 1), connection, 0, "11:45"),
+					demoSegment("demo-3", "LH", "Lufthansa", "99", "MUC", "Munich", "Europe/Berlin", "FRA", "Frankfurt", "Europe/Berlin", munich.AddDate(0, 0, 5), "07:00", 0, "08:10"),
+					demoSegment("demo-4", "LH", "Lufthansa", "454", "FRA", "Frankfurt", "Europe/Berlin", "SFO", "San Francisco", "America/Los_Angeles", munich.AddDate(0, 0, 5), "09:55", 0, "12:35"),
+				},
+			},
+			{
+				ID:              "demo-portland-air",
+				TripID:          "demo-portland",
+				SupplierName:    "Alaska Airlines",
+				SupplierConfNum: "AS7PDX",
+				Segments: tripit.FlightSegments{
+					demoSegment("demo-5", "AS", "Alaska Airlines", "1311", "SFO", "San Francisco", "America/Los_Angeles", "PDX", "Portland", "America/Los_Angeles", portland, "17:30", 0, "19:25"),
+					demoSegment("demo-6", "AS", "Alaska Airlines", "1320", "PDX", "Portland", "America/Los_Angeles", "SFO", "San Francisco", "America/Los_Angeles", portland.AddDate(0, 0, 2), "18:10", 0, "20:05"),
+				},
+			},
+		},
+	}
+
+	if current {
+		// The hotel was booked since.
+		resp.Lodging = tripit.Lodges{{
+			ID:              "demo-7",
+			TripID:          "demo-munich",
+			DisplayName:     "Hotel Bayerischer Hof",
+			SupplierName:    "Hotel Bayerischer Hof",
+			SupplierConfNum: "BH-20419",
+			StartDateTime:   tripit.DateTime{Date: date(munich, 1), Time: "15:00:00"},
+			EndDateTime:     tripit.DateTime{Date: date(munich, 5), Time: "11:00:00"},
+			Address:         tripit.Address{Address: "Promenadeplatz 2-6, 80333 Munich, Germany"},
+		}}
+		// And the flight home from Portland was cancelled.
+		resp.Flights[1].Segments[1].Status.FlightStatus = tripit.FlightStatusCancelled
+	}
+	return resp
+}
+
+// demoSegment returns a made-up flight segment departing on the day at the
+// clock time where it departs, arriving days later at the clock time where it
+// arrives.
+func demoSegment(id, airlineCode, airline, number, from, fromCity, fromZone, to, toCity, toZone string, day time.Time, departs string, days int, arrives string) tripit.FlightSegment {
+	return tripit.FlightSegment{
+		ID:                    id,
+		StartDateTime:         demoDateTime(day, departs, fromZone),
+		EndDateTime:           demoDateTime(day.AddDate(0, 0, days), arrives, toZone),
+		StartAirportCode:      from,
+		StartCityName:         fromCity,
+		EndAirportCode:        to,
+		EndCityName:           toCity,
+		MarketingAirline:      airline,
+		MarketingAirlineCode:  airlineCode,
+		MarketingFlightNumber: number,
+	}
+}
+
+// demoDateTime returns the TripIt date and time of the clock time on the day
+// in the timezone, or in UTC if the timezone database is not available.
+func demoDateTime(day time.Time, clock, zone string) tripit.DateTime {
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		loc = time.UTC
+	}
+	t, _ := time.ParseInLocation("2006-01-02 15:04", day.Format("2006-01-02")+" "+clock, loc)
+	return tripit.DateTime{
+		Date:      t.Format("2006-01-02"),
+		Time:      t.Format("15:04:05"),
+		Timezone:  loc.String(),
+		UTCOffset: t.Format("-07:00"),
+	}
+}
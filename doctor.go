@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/oauth2/google"
+	calendar "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// Statuses of a privilege.
+const (
+	privilegeOK        = "ok"
+	privilegeOverBroad = "over-broad"
+	privilegeMissing   = "missing"
+	privilegeUnknown   = "unknown"
+)
+
+// calendarEventsScope lets a token see and change the events of the
+// calendars it has access to, but not the calendars themselves or who they
+// are shared with. The calendar API package we use predates it.
+const calendarEventsScope = "https://www.googleapis.com/auth/calendar.events"
+
+// googleScopes returns the OAuth scopes the bot asks Google for. Everything
+// it does goes through the events of the calendars it syncs to.
+func googleScopes() []string {
+	return []string{calendarEventsScope}
+}
+
+// privilege is what a credential can do compared to what the enabled
+// features need.
+type privilege struct {
+	Credential string `json:"credential"`
+	Has        string `json:"has"`
+	Needs      string `json:"needs"`
+	Status     string `json:"status"`
+	Note       string `json:"note,omitempty"`
+}
+
+// checkPrivileges returns what the configured credentials can do compared to
+// what the enabled features need.
+func checkPrivileges(ctx context.Context) []privilege {
+	var privileges []privilege
+
+	privileges = append(privileges, privilege{
+		Credential: "tripit",
+		Has:        "full account",
+		Needs:      "read trips",
+		Status:     privilegeOK,
+		Note:       "TripIt has no scoped credentials, use an account that only has the trips you want synced",
+	})
+
+	privileges = append(privileges, googlePrivileges(ctx)...)
+
+	if len(slackToken) > 0 {
+		privileges = append(privileges, slackPrivilege(ctx))
+	}
+
+	return privileges
+}
+
+// googlePrivileges checks the scopes of the Google token, and the access the
+// service account has to calendars.
+func googlePrivileges(ctx context.Context) []privilege {
+	data, err := readGoogleKeyfile()
+	if err != nil {
+		return []privilege{{Credential: "google", Status: privilegeUnknown, Note: err.Error()}}
+	}
+	defer zero(data)
+
+	var privileges []privilege
+
+	// The scopes the token the bot uses was granted.
+	needs := googleScopes()
+	p := privilege{Credential: "google scopes", Needs: strings.Join(shortScopes(needs), " ")}
+	scopes, err := googleTokenScopes(ctx, data, needs)
+	if err != nil {
+		p.Status = privilegeUnknown
+		p.Note = err.Error()
+	} else {
+		p.Has = strings.Join(shortScopes(scopes), " ")
+		p.Status, p.Note = compareScopes(scopes, needs)
+	}
+	privileges = append(privileges, p)
+
+	// The calendars the service account can get to. The calendar list needs
+	// a read-only token of its own, which is only used here.
+	conf, err := google.JWTConfigFromJSON(data, calendar.CalendarReadonlyScope)
+	if err != nil {
+		return append(privileges, privilege{Credential: "google calendars", Status: privilegeUnknown, Note: err.Error()})
+	}
+	gcal, err := calendar.New(conf.Client(ctx))
+	if err != nil {
+		return append(privileges, privilege{Credential: "google calendars", Status: privilegeUnknown, Note: err.Error()})
+	}
+
+	wanted := map[string]bool{}
+	for _, id := range []string{calendarName, archiveCalendar} {
+		if len(id) > 0 {
+			wanted[id] = true
+		}
+	}
+
+	roles := map[string]string{}
+	if err := gcal.CalendarList.List().Pages(ctx, func(list *calendar.CalendarList) error {
+		for _, c := range list.Items {
+			roles[c.Id] = c.AccessRole
+		}
+		return nil
+	}); err != nil {
+		return append(privileges, privilege{Credential: "google calendars", Status: privilegeUnknown, Note: fmt.Sprintf("listing calendars failed: %v", err)})
+	}
+	for id := range wanted {
+		if _, ok := roles[id]; ok {
+			continue
+		}
+		// Calendars shared with a service account are not always in
+		// its calendar list, look them up one by one.
+		c, err := gcal.CalendarList.Get(id).Context(ctx).Do()
+		if err != nil {
+			if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusNotFound {
+				roles[id] = ""
+				continue
+			}
+			roles[id] = "?"
+			continue
+		}
+		roles[id] = c.AccessRole
+	}
+
+	var ids []string
+	for id := range roles {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		role := roles[id]
+		p := privilege{Credential: "calendar " + id, Has: role}
+		if wanted[id] {
+			p.Needs = "writer"
+		}
+		switch {
+		case role == "?":
+			p.Has = ""
+			p.Status = privilegeUnknown
+			p.Note = "the access role of the calendar could not be read"
+		case wanted[id] && role == "owner":
+			p.Status = privilegeOverBroad
+			p.Note = "owner can change who the calendar is shared with and delete it, share it with make changes to events instead"
+		case wanted[id] && role == "writer":
+			p.Status = privilegeOK
+		case wanted[id]:
+			p.Status = privilegeMissing
+			p.Note = "share the calendar with the service account with make changes to events"
+			if len(role) < 1 {
+				p.Note = "the calendar is not shared with the service account, " + p.Note
+			}
+		case id == googleServiceAccount(data):
+			// The service account's own primary calendar.
+			p.Status = privilegeOK
+			p.Note = "the service account's own calendar"
+		default:
+			p.Needs = "none"
+			p.Status = privilegeOverBroad
+			p.Note = "not used by any enabled feature, stop sharing it with the service account"
+		}
+		privileges = append(privileges, p)
+	}
+
+	return privileges
+}
+
+// googleTokenScopes returns the scopes Google granted a token asked for with
+// the given scopes.
+func googleTokenScopes(ctx context.Context, data []byte, scopes []string) ([]string, error) {
+	conf, err := google.JWTConfigFromJSON(data, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("reading google keyfile failed: %v", err)
+	}
+	token, err := conf.TokenSource(ctx).Token()
+	if err != nil {
+		return nil, fmt.Errorf("getting a google token failed: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://oauth2.googleapis.com/tokeninfo?access_token="+url.QueryEscape(token.AccessToken), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("getting google token info failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting google token info failed: %s", resp.Status)
+	}
+	var info struct {
+		Scope string `json:"scope"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("decoding google token info failed: %v", err)
+	}
+	return strings.Fields(info.Scope), nil
+}
+
+// googleServiceAccount returns the email of the service account in the
+// keyfile.
+func googleServiceAccount(data []byte) string {
+	var key struct {
+		ClientEmail string `json:"client_email"`
+	}
+	json.Unmarshal(data, &key)
+	return key.ClientEmail
+}
+
+// slackPrivilege checks the scopes of the Slack token.
+func slackPrivilege(ctx context.Context) privilege {
+	needs := []string{"users.profile:read", "users.profile:write"}
+	p := privilege{Credential: "slack", Needs: strings.Join(needs, " ")}
+
+	req, err := http.NewRequest(http.MethodGet, slackAPI+"/auth.test", nil)
+	if err != nil {
+		p.Status, p.Note = privilegeUnknown, err.Error()
+		return p
+	}
+	req.Header.Set("Authorization", "Bearer "+slackToken)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		p.Status, p.Note = privilegeUnknown, fmt.Sprintf("calling slack auth.test failed: %v", err)
+		return p
+	}
+	resp.Body.Close()
+
+	var scopes []string
+	for _, s := range strings.Split(resp.Header.Get("X-OAuth-Scopes"), ",") {
+		if s = strings.TrimSpace(s); len(s) > 0 {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) < 1 {
+		p.Status, p.Note = privilegeUnknown, "slack did not say what scopes the token has"
+		return p
+	}
+	p.Has = strings.Join(scopes, " ")
+	p.Status, p.Note = compareScopes(scopes, needs)
+	return p
+}
+
+// compareScopes returns the status of a credential with the scopes it has
+// and the note to show with it.
+func compareScopes(has, needs []string) (string, string) {
+	var missing, extra []string
+	for _, s := range needs {
+		if !containsString(has, s) {
+			missing = append(missing, s)
+		}
+	}
+	for _, s := range has {
+		if !containsString(needs, s) {
+			extra = append(extra, s)
+		}
+	}
+
+	switch {
+	case len(missing) > 0:
+		return privilegeMissing, "missing " + strings.Join(shortScopes(missing), ", ")
+	case len(extra) > 0:
+		return privilegeOverBroad, "not needed by any enabled feature: " + strings.Join(shortScopes(extra), ", ")
+	}
+	return privilegeOK, ""
+}
+
+// shortScopes trims the Google API prefix off the scopes, so they fit in a
+// table.
+func shortScopes(scopes []string) []string {
+	var out []string
+	for _, s := range scopes {
+		out = append(out, strings.TrimPrefix(s, "https://www.googleapis.com/auth/"))
+	}
+	return out
+}
+
+const doctorHelp = `Check that the bot is set up right.`
+
+const doctorLongHelp = `Check that the bot is set up right.
+
+Checks that the TripIt and Google credentials work and that the bot can get
+to the calendar. With --privileges it also reports what the credentials can
+do compared to what the enabled features need, flagging grants that are
+broader than they have to be, like owning a calendar when making changes to
+its events is enough, or calendars shared with the bot that it does not use.
+
+Exits non-zero if a check fails or a privilege is missing, but not for
+over-broad grants.`
+
+func (cmd *doctorCommand) Name() string      { return "doctor" }
+func (cmd *doctorCommand) Args() string      { return "" }
+func (cmd *doctorCommand) ShortHelp() string { return doctorHelp }
+func (cmd *doctorCommand) LongHelp() string  { return doctorLongHelp }
+func (cmd *doctorCommand) Hidden() bool      { return false }
+
+func (cmd *doctorCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.privileges, "privileges", false, "Report what the credentials can do compared to what the enabled features need")
+}
+
+type doctorCommand struct {
+	privileges bool
+}
+
+func (cmd *doctorCommand) Run(ctx context.Context, args []string) error {
+	tripitClient, err := newTripItClient()
+	if err != nil {
+		fatal(exitCodeConfig, err)
+	}
+	if err := validateCalendarFlags(); err != nil {
+		fatal(exitCodeConfig, err)
+	}
+	backends, err := getCalendarBackends(ctx)
+	if err != nil {
+		fatal(exitCodeCalendarAuth, err)
+	}
+
+	code, checkErr := preflight(ctx, tripitClient, backends)
+
+	var privileges []privilege
+	if cmd.privileges {
+		privileges = checkPrivileges(ctx)
+	}
+
+	if output == "json" {
+		result := struct {
+			OK         bool        `json:"ok"`
+			Error      string      `json:"error,omitempty"`
+			Privileges []privilege `json:"privileges,omitempty"`
+		}{OK: checkErr == nil, Privileges: privileges}
+		if checkErr != nil {
+			result.Error = checkErr.Error()
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		if checkErr != nil {
+			fmt.Printf("Credentials: %v\n", checkErr)
+		} else {
+			fmt.Println("Credentials: ok")
+		}
+		if cmd.privileges {
+			fmt.Println()
+			w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			fmt.Fprintln(w, "CREDENTIAL\tHAS\tNEEDS\tSTATUS\tNOTE")
+			for _, p := range privileges {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.Credential, p.Has, p.Needs, p.Status, p.Note)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if checkErr != nil {
+		fatal(code, checkErr)
+	}
+	for _, p := range privileges {
+		if p.Status == privilegeMissing {
+			return errors.New("some credentials are missing privileges the enabled features need")
+		}
+	}
+	return nil
+}
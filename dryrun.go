@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// dryRunSync fetches the itinerary from TripIt and prints what a sync would
+// change in each calendar to w, without writing to the calendars or their
+// state.
+func dryRunSync(ctx context.Context, w io.Writer, tripitClient *tripit.Client, backends []calendarBackend, pastFilter string) error {
+	trips, truncated, err := getTripItEvents(ctx, tripitClient, pastFilter)
+	if err != nil {
+		return fmt.Errorf("getting tripit events failed: %v", err)
+	}
+	if approve {
+		// Only the approved changes would be written. The state is not
+		// saved, so nothing is proposed.
+		st, err := loadState(stateFile)
+		if err != nil {
+			return err
+		}
+		trips, _ = gateChanges(st, trips, time.Now())
+	}
+	sort.SliceStable(trips, func(i, j int) bool {
+		return eventTime(trips[i].Start).Before(eventTime(trips[j].Start))
+	})
+
+	for i, backend := range backends {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if err := dryRunBackend(ctx, w, backend, backendStateFile(i), trips, !truncated); err != nil {
+			return err
+		}
+	}
+
+	if len(companionCalendar) > 0 {
+		backend, err := getCompanionBackend(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+		return dryRunBackend(ctx, w, backend, companionStateFile(), companionEvents(trips), !truncated)
+	}
+	return nil
+}
+
+// dryRunBackend prints what a sync would change in the calendar, with the
+// state in the file at path, to w.
+func dryRunBackend(ctx context.Context, w io.Writer, backend calendarBackend, path string, trips []tripit.Event, complete bool) error {
+	st, err := loadState(path)
+	if err != nil {
+		return err
+	}
+	return dryRunState(ctx, w, backend, st, trips, complete)
+}
+
+// dryRunState prints what a sync would change in the calendar, with the
+// state st, to w. Like writePhase, it only counts segments missing from the
+// trips towards their removal if the trips are complete.
+func dryRunState(ctx context.Context, w io.Writer, backend calendarBackend, st *syncState, trips []tripit.Event, complete bool) error {
+	existing, err := backend.List(ctx)
+	if err != nil {
+		return err
+	}
+	google, _ := backend.(*googleBackend)
+	if google != nil {
+		existing, err = addLegacyEvents(ctx, google.service, google.calendarID, existing, trips)
+		if err != nil {
+			return err
+		}
+	}
+
+	remove := "- delete"
+	if cancelledEvents == cancelMark {
+		remove = "- cancel"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 1, 2, ' ', 0)
+	fmt.Fprintf(w, "Dry run against %s, nothing is written.\n\n", backend)
+
+	var created, updated, removed int
+	present := map[string]bool{}
+	for _, trip := range trips {
+		present[trip.SegmentID] = true
+		if !trip.Confirmed() || st.archived(trip.SegmentID) {
+			continue
+		}
+		when := dryRunTime(trip.Start)
+
+		if trip.Status == tripit.FlightStatusCancelled {
+			matching := findMatchingEvent(existing, trip.SegmentID)
+			if matching == nil || privateProperty(matching, propertyManaged) == "false" {
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s (cancelled in TripIt)\n", remove, when, trip.Title)
+			removed++
+			continue
+		}
+
+		action, event, matching, err := planEvent(existing, trip)
+		if err != nil {
+			fmt.Fprintf(tw, "! error\t%s\t%s: %v\n", when, trip.Title, err)
+			continue
+		}
+		switch action {
+		case syncCreated:
+			fmt.Fprintf(tw, "+ create\t%s\t%s\n", when, trip.Title)
+			created++
+		case syncUpdated:
+			fmt.Fprintf(tw, "~ update\t%s\t%s\n", when, trip.Title)
+			for _, change := range eventChanges(matching, event) {
+				fmt.Fprintf(tw, "\t\t    %s\n", change)
+			}
+			updated++
+		}
+	}
+
+	// Segments gone from TripIt count down to their removal on every run.
+	var gone []*stateEvent
+	for _, se := range st.Events {
+		if !complete || present[se.SegmentID] || se.Archived || se.End.IsZero() || se.End.Before(time.Now()) {
+			continue
+		}
+		gone = append(gone, se)
+	}
+	sort.Slice(gone, func(i, j int) bool { return gone[i].Start.Before(gone[j].Start) })
+	for _, se := range gone {
+		when := se.Start.Format("2006-01-02 15:04")
+		if left := removalGraceRuns - se.Missing - 1; left > 0 {
+			fmt.Fprintf(tw, "  missing\t%s\t%s (gone from TripIt, removed after %d more runs)\n", when, se.Title, left)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s (gone from TripIt)\n", remove, when, se.Title)
+		removed++
+	}
+
+	if retentionYears > 0 && google != nil {
+		pruned, err := pruneOldEvents(ctx, google.service, st, retentionCutoff(time.Now()), true)
+		if err != nil {
+			return err
+		}
+		for _, e := range pruned {
+			fmt.Fprintf(tw, "- delete\t%s\t%s (older than the retention period)\n", dryRunTime(*e.Start), e.Summary)
+			removed++
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if created+updated+removed > 0 {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d to create, %d to update, %d to remove.\n", created, updated, removed)
+	return nil
+}
+
+// eventChanges describes how updating the calendar event from old to updated
+// changes it.
+func eventChanges(old, updated *calendar.Event) []string {
+	var changes []string
+	change := func(field, a, b string) {
+		if a != b {
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", field, a, b))
+		}
+	}
+	change("title", old.Summary, updated.Summary)
+	if !sameEventDateTime(old.Start, updated.Start) {
+		change("start", formatEventDateTime(old.Start), formatEventDateTime(updated.Start))
+	}
+	if !sameEventDateTime(old.End, updated.End) {
+		change("end", formatEventDateTime(old.End), formatEventDateTime(updated.End))
+	}
+	change("location", old.Location, updated.Location)
+	change("visibility", old.Visibility, updated.Visibility)
+	change("transparency", old.Transparency, updated.Transparency)
+	if stripFooter(old.Description) != stripFooter(updated.Description) {
+		changes = append(changes, "description changed")
+	}
+	if len(changes) < 1 {
+		// The hash differs but none of the fields we show do, like the
+		// tags or the source.
+		changes = append(changes, "details changed")
+	}
+	return changes
+}
+
+// sameEventDateTime returns true if a and b are the same date, or the same
+// time in the same timezone. Google returns times in the calendar's offset,
+// so they are compared as instants.
+func sameEventDateTime(a, b *calendar.EventDateTime) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Date == b.Date && a.TimeZone == b.TimeZone && eventTime(*a).Equal(eventTime(*b))
+}
+
+// formatEventDateTime formats the start or end of a calendar event, which is
+// a date for all-day events.
+func formatEventDateTime(t *calendar.EventDateTime) string {
+	if t == nil {
+		return ""
+	}
+	if len(t.Date) > 0 {
+		return t.Date
+	}
+	return formatZoned(*t)
+}
+
+// dryRunTime returns when the event starts, as its date for all-day events
+// like hotel stays, which have no time.
+func dryRunTime(t calendar.EventDateTime) string {
+	if len(t.Date) > 0 {
+		return t.Date
+	}
+	return eventTime(t).Format("2006-01-02 15:04")
+}
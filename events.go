@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const eventsHelp = `List the events the bot manages in the calendar.`
+
+const eventsLongHelp = `List the events the bot manages in the calendar.
+
+Pass --on to only list the events on at some point then, said the same way as
+trips list --when: today, friday, next week, this month, in 2 weeks, the next
+10 days, july, 2024-07, or a range like 2024-07-01..2024-07-15.
+
+  tripitcalb0t events list --on friday
+
+With --backends, this lists the events in the first calendar.`
+
+func (cmd *eventsCommand) Name() string      { return "events" }
+func (cmd *eventsCommand) Args() string      { return "list" }
+func (cmd *eventsCommand) ShortHelp() string { return eventsHelp }
+func (cmd *eventsCommand) LongHelp() string  { return eventsLongHelp }
+func (cmd *eventsCommand) Hidden() bool      { return false }
+
+func (cmd *eventsCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.on, "on", "", "Only list the events on then (ex. friday, next week, july, 2024-07-01..2024-07-15)")
+}
+
+type eventsCommand struct {
+	on string
+}
+
+func (cmd *eventsCommand) Run(ctx context.Context, args []string) error {
+	if len(args) < 1 || args[0] != "list" {
+		return errors.New("usage: events list")
+	}
+
+	// Flags may also come after the subcommand.
+	fs := flag.NewFlagSet("events list", flag.ExitOnError)
+	fs.StringVar(&cmd.on, "on", cmd.on, "")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var from, to time.Time
+	if len(cmd.on) > 0 {
+		var err error
+		from, to, err = parseWhen(cmd.on, time.Now())
+		if err != nil {
+			fatal(exitCodeConfig, err)
+		}
+	}
+
+	backend := getPrimaryBackend(ctx)
+	events, err := listAllEvents(ctx, backend)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(*events[i].Start).Before(eventTime(*events[j].Start))
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "SEGMENT\tSTART\tEND\tTITLE\tLOCATION")
+	for _, e := range events {
+		if e.Start == nil || e.End == nil {
+			continue
+		}
+		if len(cmd.on) > 0 && !eventDuring(e, from, to) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", privateProperty(e, propertySegmentID), formatEventTime(*e.Start), formatEventTime(*e.End), e.Summary, e.Location)
+	}
+	return w.Flush()
+}
+
+// eventDuring returns true if the event is on at some point from from until
+// to.
+func eventDuring(e *calendar.Event, from, to time.Time) bool {
+	if len(e.Start.Date) > 0 {
+		// All-day events are plain dates, ending the day after the
+		// last, so compare them as strings.
+		return e.Start.Date < to.Format("2006-01-02") && e.End.Date > from.Format("2006-01-02")
+	}
+	return eventTime(*e.Start).Before(to) && eventTime(*e.End).After(from)
+}
+
+// formatEventTime returns the date of an all-day event start or end, or the
+// local time of any other.
+func formatEventTime(t calendar.EventDateTime) string {
+	if len(t.Date) > 0 {
+		return t.Date
+	}
+	return eventTime(t).Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+func TestEventDuring(t *testing.T) {
+	// Friday.
+	from := time.Date(2024, time.July, 12, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+
+	testcases := []struct {
+		name  string
+		start calendar.EventDateTime
+		end   calendar.EventDateTime
+		want  bool
+	}{
+		{name: "flight that day", start: calendar.EventDateTime{DateTime: "2024-07-12T09:00:00Z"}, end: calendar.EventDateTime{DateTime: "2024-07-12T11:00:00Z"}, want: true},
+		{name: "overnight flight into the day", start: calendar.EventDateTime{DateTime: "2024-07-11T22:00:00Z"}, end: calendar.EventDateTime{DateTime: "2024-07-12T06:00:00Z"}, want: true},
+		{name: "flight landing at midnight", start: calendar.EventDateTime{DateTime: "2024-07-11T20:00:00Z"}, end: calendar.EventDateTime{DateTime: "2024-07-12T00:00:00Z"}, want: false},
+		{name: "flight the next day", start: calendar.EventDateTime{DateTime: "2024-07-13T00:00:00Z"}, end: calendar.EventDateTime{DateTime: "2024-07-13T02:00:00Z"}, want: false},
+		{name: "stay over the day", start: calendar.EventDateTime{Date: "2024-07-10"}, end: calendar.EventDateTime{Date: "2024-07-14"}, want: true},
+		{name: "stay ending the day before", start: calendar.EventDateTime{Date: "2024-07-08"}, end: calendar.EventDateTime{Date: "2024-07-12"}, want: false},
+		{name: "stay starting the day after", start: calendar.EventDateTime{Date: "2024-07-13"}, end: calendar.EventDateTime{Date: "2024-07-15"}, want: false},
+	}
+
+	for _, tc := range testcases {
+		e := &calendar.Event{Start: &tc.start, End: &tc.end}
+		if got := eventDuring(e, from, to); got != tc.want {
+			t.Errorf("%s: eventDuring = %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+)
+
+// Exit codes so wrapper scripts and cron can branch on the class of failure.
+const (
+	// exitCodeError is the exit code for any other error.
+	exitCodeError = 1
+	// exitCodeConfig is the exit code for configuration errors.
+	exitCodeConfig = 2
+	// exitCodeTripItAuth is the exit code for TripIt authentication errors.
+	exitCodeTripItAuth = 3
+	// exitCodeCalendarAuth is the exit code for errors setting up or
+	// authenticating to any calendar we sync to, Google, CalDAV, or
+	// Outlook.
+	exitCodeCalendarAuth = 4
+	// exitCodePartialSync is the exit code when some events failed to sync.
+	exitCodePartialSync = 5
+)
+
+// partialSyncError is returned from a run when some of the events failed to
+// sync but the rest were synced.
+type partialSyncError struct {
+	failed int
+	total  int
+}
+
+func (e *partialSyncError) Error() string {
+	return fmt.Sprintf("%d of %d events failed to sync", e.failed, e.total)
+}
+
+// runTimeoutError is returned from a run that did not finish within the run timeout.
+type runTimeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e *runTimeoutError) Error() string {
+	return fmt.Sprintf("sync run did not finish within %s: %v", e.timeout, e.err)
+}
+
+// fatal logs the error and exits with the given exit code.
+func fatal(code int, err error) {
+	logrus.Error(err)
+	os.Exit(code)
+}
+
+// exitCodeForSyncError returns the exit code for an error returned from a run.
+func exitCodeForSyncError(err error) int {
+	if _, ok := err.(*partialSyncError); ok {
+		return exitCodePartialSync
+	}
+	return exitCodeError
+}
+
+// preflight checks that our TripIt credentials and those of every calendar
+// we sync to work. It returns the exit code for the class of failure if they
+// do not.
+func preflight(ctx context.Context, tripitClient *tripit.Client, backends []calendarBackend) (int, error) {
+	// Ask TripIt for the smallest page of trips it will give us.
+	if _, err := tripitClient.ListTrips(ctx, tripit.Filter{
+		Type:  tripit.FilterPageSize,
+		Value: "1",
+	}); err != nil {
+		if e, ok := err.(*tripit.APIError); ok && (e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden) {
+			return exitCodeTripItAuth, fmt.Errorf("checking tripit credentials failed: %v", err)
+		}
+		return exitCodeError, fmt.Errorf("checking tripit credentials failed: %v", err)
+	}
+
+	for _, backend := range backends {
+		switch b := backend.(type) {
+		case *googleBackend:
+			// Read a single event from the calendar, which checks
+			// both that our credentials work and that we have access
+			// to the calendar.
+			if _, err := b.service.Events.List(b.calendarID).MaxResults(1).Context(ctx).Do(); err != nil {
+				return exitCodeCalendarAuth, fmt.Errorf("checking google calendar credentials for calendar %s failed: %v", b.calendarID, err)
+			}
+		case *caldavBackend:
+			if err := b.check(ctx); err != nil {
+				return exitCodeCalendarAuth, fmt.Errorf("checking caldav credentials for %s failed: %v", b.url, err)
+			}
+		case *graphBackend:
+			if err := b.check(ctx); err != nil {
+				return exitCodeCalendarAuth, fmt.Errorf("checking outlook credentials for %s failed: %v", b, err)
+			}
+		case *icsFileBackend:
+			if err := b.check(); err != nil {
+				return exitCodeConfig, err
+			}
+		}
+	}
+
+	return 0, nil
+}
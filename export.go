@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const (
+	// icsTimeFormat is the format of UTC times in iCalendar files.
+	icsTimeFormat = "20060102T150405Z"
+	// icsDateFormat is the format of dates in iCalendar files.
+	icsDateFormat = "20060102"
+)
+
+// writeICS writes the events to w as an iCalendar file with the given name.
+func writeICS(w io.Writer, name string, events []*calendar.Event, now time.Time) error {
+	vevents := make([][]string, 0, len(events))
+	for _, e := range events {
+		vevents = append(vevents, veventLines(e, now))
+	}
+	return writeVCalendar(w, name, vevents)
+}
+
+// writeVCalendar writes the VEVENTs, as content lines, to w as an iCalendar
+// file with the given name.
+func writeVCalendar(w io.Writer, name string, vevents [][]string) error {
+	bw := bufio.NewWriter(w)
+	line := func(s string) {
+		bw.WriteString(foldICS(s))
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//tripitcalb0t//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeICS(name))
+	for _, lines := range vevents {
+		for _, l := range lines {
+			line(l)
+		}
+	}
+	line("END:VCALENDAR")
+
+	return bw.Flush()
+}
+
+// veventLines returns the content lines of the event as an iCalendar VEVENT,
+// unfolded.
+func veventLines(e *calendar.Event, now time.Time) []string {
+	start, end := eventTime(*e.Start), eventTime(*e.End)
+	lines := []string{
+		"BEGIN:VEVENT",
+		"UID:" + escapeICS(privateProperty(e, propertySegmentID)) + "@tripitcalb0t",
+		"DTSTAMP:" + now.UTC().Format(icsTimeFormat),
+	}
+	if len(e.Start.Date) > 0 {
+		lines = append(lines,
+			"DTSTART;VALUE=DATE:"+start.Format(icsDateFormat),
+			"DTEND;VALUE=DATE:"+end.Format(icsDateFormat))
+	} else {
+		lines = append(lines,
+			"DTSTART:"+start.UTC().Format(icsTimeFormat),
+			"DTEND:"+end.UTC().Format(icsTimeFormat))
+	}
+	if e.Transparency == "transparent" {
+		lines = append(lines, "TRANSP:TRANSPARENT")
+	}
+	lines = append(lines, "SUMMARY:"+escapeICS(e.Summary))
+	if len(e.Location) > 0 {
+		lines = append(lines, "LOCATION:"+escapeICS(e.Location))
+	}
+	if len(e.Description) > 0 {
+		lines = append(lines, "DESCRIPTION:"+escapeICS(e.Description))
+	}
+	if e.Source != nil && len(e.Source.Url) > 0 {
+		lines = append(lines, "URL:"+e.Source.Url)
+	}
+	return append(lines, "END:VEVENT")
+}
+
+// escapeICS escapes the text for an iCalendar property value.
+func escapeICS(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// foldICS ends the content line with CRLF, folding it so no line is longer
+// than 75 octets without splitting a UTF-8 character.
+func foldICS(s string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+const exportHelp = `Export the events of a trip.`
+
+const exportLongHelp = `Export the events of a trip.
+
+Writes every event the bot would sync for the trip to a single .ics file, for
+emailing to people traveling with you who manage their own calendars. Find
+the trip ID with trips list.
+
+  tripitcalb0t export --trip 123456789 --ics`
+
+func (cmd *exportCommand) Name() string      { return "export" }
+func (cmd *exportCommand) Args() string      { return "" }
+func (cmd *exportCommand) ShortHelp() string { return exportHelp }
+func (cmd *exportCommand) LongHelp() string  { return exportLongHelp }
+func (cmd *exportCommand) Hidden() bool      { return false }
+
+func (cmd *exportCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.trip, "trip", "", "ID of the trip to export")
+	fs.BoolVar(&cmd.ics, "ics", false, "Export as an iCalendar (.ics) file")
+	fs.StringVar(&cmd.out, "out", "", "File to write to, - for stdout (defaults to trip-<trip-id>.ics)")
+}
+
+type exportCommand struct {
+	trip string
+	ics  bool
+	out  string
+}
+
+func (cmd *exportCommand) Run(ctx context.Context, args []string) error {
+	if len(cmd.trip) < 1 {
+		return errors.New("pass the id of the trip with --trip")
+	}
+	if !cmd.ics {
+		return errors.New("pass the format to export, only --ics is supported")
+	}
+
+	tripitClient, err := newTripItClient()
+	if err != nil {
+		fatal(exitCodeConfig, err)
+	}
+
+	resp, err := tripitClient.GetTripWithObjects(ctx, cmd.trip)
+	if err != nil {
+		return fmt.Errorf("getting trip %s from TripIt failed: %v", cmd.trip, err)
+	}
+
+	trips := responseEvents(resp)
+	if len(trips) < 1 {
+		return fmt.Errorf("trip %s has no events to export", cmd.trip)
+	}
+	sort.SliceStable(trips, func(i, j int) bool {
+		return eventTime(trips[i].Start).Before(eventTime(trips[j].Start))
+	})
+
+	var events []*calendar.Event
+	for _, trip := range trips {
+		location, err := eventLocation(trip)
+		if err != nil {
+			location = trip.AirportCode
+		}
+		events = append(events, newCalendarEvent(trip, location))
+	}
+
+	out := cmd.out
+	if len(out) < 1 {
+		out = "trip-" + cmd.trip + ".ics"
+	}
+	var w io.Writer = os.Stdout
+	if out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return fmt.Errorf("creating %s failed: %v", out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := writeICS(w, resp.Trips[0].DisplayName, events, time.Now()); err != nil {
+		return fmt.Errorf("writing %s failed: %v", out, err)
+	}
+
+	if out != "-" {
+		fmt.Fprintf(os.Stderr, "Wrote %d events to %s\n", len(events), out)
+	}
+	return nil
+}
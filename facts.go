@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jessfraz/tripitcalb0t/countries"
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+// addDestinationFacts adds what to know about the countries a trip goes to,
+// like their currency, plugs, emergency numbers, and tipping, to the
+// description of the event spanning the trip.
+func addDestinationFacts(events []tripit.Event, trips map[string]tripit.Trip) {
+	flights := tripFlights(events)
+	for i := range events {
+		e := &events[i]
+		trip, ok := trips[e.ID]
+		if !ok || e.Type != tripit.EventTypeTrip {
+			continue
+		}
+
+		seen := map[string]bool{}
+		var blocks []string
+		for _, s := range countryStays(trip, flights[e.ID]) {
+			c, ok := countries.Lookup(s.country)
+			if !ok || seen[c.Name] {
+				continue
+			}
+			seen[c.Name] = true
+			blocks = append(blocks, countryFacts(c))
+		}
+		if len(blocks) > 0 {
+			e.Description += "\n\n" + strings.Join(blocks, "\n\n")
+		}
+	}
+}
+
+// countryFacts returns the facts about the country for an event description.
+func countryFacts(c countries.Country) string {
+	return fmt.Sprintf("%s\nCurrency: %s (%s)\nPlugs: type %s, %s\nEmergency: %s\nTipping: %s",
+		c.Name, c.CurrencyName, c.Currency, strings.Join(c.Plugs, ", "), c.Voltage, c.Emergency, c.Tipping)
+}
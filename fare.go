@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const (
+	// eventTypeFareCheck is the type of the events reminding us to check
+	// whether the fare of a refundable flight dropped. They are made by the
+	// bot, not TripIt.
+	eventTypeFareCheck = "fare-check"
+
+	// fareCheckMax is the most reminders to recheck a fare we add for a
+	// booking, so one made a year out does not fill the calendar.
+	fareCheckMax = 8
+)
+
+// isRefundable returns true if the booking's restrictions say it is
+// refundable, or it is one of the --refundable confirmation numbers.
+func isRefundable(f tripit.Flight) bool {
+	for _, c := range strings.Split(refundable, ",") {
+		c = strings.TrimSpace(c)
+		if len(c) > 0 && (strings.EqualFold(c, f.SupplierConfNum) || strings.EqualFold(c, f.BookingSiteConfNum) || strings.EqualFold(c, f.RecordLocator)) {
+			return true
+		}
+	}
+
+	r := strings.ToLower(f.Restrictions)
+	for _, not := range []string{"non-refundable", "nonrefundable", "non refundable", "not refundable"} {
+		if strings.Contains(r, not) {
+			return false
+		}
+	}
+	return strings.Contains(r, "refundable")
+}
+
+// fareCheckEvents returns all-day events reminding us to check whether the
+// fare of every refundable flight booking dropped, every --fare-recheck-every
+// until --fare-recheck-cutoff before it departs. They count back from the
+// cutoff, so they stay on the same days from run to run, and start after the
+// booking was made, or today if we do not know when that was, since a
+// reminder for a day that has passed is no use.
+func fareCheckEvents(resp *tripit.Response, now time.Time) []tripit.Event {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var events []tripit.Event
+	for _, f := range resp.Flights {
+		if len(f.Segments) < 1 || !isRefundable(f) {
+			continue
+		}
+		segments := append(tripit.FlightSegments(nil), f.Segments...)
+		sort.SliceStable(segments, func(i, j int) bool {
+			return segments[i].StartDateTime.Date+segments[i].StartDateTime.Time < segments[j].StartDateTime.Date+segments[j].StartDateTime.Time
+		})
+		first, last := segments[0], segments[len(segments)-1]
+		departs, err := time.Parse("2006-01-02", first.StartDateTime.Date)
+		if err != nil {
+			continue
+		}
+		// Without a booking date, the reminders start after yesterday.
+		booked := today.AddDate(0, 0, -1)
+		if len(f.BookingDate) > 0 {
+			b, err := time.Parse("2006-01-02", f.BookingDate)
+			if err != nil {
+				logrus.Warnf("flight booking %s has booking date %q, which is not a date: %v", f.ID, f.BookingDate, err)
+			} else if b.After(booked) {
+				booked = b
+			}
+		}
+
+		route := first.StartAirportCode + " to " + last.EndAirportCode
+		confirmation := firstNonEmpty(f.SupplierConfNum, f.BookingSiteConfNum, f.RecordLocator)
+		var details []string
+		if len(f.TotalCost) > 0 {
+			details = append(details, "You paid "+f.TotalCost+".")
+		}
+		if len(confirmation) > 0 {
+			details = append(details, "Confirmation # "+confirmation)
+		}
+		if url := firstNonEmpty(f.BookingSiteURL, f.SupplierURL); len(url) > 0 {
+			details = append(details, "Book at "+url)
+		}
+		description := fmt.Sprintf("The fare of your refundable flight from %s on %s may have dropped since you booked it. If it is cheaper now, rebook it or ask %s for the difference.",
+			route, departs.Format("Mon Jan 2"), firstNonEmpty(f.SupplierName, f.BookingSiteName, "the airline"))
+		if len(details) > 0 {
+			description += "\n\n" + strings.Join(details, "\n")
+		}
+		if len(f.Restrictions) > 0 {
+			description += "\n\nFare rules: " + f.Restrictions
+		}
+
+		day := departs.Add(-fareRecheckCutoff)
+		for i := 0; i < fareCheckMax && day.After(booked); i++ {
+			events = append(events, tripit.Event{
+				Type:        eventTypeFareCheck,
+				Title:       "Recheck the fare from " + route,
+				Description: description,
+				// All-day events end the day after their last day.
+				Start:              calendar.EventDateTime{Date: day.Format("2006-01-02")},
+				End:                calendar.EventDateTime{Date: day.AddDate(0, 0, 1).Format("2006-01-02")},
+				ID:                 f.TripID,
+				SegmentID:          f.ID + "-fare-" + day.Format("20060102"),
+				ConfirmationNumber: confirmation,
+				AllDay:             true,
+			})
+			day = day.Add(-fareRecheckEvery)
+		}
+	}
+	return events
+}
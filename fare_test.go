@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+func TestFareCheckEvents(t *testing.T) {
+	every, cutoff := fareRecheckEvery, fareRecheckCutoff
+	fareRecheckEvery, fareRecheckCutoff = 7*24*time.Hour, 7*24*time.Hour
+	t.Cleanup(func() { fareRecheckEvery, fareRecheckCutoff = every, cutoff })
+
+	now := time.Date(2030, time.July, 1, 12, 0, 0, 0, time.UTC)
+	booking := func(bookingDate, departs string) *tripit.Response {
+		return &tripit.Response{Flights: []tripit.Flight{{
+			ID:           "flight",
+			TripID:       "trip",
+			BookingDate:  bookingDate,
+			Restrictions: "Refundable",
+			Segments: tripit.FlightSegments{{
+				StartDateTime:    tripit.DateTime{Date: departs, Time: "09:00:00"},
+				StartAirportCode: "SFO",
+				EndAirportCode:   "EWR",
+			}},
+		}}}
+	}
+
+	testcases := []struct {
+		name        string
+		bookingDate string
+		departs     string
+		want        []string
+	}{
+		{name: "booked", bookingDate: "2030-06-20", departs: "2030-07-29", want: []string{"2030-07-22", "2030-07-15", "2030-07-08", "2030-07-01"}},
+		{name: "booked today", bookingDate: "2030-07-01", departs: "2030-07-29", want: []string{"2030-07-22", "2030-07-15", "2030-07-08"}},
+		{name: "no booking date", departs: "2030-07-29", want: []string{"2030-07-22", "2030-07-15", "2030-07-08", "2030-07-01"}},
+		{name: "bad booking date", bookingDate: "last week", departs: "2030-07-29", want: []string{"2030-07-22", "2030-07-15", "2030-07-08", "2030-07-01"}},
+		{name: "no booking date, far out", departs: "2031-07-01", want: []string{"2031-06-24", "2031-06-17", "2031-06-10", "2031-06-03", "2031-05-27", "2031-05-20", "2031-05-13", "2031-05-06"}},
+		{name: "past the cutoff", departs: "2030-07-05"},
+		{name: "departed", bookingDate: "2030-01-01", departs: "2030-06-01"},
+	}
+	for _, tc := range testcases {
+		events := fareCheckEvents(booking(tc.bookingDate, tc.departs), now)
+		var got []string
+		for _, e := range events {
+			got = append(got, e.Start.Date)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: reminders on %v, want %v", tc.name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: reminders on %v, want %v", tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+}
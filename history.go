@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+const historyTimeFormat = "20060102T150405Z"
+
+// historyDir returns the directory the itinerary history is kept in, next to
+// the state file.
+func historyDir() string {
+	return filepath.Join(filepath.Dir(stateFile), "history")
+}
+
+// saveHistory adds the snapshot to the history if the itinerary changed since
+// the last one, and drops the oldest snapshots beyond --history-size.
+func saveHistory(snap *snapshot) error {
+	if historySize < 1 || len(stateFile) < 1 {
+		return nil
+	}
+
+	history, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	if len(history) > 0 && itineraryHash(history[len(history)-1].Events) == itineraryHash(snap.Events) {
+		return nil
+	}
+
+	dir := historyDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("creating history directory failed: %v", err)
+	}
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, snap.Fetched.UTC().Format(historyTimeFormat)+".json")
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		return fmt.Errorf("writing itinerary snapshot %s failed: %v", path, err)
+	}
+
+	history = append(history, snap)
+	for len(history) > historySize {
+		old := filepath.Join(dir, history[0].Fetched.UTC().Format(historyTimeFormat)+".json")
+		if err := os.Remove(old); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing itinerary snapshot %s failed: %v", old, err)
+		}
+		history = history[1:]
+	}
+
+	return nil
+}
+
+// loadHistory returns the itinerary snapshots in the history, oldest first.
+func loadHistory() ([]*snapshot, error) {
+	files, err := ioutil.ReadDir(historyDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading history directory failed: %v", err)
+	}
+
+	var history []*snapshot
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(historyDir(), f.Name())
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading itinerary snapshot %s failed: %v", path, err)
+		}
+		var snap snapshot
+		if err := json.Unmarshal(b, &snap); err != nil {
+			return nil, fmt.Errorf("parsing itinerary snapshot %s failed: %v", path, err)
+		}
+		history = append(history, &snap)
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Fetched.Before(history[j].Fetched)
+	})
+
+	return history, nil
+}
+
+// itineraryHash returns a hash of the events that does not depend on the
+// order TripIt returned them in.
+func itineraryHash(events []tripit.Event) string {
+	sorted := make([]tripit.Event, len(events))
+	copy(sorted, events)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].SegmentID < sorted[j].SegmentID
+	})
+
+	b, _ := json.Marshal(sorted)
+	return fmt.Sprintf("%x", sha256.Sum256(b))
+}
+
+// snapshotAt returns the last snapshot taken at or before t, or the first
+// one if they were all taken after t.
+func snapshotAt(history []*snapshot, t time.Time) *snapshot {
+	if len(history) < 1 {
+		return nil
+	}
+
+	at := history[0]
+	for _, snap := range history {
+		if snap.Fetched.After(t) {
+			break
+		}
+		at = snap
+	}
+	return at
+}
+
+// itineraryChange is how a single segment changed between two snapshots.
+type itineraryChange struct {
+	Change    string    `json:"change"`
+	SegmentID string    `json:"segmentID"`
+	Title     string    `json:"title"`
+	Flight    string    `json:"flight,omitempty"`
+	Departs   time.Time `json:"departs"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// Kinds of itinerary changes.
+const (
+	changeAdded       = "added"
+	changeCancelled   = "cancelled"
+	changeRescheduled = "rescheduled"
+	changeRerouted    = "rerouted"
+)
+
+// diffItineraries returns how the segments that were upcoming in from changed
+// by to, ordered by departure.
+func diffItineraries(from, to *snapshot) []itineraryChange {
+	before := map[string]tripit.Event{}
+	for _, e := range from.Events {
+		before[e.SegmentID] = e
+	}
+	after := map[string]tripit.Event{}
+	for _, e := range to.Events {
+		after[e.SegmentID] = e
+	}
+
+	var changes []itineraryChange
+	for id, old := range before {
+		if eventTime(old.Start).Before(from.Fetched) {
+			continue
+		}
+
+		change := itineraryChange{
+			SegmentID: id,
+			Title:     old.Title,
+			Flight:    old.FlightNumber,
+			Departs:   eventTime(old.Start),
+		}
+
+		cur, ok := after[id]
+		if !ok {
+			change.Change = changeCancelled
+			changes = append(changes, change)
+			continue
+		}
+
+		if old.AirportCode != cur.AirportCode || old.DestinationCode != cur.DestinationCode || old.FlightNumber != cur.FlightNumber {
+			c := change
+			c.Change = changeRerouted
+			c.Detail = fmt.Sprintf("%s %s-%s -> %s %s-%s", old.FlightNumber, old.AirportCode, old.DestinationCode, cur.FlightNumber, cur.AirportCode, cur.DestinationCode)
+			changes = append(changes, c)
+		}
+
+		var moved []string
+		if d := eventTime(cur.Start).Sub(eventTime(old.Start)); d != 0 {
+			moved = append(moved, fmt.Sprintf("departs %s -> %s (%s)", formatScheduleTime(eventTime(old.Start)), formatScheduleTime(eventTime(cur.Start)), formatShift(d)))
+		}
+		if d := eventTime(cur.End).Sub(eventTime(old.End)); d != 0 {
+			moved = append(moved, fmt.Sprintf("arrives %s -> %s (%s)", formatScheduleTime(eventTime(old.End)), formatScheduleTime(eventTime(cur.End)), formatShift(d)))
+		}
+		if len(moved) > 0 {
+			c := change
+			c.Change = changeRescheduled
+			c.Detail = strings.Join(moved, ", ")
+			changes = append(changes, c)
+		}
+	}
+
+	for id, cur := range after {
+		if _, ok := before[id]; ok || eventTime(cur.Start).Before(from.Fetched) {
+			continue
+		}
+		changes = append(changes, itineraryChange{
+			Change:    changeAdded,
+			SegmentID: id,
+			Title:     cur.Title,
+			Flight:    cur.FlightNumber,
+			Departs:   eventTime(cur.Start),
+		})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if !changes[i].Departs.Equal(changes[j].Departs) {
+			return changes[i].Departs.Before(changes[j].Departs)
+		}
+		return changes[i].SegmentID < changes[j].SegmentID
+	})
+
+	return changes
+}
+
+func formatScheduleTime(t time.Time) string {
+	return t.Format("Jan 2 15:04")
+}
+
+// formatShift formats how far a time moved, like +2h30m or -45m.
+func formatShift(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return sign + s
+}
+
+const historyHelp = `Show how the itinerary changed over time.`
+
+const historyLongHelp = `Show how the itinerary changed over time.
+
+Every time a sync or fetch sees the itinerary change, a snapshot of it is
+kept, up to --history-size snapshots.
+
+  tripitcalb0t history list
+  tripitcalb0t history diff --from 2024-06-01 --to today
+
+diff compares the snapshots from the two dates and reports the flights that
+were upcoming at --from and were added, cancelled, rescheduled, or rerouted
+by --to. Keep the output for compensation claims.`
+
+func (cmd *historyCommand) Name() string      { return "history" }
+func (cmd *historyCommand) Args() string      { return "list|diff" }
+func (cmd *historyCommand) ShortHelp() string { return historyHelp }
+func (cmd *historyCommand) LongHelp() string  { return historyLongHelp }
+func (cmd *historyCommand) Hidden() bool      { return false }
+
+func (cmd *historyCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.from, "from", "", "Date to diff from (ex. 2024-06-01), defaults to the oldest snapshot")
+	fs.StringVar(&cmd.to, "to", "today", "Date to diff to (ex. 2024-07-01, today)")
+}
+
+type historyCommand struct {
+	from string
+	to   string
+}
+
+func (cmd *historyCommand) Run(ctx context.Context, args []string) error {
+	if len(args) < 1 || (args[0] != "list" && args[0] != "diff") {
+		return errors.New("usage: history list|diff")
+	}
+
+	// Flags may also come after the subcommand.
+	fs := flag.NewFlagSet("history "+args[0], flag.ExitOnError)
+	fs.StringVar(&cmd.from, "from", cmd.from, "")
+	fs.StringVar(&cmd.to, "to", cmd.to, "")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	history, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	if len(history) < 1 {
+		return errors.New("there is no itinerary history yet, it is kept by syncs and fetch")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	if args[0] == "list" {
+		fmt.Fprintln(w, "FETCHED\tEVENTS")
+		for _, snap := range history {
+			fmt.Fprintf(w, "%s\t%d\n", snap.Fetched.Local().Format("2006-01-02 15:04"), len(snap.Events))
+		}
+		return w.Flush()
+	}
+
+	from := history[0].Fetched
+	if len(cmd.from) > 0 {
+		if from, err = parseHistoryDate(cmd.from, false); err != nil {
+			fatal(exitCodeConfig, err)
+		}
+	}
+	to, err := parseHistoryDate(cmd.to, true)
+	if err != nil {
+		fatal(exitCodeConfig, err)
+	}
+
+	fromSnap, toSnap := snapshotAt(history, from), snapshotAt(history, to)
+	changes := diffItineraries(fromSnap, toSnap)
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	}
+
+	fmt.Fprintf(w, "Changes between the snapshots from %s and %s\n\n", fromSnap.Fetched.Local().Format(time.RFC1123), toSnap.Fetched.Local().Format(time.RFC1123))
+	fmt.Fprintln(w, "CHANGE\tDEPARTS\tFLIGHT\tTITLE\tDETAIL")
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Change, c.Departs.Format("2006-01-02 15:04"), c.Flight, c.Title, c.Detail)
+	}
+	return w.Flush()
+}
+
+// parseHistoryDate parses a date or today. With end set it returns the end of
+// the day, so the snapshots from that day are included.
+func parseHistoryDate(s string, end bool) (time.Time, error) {
+	if s == "today" || s == "now" {
+		return time.Now(), nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("history dates must be like 2024-06-01 or today, got %q", s)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/holidays"
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+const (
+	// holidayMinStay is how long we have to be in a country between flights
+	// for its holidays to matter, so connections do not count.
+	holidayMinStay = 12 * time.Hour
+
+	// The ways to name holidays.
+	holidayNamesEnglish = "english"
+	holidayNamesLocal   = "local"
+	holidayNamesBoth    = "both"
+)
+
+// countryStay is the days of a trip spent in a country, from the day we get
+// there through the day we leave.
+type countryStay struct {
+	country  string
+	from, to time.Time
+}
+
+// countryStays returns the countries the trip goes to, from its flights, or
+// the country of the trip's destination if it has no flights. A trip
+// without a flight out of a country stays there until the trip ends.
+func countryStays(trip tripit.Trip, flights []tripit.Event) []countryStay {
+	tripEnd, _ := time.Parse("2006-01-02", trip.EndDate)
+	if len(flights) < 1 {
+		tripStart, err := time.Parse("2006-01-02", trip.StartDate)
+		if err != nil || tripEnd.IsZero() || len(trip.PrimaryLocationAddress.Country) < 1 {
+			return nil
+		}
+		return []countryStay{{country: trip.PrimaryLocationAddress.Country, from: tripStart, to: tripEnd}}
+	}
+
+	sort.SliceStable(flights, func(i, j int) bool {
+		return eventTime(flights[i].Start).Before(eventTime(flights[j].Start))
+	})
+
+	var stays []countryStay
+	for i, f := range flights {
+		arrive := eventTime(f.End)
+		airport, ok := getAirport(f.DestinationCode)
+		if arrive.IsZero() || !ok {
+			continue
+		}
+
+		var leave time.Time
+		if i+1 < len(flights) {
+			leave = eventTime(flights[i+1].Start)
+			if leave.Sub(arrive) < holidayMinStay {
+				continue
+			}
+		} else {
+			// The last flight is usually home, so only count it if the
+			// trip goes on after it lands.
+			if tripEnd.IsZero() || !tripEnd.After(localDate(arrive)) {
+				continue
+			}
+			leave = tripEnd
+		}
+
+		stays = append(stays, countryStay{country: airport.Country, from: localDate(arrive), to: localDate(leave)})
+	}
+	return stays
+}
+
+// tripFlights returns the flights of the events by trip ID.
+func tripFlights(events []tripit.Event) map[string][]tripit.Event {
+	flights := map[string][]tripit.Event{}
+	for _, e := range events {
+		if e.IsFlight() {
+			flights[e.ID] = append(flights[e.ID], e)
+		}
+	}
+	return flights
+}
+
+// localDate returns the date of the time where it is, at midnight UTC, to
+// compare dates without their timezones getting in the way.
+func localDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// eventDates returns the first and last days of the event, where it takes
+// place.
+func eventDates(e tripit.Event) (time.Time, time.Time) {
+	first, last := localDate(eventTime(e.Start)), localDate(eventTime(e.End))
+	if len(e.End.Date) > 0 {
+		// All-day events end the day after their last day.
+		last = last.AddDate(0, 0, -1)
+	}
+	if last.Before(first) {
+		last = first
+	}
+	return first, last
+}
+
+// annotateHolidays adds the public holidays at the destination to the
+// description of every event of a trip that falls on one, like the trip
+// itself, hotel stays, and flights landing on the day, since shops, offices,
+// and transport may be closed.
+func annotateHolidays(events []tripit.Event, trips map[string]tripit.Trip) {
+	flights := tripFlights(events)
+	stays := map[string][]countryStay{}
+	for i := range events {
+		e := &events[i]
+		trip, ok := trips[e.ID]
+		if !ok {
+			continue
+		}
+		if _, ok := stays[e.ID]; !ok {
+			stays[e.ID] = countryStays(trip, flights[e.ID])
+		}
+
+		first, last := eventDates(*e)
+		var lines []string
+		for _, s := range stays[e.ID] {
+			from, to := s.from, s.to
+			if first.After(from) {
+				from = first
+			}
+			if last.Before(to) {
+				to = last
+			}
+			if to.Before(from) {
+				continue
+			}
+			for _, h := range holidays.Between(s.country, from, to) {
+				lines = append(lines, fmt.Sprintf("- %s, %s: %s", h.Date.Format("Mon Jan 2"), h.Country, holidayName(h)))
+			}
+		}
+		if len(lines) > 0 {
+			e.Description += "\n\nPublic holidays at your destination, when shops, offices, and transport may be closed or run a reduced service:\n" + strings.Join(lines, "\n")
+		}
+	}
+}
+
+// holidayName names the holiday as --holiday-names says.
+func holidayName(h holidays.Holiday) string {
+	switch {
+	case holidayNames == holidayNamesEnglish || h.Name == h.LocalName:
+		return h.Name
+	case holidayNames == holidayNamesLocal:
+		return h.LocalName
+	}
+	return fmt.Sprintf("%s (%s)", h.LocalName, h.Name)
+}
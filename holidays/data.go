@@ -0,0 +1,184 @@
+package holidays
+
+import "time"
+
+// countries are the rules for the national public holidays of each country.
+var countries = map[string][]rule{
+	"Australia": {
+		fixed(time.January, 1, "New Year's Day", "New Year's Day"),
+		fixed(time.January, 26, "Australia Day", "Australia Day"),
+		easter(-2, "Good Friday", "Good Friday"),
+		easter(1, "Easter Monday", "Easter Monday"),
+		fixed(time.April, 25, "Anzac Day", "Anzac Day"),
+		fixed(time.December, 25, "Christmas Day", "Christmas Day"),
+		fixed(time.December, 26, "Boxing Day", "Boxing Day"),
+	},
+	"Canada": {
+		fixed(time.January, 1, "New Year's Day", "New Year's Day"),
+		easter(-2, "Good Friday", "Good Friday"),
+		weekdayBefore(time.Monday, time.May, 24, "Victoria Day", "Victoria Day"),
+		fixed(time.July, 1, "Canada Day", "Canada Day"),
+		nthWeekday(1, time.Monday, time.September, "Labour Day", "Labour Day"),
+		nthWeekday(2, time.Monday, time.October, "Thanksgiving", "Thanksgiving"),
+		fixed(time.December, 25, "Christmas Day", "Christmas Day"),
+		fixed(time.December, 26, "Boxing Day", "Boxing Day"),
+	},
+	"France": {
+		fixed(time.January, 1, "New Year's Day", "Jour de l'an"),
+		easter(1, "Easter Monday", "Lundi de Pâques"),
+		fixed(time.May, 1, "Labour Day", "Fête du Travail"),
+		fixed(time.May, 8, "Victory in Europe Day", "Victoire 1945"),
+		easter(39, "Ascension Day", "Ascension"),
+		easter(50, "Whit Monday", "Lundi de Pentecôte"),
+		fixed(time.July, 14, "Bastille Day", "Fête nationale"),
+		fixed(time.August, 15, "Assumption Day", "Assomption"),
+		fixed(time.November, 1, "All Saints' Day", "Toussaint"),
+		fixed(time.November, 11, "Armistice Day", "Armistice 1918"),
+		fixed(time.December, 25, "Christmas Day", "Noël"),
+	},
+	"Germany": {
+		fixed(time.January, 1, "New Year's Day", "Neujahr"),
+		easter(-2, "Good Friday", "Karfreitag"),
+		easter(1, "Easter Monday", "Ostermontag"),
+		fixed(time.May, 1, "Labour Day", "Tag der Arbeit"),
+		easter(39, "Ascension Day", "Christi Himmelfahrt"),
+		easter(50, "Whit Monday", "Pfingstmontag"),
+		fixed(time.October, 3, "German Unity Day", "Tag der Deutschen Einheit"),
+		fixed(time.December, 25, "Christmas Day", "1. Weihnachtstag"),
+		fixed(time.December, 26, "St. Stephen's Day", "2. Weihnachtstag"),
+	},
+	"Ireland": {
+		fixed(time.January, 1, "New Year's Day", "Lá Caille"),
+		fixed(time.March, 17, "St. Patrick's Day", "Lá Fhéile Pádraig"),
+		easter(1, "Easter Monday", "Luan Cásca"),
+		nthWeekday(1, time.Monday, time.May, "May Bank Holiday", "Lá Bealtaine"),
+		nthWeekday(1, time.Monday, time.June, "June Bank Holiday", "Lá Saoire i mí an Mheithimh"),
+		nthWeekday(1, time.Monday, time.August, "August Bank Holiday", "Lá Saoire i mí Lúnasa"),
+		nthWeekday(-1, time.Monday, time.October, "October Bank Holiday", "Lá Saoire i mí Dheireadh Fómhair"),
+		fixed(time.December, 25, "Christmas Day", "Lá Nollag"),
+		fixed(time.December, 26, "St. Stephen's Day", "Lá Fhéile Stiofáin"),
+	},
+	"Italy": {
+		fixed(time.January, 1, "New Year's Day", "Capodanno"),
+		fixed(time.January, 6, "Epiphany", "Epifania"),
+		easter(1, "Easter Monday", "Lunedì dell'Angelo"),
+		fixed(time.April, 25, "Liberation Day", "Festa della Liberazione"),
+		fixed(time.May, 1, "Labour Day", "Festa dei Lavoratori"),
+		fixed(time.June, 2, "Republic Day", "Festa della Repubblica"),
+		fixed(time.August, 15, "Assumption Day", "Ferragosto"),
+		fixed(time.November, 1, "All Saints' Day", "Ognissanti"),
+		fixed(time.December, 8, "Immaculate Conception", "Immacolata Concezione"),
+		fixed(time.December, 25, "Christmas Day", "Natale"),
+		fixed(time.December, 26, "St. Stephen's Day", "Santo Stefano"),
+	},
+	"Japan": {
+		fixed(time.January, 1, "New Year's Day", "元日"),
+		nthWeekday(2, time.Monday, time.January, "Coming of Age Day", "成人の日"),
+		fixed(time.February, 11, "National Foundation Day", "建国記念の日"),
+		fixed(time.February, 23, "Emperor's Birthday", "天皇誕生日"),
+		fixed(time.April, 29, "Shōwa Day", "昭和の日"),
+		fixed(time.May, 3, "Constitution Memorial Day", "憲法記念日"),
+		fixed(time.May, 4, "Greenery Day", "みどりの日"),
+		fixed(time.May, 5, "Children's Day", "こどもの日"),
+		nthWeekday(3, time.Monday, time.July, "Marine Day", "海の日"),
+		fixed(time.August, 11, "Mountain Day", "山の日"),
+		nthWeekday(3, time.Monday, time.September, "Respect for the Aged Day", "敬老の日"),
+		nthWeekday(2, time.Monday, time.October, "Sports Day", "スポーツの日"),
+		fixed(time.November, 3, "Culture Day", "文化の日"),
+		fixed(time.November, 23, "Labour Thanksgiving Day", "勤労感謝の日"),
+	},
+	"Mexico": {
+		fixed(time.January, 1, "New Year's Day", "Año Nuevo"),
+		nthWeekday(1, time.Monday, time.February, "Constitution Day", "Día de la Constitución"),
+		nthWeekday(3, time.Monday, time.March, "Benito Juárez's Birthday", "Natalicio de Benito Juárez"),
+		fixed(time.May, 1, "Labour Day", "Día del Trabajo"),
+		fixed(time.September, 16, "Independence Day", "Día de la Independencia"),
+		nthWeekday(3, time.Monday, time.November, "Revolution Day", "Día de la Revolución"),
+		fixed(time.December, 25, "Christmas Day", "Navidad"),
+	},
+	"Netherlands": {
+		fixed(time.January, 1, "New Year's Day", "Nieuwjaarsdag"),
+		easter(1, "Easter Monday", "Tweede Paasdag"),
+		fixed(time.April, 27, "King's Day", "Koningsdag"),
+		easter(39, "Ascension Day", "Hemelvaartsdag"),
+		easter(50, "Whit Monday", "Tweede Pinksterdag"),
+		fixed(time.December, 25, "Christmas Day", "Eerste Kerstdag"),
+		fixed(time.December, 26, "Boxing Day", "Tweede Kerstdag"),
+	},
+	"New Zealand": {
+		fixed(time.January, 1, "New Year's Day", "New Year's Day"),
+		fixed(time.January, 2, "Day after New Year's Day", "Day after New Year's Day"),
+		fixed(time.February, 6, "Waitangi Day", "Waitangi Day"),
+		easter(-2, "Good Friday", "Good Friday"),
+		easter(1, "Easter Monday", "Easter Monday"),
+		fixed(time.April, 25, "Anzac Day", "Anzac Day"),
+		nthWeekday(1, time.Monday, time.June, "King's Birthday", "King's Birthday"),
+		nthWeekday(4, time.Monday, time.October, "Labour Day", "Labour Day"),
+		fixed(time.December, 25, "Christmas Day", "Christmas Day"),
+		fixed(time.December, 26, "Boxing Day", "Boxing Day"),
+	},
+	"Spain": {
+		fixed(time.January, 1, "New Year's Day", "Año Nuevo"),
+		fixed(time.January, 6, "Epiphany", "Epifanía del Señor"),
+		easter(-2, "Good Friday", "Viernes Santo"),
+		fixed(time.May, 1, "Labour Day", "Fiesta del Trabajo"),
+		fixed(time.August, 15, "Assumption Day", "Asunción de la Virgen"),
+		fixed(time.October, 12, "National Day", "Fiesta Nacional de España"),
+		fixed(time.November, 1, "All Saints' Day", "Todos los Santos"),
+		fixed(time.December, 6, "Constitution Day", "Día de la Constitución"),
+		fixed(time.December, 8, "Immaculate Conception", "Inmaculada Concepción"),
+		fixed(time.December, 25, "Christmas Day", "Navidad"),
+	},
+	"Switzerland": {
+		fixed(time.January, 1, "New Year's Day", "Neujahr"),
+		easter(-2, "Good Friday", "Karfreitag"),
+		easter(1, "Easter Monday", "Ostermontag"),
+		easter(39, "Ascension Day", "Auffahrt"),
+		easter(50, "Whit Monday", "Pfingstmontag"),
+		fixed(time.August, 1, "Swiss National Day", "Bundesfeier"),
+		fixed(time.December, 25, "Christmas Day", "Weihnachten"),
+		fixed(time.December, 26, "St. Stephen's Day", "Stephanstag"),
+	},
+	"United Kingdom": {
+		fixed(time.January, 1, "New Year's Day", "New Year's Day"),
+		easter(-2, "Good Friday", "Good Friday"),
+		easter(1, "Easter Monday", "Easter Monday"),
+		nthWeekday(1, time.Monday, time.May, "Early May Bank Holiday", "Early May Bank Holiday"),
+		nthWeekday(-1, time.Monday, time.May, "Spring Bank Holiday", "Spring Bank Holiday"),
+		nthWeekday(-1, time.Monday, time.August, "Summer Bank Holiday", "Summer Bank Holiday"),
+		fixed(time.December, 25, "Christmas Day", "Christmas Day"),
+		fixed(time.December, 26, "Boxing Day", "Boxing Day"),
+	},
+	"United States": {
+		fixed(time.January, 1, "New Year's Day", "New Year's Day"),
+		nthWeekday(3, time.Monday, time.January, "Martin Luther King Jr. Day", "Martin Luther King Jr. Day"),
+		nthWeekday(3, time.Monday, time.February, "Presidents' Day", "Presidents' Day"),
+		nthWeekday(-1, time.Monday, time.May, "Memorial Day", "Memorial Day"),
+		fixed(time.June, 19, "Juneteenth", "Juneteenth"),
+		fixed(time.July, 4, "Independence Day", "Independence Day"),
+		nthWeekday(1, time.Monday, time.September, "Labor Day", "Labor Day"),
+		nthWeekday(2, time.Monday, time.October, "Columbus Day", "Columbus Day"),
+		fixed(time.November, 11, "Veterans Day", "Veterans Day"),
+		nthWeekday(4, time.Thursday, time.November, "Thanksgiving", "Thanksgiving"),
+		fixed(time.December, 25, "Christmas Day", "Christmas Day"),
+	},
+}
+
+// codes are the ISO 3166 country codes of the countries, which TripIt uses
+// in addresses.
+var codes = map[string]string{
+	"AU": "Australia",
+	"CA": "Canada",
+	"CH": "Switzerland",
+	"DE": "Germany",
+	"ES": "Spain",
+	"FR": "France",
+	"GB": "United Kingdom",
+	"IE": "Ireland",
+	"IT": "Italy",
+	"JP": "Japan",
+	"MX": "Mexico",
+	"NL": "Netherlands",
+	"NZ": "New Zealand",
+	"US": "United States",
+}
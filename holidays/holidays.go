@@ -0,0 +1,134 @@
+// Package holidays has the national public holidays of countries, computed
+// from rules so the dataset does not run out. Countries are named like the
+// OpenFlights dataset names them, or by their ISO 3166 codes. Holidays that follow a lunar calendar or
+// an astronomical event, and those only some regions have, are not included.
+package holidays
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Holiday is a public holiday on a date in a country.
+type Holiday struct {
+	// Date is the day of the holiday, at midnight UTC.
+	Date    time.Time
+	Country string
+	// Name is the English name of the holiday, and LocalName its name in
+	// the country's language, which is the same in English speaking
+	// countries.
+	Name      string
+	LocalName string
+}
+
+// rule is how to find the date of a holiday in a year. A rule with an
+// Easter offset is that many days after Easter Sunday. Otherwise a rule with
+// an nth weekday is the nth weekday of the month, counting from the end if
+// negative, and a rule with a weekday but no nth is the last weekday on or
+// before the day. Any other rule is the day of the month.
+type rule struct {
+	name    string
+	local   string
+	month   time.Month
+	day     int
+	weekday time.Weekday
+	nth     int
+	easter  int
+	// isEaster and byWeekday say which of the fields are set, since their
+	// zero values are valid.
+	isEaster  bool
+	byWeekday bool
+}
+
+// fixed is a holiday on the same date every year.
+func fixed(month time.Month, day int, name, local string) rule {
+	return rule{name: name, local: local, month: month, day: day}
+}
+
+// nthWeekday is a holiday on the nth weekday of the month, the last if n is
+// -1.
+func nthWeekday(n int, weekday time.Weekday, month time.Month, name, local string) rule {
+	return rule{name: name, local: local, month: month, weekday: weekday, nth: n, byWeekday: true}
+}
+
+// weekdayBefore is a holiday on the last weekday on or before the date.
+func weekdayBefore(weekday time.Weekday, month time.Month, day int, name, local string) rule {
+	return rule{name: name, local: local, month: month, day: day, weekday: weekday, byWeekday: true}
+}
+
+// easter is a holiday the days after Easter Sunday, before it if negative.
+func easter(days int, name, local string) rule {
+	return rule{name: name, local: local, easter: days, isEaster: true}
+}
+
+// date returns the date of the holiday in the year.
+func (r rule) date(year int) time.Time {
+	switch {
+	case r.isEaster:
+		return easterSunday(year).AddDate(0, 0, r.easter)
+	case r.byWeekday && r.nth > 0:
+		first := time.Date(year, r.month, 1, 0, 0, 0, 0, time.UTC)
+		offset := (int(r.weekday) - int(first.Weekday()) + 7) % 7
+		return first.AddDate(0, 0, offset+7*(r.nth-1))
+	case r.byWeekday && r.nth < 0:
+		last := time.Date(year, r.month+1, 0, 0, 0, 0, 0, time.UTC)
+		offset := (int(last.Weekday()) - int(r.weekday) + 7) % 7
+		return last.AddDate(0, 0, -offset-7*(-r.nth-1))
+	case r.byWeekday:
+		on := time.Date(year, r.month, r.day, 0, 0, 0, 0, time.UTC)
+		offset := (int(on.Weekday()) - int(r.weekday) + 7) % 7
+		return on.AddDate(0, 0, -offset)
+	}
+	return time.Date(year, r.month, r.day, 0, 0, 0, 0, time.UTC)
+}
+
+// easterSunday returns the date of Easter Sunday in the Gregorian calendar,
+// with the anonymous Gregorian algorithm.
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// Between returns the public holidays in the country from the date of from
+// through the date of to, in order. Only the dates of from and to matter,
+// not their times or locations.
+func Between(country string, from, to time.Time) []Holiday {
+	if name, ok := codes[strings.ToUpper(country)]; ok {
+		country = name
+	}
+	rules, ok := countries[country]
+	if !ok {
+		return nil
+	}
+	first := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	last := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+
+	var holidays []Holiday
+	for year := first.Year(); year <= last.Year(); year++ {
+		for _, r := range rules {
+			d := r.date(year)
+			if d.Before(first) || d.After(last) {
+				continue
+			}
+			holidays = append(holidays, Holiday{
+				Date:      d,
+				Country:   country,
+				Name:      r.name,
+				LocalName: r.local,
+			})
+		}
+	}
+	sort.SliceStable(holidays, func(i, j int) bool { return holidays[i].Date.Before(holidays[j].Date) })
+	return holidays
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// errICSEventNotFound is returned for an event that is not in the .ics file.
+var errICSEventNotFound = errors.New("event is not in the ics file")
+
+// icsFileBackend is an iCalendar (.ics) file, for setups without a calendar
+// server, or to subscribe any calendar app to the file over HTTP. Every
+// segment is one VEVENT with the segment ID as its UID, and the file is
+// rewritten whole on every change, so writing it again gives the same file.
+type icsFileBackend struct {
+	path string
+	name string
+	mu   sync.Mutex
+}
+
+// newICSFileBackend returns the backend for the .ics file at the path, with
+// the calendar name apps show for it.
+func newICSFileBackend(path, name string) *icsFileBackend {
+	return &icsFileBackend{path: path, name: name}
+}
+
+func (f *icsFileBackend) String() string {
+	return "ics file " + f.path
+}
+
+// List returns the events in the file.
+func (f *icsFileBackend) List(ctx context.Context) ([]*calendar.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	events, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	payloads.dump("ics file events", events)
+	return events, nil
+}
+
+// Create adds the event for the key, replacing the one already there for
+// it, like when a run failed before it saved the state.
+func (f *icsFileBackend) Create(ctx context.Context, key string, e *calendar.Event) (*calendar.Event, error) {
+	payloads.dump("writing ics file event", e)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	events, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	created := *e
+	created.Id, created.Updated = key, ""
+	events = append(removeICSEvent(events, key), &created)
+	if err := f.write(events); err != nil {
+		return nil, fmt.Errorf("writing ics file event for segment %s failed: %w", key, err)
+	}
+	return &created, nil
+}
+
+// Update sets the fields in the patch on the event.
+func (f *icsFileBackend) Update(ctx context.Context, key, id string, patch *calendar.Event) error {
+	payloads.dump("patching ics file event", patch)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	events, err := f.read()
+	if err != nil {
+		return err
+	}
+	for i, e := range events {
+		if e.Id == id {
+			events[i] = mergeEventPatch(e, patch)
+			events[i].Updated = ""
+			if err := f.write(events); err != nil {
+				return fmt.Errorf("updating ics file event %s failed: %w", id, err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("updating ics file event %s failed: %w", id, errICSEventNotFound)
+}
+
+// Delete removes the event.
+func (f *icsFileBackend) Delete(ctx context.Context, key, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	events, err := f.read()
+	if err != nil {
+		return err
+	}
+	kept := removeICSEvent(events, id)
+	if len(kept) == len(events) {
+		return nil
+	}
+	if err := f.write(kept); err != nil {
+		return fmt.Errorf("removing ics file event %s failed: %w", id, err)
+	}
+	return nil
+}
+
+// read returns the events in the file, with their UIDs as their IDs. A file
+// that does not exist yet has no events.
+func (f *icsFileBackend) read() ([]*calendar.Event, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading ics file %s failed: %v", f.path, err)
+	}
+
+	events := parseICS(string(data))
+	for _, e := range events {
+		e.Id = strings.TrimSuffix(e.ICalUID, "@tripitcalb0t")
+	}
+	return events, nil
+}
+
+// write replaces the file with the events, in order of when they start so
+// the same events always give the same file. Events keep the time they were
+// last written as their DTSTAMP.
+func (f *icsFileBackend) write(events []*calendar.Event) error {
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := eventTime(*events[i].Start), eventTime(*events[j].Start)
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return events[i].Id < events[j].Id
+	})
+
+	now := time.Now()
+	vevents := make([][]string, 0, len(events))
+	for _, e := range events {
+		stamp, err := time.Parse(time.RFC3339, e.Updated)
+		if err != nil {
+			stamp = now
+		}
+		vevents = append(vevents, icsEventLines(e, stamp))
+	}
+	var buf bytes.Buffer
+	if err := writeVCalendar(&buf, f.name, vevents); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return fmt.Errorf("creating ics file directory failed: %v", err)
+	}
+	// Write to a temporary file and rename it so a calendar app reading
+	// the file never sees half of it. The file is meant to be served to
+	// calendar apps, so it is readable by others.
+	tmp := f.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing ics file %s failed: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("renaming ics file %s failed: %v", tmp, err)
+	}
+	return nil
+}
+
+// check checks that the file can be read, and its directory written to.
+func (f *icsFileBackend) check() error {
+	if _, err := f.read(); err != nil {
+		return err
+	}
+	dir := filepath.Dir(f.path)
+	tmp, err := ioutil.TempFile(dir, ".tripitcalb0t-check")
+	if os.IsNotExist(err) {
+		// The directory is created on the first write.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ics file directory %s is not writable: %v", dir, err)
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
+
+// removeICSEvent returns the events without the one with the ID.
+func removeICSEvent(events []*calendar.Event, id string) []*calendar.Event {
+	kept := events[:0:0]
+	for _, e := range events {
+		if e.Id != id {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+func TestICSFileBackend(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "calendar", "trips.ics")
+	f := newICSFileBackend(path, "Trips")
+
+	if events, err := f.List(ctx); err != nil || len(events) != 0 {
+		t.Fatalf("List of a file that does not exist yet = %v, %v, want no events", events, err)
+	}
+
+	departs := time.Date(2030, time.July, 10, 9, 0, 0, 0, time.UTC)
+	trip := hashEvent(departs)
+	trip.Description = "Confirmation: ABC123; seat 12A, window\nTerminal 3, München–Newark, with a note long enough to be folded over more than one line"
+	trip.Tags = []string{"work", "conference"}
+	flight := newCalendarEvent(trip, "San Francisco International Airport")
+	flight.Visibility = "private"
+	stay := hashEvent(departs)
+	stay.SegmentID, stay.Title, stay.AllDay = "hotel", "Hotel in Newark", true
+	stay.Start = calendar.EventDateTime{Date: "2030-07-10"}
+	stay.End = calendar.EventDateTime{Date: "2030-07-12"}
+	hotel := newCalendarEvent(stay, "")
+
+	for _, e := range []*calendar.Event{hotel, flight} {
+		if _, err := f.Create(ctx, privateProperty(e, propertySegmentID), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	written, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range strings.Split(string(written), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("line %q is longer than 75 octets, want it folded", l)
+		}
+	}
+
+	events, err := f.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := map[string]*calendar.Event{}
+	for _, e := range events {
+		byID[e.Id] = e
+	}
+	got := byID["segment"]
+	if len(events) != 2 || got == nil || byID["hotel"] == nil {
+		t.Fatalf("List = %v, want the flight and the hotel", events)
+	}
+	if got.Summary != flight.Summary || got.Description != flight.Description || got.Location != flight.Location {
+		t.Errorf("read back %q, %q, %q, want %q, %q, %q", got.Summary, got.Description, got.Location, flight.Summary, flight.Description, flight.Location)
+	}
+	if !eventTime(*got.Start).Equal(departs) || !eventTime(*got.End).Equal(departs.Add(5*time.Hour)) {
+		t.Errorf("read back %s to %s, want %s to %s", got.Start.DateTime, got.End.DateTime, departs, departs.Add(5*time.Hour))
+	}
+	if got.Visibility != "private" || got.Source == nil || got.Source.Url != flight.Source.Url {
+		t.Errorf("read back visibility %q and source %+v, want private and the trip", got.Visibility, got.Source)
+	}
+	for k, v := range flight.ExtendedProperties.Private {
+		if privateProperty(got, k) != v {
+			t.Errorf("read back private property %s = %q, want %q", k, privateProperty(got, k), v)
+		}
+	}
+	if got.ExtendedProperties.Shared[propertyTags] != "work,conference" {
+		t.Errorf("read back tags %q, want work,conference", got.ExtendedProperties.Shared[propertyTags])
+	}
+	if h := byID["hotel"]; h.Start.Date != "2030-07-10" || h.End.Date != "2030-07-12" || h.Transparency != "transparent" {
+		t.Errorf("read back hotel %+v, want an all-day event that does not make us busy", h)
+	}
+
+	// Writing the events read back gives the same file.
+	f.mu.Lock()
+	err = f.write(events)
+	f.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := ioutil.ReadFile(path); err != nil || string(again) != string(written) {
+		t.Errorf("writing the events read back gave\n%s\nwant\n%s", again, written)
+	}
+
+	// Creating an event again replaces it.
+	if _, err := f.Create(ctx, "hotel", hotel); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := f.List(ctx); len(events) != 2 {
+		t.Errorf("List after creating the hotel again = %d events, want 2", len(events))
+	}
+
+	if err := f.Update(ctx, "segment", "segment", &calendar.Event{Summary: "Flight to Boston (UA 123)"}); err != nil {
+		t.Fatal(err)
+	}
+	events, err = f.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events {
+		if e.Id == "segment" && (e.Summary != "Flight to Boston (UA 123)" || e.Description != flight.Description) {
+			t.Errorf("after Update read back %+v, want only the summary changed", e)
+		}
+	}
+
+	if err := f.Delete(ctx, "segment", "segment"); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := f.List(ctx); len(events) != 1 || events[0].Id != "hotel" {
+		t.Errorf("List after Delete = %v, want only the hotel", events)
+	}
+	if err := f.Delete(ctx, "segment", "segment"); err != nil {
+		t.Errorf("Delete of a missing event failed: %v", err)
+	}
+	if err := f.Update(ctx, "segment", "segment", &calendar.Event{Summary: "Gone"}); !isNotFound(err) {
+		t.Errorf("Update of a missing event = %v, want not found", err)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+// insurancePolicy is a travel insurance policy and the days it covers.
+type insurancePolicy struct {
+	Name string `json:"name"`
+	// From and To are the first and last days of cover, like 2018-07-01.
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Annual policies cover every trip in the year, up to MaxTripDays long
+	// if it is set, and lapse unless renewed.
+	Annual      bool `json:"annual,omitempty"`
+	MaxTripDays int  `json:"maxTripDays,omitempty"`
+
+	from, to time.Time
+}
+
+// covers returns true if the policy covers the trip from its first to its
+// last day.
+func (p insurancePolicy) covers(first, last time.Time) bool {
+	if first.Before(p.from) || last.After(p.to) {
+		return false
+	}
+	return p.MaxTripDays < 1 || int(last.Sub(first).Hours()/24)+1 <= p.MaxTripDays
+}
+
+// loadInsurancePolicies reads the travel insurance policies from the JSON
+// file at path.
+func loadInsurancePolicies(path string) ([]insurancePolicy, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading insurance file %s failed: %v", path, err)
+	}
+	var policies []insurancePolicy
+	if err := json.Unmarshal(b, &policies); err != nil {
+		return nil, fmt.Errorf("parsing insurance file %s failed: %v", path, err)
+	}
+	for i, p := range policies {
+		if len(p.Name) < 1 {
+			return nil, fmt.Errorf("every policy in insurance file %s needs a name", path)
+		}
+		if policies[i].from, err = time.Parse("2006-01-02", p.From); err != nil {
+			return nil, fmt.Errorf("policy %q in insurance file %s needs a from date like 2018-07-01, got %q", p.Name, path, p.From)
+		}
+		if policies[i].to, err = time.Parse("2006-01-02", p.To); err != nil {
+			return nil, fmt.Errorf("policy %q in insurance file %s needs a to date like 2018-07-01, got %q", p.Name, path, p.To)
+		}
+		if policies[i].to.Before(policies[i].from) {
+			return nil, fmt.Errorf("policy %q in insurance file %s ends before it starts", p.Name, path)
+		}
+	}
+	return policies, nil
+}
+
+// warnInsurance warns once about every upcoming trip abroad that none of the
+// --insurance-file policies cover, saying so when it is because an annual
+// policy lapses before the trip is over.
+func warnInsurance(st *syncState, events []tripit.Event, now time.Time) []string {
+	policies, err := loadInsurancePolicies(insuranceFile)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var warnings []string
+	for _, trip := range upcomingTrips(events, now) {
+		if trip.Start.Before(now) || !isAbroad(trip.Events) {
+			continue
+		}
+		first, last := localDate(trip.Start), localDate(trip.End)
+		if insured(policies, first, last) {
+			continue
+		}
+		key := fmt.Sprintf("insurance:%s:%s:%s", trip.ID, first.Format("2006-01-02"), last.Format("2006-01-02"))
+		if !st.warnOnce(key) {
+			continue
+		}
+
+		warning := fmt.Sprintf("%s, %s to %s, is not covered by any of your travel insurance policies", trip.Name, first.Format("Mon Jan 2"), last.Format("Mon Jan 2"))
+		if p, ok := lapsingPolicy(policies, first, last, now); ok {
+			warning = fmt.Sprintf("Your annual travel insurance %s lapses on %s, before %s is over on %s, renew it before you go", p.Name, p.to.Format("Mon Jan 2"), trip.Name, last.Format("Mon Jan 2"))
+		}
+		warnings = append(warnings, warning)
+	}
+	return warnings
+}
+
+// insured returns true if one of the policies covers the days.
+func insured(policies []insurancePolicy, first, last time.Time) bool {
+	for _, p := range policies {
+		if p.covers(first, last) {
+			return true
+		}
+	}
+	return false
+}
+
+// lapsingPolicy returns the annual policy still in force that would have
+// covered the days if it did not end first.
+func lapsingPolicy(policies []insurancePolicy, first, last, now time.Time) (insurancePolicy, bool) {
+	for _, p := range policies {
+		if !p.Annual || !p.to.Before(last) || p.to.Before(localDate(now)) || first.Before(p.from) {
+			continue
+		}
+		renewed := p
+		renewed.to = last
+		if renewed.covers(first, last) {
+			return p, true
+		}
+	}
+	return insurancePolicy{}, false
+}
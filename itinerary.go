@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jessfraz/tripitcalb0t/pdf"
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+const pdfHelp = `Write a printable itinerary for a trip as a PDF.`
+
+const pdfLongHelp = `Write a printable itinerary for a trip as a PDF.
+
+The itinerary lists every flight, train, stay, car, and reservation of the
+trip in order, with their confirmation numbers, addresses, and phone
+numbers, followed by the --emergency-contacts. Find the trip ID with
+trips list.
+
+  tripitcalb0t pdf --out trip.pdf 123456789`
+
+func (cmd *pdfCommand) Name() string      { return "pdf" }
+func (cmd *pdfCommand) Args() string      { return "<trip-id>" }
+func (cmd *pdfCommand) ShortHelp() string { return pdfHelp }
+func (cmd *pdfCommand) LongHelp() string  { return pdfLongHelp }
+func (cmd *pdfCommand) Hidden() bool      { return false }
+
+func (cmd *pdfCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.out, "out", "", "File to write the PDF to, - for stdout (defaults to itinerary-<trip-id>.pdf)")
+}
+
+type pdfCommand struct {
+	out string
+}
+
+func (cmd *pdfCommand) Run(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return errors.New("pass the id of the trip")
+	}
+	id := args[0]
+
+	tripitClient, err := newTripItClient()
+	if err != nil {
+		fatal(exitCodeConfig, err)
+	}
+
+	resp, err := tripitClient.GetTripWithObjects(ctx, id)
+	if err != nil {
+		return fmt.Errorf("getting trip %s from TripIt failed: %v", id, err)
+	}
+
+	doc := itineraryPDF(resp, splitContacts(emergencyContacts))
+
+	return writePDF(doc, cmd.out, "itinerary-"+id+".pdf")
+}
+
+// writePDF writes the document to the file out, to stdout if out is -, or to
+// the file name if out is empty.
+func writePDF(doc *pdf.Document, out, name string) error {
+	if len(out) < 1 {
+		out = name
+	}
+	var w io.Writer = os.Stdout
+	if out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return fmt.Errorf("creating %s failed: %v", out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if _, err := doc.WriteTo(w); err != nil {
+		return fmt.Errorf("writing %s failed: %v", out, err)
+	}
+
+	if out != "-" {
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", out)
+	}
+	return nil
+}
+
+// splitContacts splits the semicolon separated contacts.
+func splitContacts(s string) []string {
+	var contacts []string
+	for _, c := range strings.Split(s, ";") {
+		if c = strings.TrimSpace(c); len(c) > 0 {
+			contacts = append(contacts, c)
+		}
+	}
+	return contacts
+}
+
+// itineraryItem is a single entry of the itinerary.
+type itineraryItem struct {
+	start string // sortable start time, "2006-01-02T15:04"
+	title string
+	lines []string
+}
+
+// itineraryPDF renders the trip and its objects in the response as a
+// printable itinerary.
+func itineraryPDF(resp *tripit.Response, contacts []string) *pdf.Document {
+	trip := resp.Trips[0]
+	doc := pdf.New(trip.DisplayName)
+
+	doc.Heading(trip.DisplayName)
+	doc.Text(fmt.Sprintf("%s to %s", trip.StartDate, trip.EndDate))
+	if len(trip.PrimaryLocation) > 0 {
+		doc.Text(trip.PrimaryLocation)
+	}
+
+	var items []itineraryItem
+	for _, f := range resp.Flights {
+		for _, s := range f.Segments {
+			items = append(items, itineraryItem{
+				start: sortableTime(s.StartDateTime),
+				title: fmt.Sprintf("%s  Flight %s %s, %s to %s", formatDateTime(s.StartDateTime), firstNonEmpty(s.MarketingAirlineCode, s.OperatingAirlineCode), firstNonEmpty(s.MarketingFlightNumber, s.OperatingFlightNumber), s.StartAirportCode, s.EndAirportCode),
+				lines: nonEmpty(
+					labeled("Airline", firstNonEmpty(s.MarketingAirline, s.OperatingAirline)),
+					labeled("Departs", joinNonEmpty(", ", s.StartCityName, labeled("Terminal", s.StartTerminal), labeled("Gate", s.StartGate))),
+					labeled("Arrives", joinNonEmpty(", ", formatDateTime(s.EndDateTime), s.EndCityName, labeled("Terminal", s.EndTerminal))),
+					labeled("Seats", s.Seats),
+					labeled("Class", s.ServiceClass),
+					confirmations(f.SupplierConfNum, f.BookingSiteConfNum, f.RecordLocator),
+					labeled("Phone", f.SupplierPhone),
+				),
+			})
+		}
+	}
+	for _, r := range resp.Rails {
+		for _, s := range r.Segments {
+			items = append(items, itineraryItem{
+				start: sortableTime(s.StartDateTime),
+				title: fmt.Sprintf("%s  Train %s %s, %s to %s", formatDateTime(s.StartDateTime), s.CarrierName, s.TrainNumber, s.StartStationName, s.EndStationName),
+				lines: nonEmpty(
+					labeled("Departs", formatAddress(s.StartStationAddress)),
+					labeled("Arrives", joinNonEmpty(", ", formatDateTime(s.EndDateTime), formatAddress(s.EndStationAddress))),
+					labeled("Coach", s.CoachNumber),
+					labeled("Seats", s.Seats),
+					confirmations(firstNonEmpty(s.ConfirmationNum, r.SupplierConfNum), r.BookingSiteConfNum, r.RecordLocator),
+					labeled("Phone", r.SupplierPhone),
+				),
+			})
+		}
+	}
+	for _, l := range resp.Lodging {
+		items = append(items, itineraryItem{
+			start: sortableTime(l.StartDateTime),
+			title: fmt.Sprintf("%s  Check in at %s", formatDateTime(l.StartDateTime), firstNonEmpty(l.SupplierName, l.DisplayName)),
+			lines: nonEmpty(
+				labeled("Check out", formatDateTime(l.EndDateTime)),
+				labeled("Address", formatAddress(l.Address)),
+				labeled("Room", l.RoomType),
+				confirmations(l.SupplierConfNum, l.BookingSiteConfNum, l.RecordLocator),
+				labeled("Phone", l.SupplierPhone),
+			),
+		})
+	}
+	for _, c := range resp.Cars {
+		items = append(items, itineraryItem{
+			start: sortableTime(c.StartDateTime),
+			title: fmt.Sprintf("%s  Pick up car from %s", formatDateTime(c.StartDateTime), firstNonEmpty(c.SupplierName, c.DisplayName)),
+			lines: nonEmpty(
+				labeled("Pick up", joinNonEmpty(", ", c.StartLocationName, formatAddress(c.StartLocationAddress), c.StartLocationPhone)),
+				labeled("Drop off", joinNonEmpty(", ", formatDateTime(c.EndDateTime), c.EndLocationName, formatAddress(c.EndLocationAddress))),
+				labeled("Car", joinNonEmpty(", ", c.CarType, c.CarDescription)),
+				confirmations(c.SupplierConfNum, c.BookingSiteConfNum, c.RecordLocator),
+				labeled("Phone", c.SupplierPhone),
+			),
+		})
+	}
+	for _, t := range resp.Transports {
+		for _, s := range t.Segments {
+			items = append(items, itineraryItem{
+				start: sortableTime(s.StartDateTime),
+				title: fmt.Sprintf("%s  %s to %s", formatDateTime(s.StartDateTime), firstNonEmpty(s.CarrierName, t.DisplayName, "Transport"), firstNonEmpty(s.EndLocationName, formatAddress(s.EndLocationAddress))),
+				lines: nonEmpty(
+					labeled("From", joinNonEmpty(", ", s.StartLocationName, formatAddress(s.StartLocationAddress))),
+					confirmations(firstNonEmpty(s.ConfirmationNum, t.SupplierConfNum), t.BookingSiteConfNum, t.RecordLocator),
+					labeled("Phone", t.SupplierPhone),
+				),
+			})
+		}
+	}
+	for _, r := range resp.Restaurants {
+		items = append(items, itineraryItem{
+			start: sortableTime(r.DateTime),
+			title: fmt.Sprintf("%s  %s", formatDateTime(r.DateTime), firstNonEmpty(r.SupplierName, r.DisplayName)),
+			lines: nonEmpty(
+				labeled("Address", formatAddress(r.Address)),
+				labeled("Party of", r.NumberPatrons),
+				confirmations(r.SupplierConfNum, r.BookingSiteConfNum, r.RecordLocator),
+				labeled("Phone", r.SupplierPhone),
+			),
+		})
+	}
+	for _, a := range resp.Activities {
+		items = append(items, itineraryItem{
+			start: sortableTime(a.StartDateTime),
+			title: fmt.Sprintf("%s  %s", formatDateTime(a.StartDateTime), a.DisplayName),
+			lines: nonEmpty(
+				labeled("Where", joinNonEmpty(", ", a.LocationName, formatAddress(a.Address))),
+				confirmations(a.SupplierConfNum, a.BookingSiteConfNum, a.RecordLocator),
+				labeled("Phone", a.SupplierPhone),
+			),
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].start < items[j].start
+	})
+	for _, item := range items {
+		doc.Subheading(item.title)
+		doc.Text(strings.Join(item.lines, "\n"))
+	}
+
+	if len(contacts) > 0 {
+		doc.Space()
+		doc.Heading("Emergency contacts")
+		doc.Text(strings.Join(contacts, "\n"))
+	}
+
+	return doc
+}
+
+// sortableTime returns the local date and time in a form that sorts in time
+// order.
+func sortableTime(d tripit.DateTime) string {
+	return d.Date + "T" + d.Time
+}
+
+// formatDateTime formats the local date and time like Mon Jul 4 10:00.
+func formatDateTime(d tripit.DateTime) string {
+	if len(d.Date) < 1 {
+		return ""
+	}
+	t, err := d.Parse()
+	if err != nil {
+		return strings.TrimSpace(d.Date + " " + d.Time)
+	}
+	if len(d.Time) < 1 {
+		return t.Format("Mon Jan 2")
+	}
+	return t.Format("Mon Jan 2 15:04")
+}
+
+// formatAddress formats the address on a single line.
+func formatAddress(a tripit.Address) string {
+	if len(a.Address) > 0 {
+		return a.Address
+	}
+	return joinNonEmpty(", ", a.Addr1, a.Addr2, a.City, joinNonEmpty(" ", a.State, a.Zip), a.Country)
+}
+
+// confirmations formats the confirmation numbers that are set.
+func confirmations(supplier, bookingSite, recordLocator string) string {
+	return joinNonEmpty(", ",
+		labeled("Confirmation", supplier),
+		labeled("Booking", bookingSite),
+		labeled("Record locator", recordLocator))
+}
+
+// labeled returns "label: value", or an empty string if the value is empty.
+func labeled(label, value string) string {
+	if len(strings.TrimSpace(value)) < 1 {
+		return ""
+	}
+	return label + ": " + strings.TrimSpace(value)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if len(strings.TrimSpace(v)) > 0 {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+func joinNonEmpty(sep string, values ...string) string {
+	return strings.Join(nonEmpty(values...), sep)
+}
+
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if len(strings.TrimSpace(v)) > 0 {
+			out = append(out, strings.TrimSpace(v))
+		}
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const (
+	// eventTypeJetLag is the type of the events of a jet lag plan. They are
+	// made by the bot, not TripIt.
+	eventTypeJetLag = "jet-lag"
+
+	// jetLagMinHours is how many hours a journey has to shift the clock by
+	// to get a jet lag plan.
+	jetLagMinHours = 3
+	// jetLagMaxDays is how many days before departure the plan starts
+	// shifting sleep, an hour a day.
+	jetLagMaxDays = 3
+	// jetLagConnection is the longest layover that still counts as the
+	// same journey.
+	jetLagConnection = 24 * time.Hour
+
+	// jetLagBedtime and jetLagWake are the usual sleep the plan shifts.
+	jetLagBedtime = 23 * time.Hour
+	jetLagWake    = 7 * time.Hour
+)
+
+// jetLagEvents returns a jet lag plan for every long-haul journey in the
+// trips in the events: an event for each of the nights before departure
+// that moves sleep an hour towards the destination's clock, earlier flying
+// east and later flying west, with when to seek and avoid bright light.
+// Connecting flights are one journey, from the first departure to the last
+// arrival.
+func jetLagEvents(events []tripit.Event) []tripit.Event {
+	byTrip := map[string][]tripit.Event{}
+	var ids []string
+	for _, e := range events {
+		if !e.IsFlight() {
+			continue
+		}
+		if _, ok := byTrip[e.ID]; !ok {
+			ids = append(ids, e.ID)
+		}
+		byTrip[e.ID] = append(byTrip[e.ID], e)
+	}
+
+	var out []tripit.Event
+	for _, id := range ids {
+		flights := byTrip[id]
+		sort.SliceStable(flights, func(i, j int) bool {
+			return eventTime(flights[i].Start).Before(eventTime(flights[j].Start))
+		})
+
+		for i := 0; i < len(flights); {
+			first, last := flights[i], flights[i]
+			j := i + 1
+			for ; j < len(flights); j++ {
+				if eventTime(flights[j].Start).Sub(eventTime(last.End)) > jetLagConnection {
+					break
+				}
+				last = flights[j]
+			}
+			i = j
+
+			out = append(out, jetLagPlan(first, last)...)
+		}
+	}
+	return out
+}
+
+// jetLagPlan returns the events of the jet lag plan for the journey from the
+// departure of the first flight to the arrival of the last.
+func jetLagPlan(first, last tripit.Event) []tripit.Event {
+	departs, arrives := eventTime(first.Start), eventTime(last.End)
+	if departs.IsZero() || arrives.IsZero() {
+		return nil
+	}
+	_, from := departs.Zone()
+	_, to := arrives.Zone()
+	shift := time.Duration(to-from) * time.Second
+	// Going more than 12 hours one way is closer going the other.
+	if shift > 12*time.Hour {
+		shift -= 24 * time.Hour
+	} else if shift < -12*time.Hour {
+		shift += 24 * time.Hour
+	}
+
+	hours := shift
+	direction, light := "ahead of", "Get bright light as soon as you get up, and keep the lights low in the evening."
+	step, way := -time.Hour, "earlier"
+	if shift < 0 {
+		hours = -shift
+		direction, light = "behind", "Get bright light in the late afternoon and evening, and keep the lights low in the morning."
+		step, way = time.Hour, "later"
+	}
+	if hours < jetLagMinHours*time.Hour {
+		return nil
+	}
+	days := int(hours / time.Hour)
+	if days > jetLagMaxDays {
+		days = jetLagMaxDays
+	}
+
+	departureDay := time.Date(departs.Year(), departs.Month(), departs.Day(), 0, 0, 0, 0, departs.Location())
+	var events []tripit.Event
+	for k := 1; k <= days; k++ {
+		night := departureDay.AddDate(0, 0, k-days-1)
+		bed := night.Add(jetLagBedtime + time.Duration(k)*step)
+		wake := night.Add(24*time.Hour + jetLagWake + time.Duration(k)*step)
+
+		description := fmt.Sprintf(`Night %d of %d getting used to %s time (%s), %s %s where you depart, before %s on %s.
+
+Go to bed at %s and get up at %s, an hour %s than the night before. %s`,
+			k, days,
+			last.DestinationCity,
+			utcOffset(arrives),
+			formatHours(hours),
+			direction,
+			first.Title,
+			departs.Format("Mon Jan 2"),
+			bed.Format("15:04"),
+			wake.Format("15:04"),
+			way,
+			light)
+
+		events = append(events, tripit.Event{
+			Type:               eventTypeJetLag,
+			Title:              fmt.Sprintf("Jet lag plan: sleep %s to %s (%d/%d)", bed.Format("15:04"), wake.Format("15:04"), k, days),
+			Description:        description,
+			Start:              calendar.EventDateTime{DateTime: bed.Format(time.RFC3339), TimeZone: first.Start.TimeZone},
+			End:                calendar.EventDateTime{DateTime: wake.Format(time.RFC3339), TimeZone: first.Start.TimeZone},
+			ID:                 first.ID,
+			SegmentID:          fmt.Sprintf("%s-jet-lag-%d", first.SegmentID, k),
+			ConfirmationNumber: first.ConfirmationNumber,
+			DestinationCity:    last.DestinationCity,
+			TripName:           first.TripName,
+			Private:            first.Private,
+			Tags:               first.Tags,
+		})
+	}
+	return events
+}
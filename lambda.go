@@ -0,0 +1,106 @@
+//go:build lambda
+// +build lambda
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	lambdaRuntimeAPIVersion = "2018-06-01"
+)
+
+// functionSync is the sync that is run for every function invocation.
+var functionSync func(context.Context) error
+
+// serveFunction runs the given sync once per invocation when the binary is
+// running inside an AWS Lambda custom runtime or a Cloud Functions (or Cloud
+// Run) container. It returns false if we are not inside either of those.
+func serveFunction(ctx context.Context, sync func(context.Context) error) (bool, error) {
+	functionSync = sync
+
+	if api := os.Getenv("AWS_LAMBDA_RUNTIME_API"); len(api) > 0 {
+		logrus.Infof("Serving AWS Lambda invocations from %s", api)
+		return true, serveLambda(ctx, api)
+	}
+
+	port := os.Getenv("PORT")
+	if len(port) > 0 && (len(os.Getenv("FUNCTION_TARGET")) > 0 || len(os.Getenv("K_SERVICE")) > 0) {
+		logrus.Infof("Serving function invocations on port %s", port)
+		http.HandleFunc("/", Handler)
+		return true, http.ListenAndServe(":"+port, nil)
+	}
+
+	return false, nil
+}
+
+// Handler is an HTTP handler that runs a single sync of TripIt to Google
+// Calendar. It is meant to be triggered on a schedule by something like
+// Cloud Scheduler.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	if err := functionSync(r.Context()); err != nil {
+		logrus.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// lambdaError is the error document the Lambda runtime API expects.
+type lambdaError struct {
+	Message string `json:"errorMessage"`
+	Type    string `json:"errorType"`
+}
+
+// serveLambda implements the AWS Lambda runtime API loop for custom runtimes.
+// See: https://docs.aws.amazon.com/lambda/latest/dg/runtimes-api.html
+func serveLambda(ctx context.Context, api string) error {
+	base := fmt.Sprintf("http://%s/%s/runtime/invocation", api, lambdaRuntimeAPIVersion)
+
+	for {
+		// Wait for the next invocation.
+		resp, err := http.Get(base + "/next")
+		if err != nil {
+			return fmt.Errorf("getting next lambda invocation failed: %v", err)
+		}
+		// We do not care about the event payload, the schedule is the trigger.
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		requestID := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+
+		// Bound the sync by the invocation deadline.
+		deadline := time.Now().Add(15 * time.Minute)
+		if ms, err := strconv.ParseInt(resp.Header.Get("Lambda-Runtime-Deadline-Ms"), 10, 64); err == nil {
+			deadline = time.Unix(0, ms*int64(time.Millisecond))
+		}
+		ictx, cancel := context.WithDeadline(ctx, deadline)
+		syncErr := functionSync(ictx)
+		cancel()
+
+		// Report the invocation as done, or as failed.
+		uri := fmt.Sprintf("%s/%s/response", base, requestID)
+		body := []byte(`{"status":"ok"}`)
+		if syncErr != nil {
+			logrus.Error(syncErr)
+			uri = fmt.Sprintf("%s/%s/error", base, requestID)
+			body, _ = json.Marshal(lambdaError{Message: syncErr.Error(), Type: "SyncError"})
+		}
+		resp, err = http.Post(uri, "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			return fmt.Errorf("posting lambda result for request %s failed: %v", requestID, err)
+		}
+		resp.Body.Close()
+	}
+}
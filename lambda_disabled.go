@@ -0,0 +1,11 @@
+//go:build !lambda
+// +build !lambda
+
+package main
+
+import "context"
+
+// serveFunction is a no-op when we are not built with the lambda build tag.
+func serveFunction(ctx context.Context, sync func(context.Context) error) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	kubernetesMicroTimeFormat   = "2006-01-02T15:04:05.000000Z07:00"
+
+	// leaseLockStale is how old a lease lock file is before it is broken.
+	// Holding it only takes a read and a write of the lease file.
+	leaseLockStale = 30 * time.Second
+	// leaseLockRetry is how long to wait for another replica to release
+	// the lease lock file.
+	leaseLockRetry = 50 * time.Millisecond
+)
+
+// leaser acquires and renews a lease so that only one of many replicas syncs
+// at a time. If the replica holding the lease dies, another replica takes
+// over once the lease expires.
+type leaser interface {
+	// Acquire acquires or renews the lease and returns true if we hold it.
+	Acquire(ctx context.Context) (bool, error)
+}
+
+// newLeaser returns the leaser for the given configuration or nil if leader
+// election is disabled.
+func newLeaser(kubernetesLease, leaseFile, identity string, duration time.Duration) (leaser, error) {
+	if len(identity) < 1 {
+		h, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("getting hostname for lease identity failed: %v", err)
+		}
+		identity = h
+	}
+
+	switch {
+	case len(kubernetesLease) > 0 && len(leaseFile) > 0:
+		return nil, fmt.Errorf("only one of lease-kubernetes and lease-file can be set")
+	case len(kubernetesLease) > 0:
+		return newKubernetesLeaser(kubernetesLease, identity, duration)
+	case len(leaseFile) > 0:
+		return &fileLeaser{path: leaseFile, identity: identity, duration: duration}, nil
+	}
+
+	return nil, nil
+}
+
+// fileLease is the lease document stored in the lease file.
+type fileLease struct {
+	Holder    string    `json:"holder"`
+	RenewTime time.Time `json:"renewTime"`
+	Duration  string    `json:"duration"`
+}
+
+// fileLeaser is a simple lease stored in a file on a volume shared by all
+// the replicas.
+type fileLeaser struct {
+	path     string
+	identity string
+	duration time.Duration
+}
+
+// Acquire acquires or renews the lease in the lease file. The lease is read,
+// checked, and written while holding the lock file next to it, so two
+// replicas that both find it expired cannot both take it.
+func (l *fileLeaser) Acquire(ctx context.Context) (bool, error) {
+	unlock, err := l.lock(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	current, err := l.read()
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	if current != nil && current.Holder != l.identity {
+		d, err := time.ParseDuration(current.Duration)
+		if err != nil {
+			d = l.duration
+		}
+		if now.Before(current.RenewTime.Add(d)) {
+			// Someone else holds a lease that has not yet expired.
+			return false, nil
+		}
+	}
+
+	b, err := json.Marshal(fileLease{
+		Holder:    l.identity,
+		RenewTime: now,
+		Duration:  l.duration.String(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// Write the lease atomically so other replicas never read a partial file.
+	tmp := fmt.Sprintf("%s.%s.tmp", l.path, l.identity)
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return false, fmt.Errorf("writing lease file %s failed: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return false, fmt.Errorf("renaming lease file %s to %s failed: %v", tmp, l.path, err)
+	}
+	return true, nil
+}
+
+// lock creates the lock file next to the lease file, waiting for the replica
+// that holds it to finish, and returns the func that removes it. Creating a
+// file that must not exist yet is atomic, on network volumes too. A lock
+// file older than leaseLockStale was left behind by a replica that died
+// while holding it, and is broken, see breakStaleLock.
+func (l *fileLeaser) lock(ctx context.Context) (func(), error) {
+	path := l.path + ".lock"
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() {
+				os.Remove(path)
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("creating lease lock file %s failed: %v", path, err)
+		}
+
+		if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) > leaseLockStale {
+			if breakStaleLock(path, fi) {
+				logrus.Warnf("broke lease lock file %s left behind %s ago", path, time.Since(fi.ModTime()).Round(time.Second))
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(leaseLockRetry):
+		}
+	}
+}
+
+// breakStaleLock removes the stale lock file at path, which was stale when
+// we looked at it with stale, and returns true if it did. Another replica
+// may break it and take the lock in between, so it moves the file out of the
+// way first, which only one replica can do, and puts it back if it turns
+// out not to be the file that was stale.
+func breakStaleLock(path string, stale os.FileInfo) bool {
+	moved := path + ".stale-" + randomString()
+	if err := os.Rename(path, moved); err != nil {
+		return false
+	}
+	if fi, err := os.Stat(moved); err == nil && !os.SameFile(fi, stale) {
+		// Linking fails if another replica took the lock since, which
+		// is as good as putting it back.
+		os.Link(moved, path)
+		os.Remove(moved)
+		return false
+	}
+	os.Remove(moved)
+	return true
+}
+
+// holdLease renews the lease every third of its duration while a run is in
+// progress, so a run longer than the lease does not let another replica take
+// it over and sync at the same time. The returned context is cancelled if the
+// lease is lost, and stop stops renewing it and returns true if it was.
+func holdLease(ctx context.Context, elector leaser, duration time.Duration) (context.Context, func() bool) {
+	if elector == nil {
+		return ctx, func() bool { return false }
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var (
+		lost    int32
+		done    = make(chan struct{})
+		stopped = make(chan struct{})
+	)
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(duration / 3)
+		defer ticker.Stop()
+
+		renewed := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			ok, err := elector.Acquire(ctx)
+			if err == nil && ok {
+				renewed = time.Now()
+				continue
+			}
+			if err != nil && time.Since(renewed) < duration {
+				// We still hold it until it expires, so try again.
+				logrus.Warnf("renewing lease failed: %v", err)
+				continue
+			}
+			logrus.Error("lost the lease, stopping the run in progress")
+			atomic.StoreInt32(&lost, 1)
+			cancel()
+			return
+		}
+	}()
+
+	return ctx, func() bool {
+		close(done)
+		<-stopped
+		cancel()
+		return atomic.LoadInt32(&lost) == 1
+	}
+}
+
+func (l *fileLeaser) read() (*fileLease, error) {
+	b, err := ioutil.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading lease file %s failed: %v", l.path, err)
+	}
+
+	var lease fileLease
+	if err := json.Unmarshal(b, &lease); err != nil {
+		return nil, fmt.Errorf("decoding lease file %s failed: %v", l.path, err)
+	}
+	return &lease, nil
+}
+
+// kubernetesLease is the subset of a coordination.k8s.io/v1 Lease we use.
+type kubernetesLease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          string `json:"acquireTime,omitempty"`
+		RenewTime            string `json:"renewTime,omitempty"`
+		LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+	} `json:"spec"`
+}
+
+// kubernetesLeaser uses a Kubernetes Lease object in the namespace of the
+// pod for leader election. It talks to the API server with the pod's
+// service account, which needs get, create, and update on leases.
+type kubernetesLeaser struct {
+	client    *http.Client
+	host      string
+	token     string
+	namespace string
+	name      string
+	identity  string
+	duration  time.Duration
+}
+
+func newKubernetesLeaser(name, identity string, duration time.Duration) (*kubernetesLeaser, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if len(host) < 1 || len(port) < 1 {
+		return nil, fmt.Errorf("kubernetes leases are only supported when running inside a cluster")
+	}
+
+	token, err := ioutil.ReadFile(filepath.Join(kubernetesServiceAccountDir, "token"))
+	if err != nil {
+		return nil, fmt.Errorf("reading kubernetes service account token failed: %v", err)
+	}
+	namespace, err := ioutil.ReadFile(filepath.Join(kubernetesServiceAccountDir, "namespace"))
+	if err != nil {
+		return nil, fmt.Errorf("reading kubernetes namespace failed: %v", err)
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(kubernetesServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("reading kubernetes ca certificate failed: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("parsing kubernetes ca certificate failed")
+	}
+
+	return &kubernetesLeaser{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+		host:      "https://" + net.JoinHostPort(host, port),
+		token:     strings.TrimSpace(string(token)),
+		namespace: strings.TrimSpace(string(namespace)),
+		name:      name,
+		identity:  identity,
+		duration:  duration,
+	}, nil
+}
+
+// Acquire acquires or renews the Kubernetes lease. Updates use the
+// resourceVersion of the lease we read, so if two replicas race for an
+// expired lease the API server only lets one of them win.
+func (l *kubernetesLeaser) Acquire(ctx context.Context) (bool, error) {
+	uri := fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", l.host, l.namespace)
+
+	var lease kubernetesLease
+	status, err := l.do(ctx, http.MethodGet, uri+"/"+l.name, nil, &lease)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	method := http.MethodPut
+	switch status {
+	case http.StatusNotFound:
+		// Nobody has created the lease yet.
+		lease = kubernetesLease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+		lease.Metadata.Name = l.name
+		lease.Metadata.Namespace = l.namespace
+		method = http.MethodPost
+	case http.StatusOK:
+		if lease.Spec.HolderIdentity != l.identity {
+			renew, err := time.Parse(kubernetesMicroTimeFormat, lease.Spec.RenewTime)
+			expiry := renew.Add(time.Duration(lease.Spec.LeaseDurationSeconds) * time.Second)
+			if err == nil && now.Before(expiry) {
+				// Someone else holds a lease that has not yet expired.
+				return false, nil
+			}
+			lease.Spec.LeaseTransitions++
+			lease.Spec.AcquireTime = ""
+		}
+	default:
+		return false, fmt.Errorf("getting kubernetes lease %s/%s returned status code %d", l.namespace, l.name, status)
+	}
+
+	lease.Spec.HolderIdentity = l.identity
+	lease.Spec.LeaseDurationSeconds = int(l.duration.Seconds())
+	lease.Spec.RenewTime = now.UTC().Format(kubernetesMicroTimeFormat)
+	if len(lease.Spec.AcquireTime) < 1 {
+		lease.Spec.AcquireTime = lease.Spec.RenewTime
+	}
+
+	if method == http.MethodPut {
+		uri += "/" + l.name
+	}
+	status, err = l.do(ctx, method, uri, lease, nil)
+	if err != nil {
+		return false, err
+	}
+	switch status {
+	case http.StatusOK, http.StatusCreated:
+		return true, nil
+	case http.StatusConflict:
+		// Another replica beat us to it.
+		return false, nil
+	}
+	return false, fmt.Errorf("updating kubernetes lease %s/%s returned status code %d", l.namespace, l.name, status)
+}
+
+func (l *kubernetesLeaser) do(ctx context.Context, method, uri string, in, out interface{}) (int, error) {
+	b := bytes.NewBuffer(nil)
+	if in != nil {
+		if err := json.NewEncoder(b).Encode(in); err != nil {
+			return 0, fmt.Errorf("json encoding kubernetes lease failed: %v", err)
+		}
+	}
+
+	req, err := http.NewRequest(method, uri, b)
+	if err != nil {
+		return 0, fmt.Errorf("creating %s request to %s failed: %v", method, uri, err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+l.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("performing %s request to %s failed: %v", method, uri, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return 0, fmt.Errorf("decoding kubernetes lease failed: %v", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
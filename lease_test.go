@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileLeaser(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "lease.json")
+	a := &fileLeaser{path: path, identity: "a", duration: time.Minute}
+	b := &fileLeaser{path: path, identity: "b", duration: time.Minute}
+
+	if ok, err := a.Acquire(ctx); err != nil || !ok {
+		t.Fatalf("a.Acquire of a free lease = %t, %v, want it taken", ok, err)
+	}
+	if ok, err := b.Acquire(ctx); err != nil || ok {
+		t.Fatalf("b.Acquire of a's lease = %t, %v, want it refused", ok, err)
+	}
+	if ok, err := a.Acquire(ctx); err != nil || !ok {
+		t.Fatalf("a.Acquire renewing its own lease = %t, %v, want it renewed", ok, err)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("the lock file is left behind: %v", err)
+	}
+}
+
+func TestFileLeaserExpired(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "lease.json")
+	a := &fileLeaser{path: path, identity: "a", duration: time.Millisecond}
+	b := &fileLeaser{path: path, identity: "b", duration: time.Minute}
+
+	if ok, err := a.Acquire(ctx); err != nil || !ok {
+		t.Fatalf("a.Acquire of a free lease = %t, %v, want it taken", ok, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if ok, err := b.Acquire(ctx); err != nil || !ok {
+		t.Fatalf("b.Acquire of an expired lease = %t, %v, want it taken", ok, err)
+	}
+	if ok, err := a.Acquire(ctx); err != nil || ok {
+		t.Fatalf("a.Acquire of b's lease = %t, %v, want it refused", ok, err)
+	}
+}
+
+func TestFileLeaserConcurrent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "lease.json")
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		holders []string
+	)
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			l := &fileLeaser{path: path, identity: id, duration: time.Minute}
+			ok, err := l.Acquire(ctx)
+			if err != nil {
+				t.Errorf("%s.Acquire failed: %v", id, err)
+			}
+			if ok {
+				mu.Lock()
+				holders = append(holders, id)
+				mu.Unlock()
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if len(holders) != 1 {
+		t.Errorf("%v took the lease at once, want only one", holders)
+	}
+}
+
+func TestFileLeaserStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	lock := path + ".lock"
+	if err := ioutil.WriteFile(lock, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * leaseLockStale)
+	if err := os.Chtimes(lock, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	l := &fileLeaser{path: path, identity: "a", duration: time.Minute}
+	if ok, err := l.Acquire(ctx); err != nil || !ok {
+		t.Fatalf("Acquire with a stale lock file = %t, %v, want it broken and the lease taken", ok, err)
+	}
+}
+
+func TestFileLeaserStaleLockConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lease.json")
+	lock := path + ".lock"
+	if err := ioutil.WriteFile(lock, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * leaseLockStale)
+	if err := os.Chtimes(lock, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		holders []string
+	)
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			l := &fileLeaser{path: path, identity: id, duration: time.Minute}
+			ok, err := l.Acquire(ctx)
+			if err != nil {
+				t.Errorf("%s.Acquire failed: %v", id, err)
+			}
+			if ok {
+				mu.Lock()
+				holders = append(holders, id)
+				mu.Unlock()
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if len(holders) != 1 {
+		t.Errorf("%v took the lease after breaking the stale lock, want only one", holders)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0] != path {
+		t.Errorf("files left behind = %v, want only the lease file", files)
+	}
+}
+
+// flakyLeaser is a leaser that answers with the next of its results.
+type flakyLeaser struct {
+	mu      sync.Mutex
+	results []error
+	held    bool
+}
+
+func (f *flakyLeaser) Acquire(ctx context.Context) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.results) < 1 {
+		return f.held, nil
+	}
+	err := f.results[0]
+	f.results = f.results[1:]
+	return err == nil && f.held, err
+}
+
+func TestHoldLease(t *testing.T) {
+	elector := &flakyLeaser{held: true, results: []error{nil, errors.New("timeout"), nil}}
+	ctx, stop := holdLease(context.Background(), elector, 30*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	if ctx.Err() != nil {
+		t.Error("the run was cancelled though a failed renewal was retried in time")
+	}
+	if stop() {
+		t.Error("stop says the lease was lost, want it held")
+	}
+}
+
+func TestHoldLeaseLost(t *testing.T) {
+	elector := &flakyLeaser{held: false}
+	ctx, stop := holdLease(context.Background(), elector, 30*time.Millisecond)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run was not cancelled after the lease was lost")
+	}
+	if !stop() {
+		t.Error("stop says the lease was held, want it lost")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// logBackupFormat is the format of the time in the name of a rotated log file.
+const logBackupFormat = "20060102T150405"
+
+// rotatingFile is a log file that rotates itself once it grows larger than
+// maxSize or older than maxAge, keeping the last maxBackups rotated files, for
+// running the bot without an init system that collects its logs.
+type rotatingFile struct {
+	mu sync.Mutex
+
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	f       *os.File
+	size    int64
+	created time.Time
+}
+
+// openRotatingFile opens the log file at path, appending to it if it exists.
+func openRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("opening log file %s failed: %v", r.path, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("opening log file %s failed: %v", r.path, err)
+	}
+	r.f = f
+	r.size = fi.Size()
+	// The file does not know when it was created, so a file we append to
+	// counts from when it was last written.
+	r.created = fi.ModTime()
+	if r.size == 0 {
+		r.created = time.Now()
+	}
+	return nil
+}
+
+// Write writes p to the log file, rotating it first if it is due.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size > 0 && ((r.maxSize > 0 && r.size+int64(len(p)) > r.maxSize) || (r.maxAge > 0 && time.Since(r.created) > r.maxAge)) {
+		if err := r.rotate(); err != nil {
+			// Keep logging to the file we have rather than losing
+			// the logs.
+			fmt.Fprintf(os.Stderr, "rotating log file %s failed: %v\n", r.path, err)
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate renames the log file out of the way, opens a new one, and removes
+// the oldest rotated files.
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(r.path, r.path+"."+time.Now().Format(logBackupFormat)); err != nil {
+		// Carry on with the old file.
+		if openErr := r.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	backups, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return err
+	}
+	// The time in the names sorts them oldest first.
+	sort.Strings(backups)
+	for len(backups) > r.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// journaldSocket is where journald takes log entries in its native
+	// protocol.
+	journaldSocket = "/run/systemd/journal/socket"
+
+	// syslogFacilityDaemon is the syslog facility of system daemons.
+	syslogFacilityDaemon = 3
+)
+
+// syslogSeverity returns the syslog severity, which journald calls the
+// priority, for the log level.
+func syslogSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel:
+		return 0 // emerg
+	case logrus.FatalLevel:
+		return 2 // crit
+	case logrus.ErrorLevel:
+		return 3 // err
+	case logrus.WarnLevel:
+		return 4 // warning
+	case logrus.InfoLevel:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
+
+// entryText returns the message of the log entry followed by its fields.
+func entryText(entry *logrus.Entry) string {
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	msg := entry.Message
+	for _, k := range keys {
+		msg += fmt.Sprintf(" %s=%v", k, entry.Data[k])
+	}
+	return msg
+}
+
+// syslogHook sends log entries to a syslog server as RFC 5424 messages.
+type syslogHook struct {
+	mu       sync.Mutex
+	network  string
+	addr     string
+	conn     net.Conn
+	hostname string
+}
+
+// newSyslogHook returns a hook that logs to the syslog server at raw, one of
+// udp://host:port, tcp://host:port, or unix:///path/to/socket.
+func newSyslogHook(raw string) (*syslogHook, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing syslog address %s failed: %v", raw, err)
+	}
+	h := &syslogHook{}
+	switch u.Scheme {
+	case "udp", "tcp":
+		if len(u.Host) < 1 {
+			return nil, fmt.Errorf("syslog address %s has no host", raw)
+		}
+		h.network, h.addr = u.Scheme, u.Host
+	case "unix":
+		if len(u.Path) < 1 {
+			return nil, fmt.Errorf("syslog address %s has no path", raw)
+		}
+		// Local syslog daemons listen on a datagram socket, like /dev/log.
+		h.network, h.addr = "unixgram", u.Path
+	default:
+		return nil, fmt.Errorf("syslog address %s must start with udp://, tcp://, or unix://", raw)
+	}
+	h.hostname, _ = os.Hostname()
+	if len(h.hostname) < 1 {
+		h.hostname = "-"
+	}
+
+	if err := h.dial(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *syslogHook) dial() error {
+	conn, err := net.DialTimeout(h.network, h.addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("connecting to syslog at %s failed: %v", h.addr, err)
+	}
+	h.conn = conn
+	return nil
+}
+
+// Levels returns the levels the hook logs, which is all of them.
+func (h *syslogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire sends the log entry to syslog, connecting again once if the
+// connection was lost.
+func (h *syslogHook) Fire(entry *logrus.Entry) error {
+	msg := fmt.Sprintf("<%d>1 %s %s tripitcalb0t %d - - %s",
+		syslogFacilityDaemon*8+syslogSeverity(entry.Level),
+		entry.Time.Format(time.RFC3339Nano),
+		h.hostname,
+		os.Getpid(),
+		entryText(entry))
+	// Over TCP messages are framed by their length, as RFC 6587 says.
+	if h.network == "tcp" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conn != nil {
+		if _, err := h.conn.Write([]byte(msg)); err == nil {
+			return nil
+		}
+		h.conn.Close()
+		h.conn = nil
+	}
+	if err := h.dial(); err != nil {
+		return err
+	}
+	_, err := h.conn.Write([]byte(msg))
+	return err
+}
+
+// journaldHook sends log entries to journald in its native protocol, so
+// they keep their priority and fields.
+type journaldHook struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// newJournaldHook returns a hook that logs to the local journald.
+func newJournaldHook() (*journaldHook, error) {
+	conn, err := net.Dial("unixgram", journaldSocket)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to journald at %s failed: %v", journaldSocket, err)
+	}
+	return &journaldHook{conn: conn}, nil
+}
+
+// Levels returns the levels the hook logs, which is all of them.
+func (h *journaldHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire sends the log entry to journald.
+func (h *journaldHook) Fire(entry *logrus.Entry) error {
+	var b bytes.Buffer
+	journaldField(&b, "MESSAGE", entry.Message)
+	journaldField(&b, "PRIORITY", fmt.Sprintf("%d", syslogSeverity(entry.Level)))
+	journaldField(&b, "SYSLOG_IDENTIFIER", "tripitcalb0t")
+	for k, v := range entry.Data {
+		journaldField(&b, journaldFieldName(k), fmt.Sprint(v))
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.conn.Write(b.Bytes())
+	return err
+}
+
+// journaldField writes the field to b. Values with newlines are written with
+// their length in front, since a newline would otherwise end them.
+func journaldField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journaldFieldName returns the log field name as journald wants it, in
+// uppercase letters, digits, and underscores. The prefix keeps it from
+// clashing with the fields journald sets itself.
+func journaldFieldName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+	return "FIELD_" + name
+}
@@ -10,7 +10,6 @@ import (
 	"os/signal"
 	"os/user"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -18,26 +17,155 @@ import (
 	"github.com/genuinetools/pkg/cli"
 	"github.com/jessfraz/tripitcalb0t/tripit"
 	"github.com/jessfraz/tripitcalb0t/version"
-	"github.com/mmcloughlin/openflights"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/oauth2/google"
-	calendar "google.golang.org/api/calendar/v3"
 )
 
 var (
+	configFile string
+
 	googleCalendarKeyfile string
 	calendarName          string
+	caldavURL             string
+	caldavUsername        string
+	caldavPassword        string
+	outlookTenant         string
+	outlookClientID       string
+	outlookClientSecret   string
+	outlookUser           string
+	outlookCalendar       string
+	outlookTokenFile      string
+	icsFile               string
+	calendarBackends      string
+	icsName               string
+	companionCalendar     string
+	companionKeyfile      string
+	companionTags         string
 	credsDir              string
+	stateFile             string
+	incremental           bool
+	fullFetch             time.Duration
 	pastFilter            string
 
+	duplicateWindow    time.Duration
+	busyCalendars      string
+	declineMeetings    bool
+	declineMessage     string
+	declineSendUpdates string
+	activities         bool
+	tripEvents         bool
+	jetLagPlans        bool
+	holidayNotes       bool
+	destinationInfo    bool
+	passport           string
+	visaReminder       time.Duration
+	checklist          bool
+	checklistFile      string
+	refdataDir         string
+	insuranceFile      string
+	baggageFile        string
+	holidayNames       string
+	workingHours       string
+	homeTimezone       string
+
+	cancellationReminders bool
+	cancellationReminder  time.Duration
+
+	fareRechecks      bool
+	fareRecheckEvery  time.Duration
+	fareRecheckCutoff time.Duration
+	refundable        string
+
+	visibility string
+	hashtags   bool
+
+	tripKinds            string
+	businessDestinations string
+
+	descriptionFooter     bool
+	descriptionFooterText string
+
+	sendUpdatesCreate string
+	sendUpdatesUpdate string
+
+	leaseKubernetes string
+	leaseFile       string
+	leaseIdentity   string
+	leaseDuration   time.Duration
+
 	tripitUsername string
 	tripitPassword string
+	tripitMaxPages int
+
+	tripitRetries      int
+	tripitRetryBackoff time.Duration
+	tripitRetryJitter  float64
+
+	removalGraceRuns int
+	cancelledEvents  string
+	historySize      int
+
+	strictPermissions bool
+	fixPermissions    bool
+
+	archive         bool
+	archiveCalendar string
+	retentionYears  int
+
+	travelingFile string
+	mqttBroker    string
+	mqttTopic     string
+	mqttUsername  string
+	mqttPassword  string
+
+	slackToken         string
+	slackSigningSecret string
+	slackUsers         string
+
+	vacationResponder string
+	vacationSubject   string
+	vacationMessage   string
 
-	interval time.Duration
-	once     bool
-	past     bool
+	emergencyContacts string
 
-	debug bool
+	googleChatWebhook string
+	teamsWebhook      string
+
+	httpAddr    string
+	metricsAddr string
+	staleAfter  int
+
+	shareSecret string
+	shareURL    string
+
+	usersFile string
+
+	oidcIssuer       string
+	oidcClientID     string
+	oidcClientSecret string
+	oidcRedirectURL  string
+
+	interval    time.Duration
+	runTimeout  time.Duration
+	maxFailures int
+	once        bool
+	dryRun      bool
+	demo        bool
+	approve     bool
+	output      string
+	past        bool
+
+	referenceCacheSize int
+
+	debug       bool
+	debugSample int
+
+	logFile       string
+	logMaxSize    int
+	logMaxAge     time.Duration
+	logMaxBackups int
+	logStderr     bool
+	syslogAddr    string
+	journald      bool
 )
 
 func main() {
@@ -57,43 +185,228 @@ func main() {
 	p.GitCommit = version.GITCOMMIT
 	p.Version = version.VERSION
 
+	// Setup the commands.
+	p.Commands = []cli.Command{
+		&approveCommand{},
+		&cardCommand{},
+		&checklistCommand{},
+		&compensationCommand{},
+		&conflictsCommand{},
+		&dedupeCommand{},
+		&diffCommand{},
+		&doctorCommand{},
+		&eventsCommand{},
+		&exportCommand{},
+		&fetchCommand{},
+		&historyCommand{},
+		&nextCommand{},
+		&outlookCommand{},
+		&pdfCommand{},
+		&reconcileCommand{},
+		&pruneCommand{},
+		&replayCommand{},
+		&restoreCommand{},
+		&searchCommand{},
+		&shareCommand{},
+		&tripsCommand{},
+		&tuiCommand{},
+		&undoCommand{},
+		&watchCommand{},
+	}
+
 	// Setup the global flags.
 	p.FlagSet = flag.NewFlagSet("global", flag.ExitOnError)
+	p.FlagSet.StringVar(&configFile, "config", firstNonEmpty(os.Getenv("TRIPITCALB0T_CONFIG"), filepath.Join(credsDir, "config.yaml")), "Path to a config file of flags, which the flags passed and their env vars win over (or env var TRIPITCALB0T_CONFIG)")
 	p.FlagSet.StringVar(&googleCalendarKeyfile, "google-keyfile", filepath.Join(credsDir, "google.json"), "Path to Google Calendar keyfile")
 	p.FlagSet.StringVar(&calendarName, "calendar", os.Getenv("GOOGLE_CALENDAR_ID"), "Calendar name to add events to (or env var GOOGLE_CALENDAR_ID)")
+	p.FlagSet.StringVar(&caldavURL, "caldav-url", os.Getenv("CALDAV_URL"), "URL of a CalDAV calendar to add events to instead of Google Calendar (or env var CALDAV_URL)")
+	p.FlagSet.StringVar(&caldavUsername, "caldav-username", os.Getenv("CALDAV_USERNAME"), "CalDAV username for authentication (or env var CALDAV_USERNAME)")
+	p.FlagSet.StringVar(&caldavPassword, "caldav-password", "", "CalDAV app password for authentication (or env var CALDAV_PASSWORD)")
+	p.FlagSet.StringVar(&outlookClientID, "outlook-client-id", os.Getenv("OUTLOOK_CLIENT_ID"), "Application ID of the Microsoft Entra app registration to add events to an Outlook calendar with instead of Google Calendar (or env var OUTLOOK_CLIENT_ID)")
+	p.FlagSet.StringVar(&outlookClientSecret, "outlook-client-secret", "", "Client secret of the app registration, to sign in as the app instead of with outlook login (or env var OUTLOOK_CLIENT_SECRET)")
+	p.FlagSet.StringVar(&outlookTenant, "outlook-tenant", firstNonEmpty(os.Getenv("OUTLOOK_TENANT_ID"), "common"), "Microsoft Entra tenant ID or domain of the app registration (or env var OUTLOOK_TENANT_ID)")
+	p.FlagSet.StringVar(&outlookUser, "outlook-user", os.Getenv("OUTLOOK_USER"), "User whose calendar to add events to, needed with --outlook-client-secret (or env var OUTLOOK_USER)")
+	p.FlagSet.StringVar(&outlookCalendar, "outlook-calendar", "", "ID of the Outlook calendar to add events to (defaults to the user's default calendar)")
+	p.FlagSet.StringVar(&icsFile, "ics-file", os.Getenv("ICS_FILE"), "Path of an iCalendar (.ics) file to write events to instead of Google Calendar (or env var ICS_FILE)")
+	p.FlagSet.StringVar(&calendarBackends, "backends", os.Getenv("CALENDAR_BACKENDS"), "Comma separated calendars to sync to at once, of google, caldav, outlook, and ics, each set up with its own flags (or env var CALENDAR_BACKENDS)")
+	p.FlagSet.StringVar(&icsName, "ics-name", "TripIt", "Name calendar apps show for the .ics file")
+	p.FlagSet.StringVar(&companionCalendar, "companion-calendar", os.Getenv("COMPANION_CALENDAR"), "Google calendar of a companion to mirror the trips you take together to (or env var COMPANION_CALENDAR)")
+	p.FlagSet.StringVar(&companionKeyfile, "companion-keyfile", os.Getenv("COMPANION_KEYFILE"), "Path to the Google service account keyfile for the companion's calendar, defaults to your own (or env var COMPANION_KEYFILE)")
+	p.FlagSet.StringVar(&companionTags, "companion-tags", "family", "Comma separated tags of the trips to mirror to the companion's calendar")
+	p.FlagSet.StringVar(&outlookTokenFile, "outlook-token-file", filepath.Join(credsDir, "outlook-token.json"), "Path to the file outlook login saves the token to")
+
+	p.FlagSet.BoolVar(&incremental, "incremental", false, "Only fetch the trips that changed in TripIt since the last successful sync, and everything every --full-fetch")
+	p.FlagSet.DurationVar(&fullFetch, "full-fetch", 24*time.Hour, "How often to fetch every trip from TripIt with --incremental, to notice deleted trips")
+	p.FlagSet.StringVar(&stateFile, "state-file", filepath.Join(credsDir, "state.json"), "Path to the file where the bot remembers the events it synced, empty to disable")
 
 	p.FlagSet.StringVar(&tripitUsername, "tripit-username", os.Getenv("TRIPIT_USERNAME"), "TripIt Username for authentication (or env var TRIPIT_USERNAME)")
-	p.FlagSet.StringVar(&tripitPassword, "tripit-password", os.Getenv("TRIPIT_PASSWORD"), "TripIt Password for authentication (or env var TRIPIT_PASSWORD)")
+	p.FlagSet.StringVar(&tripitPassword, "tripit-password", "", "TripIt Password for authentication (or env var TRIPIT_PASSWORD)")
+	p.FlagSet.IntVar(&tripitRetries, "tripit-retries", tripit.DefaultRetryPolicy.MaxAttempts-1, "How many times to retry TripIt requests that fail with a network or server error")
+	p.FlagSet.DurationVar(&tripitRetryBackoff, "tripit-retry-backoff", tripit.DefaultRetryPolicy.Backoff, "How long to wait before the first retry of a TripIt request, doubling with every retry")
+	p.FlagSet.Float64Var(&tripitRetryJitter, "tripit-retry-jitter", tripit.DefaultRetryPolicy.Jitter, "Fraction of every wait between TripIt retries, from 0 to 1, that is random")
+	p.FlagSet.IntVar(&tripitMaxPages, "tripit-max-pages", 0, "Most pages of 25 trips to get from TripIt at a time, 0 gets every page")
+
+	p.FlagSet.DurationVar(&duplicateWindow, "duplicate-window", 6*time.Hour, "Flights on the same route departing within this long of each other with different confirmations are reported as double bookings")
+	p.FlagSet.StringVar(&tripKinds, "trip-kinds", "", "Comma separated trip ID=kind pairs for trips classified wrong, kind is business, personal, or weekend")
+	p.FlagSet.StringVar(&businessDestinations, "business-destinations", "", "Comma separated cities or places you travel to for work, trips there are business trips unless tagged otherwise")
+	p.FlagSet.BoolVar(&holidayNotes, "destination-holidays", false, "Note the public holidays at the destination in the events of a trip that fall on them")
+	p.FlagSet.BoolVar(&destinationInfo, "destination-facts", false, "Add the currency, plug types, emergency numbers, and tipping norms of the countries a trip goes to to the event spanning it")
+	p.FlagSet.StringVar(&passport, "passport", os.Getenv("PASSPORT"), "Comma separated countries whose passports you hold (ex. US or United Kingdom), to note the visas trips abroad need (or env var PASSPORT)")
+	p.FlagSet.DurationVar(&visaReminder, "visa-reminder", 30*24*time.Hour, "How long before a trip that needs a visa or travel authorization to add an event reminding you to apply")
+	p.FlagSet.BoolVar(&checklist, "checklist", false, "Keep a checklist of things to do before each trip, like buying travel insurance, and remind you of the items that are due")
+	p.FlagSet.StringVar(&refdataDir, "refdata-dir", os.Getenv("REFDATA_DIR"), "Path to a directory of airports.dat, airlines.dat, and countries.json files that add to or replace the airports, airlines, and countries built in (or env var REFDATA_DIR)")
+	p.FlagSet.StringVar(&checklistFile, "checklist-file", os.Getenv("CHECKLIST_FILE"), "Path to a JSON file of checklist items, by trip kind, to use instead of the default checklist (or env var CHECKLIST_FILE)")
+	p.FlagSet.StringVar(&insuranceFile, "insurance-file", os.Getenv("INSURANCE_FILE"), "Path to a JSON file of your travel insurance policies, to warn about trips abroad they do not cover (or env var INSURANCE_FILE)")
+	p.FlagSet.BoolVar(&cancellationReminders, "cancellation-reminders", false, "Add an event reminding you of the last day to cancel hotels, rental cars, and activities free of charge, when TripIt knows it")
+	p.FlagSet.DurationVar(&cancellationReminder, "cancellation-reminder", 24*time.Hour, "How long before the last chance to cancel a booking free of charge to remind you")
+	p.FlagSet.BoolVar(&fareRechecks, "fare-rechecks", false, "Add events reminding you to check whether the fares of refundable flights dropped")
+	p.FlagSet.DurationVar(&fareRecheckEvery, "fare-recheck-every", 7*24*time.Hour, "How often to remind you to recheck the fare of a refundable flight")
+	p.FlagSet.DurationVar(&fareRecheckCutoff, "fare-recheck-cutoff", 7*24*time.Hour, "How long before a refundable flight departs to stop reminding you to recheck its fare")
+	p.FlagSet.StringVar(&refundable, "refundable", os.Getenv("REFUNDABLE"), "Comma separated confirmation numbers of refundable flights TripIt does not know are refundable (or env var REFUNDABLE)")
+	p.FlagSet.StringVar(&baggageFile, "baggage-file", os.Getenv("BAGGAGE_FILE"), "Path to a JSON file of baggage allowances by airline and class, to note in the events of flights (or env var BAGGAGE_FILE)")
+	p.FlagSet.StringVar(&holidayNames, "holiday-names", holidayNamesBoth, "Name public holidays in english, in the local language, or both")
+	p.FlagSet.BoolVar(&tripEvents, "trip-events", true, "Add an all-day event spanning each trip, named after it")
+	p.FlagSet.BoolVar(&activities, "activities", true, "Sync restaurant reservations and activities like tours and tickets, turn off to only sync transportation and stays")
+	p.FlagSet.StringVar(&workingHours, "working-hours", "", "Your working hours at home (ex. 09:00-17:00), to add an event suggesting adjusted working hours for multiple days spent in another timezone")
+	p.FlagSet.StringVar(&homeTimezone, "home-timezone", "", "Timezone you work in at home (ex. America/Los_Angeles), defaults to the local timezone")
+	p.FlagSet.BoolVar(&jetLagPlans, "jet-lag-plan", false, "Add events for the nights before long-haul flights that shift your sleep towards the destination's timezone")
+	p.FlagSet.StringVar(&busyCalendars, "busy-calendars", "", "Comma separated IDs of other calendars to check for meetings that new flights collide with")
+	p.FlagSet.BoolVar(&declineMeetings, "decline-meetings", false, "Decline the meetings in the busy calendars that new flights collide with")
+	p.FlagSet.StringVar(&declineMessage, "decline-message", "Sorry, I'm on flight {flight} from {from} to {to} then, departing {departs}.", "Message to decline meetings with, {flight}, {from}, {to}, {departs}, and {arrives} are replaced with the flight's, and {kind} with the kind of trip")
+	p.FlagSet.StringVar(&declineSendUpdates, "decline-send-updates", "all", "Who Google should notify when a meeting is declined (all, externalOnly, none)")
+
+	p.FlagSet.StringVar(&visibility, "visibility", "", "Visibility of every event (default, public, private, confidential), by default private trips get private events")
+
+	p.FlagSet.BoolVar(&hashtags, "hashtags", false, "Add the TripIt trip tags to event descriptions as #hashtags")
+
+	p.FlagSet.BoolVar(&descriptionFooter, "description-footer", true, "Append a footer saying the event is synced by the bot to every event description")
+	p.FlagSet.StringVar(&descriptionFooterText, "description-footer-text", "Synced from TripIt by tripitcalb0t at {time}; do not edit manually.", "Text of the description footer, {time} is replaced with the time of the sync")
+
+	p.FlagSet.StringVar(&sendUpdatesCreate, "send-updates-create", "all", "Who Google should notify when an event is created (all, externalOnly, none)")
+	p.FlagSet.StringVar(&sendUpdatesUpdate, "send-updates-update", "none", "Who Google should notify when an event is updated (all, externalOnly, none)")
 
+	p.FlagSet.StringVar(&leaseKubernetes, "lease-kubernetes", "", "Name of a Kubernetes Lease to use for leader election between replicas")
+	p.FlagSet.StringVar(&leaseFile, "lease-file", "", "Path to a lease file on a shared volume to use for leader election between replicas")
+	p.FlagSet.StringVar(&leaseIdentity, "lease-identity", "", "Identity of this replica for leader election (defaults to the hostname)")
+	p.FlagSet.DurationVar(&leaseDuration, "lease-duration", 5*time.Minute, "How long a replica holds the lease without renewing it before another takes over")
+
+	p.FlagSet.BoolVar(&strictPermissions, "strict-permissions", false, "Refuse to start when the keyfile, state, or users file can be read by other users, instead of only warning")
+	p.FlagSet.BoolVar(&fixPermissions, "fix-permissions", false, "Make the keyfile, state, and users file private to their owner before starting")
+
+	p.FlagSet.IntVar(&historySize, "history-size", 50, "Number of itinerary snapshots to keep, a new one is kept every time the itinerary changes, 0 to disable")
+	p.FlagSet.IntVar(&removalGraceRuns, "removal-grace-runs", 3, "Number of consecutive runs a trip must be missing from TripIt before its events are removed")
+	p.FlagSet.StringVar(&cancelledEvents, "cancelled-events", cancelDelete, "What to do with the events of flights cancelled or removed in TripIt (delete, mark)")
+
+	p.FlagSet.BoolVar(&archive, "archive", false, "Stop syncing trips once they have ended and compact their state")
+	p.FlagSet.StringVar(&archiveCalendar, "archive-calendar", "", "Calendar to move the events of archived trips to, for example a \"Travel archive\" calendar")
+
+	p.FlagSet.IntVar(&retentionYears, "retention-years", 0, "Delete the events the bot created once they are older than this many years, 0 to keep them forever")
+
+	p.FlagSet.StringVar(&travelingFile, "traveling-file", "", "Path to a file to write whether we are on a flight right now to after every run")
+	p.FlagSet.StringVar(&mqttBroker, "mqtt-broker", "", "URL of an MQTT broker to publish whether we are on a flight right now to (ex. tcp://localhost:1883)")
+	p.FlagSet.StringVar(&mqttTopic, "mqtt-topic", "tripitcalb0t/traveling", "MQTT topic to publish whether we are on a flight right now to")
+	p.FlagSet.StringVar(&mqttUsername, "mqtt-username", os.Getenv("MQTT_USERNAME"), "MQTT username (or env var MQTT_USERNAME)")
+	p.FlagSet.StringVar(&mqttPassword, "mqtt-password", "", "MQTT password (or env var MQTT_PASSWORD)")
+
+	p.FlagSet.StringVar(&emergencyContacts, "emergency-contacts", os.Getenv("EMERGENCY_CONTACTS"), "Semicolon separated emergency contacts to print on itineraries (or env var EMERGENCY_CONTACTS)")
+	p.FlagSet.StringVar(&slackToken, "slack-token", "", "Slack user token to set your status while traveling (or env var SLACK_TOKEN)")
+	p.FlagSet.StringVar(&slackSigningSecret, "slack-signing-secret", "", "Signing secret of a Slack app to answer the /tripit slash command for on the HTTP server (or env var SLACK_SIGNING_SECRET)")
+	p.FlagSet.StringVar(&slackUsers, "slack-users", os.Getenv("SLACK_USERS"), "Comma separated IDs of the Slack users allowed to use the /tripit slash command, needed with --slack-signing-secret (or env var SLACK_USERS)")
+	p.FlagSet.StringVar(&vacationResponder, "vacation-responder", "", "Gmail address to turn the vacation responder on for during personal trips, the service account needs domain-wide delegation for it")
+	p.FlagSet.StringVar(&vacationSubject, "vacation-subject", "Out of office until {back}", "Subject of the vacation responder, {trip}, {kind}, {location}, {end}, and {back} are replaced with the trip's")
+	p.FlagSet.StringVar(&vacationMessage, "vacation-message", "Thanks for your email. I'm away until {end} with limited access to email, and will reply when I'm back on {back}.", "Message of the vacation responder, {trip}, {kind}, {location}, {end}, and {back} are replaced with the trip's")
+
+	p.FlagSet.StringVar(&googleChatWebhook, "google-chat-webhook", "", "Google Chat incoming webhook URL to announce travel to (or env var GOOGLE_CHAT_WEBHOOK)")
+	p.FlagSet.StringVar(&teamsWebhook, "teams-webhook", "", "Microsoft Teams incoming webhook URL to announce travel to (or env var TEAMS_WEBHOOK)")
+
+	p.FlagSet.StringVar(&httpAddr, "http-addr", "", "Address to serve readiness and metrics on (ex. :8080)")
+	p.FlagSet.StringVar(&metricsAddr, "metrics-addr", os.Getenv("METRICS_ADDR"), "Address to serve only metrics on, apart from the rest of the HTTP server (ex. :9090) (or env var METRICS_ADDR)")
+	p.FlagSet.StringVar(&shareSecret, "share-secret", "", "Secret to sign share links with, enables serving them on the HTTP server (or env var SHARE_SECRET)")
+	p.FlagSet.StringVar(&shareURL, "share-url", os.Getenv("SHARE_URL"), "Public URL of the HTTP server to build share links with (or env var SHARE_URL)")
+	p.FlagSet.StringVar(&usersFile, "users-file", "", "Path to a JSON file of read-only users of the web UI on the HTTP server, each with a token and the trips they see")
+	p.FlagSet.StringVar(&oidcIssuer, "oidc-issuer", os.Getenv("OIDC_ISSUER"), "URL of an OpenID Connect provider for users to sign in to the web UI with (or env var OIDC_ISSUER)")
+	p.FlagSet.StringVar(&oidcClientID, "oidc-client-id", os.Getenv("OIDC_CLIENT_ID"), "OpenID Connect client ID (or env var OIDC_CLIENT_ID)")
+	p.FlagSet.StringVar(&oidcClientSecret, "oidc-client-secret", "", "OpenID Connect client secret (or env var OIDC_CLIENT_SECRET)")
+	p.FlagSet.StringVar(&oidcRedirectURL, "oidc-redirect-url", os.Getenv("OIDC_REDIRECT_URL"), "Public URL the OpenID Connect provider sends users back to, ending in /oidc/callback (or env var OIDC_REDIRECT_URL)")
+	p.FlagSet.IntVar(&staleAfter, "stale-after", 3, "Number of intervals without a successful sync before the bot reports itself as not ready and alerts")
 
 	p.FlagSet.DurationVar(&interval, "interval", time.Minute, "Update interval (ex. 5ms, 10s, 1m, 3h)")
+	p.FlagSet.DurationVar(&runTimeout, "run-timeout", 10*time.Minute, "Maximum duration of a single sync run, 0 for no limit")
+	p.FlagSet.IntVar(&maxFailures, "max-failures", 5, "Exit after this many syncs in a row fail, 0 to keep trying forever")
 	p.FlagSet.BoolVar(&once, "once", false, "Run once and exit, do not run as a daemon")
+	p.FlagSet.BoolVar(&dryRun, "dry-run", false, "Print the changes a sync would make to the calendar without making them, then exit")
+	p.FlagSet.BoolVar(&demo, "demo", false, "Print what a sync of a made-up itinerary would change in a calendar in memory, without TripIt or calendar credentials, then exit")
+	p.FlagSet.BoolVar(&approve, "approve", false, "Hold the changes TripIt makes back from the calendars until they are approved with the approve command")
 	p.FlagSet.BoolVar(&past, "past", false, "Include past trips")
+	p.FlagSet.StringVar(&output, "output", "", "Format of the result printed after a run with --once, which prints none without it (text, json)")
+
+	p.FlagSet.IntVar(&referenceCacheSize, "reference-cache-size", 256, "Maximum number of airport lookups to keep cached between runs")
 
 	p.FlagSet.BoolVar(&debug, "d", false, "Enable debug logging")
+	p.FlagSet.StringVar(&logFile, "log-file", "", "File to log to instead of stderr, rotated by size and age")
+	p.FlagSet.IntVar(&logMaxSize, "log-max-size", 100, "Size in megabytes the log file is rotated at, 0 for no limit")
+	p.FlagSet.DurationVar(&logMaxAge, "log-max-age", 7*24*time.Hour, "Age the log file is rotated at, 0 for no limit")
+	p.FlagSet.IntVar(&logMaxBackups, "log-max-backups", 5, "Number of rotated log files to keep")
+	p.FlagSet.BoolVar(&logStderr, "log-stderr", true, "Log to stderr, or to the log file if there is one, turn off to only log to syslog or journald")
+	p.FlagSet.StringVar(&syslogAddr, "syslog", "", "Syslog server to log to as well (ex. udp://localhost:514, tcp://logs:601, unix:///dev/log)")
+	p.FlagSet.BoolVar(&journald, "journald", false, "Log to journald as well")
+	p.FlagSet.IntVar(&debugSample, "debug-sample", 1, "Dump full payloads at debug level for 1 in this many sync runs, and for runs that fail")
 
 	// Set the before function.
 	p.Before = func(ctx context.Context) error {
+		// Fill in the flags the config file sets. It is fine for the
+		// default one not to exist.
+		if err := applyConfig(p.FlagSet, configFile, configFile != filepath.Join(credsDir, "config.yaml")); err != nil {
+			fatal(exitCodeConfig, err)
+		}
+
+		// Secrets are only read from the environment now, so they stay out
+		// of the help output.
+		secretsFromEnv()
+
 		// Set the log level.
 		if debug {
 			logrus.SetLevel(logrus.DebugLevel)
 		}
+		payloads.every = debugSample
 
-		if len(tripitUsername) < 1 {
-			return errors.New("tripit username cannot be empty")
+		// Exit with our own exit code on configuration errors so wrapper
+		// scripts can tell them apart from other failures.
+		if err := validateFlags(); err != nil {
+			fatal(exitCodeConfig, err)
 		}
 
-		if len(tripitPassword) < 1 {
-			return errors.New("tripit password cannot be empty")
+		// Itineraries and keys deserve the same care as SSH keys.
+		if err := enforcePermissions(strictPermissions, fixPermissions); err != nil {
+			fatal(exitCodeConfig, err)
 		}
 
-		if _, err := os.Stat(googleCalendarKeyfile); os.IsNotExist(err) {
-			return fmt.Errorf("Google Calendar keyfile %q does not exist", googleCalendarKeyfile)
+		if len(logFile) > 0 {
+			f, err := openRotatingFile(logFile, int64(logMaxSize)*1024*1024, logMaxAge, logMaxBackups)
+			if err != nil {
+				fatal(exitCodeConfig, err)
+			}
+			logrus.SetOutput(f)
 		}
-
-		if len(calendarName) < 1 {
-			return errors.New("calendar name cannot be empty")
+		if len(syslogAddr) > 0 {
+			h, err := newSyslogHook(syslogAddr)
+			if err != nil {
+				fatal(exitCodeConfig, err)
+			}
+			logrus.AddHook(h)
+		}
+		if journald {
+			h, err := newJournaldHook()
+			if err != nil {
+				fatal(exitCodeConfig, err)
+			}
+			logrus.AddHook(h)
 		}
+		if !logStderr {
+			logrus.SetOutput(ioutil.Discard)
+		}
+
+		airportCache = newLRUCache(referenceCacheSize)
 
 		return nil
 	}
@@ -102,215 +415,671 @@ func main() {
 	p.Action = func(ctx context.Context, args []string) error {
 		ticker := time.NewTicker(interval)
 
-		// On ^C, or SIGTERM handle exit.
+		// On ^C, or SIGTERM, cancel the run in progress so it saves its
+		// state and stops, and exit once it has. A second signal exits
+		// straight away.
 		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt)
 		signal.Notify(c, syscall.SIGTERM)
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithCancel(ctx)
 		go func() {
-			for sig := range c {
-				cancel()
-				ticker.Stop()
-				logrus.Infof("Received %s, exiting.", sig.String())
-				os.Exit(0)
-			}
+			sig := <-c
+			logrus.Infof("Received %s, exiting after the run in progress stops.", sig.String())
+			cancel()
+			ticker.Stop()
+
+			sig = <-c
+			logrus.Infof("Received %s again, exiting now.", sig.String())
+			os.Exit(1)
 		}()
 
-		// Create the TripIt API client.
-		tripitClient := tripit.New(tripitUsername, tripitPassword)
+		// If the user passed the demo flag, show what a sync does with
+		// made-up trips and exit, before asking for any credentials.
+		if demo {
+			if err := runDemo(ctx, os.Stdout); err != nil {
+				fatal(exitCodeError, err)
+			}
+			os.Exit(0)
+		}
 
-		// Create the Google calendar API client.
-		gcalData, err := ioutil.ReadFile(googleCalendarKeyfile)
+		// Syncing needs both TripIt and a calendar, so check the flags for
+		// both before we start. The Google client itself is created on first
+		// use.
+		tripitClient, err := newTripItClient()
 		if err != nil {
-			logrus.Fatalf("reading file %s failed: %v", googleCalendarKeyfile, err)
+			fatal(exitCodeConfig, err)
 		}
-		gcalTokenSource, err := google.JWTConfigFromJSON(gcalData, calendar.CalendarScope)
-		if err != nil {
-			logrus.Fatalf("creating google calendar token source from file %s failed: %v", googleCalendarKeyfile, err)
+		if err := validateCalendarFlags(); err != nil {
+			fatal(exitCodeConfig, err)
+		}
+		if len(stateFile) < 1 {
+			logrus.Warn("Running without a state file, so pauses for the Google Calendar quota or TripIt rate limits, and warnings already sent, are not remembered from one sync to the next.")
 		}
 
-		// Create the Google calendar client.
-		gcalClient, err := calendar.New(gcalTokenSource.Client(ctx))
+		// Set up leader election if we were asked to.
+		elector, err := newLeaser(leaseKubernetes, leaseFile, leaseIdentity, leaseDuration)
 		if err != nil {
-			logrus.Fatalf("creating google calendar client failed: %v", err)
+			fatal(exitCodeConfig, err)
+		}
+
+		pastFilter := fmt.Sprintf("%v", past)
+
+		// If we were built as a serverless function and are running inside
+		// a function runtime, run a sync for every invocation instead.
+		if ok, err := serveFunction(ctx, func(ctx context.Context) error {
+			backends, err := getCalendarBackends(ctx)
+			if err != nil {
+				return err
+			}
+			_, err = runWithTimeout(ctx, tripitClient, backends, pastFilter)
+			return err
+		}); ok {
+			return err
 		}
 
-    	pastFilter := fmt.Sprintf("%v", past)
+		// If the user passed the dry-run flag, print what a run would change
+		// and exit.
+		if dryRun {
+			backends, err := getCalendarBackends(ctx)
+			if err != nil {
+				fatal(exitCodeCalendarAuth, err)
+			}
+			if code, err := preflight(ctx, tripitClient, backends); err != nil {
+				fatal(code, err)
+			}
+			if err := dryRunSync(ctx, os.Stdout, tripitClient, backends, pastFilter); err != nil {
+				fatal(exitCodeError, err)
+			}
+			os.Exit(0)
+		}
 
 		// If the user passed the once flag, just do the run once and exit.
+		if once {
+			if !isLeader(ctx, elector) {
+				logrus.Info("Another replica holds the lease, not updating TripIt calendar entries")
+				os.Exit(0)
+			}
+
+			backends, err := getCalendarBackends(ctx)
+			if err != nil {
+				fatal(exitCodeCalendarAuth, err)
+			}
+
+			// Make sure our credentials work before we start, so we can
+			// exit with a clear exit code if they do not.
+			if code, err := preflight(ctx, tripitClient, backends); err != nil {
+				fatal(code, err)
+			}
 
-    	if once {
-			run(tripitClient, gcalClient, calendarName, pastFilter)
-			logrus.Infof("Updated TripIt calendar entries in Google calendar %s", calendarName)
+			runCtx, stop := holdLease(ctx, elector, leaseDuration)
+			res, err := runWithTimeout(runCtx, tripitClient, backends, pastFilter)
+			lost := stop()
+			if len(output) > 0 {
+				if err := res.write(os.Stdout, output); err != nil {
+					logrus.Errorf("writing result failed: %v", err)
+				}
+			}
+			if err != nil && ctx.Err() == context.Canceled {
+				// We were asked to stop, the run saved what it did.
+				logrus.Infof("Stopped the run in progress: %v", err)
+				os.Exit(0)
+			}
+			if lost {
+				fatal(exitCodeError, errors.New("lost the lease to another replica during the run"))
+			}
+			if err != nil {
+				fatal(exitCodeForSyncError(err), err)
+			}
+			names := make([]string, 0, len(backends))
+			for _, b := range backends {
+				names = append(names, b.String())
+			}
+			logrus.Infof("Updated TripIt calendar entries in %s", strings.Join(names, ", "))
 			os.Exit(0)
 		}
 
-		logrus.Infof("Starting bot to update TripIt calendar entries in Google calendar %s every %s", calendarName, interval)
-		for range ticker.C {
-			run(tripitClient, gcalClient, calendarName, pastFilter)
+		// Watch for syncs that stop succeeding.
+		wd := newWatchdog(time.Duration(staleAfter) * interval)
+		go wd.run(ctx, interval)
+		accounts, err := loadAccounts(usersFile)
+		if err != nil {
+			fatal(exitCodeConfig, err)
+		}
+		if len(httpAddr) > 0 {
+			var oidc *oidcProvider
+			if len(oidcIssuer) > 0 {
+				oidc, err = newOIDCProvider(ctx, oidcIssuer, oidcClientID, oidcClientSecret, oidcRedirectURL)
+				if err != nil {
+					fatal(exitCodeConfig, err)
+				}
+			}
+			serveHTTP(httpAddr, newServeMux(wd, accounts, oidc))
+		}
+		if len(metricsAddr) > 0 {
+			serveHTTP(metricsAddr, newMetricsMux(accounts))
+		}
+
+		// Check our credentials, so we only report ready once they work.
+		// A failure is not fatal, the syncs retry until --max-failures.
+		backends, err := getCalendarBackends(ctx)
+		if err == nil {
+			_, err = preflight(ctx, tripitClient, backends)
+		}
+		if err != nil {
+			logrus.Errorf("checking credentials failed: %v", err)
 		}
+		wd.validate(err)
 
-		return nil
+		// The calendars could not be set up if the credentials failed,
+		// so name them by their flags then.
+		calendars := calendarBackendNames()
+		for i, backend := range backends {
+			calendars[i] = backend.String()
+		}
+		logrus.Infof("Starting bot to update TripIt calendar entries in %s every %s", strings.Join(calendars, ", "), interval)
+		failures := 0
+		for {
+			select {
+			case <-ctx.Done():
+				logrus.Info("Exiting.")
+				return nil
+			case <-ticker.C:
+			case <-syncNow:
+			}
+
+			if !isLeader(ctx, elector) {
+				// A standby replica is healthy as long as it is
+				// following the leader.
+				wd.success(time.Now())
+				continue
+			}
+			// A failed sync is tried again on the next tick, until too
+			// many fail in a row.
+			code := exitCodeCalendarAuth
+			backends, err := getCalendarBackends(ctx)
+			if err == nil {
+				wd.begin(time.Now())
+				runCtx, stop := holdLease(ctx, elector, leaseDuration)
+				_, err = runWithTimeout(runCtx, tripitClient, backends, pastFilter)
+				lost := stop()
+				wd.end()
+				code = exitCodeForSyncError(err)
+				if lost && ctx.Err() == nil {
+					// Another replica syncs from here on, the run
+					// saved what it did.
+					logrus.Infof("Lost the lease, stopped the run in progress: %v", err)
+					continue
+				}
+			}
+			if err != nil && ctx.Err() != nil {
+				// We are shutting down, the run saved what it did.
+				logrus.Infof("Stopped the run in progress: %v", err)
+				continue
+			}
+			if _, ok := err.(*partialSyncError); ok {
+				// Most of the events synced, the rest are retried.
+				metricSyncFailures.Inc("partial")
+				wd.validate(nil)
+				logrus.Error(err)
+				failures = 0
+				metricConsecutiveFailures.Set(0)
+				continue
+			}
+			if err != nil {
+				failures++
+				metricSyncFailures.Inc("failed")
+				metricConsecutiveFailures.Set(float64(failures))
+				logrus.Errorf("sync failed, %d in a row: %v", failures, err)
+				if maxFailures > 0 && failures >= maxFailures {
+					fatal(code, fmt.Errorf("giving up after %d syncs in a row failed: %v", failures, err))
+				}
+				continue
+			}
+			failures = 0
+			metricConsecutiveFailures.Set(0)
+			wd.success(time.Now())
+		}
 	}
 
 	// Run our program.
 	p.Run()
 }
 
-func run(tripitClient *tripit.Client, gcalClient *calendar.Service, calendarName string, pastFilter string) {
-	// Get a list of events from Google calendar.
-	t := time.Now().AddDate(-4, 0, 0).Format(time.RFC3339)
-	events, err := gcalClient.Events.List(calendarName).ShowDeleted(false).SingleEvents(true).TimeMin(t).OrderBy("startTime").Q("Flight").MaxResults(2500).Do()
-	if err != nil {
-		logrus.Fatalf("getting events from google calendar %s failed: %v", calendarName, err)
+// runWithTimeout runs a single sync bounded by the run timeout, so that a
+// hung request cannot stall the bot forever.
+func runWithTimeout(ctx context.Context, tripitClient *tripit.Client, backends []calendarBackend, pastFilter string) (*syncResult, error) {
+	if runTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, runTimeout)
+		defer cancel()
 	}
 
-	trips, err := getTripItEvents(tripitClient, 1, pastFilter)
-	if err != nil {
-		logrus.Fatalf("getting tripit events failed: %v", err)
+	payloads.begin()
+	res, err := run(ctx, tripitClient, backends, pastFilter)
+	if ctx.Err() == context.DeadlineExceeded {
+		err = &runTimeoutError{timeout: runTimeout, err: err}
+		res.Error = err.Error()
 	}
+	payloads.end(err)
+	observeSync(res)
+	return res, err
+}
 
-	// Iterate over the trip and see if we already have a matching calendar event.
-	// If not make one and/or update the old one.
-	for _, trip := range trips {
-		if trip.ConfirmationNumber == "" {
-			logrus.Warnf("skipping trip that has no confirmation number: %#v", trip)
-			continue
-		}
+// sendUpdates is a googleapi.CallOption that sets who Google Calendar should
+// notify about a change to an event. The calendar client we vendor does not
+// know about the sendUpdates parameter yet so we set it ourselves.
+type sendUpdates string
 
-		var matchingEvent *calendar.Event
-		for _, e := range events.Items {
-			// We only care about TripIt events that match our tripID or segmentID.
-			if (strings.Contains(strings.ToLower(e.Description), "tripit") ||
-				strings.Contains(strings.ToLower(e.Summary), "flight")) &&
-				strings.Contains(e.Description, trip.SegmentID) {
-				matchingEvent = e
-				break
-			}
+// Get returns the query parameter key and value for the option.
+func (s sendUpdates) Get() (string, string) {
+	return "sendUpdates", string(s)
+}
+
+func isValidSendUpdates(s string) bool {
+	switch s {
+	case "all", "externalOnly", "none":
+		return true
+	}
+	return false
+}
+
+// responseEvents returns the events for the objects in a TripIt response.
+func responseEvents(resp *tripit.Response) []tripit.Event {
+	var events []tripit.Event
+
+	tripsByID := map[string]tripit.Trip{}
+	for _, trip := range resp.Trips {
+		tripsByID[trip.ID] = trip
+	}
+
+	// add adds the events for an object to our events array.
+	add := func(evs []tripit.Event, err error) {
+		if err != nil {
+			// Warn on error and continue iterating through the objects.
+			logrus.Warn(err)
+			return
+		}
+		for i := range evs {
+			trip := tripsByID[evs[i].ID]
+			evs[i].TripName = trip.DisplayName
+			evs[i].Private = trip.IsPrivate
+			evs[i].Tags = trip.Tags()
+			evs[i].Kind = classifyTrip(trip)
 		}
+		events = append(events, evs...)
+	}
 
-		// Get airport information.
-		airport := getAirportName(trip.AirportCode)
-		if airport == "" {
-			logrus.Errorf("getting airport information from iata database for %s returned no match", trip.AirportCode)
-			continue
+	// Add an all-day event spanning each trip.
+	if tripEvents {
+		for _, trip := range resp.Trips {
+			add(trip.GetTripAsEvents())
 		}
+	}
 
-		if matchingEvent == nil {
-			// No event was found for this trip, let's create one.
-			matchingEvent = &calendar.Event{
-				Summary:     trip.Title,
-				Description: trip.Description,
-				Start:       &trip.Start,
-				End:         &trip.End,
-				Location:    airport,
-			}
+	// Iterate over our flights and create/update calendar entries in Google calendar.
+	for _, flight := range resp.Flights {
+		add(flight.GetFlightSegmentsAsEvents())
+	}
 
-			// Insert the event.
-			_, err = gcalClient.Events.Insert(calendarName, matchingEvent).Do()
-			if err != nil {
-				logrus.Errorf("inserting google calendar event failed: %v", err)
-			}
-			continue
+	// Add the hotel stays as all-day events.
+	for _, lodging := range resp.Lodging {
+		add(lodging.GetLodgingAsEvents())
+	}
+
+	// Add picking up and dropping off rental cars.
+	for _, car := range resp.Cars {
+		add(car.GetCarAsEvents())
+	}
+
+	// Add train journeys.
+	for _, rail := range resp.Rails {
+		add(rail.GetRailSegmentsAsEvents())
+	}
+
+	// Add airport shuttles, transfers, car services, and ferries.
+	for _, transport := range resp.Transports {
+		add(transport.GetTransportSegmentsAsEvents())
+	}
+
+	// Add the ports of cruises.
+	for _, cruise := range resp.Cruises {
+		add(cruise.GetCruiseSegmentsAsEvents())
+	}
+
+	// Add restaurant reservations and activities, unless we only want
+	// transportation and stays.
+	if activities {
+		for _, restaurant := range resp.Restaurants {
+			add(restaurant.GetRestaurantAsEvents())
+		}
+		for _, activity := range resp.Activities {
+			add(activity.GetActivityAsEvents())
 		}
+	}
+
+	// Remind us of the last day to cancel bookings for free.
+	if cancellationReminders {
+		add(cancellationEvents(resp), nil)
+	}
+
+	// Remind us to check whether the fares of refundable flights dropped.
+	if fareRechecks {
+		add(fareCheckEvents(resp, time.Now()), nil)
+	}
+
+	// Note how much baggage we may bring on every flight.
+	if len(baggageFile) > 0 {
+		annotateBaggage(events)
+	}
+
+	// Note the public holidays at the destination, when things may be
+	// closed.
+	if holidayNotes {
+		annotateHolidays(events, tripsByID)
+	}
+
+	// Add what to know about the countries we are going to.
+	if destinationInfo {
+		addDestinationFacts(events, tripsByID)
+	}
+
+	// Note the visas the trips need on our passports.
+	if len(passport) > 0 {
+		passports, _ := parsePassports(passport)
+		events = append(events, visaEvents(events, tripsByID, passports)...)
+	}
 
-		// Update our matching event.
-		matchingEvent.Summary = trip.Title
-		matchingEvent.Description = trip.Description
-		matchingEvent.Start = &trip.Start
-		matchingEvent.End = &trip.End
-		matchingEvent.Location = airport
+	// Plan the nights before long-haul flights to get over jet lag sooner.
+	if jetLagPlans {
+		events = append(events, jetLagEvents(events)...)
+	}
 
-		// Update the event.
-		_, err = gcalClient.Events.Update(calendarName, matchingEvent.Id, matchingEvent).Do()
+	// Suggest adjusting working hours for stays in other timezones.
+	if len(workingHours) > 0 {
+		home, err := homeLocation()
 		if err != nil {
-			logrus.Errorf("updating google calendar event %s failed: %v", matchingEvent.Id, err)
+			logrus.Warn(err)
+			return events
 		}
+		day, _ := parseWorkingHours(workingHours)
+		events = append(events, workingHoursEvents(events, home, day)...)
 	}
+
+	return events
 }
 
-func getTripItEvents(tripitClient *tripit.Client, page int, pastFilter string) ([]tripit.Event, error) {
+// getTripItEvents gets the events of the trips from TripIt, and whether
+// TripIt had more pages of trips than --tripit-max-pages, so the events are
+// not all of them.
+func getTripItEvents(ctx context.Context, tripitClient *tripit.Client, pastFilter string, filters ...tripit.Filter) ([]tripit.Event, bool, error) {
 	// Get a list of trips.
-	resp, err := tripitClient.ListTrips(
-		tripit.Filter{
+	query := []tripit.Filter{
+		{
 			Type:  tripit.FilterPast,
 			Value: pastFilter,
 		},
-		tripit.Filter{
+		{
 			Type:  tripit.FilterIncludeObjects,
 			Value: "true",
 		},
-		tripit.Filter{
-			Type:  tripit.FilterPageNum,
-			Value: fmt.Sprintf("%d", page),
-		},
-		tripit.Filter{
+		{
 			Type:  tripit.FilterPageSize,
 			Value: "25",
-		})
+		},
+	}
+	resp, err := tripitClient.ListAllTrips(ctx, tripitMaxPages, append(query, filters...)...)
 	if err != nil {
-		return nil, fmt.Errorf("listing trips from TripIt failed: %v", err)
+		return nil, false, fmt.Errorf("listing trips from TripIt failed: %w", err)
+	}
+	if resp.Truncated {
+		logrus.Warnf("TripIt has more than %d pages of trips, only syncing the first %d, raise --tripit-max-pages to get them all", tripitMaxPages, tripitMaxPages)
 	}
 
-	var events []tripit.Event
+	events := responseEvents(resp)
 
-	// Iterate over our flights and create/update calendar entries in Google calendar.
-	for _, flight := range resp.Flights {
-		// Create the events for the flight.
-		evs, err := flight.GetFlightSegmentsAsEvents()
+	if pastFilter == "true" {
+		// Get future events as well.
+		evs, truncated, err := getTripItEvents(ctx, tripitClient, "false", filters...)
 		if err != nil {
-			// Warn on error and continue iterating through the flights.
-			logrus.Warn(err)
-			continue
+			return nil, false, err
 		}
 
-		// Add to our events array.
-		events = append(events, evs...)
+		return append(events, evs...), resp.Truncated || truncated, nil
 	}
 
-	// Paginate.
-	pageNum, err := strconv.Atoi(resp.PageNum)
-	if err != nil {
-		return nil, err
+	return events, resp.Truncated, nil
+}
+
+// validateFlags checks the global flags are valid.
+func validateFlags() error {
+	if (len(leaseKubernetes) > 0 || len(leaseFile) > 0) && leaseDuration <= interval {
+		return fmt.Errorf("lease-duration (%s) must be longer than the interval (%s)", leaseDuration, interval)
 	}
-	maxPage, err := strconv.Atoi(resp.MaxPage)
-	if err != nil {
-		return nil, err
+
+	switch visibility {
+	case "", "default", "public", "private", "confidential":
+	default:
+		return fmt.Errorf("visibility must be one of default, public, private, or confidential, got %q", visibility)
 	}
 
-	if pageNum < maxPage {
-		pageNum++
+	if len(archiveCalendar) > 0 && !archive {
+		return errors.New("archive-calendar needs archive to be enabled")
+	}
 
-		evs, err := getTripItEvents(tripitClient, pageNum, pastFilter)
-		if err != nil {
-			return nil, err
+	if retentionYears < 0 {
+		return fmt.Errorf("retention-years cannot be negative, got %d", retentionYears)
+	}
+
+	if staleAfter < 1 {
+		return fmt.Errorf("stale-after must be at least 1, got %d", staleAfter)
+	}
+
+	if removalGraceRuns < 1 {
+		return fmt.Errorf("removal-grace-runs must be at least 1, got %d", removalGraceRuns)
+	}
+
+	if len(shareSecret) > 0 && len(shareSecret) < 16 {
+		return errors.New("share-secret must be at least 16 characters long")
+	}
+
+	if len(metricsAddr) > 0 && metricsAddr == httpAddr {
+		return errors.New("metrics-addr must be a different address than http-addr, which serves metrics too")
+	}
+
+	if len(slackSigningSecret) > 0 && len(httpAddr) < 1 {
+		return errors.New("slack-signing-secret needs http-addr to answer the slash command on")
+	}
+
+	if len(slackSigningSecret) > 0 && len(strings.TrimSpace(slackUsers)) < 1 {
+		return errors.New("slack-signing-secret needs slack-users to list who may use the slash command")
+	}
+
+	if len(usersFile) > 0 && len(httpAddr) < 1 {
+		return errors.New("users-file needs http-addr to serve the web UI on")
+	}
+
+	if len(oidcIssuer) > 0 {
+		if len(usersFile) < 1 {
+			return errors.New("oidc-issuer needs users-file to say who can sign in")
+		}
+		if len(oidcClientID) < 1 || len(oidcClientSecret) < 1 || len(oidcRedirectURL) < 1 {
+			return errors.New("oidc-issuer needs oidc-client-id, oidc-client-secret, and oidc-redirect-url")
+		}
+		if !strings.HasPrefix(oidcIssuer, "https://") {
+			return fmt.Errorf("oidc-issuer must be an https URL, got %q", oidcIssuer)
 		}
+	}
 
-		return append(events, evs...), nil
+	if cancelledEvents != cancelDelete && cancelledEvents != cancelMark {
+		return fmt.Errorf("cancelled-events must be one of delete or mark, got %q", cancelledEvents)
 	}
 
-	if pastFilter == "true" {
-		// Get future events as well.
-		evs, err := getTripItEvents(tripitClient, 1, "false")
-		if err != nil {
-			return nil, err
+	if logMaxSize < 0 || logMaxAge < 0 || logMaxBackups < 0 {
+		return errors.New("log-max-size, log-max-age, and log-max-backups cannot be negative")
+	}
+
+	if !logStderr && len(syslogAddr) < 1 && !journald {
+		return errors.New("turning off log-stderr needs syslog or journald to log to")
+	}
+
+	switch holidayNames {
+	case holidayNamesEnglish, holidayNamesLocal, holidayNamesBoth:
+	default:
+		return fmt.Errorf("holiday-names must be %s, %s, or %s, got %q", holidayNamesEnglish, holidayNamesLocal, holidayNamesBoth, holidayNames)
+	}
+
+	if _, err := parseTripKinds(tripKinds); err != nil {
+		return err
+	}
+
+	if len(vacationResponder) > 0 && !strings.Contains(vacationResponder, "@") {
+		return fmt.Errorf("vacation-responder must be a Gmail address, got %q", vacationResponder)
+	}
+
+	if declineMeetings && len(busyCalendars) < 1 {
+		return errors.New("decline-meetings needs busy-calendars to decline meetings in")
+	}
+
+	if len(workingHours) > 0 {
+		if _, err := parseWorkingHours(workingHours); err != nil {
+			return err
+		}
+	}
+	if _, err := parsePassports(passport); err != nil {
+		return err
+	}
+	if _, err := loadChecklistTemplates(checklistFile); err != nil {
+		return err
+	}
+	// The reference data is read once here, before anything looks it up.
+	if err := loadRefData(refdataDir); err != nil {
+		return err
+	}
+	if len(baggageFile) > 0 {
+		if _, err := loadBaggageAllowances(baggageFile); err != nil {
+			return err
+		}
+	}
+	if len(insuranceFile) > 0 {
+		if _, err := loadInsurancePolicies(insuranceFile); err != nil {
+			return err
+		}
+	}
+	if len(companionCalendar) > 0 && len(strings.Trim(companionTags, ", ")) < 1 {
+		return errors.New("companion-tags cannot be empty, or no trips would be mirrored to the companion's calendar")
+	}
+	if maxFailures < 0 {
+		return fmt.Errorf("max-failures cannot be negative, got %d", maxFailures)
+	}
+	if tripitRetries < 0 {
+		return fmt.Errorf("tripit-retries cannot be negative, got %d", tripitRetries)
+	}
+	if tripitRetryBackoff < 0 {
+		return fmt.Errorf("tripit-retry-backoff cannot be negative, got %s", tripitRetryBackoff)
+	}
+	if tripitRetryJitter < 0 || tripitRetryJitter > 1 {
+		return fmt.Errorf("tripit-retry-jitter must be between 0 and 1, got %v", tripitRetryJitter)
+	}
+	if tripitMaxPages < 0 {
+		return fmt.Errorf("tripit-max-pages cannot be negative, got %d", tripitMaxPages)
+	}
+	if incremental && fullFetch <= 0 {
+		return fmt.Errorf("full-fetch must be positive, got %s", fullFetch)
+	}
+	// These keep what they need to know from one sync to the next in the
+	// state file, and without one would silently do nothing, or never undo
+	// what they did.
+	if len(stateFile) < 1 {
+		for _, f := range []struct {
+			name string
+			set  bool
+		}{
+			{"approve", approve},
+			{"incremental", incremental},
+			{"vacation-responder", len(vacationResponder) > 0},
+		} {
+			if f.set {
+				return fmt.Errorf("%s needs a state file, it cannot be used with an empty state-file", f.name)
+			}
 		}
+	}
+	if cancellationReminder < 0 {
+		return fmt.Errorf("cancellation-reminder cannot be negative, got %s", cancellationReminder)
+	}
+	if fareRecheckEvery < 24*time.Hour {
+		return fmt.Errorf("fare-recheck-every must be at least a day, got %s", fareRecheckEvery)
+	}
+	if fareRecheckCutoff < 0 {
+		return fmt.Errorf("fare-recheck-cutoff cannot be negative, got %s", fareRecheckCutoff)
+	}
+	if visaReminder < 0 {
+		return fmt.Errorf("visa-reminder cannot be negative, got %s", visaReminder)
+	}
+	if _, err := homeLocation(); err != nil {
+		return err
+	}
+
+	if debugSample < 1 {
+		return fmt.Errorf("debug-sample must be at least 1, got %d", debugSample)
+	}
+
+	if historySize < 0 {
+		return fmt.Errorf("history-size cannot be negative, got %d", historySize)
+	}
+
+	if len(output) > 0 && output != "text" && output != "json" {
+		return fmt.Errorf("output must be one of text or json, got %q", output)
+	}
+
+	if !isValidSendUpdates(sendUpdatesCreate) {
+		return fmt.Errorf("send-updates-create must be one of all, externalOnly, or none, got %q", sendUpdatesCreate)
+	}
+
+	if !isValidSendUpdates(sendUpdatesUpdate) {
+		return fmt.Errorf("send-updates-update must be one of all, externalOnly, or none, got %q", sendUpdatesUpdate)
+	}
 
-		return append(events, evs...), nil
+	if !isValidSendUpdates(declineSendUpdates) {
+		return fmt.Errorf("decline-send-updates must be one of all, externalOnly, or none, got %q", declineSendUpdates)
 	}
 
-	return events, nil
+	return nil
 }
 
-func getAirportName(code string) string {
-	for _, airport := range openflights.Airports {
-		if airport.IATA == code {
-			return airport.Name
-		}
+// isLeader returns true if this replica should sync. Without leader election
+// every replica is the leader.
+func isLeader(ctx context.Context, elector leaser) bool {
+	if elector == nil {
+		return true
+	}
+
+	ok, err := elector.Acquire(ctx)
+	if err != nil {
+		logrus.Errorf("acquiring lease failed: %v", err)
+		return false
+	}
+	if !ok {
+		logrus.Debug("another replica holds the lease, skipping sync")
+	}
+	return ok
+}
+
+// readGoogleKeyfile returns the contents of the Google Calendar keyfile.
+// The GOOGLE_KEYFILE_JSON environment variable takes precedence over the
+// keyfile path for deployments, like serverless functions, that only have
+// the environment to read credentials from.
+func readGoogleKeyfile() ([]byte, error) {
+	if s := os.Getenv("GOOGLE_KEYFILE_JSON"); len(s) > 0 {
+		return []byte(s), nil
+	}
+
+	b, err := ioutil.ReadFile(googleCalendarKeyfile)
+	if err != nil {
+		return nil, fmt.Errorf("reading file %s failed: %v", googleCalendarKeyfile, err)
 	}
 
-	return ""
+	return b, nil
 }
 
 func getHome() (string, error) {
@@ -0,0 +1,272 @@
+// Package metrics implements the small subset of Prometheus metrics the bot
+// exposes, written in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metrics and writes them out.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// WritePrometheus writes every metric in the registry in the Prometheus text
+// exposition format.
+func (r *Registry) WritePrometheus(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range r.metrics {
+		m.write(w)
+	}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+// vec holds the values of a metric for every combination of label values.
+type vec struct {
+	mu     sync.Mutex
+	name   string
+	help   string
+	typ    string
+	labels []string
+	values map[string]float64
+}
+
+func newVec(name, help, typ string, labels []string) *vec {
+	return &vec{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		values: map[string]float64{},
+	}
+}
+
+// key returns the label pairs for the label values, formatted for output.
+func (v *vec) key(values []string) string {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", v.name, len(v.labels), len(values)))
+	}
+	return formatLabels(labelPairs(v.labels, values))
+}
+
+// labelPairs returns the label pairs for the label values, formatted for
+// output.
+func labelPairs(labels, values []string) []string {
+	pairs := make([]string, len(values))
+	for i, value := range values {
+		pairs[i] = labels[i] + `="` + labelEscaper.Replace(value) + `"`
+	}
+	return pairs
+}
+
+// formatLabels returns the label pairs in braces, or nothing if there are
+// none.
+func formatLabels(pairs []string) string {
+	if len(pairs) < 1 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (v *vec) add(delta float64, values []string) {
+	k := v.key(values)
+	v.mu.Lock()
+	v.values[k] += delta
+	v.mu.Unlock()
+}
+
+func (v *vec) set(value float64, values []string) {
+	k := v.key(values)
+	v.mu.Lock()
+	v.values[k] = value
+	v.mu.Unlock()
+}
+
+func (v *vec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, v.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.typ)
+
+	// Metrics without labels are always written, even before they are set.
+	if len(v.labels) < 1 && len(v.values) < 1 {
+		fmt.Fprintf(w, "%s 0\n", v.name)
+		return
+	}
+
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, k, formatFloat(v.values[k]))
+	}
+}
+
+// Counter is a value that only goes up, per combination of label values.
+type Counter struct {
+	v *vec
+}
+
+// NewCounter registers a new counter with the given label names.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{v: newVec(name, help, "counter", labels)}
+	r.register(c.v)
+	return c
+}
+
+// Inc adds one to the counter for the label values.
+func (c *Counter) Inc(values ...string) {
+	c.v.add(1, values)
+}
+
+// Add adds delta to the counter for the label values.
+func (c *Counter) Add(delta float64, values ...string) {
+	if delta < 0 {
+		panic("counter cannot decrease")
+	}
+	c.v.add(delta, values)
+}
+
+// Gauge is a value that can go up and down, per combination of label values.
+type Gauge struct {
+	v *vec
+}
+
+// NewGauge registers a new gauge with the given label names.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{v: newVec(name, help, "gauge", labels)}
+	r.register(g.v)
+	return g
+}
+
+// Set sets the gauge for the label values.
+func (g *Gauge) Set(value float64, values ...string) {
+	g.v.set(value, values)
+}
+
+// DefaultBuckets are the upper bounds of the histogram buckets for request
+// latencies, in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// Histogram counts observations in buckets, per combination of label values.
+type Histogram struct {
+	mu      sync.Mutex
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	series  map[string]*histogramSeries
+}
+
+// histogramSeries holds the observations for one combination of label
+// values.
+type histogramSeries struct {
+	pairs  []string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registers a new histogram with the given bucket upper bounds,
+// in increasing order, and label names.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  map[string]*histogramSeries{},
+	}
+	r.register(h)
+	return h
+}
+
+// Observe adds the value to the histogram for the label values.
+func (h *Histogram) Observe(value float64, values ...string) {
+	if len(values) != len(h.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", h.name, len(h.labels), len(values)))
+	}
+	pairs := labelPairs(h.labels, values)
+	k := formatLabels(pairs)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[k]
+	if !ok {
+		s = &histogramSeries{pairs: pairs, counts: make([]uint64, len(h.buckets))}
+		h.series[k] = s
+	}
+	for i, le := range h.buckets {
+		if value <= le {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := h.series[k]
+		for i, le := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(append(s.pairs[:len(s.pairs):len(s.pairs)], `le="`+formatFloat(le)+`"`)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(append(s.pairs[:len(s.pairs):len(s.pairs)], `le="+Inf"`)), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, k, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, k, s.count)
+	}
+}
+
+// labelEscaper escapes label values the way the text exposition format wants.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
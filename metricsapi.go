@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+)
+
+// metricsSnapshot is a compact JSON version of the metrics, for dashboards
+// that read JSON rather than Prometheus.
+type metricsSnapshot struct {
+	SyncStale   bool      `json:"syncStale"`
+	LastSuccess time.Time `json:"lastSuccess"`
+	// LastSuccessTimestamp is LastSuccess as a Unix time.
+	LastSuccessTimestamp int64 `json:"lastSuccessTimestamp"`
+	// NextDeparture is when the next flight departs, if there is one.
+	NextDeparture          *time.Time `json:"nextDeparture,omitempty"`
+	NextDepartureTimestamp int64      `json:"nextDepartureTimestamp,omitempty"`
+	// TripsNext30Days counts the trips that are on now or start within the
+	// next 30 days.
+	TripsNext30Days   int `json:"tripsNext30Days"`
+	FlightsNext30Days int `json:"flightsNext30Days"`
+	// Fetched is when the itinerary the snapshot is made from was fetched.
+	Fetched *time.Time `json:"fetched,omitempty"`
+}
+
+// newMetricsSnapshot returns the metrics at now for the events.
+func newMetricsSnapshot(wd *watchdog, events []tripit.Event, now time.Time) metricsSnapshot {
+	var m metricsSnapshot
+	m.SyncStale, m.LastSuccess = wd.stale()
+	m.LastSuccessTimestamp = m.LastSuccess.Unix()
+
+	if _, next := nextDeparture(events, now); next != nil {
+		t := eventTime(next.Start)
+		m.NextDeparture = &t
+		m.NextDepartureTimestamp = t.Unix()
+	}
+
+	horizon := now.AddDate(0, 0, 30)
+	for _, t := range upcomingTrips(events, now) {
+		if t.Start.After(horizon) {
+			continue
+		}
+		m.TripsNext30Days++
+		for _, e := range t.Events {
+			if start := eventTime(e.Start); start.After(now) && start.Before(horizon) {
+				m.FlightsNext30Days++
+			}
+		}
+	}
+
+	return m
+}
+
+// apiMetricsHandler serves the metrics as JSON.
+func apiMetricsHandler(wd *watchdog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		st, err := loadState(stateFile)
+		if err != nil {
+			logrus.Errorf("reading state for the metrics failed: %v", err)
+			http.Error(w, "the metrics are not available right now", http.StatusInternalServerError)
+			return
+		}
+		var events []tripit.Event
+		var fetched *time.Time
+		if st.Snapshot != nil {
+			events = st.Snapshot.Events
+			fetched = &st.Snapshot.Fetched
+		}
+		m := newMetricsSnapshot(wd, events, time.Now())
+		m.Fetched = fetched
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(m); err != nil {
+			logrus.Errorf("writing the metrics failed: %v", err)
+		}
+	}
+}
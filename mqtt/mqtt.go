@@ -0,0 +1,192 @@
+// Package mqtt implements just enough of MQTT 3.1.1 to publish a message to a
+// broker: connect, publish at QoS 0, and disconnect.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+const (
+	packetConnect    = 0x10
+	packetConnack    = 0x20
+	packetPublish    = 0x30
+	packetDisconnect = 0xe0
+
+	flagRetain = 0x01
+
+	flagUsername     = 0x80
+	flagPassword     = 0x40
+	flagCleanSession = 0x02
+
+	protocolLevel = 4
+	keepAlive     = 60
+)
+
+// Client publishes messages to an MQTT broker.
+type Client struct {
+	// Broker is the URL of the broker, tcp://host:1883 or ssl://host:8883.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+}
+
+// New returns a new MQTT client for the broker.
+func New(broker, clientID, username, password string) *Client {
+	return &Client{
+		Broker:   broker,
+		ClientID: clientID,
+		Username: username,
+		Password: password,
+	}
+}
+
+// Publish connects to the broker, publishes the payload to the topic at QoS 0,
+// and disconnects. A retained message is kept by the broker and handed to
+// clients that subscribe later.
+func (c *Client) Publish(ctx context.Context, topic string, payload []byte, retain bool) error {
+	u, err := url.Parse(c.Broker)
+	if err != nil {
+		return fmt.Errorf("parsing mqtt broker url %s failed: %v", c.Broker, err)
+	}
+
+	var d net.Dialer
+	var conn net.Conn
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		conn, err = d.DialContext(ctx, "tcp", hostPort(u, "1883"))
+	case "ssl", "tls", "mqtts":
+		conn, err = d.DialContext(ctx, "tcp", hostPort(u, "8883"))
+		if err == nil {
+			conn = tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		}
+	default:
+		return fmt.Errorf("unsupported mqtt broker scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return fmt.Errorf("connecting to mqtt broker %s failed: %v", u.Host, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+
+	if _, err := conn.Write(c.connectPacket()); err != nil {
+		return fmt.Errorf("sending mqtt connect failed: %v", err)
+	}
+	if err := readConnack(bufio.NewReader(conn)); err != nil {
+		return err
+	}
+
+	if _, err := conn.Write(publishPacket(topic, payload, retain)); err != nil {
+		return fmt.Errorf("sending mqtt publish failed: %v", err)
+	}
+
+	if _, err := conn.Write([]byte{packetDisconnect, 0}); err != nil {
+		return fmt.Errorf("sending mqtt disconnect failed: %v", err)
+	}
+
+	return nil
+}
+
+func (c *Client) connectPacket() []byte {
+	var flags byte = flagCleanSession
+	var body []byte
+	body = appendString(body, "MQTT")
+	body = append(body, protocolLevel)
+	if len(c.Username) > 0 {
+		flags |= flagUsername
+	}
+	if len(c.Password) > 0 {
+		flags |= flagPassword
+	}
+	body = append(body, flags, byte(keepAlive>>8), byte(keepAlive&0xff))
+	body = appendString(body, c.ClientID)
+	if len(c.Username) > 0 {
+		body = appendString(body, c.Username)
+	}
+	if len(c.Password) > 0 {
+		body = appendString(body, c.Password)
+	}
+
+	return packet(packetConnect, body)
+}
+
+func publishPacket(topic string, payload []byte, retain bool) []byte {
+	var header byte = packetPublish
+	if retain {
+		header |= flagRetain
+	}
+	body := appendString(nil, topic)
+	body = append(body, payload...)
+
+	return packet(header, body)
+}
+
+func readConnack(r *bufio.Reader) error {
+	header, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("reading mqtt connack failed: %v", err)
+	}
+	if header != packetConnack {
+		return fmt.Errorf("expected mqtt connack, got packet type %#x", header)
+	}
+
+	b := make([]byte, 3)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return fmt.Errorf("reading mqtt connack failed: %v", err)
+	}
+	if b[0] != 2 {
+		return errors.New("malformed mqtt connack")
+	}
+
+	switch b[2] {
+	case 0:
+		return nil
+	case 4, 5:
+		return fmt.Errorf("mqtt broker refused the connection: not authorized (code %d)", b[2])
+	default:
+		return fmt.Errorf("mqtt broker refused the connection with code %d", b[2])
+	}
+}
+
+// packet returns the packet with the fixed header and the remaining length.
+func packet(header byte, body []byte) []byte {
+	b := []byte{header}
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(b, body...)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)&0xff))
+	return append(b, s...)
+}
+
+func hostPort(u *url.URL, port string) string {
+	if len(u.Port()) > 0 {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const nextHelp = `Show the next flight, with a countdown to when it departs.`
+
+const nextLongHelp = `Show the next flight, with a countdown to when it departs.
+
+Shows the flight you are on, or the next one to depart, with its terminal and
+gate if TripIt knows them, its status, and its confirmation number, from the
+itinerary of the last sync or fetch.
+
+  tripitcalb0t next
+  tripitcalb0t --output json next
+
+This only reads the state file, so it is fast and does not need any
+credentials.`
+
+func (cmd *nextCommand) Name() string      { return "next" }
+func (cmd *nextCommand) Args() string      { return "" }
+func (cmd *nextCommand) ShortHelp() string { return nextHelp }
+func (cmd *nextCommand) LongHelp() string  { return nextLongHelp }
+func (cmd *nextCommand) Hidden() bool      { return false }
+
+func (cmd *nextCommand) Register(fs *flag.FlagSet) {}
+
+type nextCommand struct{}
+
+// nextFlight is the flight the next command shows.
+type nextFlight struct {
+	Title        string    `json:"title"`
+	Flight       string    `json:"flight"`
+	From         string    `json:"from"`
+	To           string    `json:"to"`
+	Departs      time.Time `json:"departs"`
+	Arrives      time.Time `json:"arrives"`
+	InAir        bool      `json:"inAir"`
+	Countdown    string    `json:"countdown"`
+	Terminal     string    `json:"terminal,omitempty"`
+	Gate         string    `json:"gate,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	Confirmation string    `json:"confirmation,omitempty"`
+	TripID       string    `json:"tripID"`
+	Trip         string    `json:"trip,omitempty"`
+	// Fetched is when the itinerary the flight is from was fetched.
+	Fetched time.Time `json:"fetched"`
+}
+
+func (cmd *nextCommand) Run(ctx context.Context, args []string) error {
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+	if st.Snapshot == nil {
+		return errNoSnapshot
+	}
+
+	now := time.Now()
+	current, next := nextDeparture(st.Snapshot.Events, now)
+	e := next
+	if current != nil {
+		e = current
+	}
+	if e == nil {
+		fmt.Println("You have no upcoming flights.")
+		return nil
+	}
+
+	f := nextFlight{
+		Title:        e.Title,
+		Flight:       e.FlightNumber,
+		From:         e.AirportCode,
+		To:           e.DestinationCode,
+		Departs:      eventTime(e.Start),
+		Arrives:      eventTime(e.End),
+		InAir:        current != nil,
+		Terminal:     e.Terminal,
+		Gate:         e.Gate,
+		Status:       flightStatusText(e.Status),
+		Confirmation: e.ConfirmationNumber,
+		TripID:       e.ID,
+		Trip:         e.TripName,
+		Fetched:      st.Snapshot.Fetched,
+	}
+	f.Countdown = formatCountdown(f.Departs.Sub(now))
+	if f.InAir {
+		f.Countdown = formatCountdown(f.Arrives.Sub(now))
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(f)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 1, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\n\n", f.Title)
+	if f.InAir {
+		fmt.Fprintf(w, "Lands\t%s (in %s)\n", f.Arrives.Format("Mon Jan 2 15:04 MST"), f.Countdown)
+	} else {
+		fmt.Fprintf(w, "Departs\t%s (in %s)\n", f.Departs.Format("Mon Jan 2 15:04 MST"), f.Countdown)
+		fmt.Fprintf(w, "Arrives\t%s\n", f.Arrives.Format("Mon Jan 2 15:04 MST"))
+	}
+	fmt.Fprintf(w, "Route\t%s to %s\n", f.From, f.To)
+	for _, row := range [][2]string{
+		{"Terminal", f.Terminal},
+		{"Gate", f.Gate},
+		{"Status", f.Status},
+		{"Confirmation", f.Confirmation},
+		{"Trip", f.Trip},
+	} {
+		if len(row[1]) > 0 {
+			fmt.Fprintf(w, "%s\t%s\n", row[0], row[1])
+		}
+	}
+	fmt.Fprintf(w, "\nAs of %s.\n", f.Fetched.Local().Format("Mon Jan 2 15:04 MST"))
+	return w.Flush()
+}
@@ -0,0 +1,25 @@
+package notify
+
+import "context"
+
+// GoogleChat sends messages to a Google Chat incoming webhook.
+type GoogleChat struct {
+	WebhookURL string
+}
+
+// NewGoogleChat returns a notifier for the Google Chat incoming webhook.
+func NewGoogleChat(webhookURL string) *GoogleChat {
+	return &GoogleChat{WebhookURL: webhookURL}
+}
+
+// Name returns the name of the chat tool.
+func (g *GoogleChat) Name() string {
+	return "google chat"
+}
+
+// Notify sends the message.
+func (g *GoogleChat) Notify(ctx context.Context, msg Message) error {
+	return postJSON(ctx, g.WebhookURL, map[string]string{
+		"text": "*" + msg.Title + "*\n" + msg.Text,
+	})
+}
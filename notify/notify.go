@@ -0,0 +1,77 @@
+// Package notify sends announcements about travel to chat tools.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Message is an announcement to send.
+type Message struct {
+	Title string
+	Text  string
+}
+
+// Notifier sends messages to a chat tool.
+type Notifier interface {
+	// Name returns the name of the chat tool, for logs.
+	Name() string
+	// Notify sends the message.
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Multi sends messages to every notifier it holds.
+type Multi []Notifier
+
+// Name returns the names of the notifiers.
+func (m Multi) Name() string {
+	var names []string
+	for _, n := range m {
+		names = append(names, n.Name())
+	}
+	return fmt.Sprintf("%v", names)
+}
+
+// Notify sends the message to every notifier. It tries all of them and
+// returns the first error.
+func (m Multi) Notify(ctx context.Context, msg Message) error {
+	var first error
+	for _, n := range m {
+		if err := n.Notify(ctx, msg); err != nil && first == nil {
+			first = fmt.Errorf("notifying %s failed: %v", n.Name(), err)
+		}
+	}
+	return first
+}
+
+// postJSON posts v as JSON to the webhook url.
+func postJSON(ctx context.Context, url string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, body)
+	}
+
+	return nil
+}
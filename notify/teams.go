@@ -0,0 +1,34 @@
+package notify
+
+import (
+	"context"
+	"strings"
+)
+
+// Teams sends messages to a Microsoft Teams incoming webhook.
+type Teams struct {
+	WebhookURL string
+}
+
+// NewTeams returns a notifier for the Microsoft Teams incoming webhook.
+func NewTeams(webhookURL string) *Teams {
+	return &Teams{WebhookURL: webhookURL}
+}
+
+// Name returns the name of the chat tool.
+func (t *Teams) Name() string {
+	return "teams"
+}
+
+// Notify sends the message as a message card.
+func (t *Teams) Notify(ctx context.Context, msg Message) error {
+	return postJSON(ctx, t.WebhookURL, map[string]string{
+		"@type":    "MessageCard",
+		"@context": "https://schema.org/extensions",
+		"summary":  msg.Title,
+		"title":    msg.Title,
+		// Teams renders the text as markdown, which needs two spaces
+		// before a newline to break the line.
+		"text": strings.Replace(msg.Text, "\n", "  \n", -1),
+	})
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// sessionCookie is the cookie that holds the session of a user signed
+	// in with OpenID Connect.
+	sessionCookie = "tripitcalb0t_session"
+	// oidcStateCookie holds the state and nonce of a sign in in progress.
+	oidcStateCookie = "tripitcalb0t_oidc"
+
+	sessionDuration = 7 * 24 * time.Hour
+)
+
+// oidcProvider signs users in to the web UI with an OpenID Connect provider,
+// like Authelia, Keycloak, or Google, using the authorization code flow.
+type oidcProvider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+
+	authURL  string
+	tokenURL string
+	client   *http.Client
+
+	// key signs the session cookies. It is made up when the bot starts,
+	// so restarting the bot signs everyone out.
+	key []byte
+}
+
+// newOIDCProvider looks up the endpoints of the provider at issuer.
+func newOIDCProvider(ctx context.Context, issuer, clientID, clientSecret, redirectURL string) (*oidcProvider, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	req, err := http.NewRequest(http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("getting the OpenID configuration of %s failed: %v", issuer, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting the OpenID configuration of %s failed: %s", issuer, resp.Status)
+	}
+
+	var config struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return nil, fmt.Errorf("decoding the OpenID configuration of %s failed: %v", issuer, err)
+	}
+	if strings.TrimSuffix(config.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("the OpenID configuration of %s is for issuer %s", issuer, config.Issuer)
+	}
+	if len(config.AuthorizationEndpoint) < 1 || len(config.TokenEndpoint) < 1 {
+		return nil, fmt.Errorf("the OpenID configuration of %s has no authorization or token endpoint", issuer)
+	}
+	// We trust the ID token because it comes from the token endpoint over
+	// TLS, so the token endpoint has to use it.
+	if u, err := url.Parse(config.TokenEndpoint); err != nil || u.Scheme != "https" {
+		return nil, fmt.Errorf("the token endpoint of %s must use https, got %s", issuer, config.TokenEndpoint)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return &oidcProvider{
+		issuer:       config.Issuer,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		authURL:      config.AuthorizationEndpoint,
+		tokenURL:     config.TokenEndpoint,
+		client:       http.DefaultClient,
+		key:          key,
+	}, nil
+}
+
+// callbackPath returns the path of the redirect URL, where the provider
+// sends users back to after they signed in.
+func (p *oidcProvider) callbackPath() string {
+	u, err := url.Parse(p.redirectURL)
+	if err != nil || len(u.Path) < 1 {
+		return "/oidc/callback"
+	}
+	return u.Path
+}
+
+// login sends the user to the provider to sign in.
+func (p *oidcProvider) login(w http.ResponseWriter, r *http.Request) {
+	state, nonce := randomString(), randomString()
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state + "." + nonce + "." + base64.RawURLEncoding.EncodeToString([]byte(r.URL.RequestURI())),
+		Path:     "/",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.clientID)
+	v.Set("redirect_uri", p.redirectURL)
+	v.Set("scope", "openid email profile")
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	sep := "?"
+	if strings.Contains(p.authURL, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, p.authURL+sep+v.Encode(), http.StatusFound)
+}
+
+// callback finishes signing in the user the provider sent back, and sends
+// them on to the page they were opening.
+func (p *oidcProvider) callback(w http.ResponseWriter, r *http.Request) {
+	c, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		http.Error(w, "the sign in expired, try again", http.StatusBadRequest)
+		return
+	}
+	parts := strings.SplitN(c.Value, ".", 3)
+	if len(parts) != 3 || !hmac.Equal([]byte(parts[0]), []byte(r.URL.Query().Get("state"))) {
+		http.Error(w, "the sign in is not valid, try again", http.StatusBadRequest)
+		return
+	}
+	if e := r.URL.Query().Get("error"); len(e) > 0 {
+		http.Error(w, "signing in failed: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	email, err := p.exchange(r.Context(), r.URL.Query().Get("code"), parts[1])
+	if err != nil {
+		logrus.Warnf("OpenID Connect sign in failed: %v", err)
+		http.Error(w, "signing in failed", http.StatusUnauthorized)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    p.signSession(email, time.Now().Add(sessionDuration)),
+		Path:     "/",
+		MaxAge:   int(sessionDuration.Seconds()),
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	next := "/"
+	if b, err := base64.RawURLEncoding.DecodeString(parts[2]); err == nil && strings.HasPrefix(string(b), "/") && !strings.HasPrefix(string(b), "//") {
+		next = string(b)
+	}
+	http.Redirect(w, r, next, http.StatusFound)
+}
+
+// exchange trades the authorization code for an ID token and returns the
+// verified email address of the user.
+//
+// The ID token comes straight from the token endpoint over TLS, so as the
+// OpenID Connect spec allows we trust the connection instead of checking its
+// signature, and only check its claims.
+func (p *oidcProvider) exchange(ctx context.Context, code, nonce string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	req, err := http.NewRequest(http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("calling the token endpoint failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decoding the token response failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || len(token.Error) > 0 {
+		return "", fmt.Errorf("the token endpoint returned %s: %s %s", resp.Status, token.Error, token.ErrorDescription)
+	}
+
+	parts := strings.Split(token.IDToken, ".")
+	if len(parts) != 3 {
+		return "", errors.New("the ID token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", fmt.Errorf("decoding the ID token failed: %v", err)
+	}
+	var claims struct {
+		Issuer        string          `json:"iss"`
+		Audience      json.RawMessage `json:"aud"`
+		Expires       int64           `json:"exp"`
+		Nonce         string          `json:"nonce"`
+		Email         string          `json:"email"`
+		EmailVerified interface{}     `json:"email_verified"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("decoding the ID token failed: %v", err)
+	}
+
+	if claims.Issuer != p.issuer {
+		return "", fmt.Errorf("the ID token is from issuer %s", claims.Issuer)
+	}
+	var audience []string
+	if err := json.Unmarshal(claims.Audience, &audience); err != nil {
+		var single string
+		json.Unmarshal(claims.Audience, &single)
+		audience = []string{single}
+	}
+	if !containsString(audience, p.clientID) {
+		return "", errors.New("the ID token is not for this client")
+	}
+	if time.Now().Unix() >= claims.Expires {
+		return "", errors.New("the ID token has expired")
+	}
+	if !hmac.Equal([]byte(claims.Nonce), []byte(nonce)) {
+		return "", errors.New("the ID token nonce does not match")
+	}
+	if len(claims.Email) < 1 {
+		return "", errors.New("the ID token has no email, the provider needs to grant the email scope")
+	}
+	// Some providers send email_verified as a string, and some leave it
+	// out when they do not verify emails at all.
+	switch verified := claims.EmailVerified.(type) {
+	case nil:
+	case bool:
+		if !verified {
+			return "", fmt.Errorf("the email %s is not verified", claims.Email)
+		}
+	case string:
+		if ok, err := strconv.ParseBool(verified); err != nil || !ok {
+			return "", fmt.Errorf("the email %s is not verified", claims.Email)
+		}
+	default:
+		return "", fmt.Errorf("the ID token has email_verified %v, want true or false", verified)
+	}
+
+	return strings.ToLower(claims.Email), nil
+}
+
+// signSession returns the value of a session cookie for the email that is
+// valid until expires.
+func (p *oidcProvider) signSession(email string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(email)) + "." + strconv.FormatInt(expires.Unix(), 10)
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// session returns the email of the user signed in with the request, or an
+// empty string if there is none.
+func (p *oidcProvider) session(r *http.Request) string {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(c.Value, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal([]byte(parts[2]), []byte(base64.RawURLEncoding.EncodeToString(mac.Sum(nil)))) {
+		return ""
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return ""
+	}
+	email, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ""
+	}
+	return string(email)
+}
+
+// secureRequest returns true if the request came in over HTTPS, directly or
+// through a reverse proxy.
+func secureRequest(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
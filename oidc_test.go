@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// idToken returns an unsigned ID token with the claims.
+func idToken(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+}
+
+// tokenProvider returns a provider whose token endpoint returns the ID token
+// with the claims.
+func tokenProvider(t *testing.T, claims map[string]interface{}) *oidcProvider {
+	t.Helper()
+	token := idToken(t, claims)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "code" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error": "invalid_grant"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"id_token": %q}`, token)
+	}))
+	t.Cleanup(srv.Close)
+
+	return &oidcProvider{
+		issuer:      "https://auth.example.com",
+		clientID:    "tripitcalb0t",
+		redirectURL: "https://bot.example.com/oidc/callback",
+		tokenURL:    srv.URL + "/token",
+		client:      srv.Client(),
+		key:         []byte("key"),
+	}
+}
+
+// validClaims returns the claims of a valid ID token, with the changes.
+func validClaims(changes map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"iss":            "https://auth.example.com",
+		"aud":            "tripitcalb0t",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"nonce":          "nonce",
+		"email":          "Jess@Example.com",
+		"email_verified": true,
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+func TestOIDCExchange(t *testing.T) {
+	ctx := context.Background()
+	testcases := []struct {
+		name    string
+		changes map[string]interface{}
+	}{
+		{name: "valid"},
+		{name: "audience list", changes: map[string]interface{}{"aud": []string{"other", "tripitcalb0t"}}},
+		{name: "verified as a string", changes: map[string]interface{}{"email_verified": "true"}},
+		{name: "verified left out", changes: map[string]interface{}{"email_verified": nil}},
+	}
+	for _, tc := range testcases {
+		p := tokenProvider(t, validClaims(tc.changes))
+		email, err := p.exchange(ctx, "code", "nonce")
+		if err != nil {
+			t.Errorf("%s: exchange failed: %v", tc.name, err)
+			continue
+		}
+		if email != "jess@example.com" {
+			t.Errorf("%s: exchange = %q, want the email in lower case", tc.name, email)
+		}
+	}
+}
+
+func TestOIDCExchangeInvalid(t *testing.T) {
+	ctx := context.Background()
+	testcases := []struct {
+		name    string
+		changes map[string]interface{}
+		code    string
+	}{
+		{name: "wrong issuer", changes: map[string]interface{}{"iss": "https://evil.example.com"}},
+		{name: "wrong audience", changes: map[string]interface{}{"aud": "other"}},
+		{name: "wrong audience list", changes: map[string]interface{}{"aud": []string{"other"}}},
+		{name: "expired", changes: map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()}},
+		{name: "wrong nonce", changes: map[string]interface{}{"nonce": "other"}},
+		{name: "no email", changes: map[string]interface{}{"email": nil}},
+		{name: "not verified", changes: map[string]interface{}{"email_verified": false}},
+		{name: "not verified as a string", changes: map[string]interface{}{"email_verified": "false"}},
+		{name: "verified as something else", changes: map[string]interface{}{"email_verified": 1}},
+		{name: "bad code", code: "other"},
+	}
+	for _, tc := range testcases {
+		p := tokenProvider(t, validClaims(tc.changes))
+		code := tc.code
+		if len(code) < 1 {
+			code = "code"
+		}
+		if email, err := p.exchange(ctx, code, "nonce"); err == nil {
+			t.Errorf("%s: exchange = %q, want an error", tc.name, email)
+		}
+	}
+}
+
+func TestOIDCCallbackWrongState(t *testing.T) {
+	p := tokenProvider(t, validClaims(nil))
+	next := base64.RawURLEncoding.EncodeToString([]byte("/"))
+
+	testcases := []struct {
+		name   string
+		cookie string
+		query  string
+	}{
+		{name: "no cookie", query: "?state=state&code=code"},
+		{name: "wrong state", cookie: "state.nonce." + next, query: "?state=other&code=code"},
+		{name: "no state", cookie: "state.nonce." + next, query: "?code=code"},
+		{name: "bad cookie", cookie: "state", query: "?state=state&code=code"},
+	}
+	for _, tc := range testcases {
+		r := httptest.NewRequest(http.MethodGet, "/oidc/callback"+tc.query, nil)
+		if len(tc.cookie) > 0 {
+			r.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tc.cookie})
+		}
+		w := httptest.NewRecorder()
+		p.callback(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: callback returned %d, want %d", tc.name, w.Code, http.StatusBadRequest)
+		}
+		if strings.Contains(w.Header().Get("Set-Cookie"), sessionCookie) {
+			t.Errorf("%s: callback set a session", tc.name)
+		}
+	}
+
+	// With the right state the user is signed in.
+	r := httptest.NewRequest(http.MethodGet, "/oidc/callback?state=state&code=code", nil)
+	r.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: "state.nonce." + next})
+	w := httptest.NewRecorder()
+	p.callback(w, r)
+	if w.Code != http.StatusFound {
+		t.Fatalf("callback returned %d, want %d", w.Code, http.StatusFound)
+	}
+	signedIn := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		signedIn.AddCookie(c)
+	}
+	if email := p.session(signedIn); email != "jess@example.com" {
+		t.Errorf("session after signing in = %q, want jess@example.com", email)
+	}
+}
+
+func TestOIDCSession(t *testing.T) {
+	p := &oidcProvider{key: []byte("key")}
+	request := func(value string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: sessionCookie, Value: value})
+		return r
+	}
+
+	valid := p.signSession("jess@example.com", time.Now().Add(time.Hour))
+	if email := p.session(request(valid)); email != "jess@example.com" {
+		t.Errorf("session = %q, want jess@example.com", email)
+	}
+
+	other := (&oidcProvider{key: []byte("other")}).signSession("jess@example.com", time.Now().Add(time.Hour))
+	parts := strings.Split(valid, ".")
+	for name, value := range map[string]string{
+		"expired":      p.signSession("jess@example.com", time.Now().Add(-time.Minute)),
+		"other key":    other,
+		"other email":  base64.RawURLEncoding.EncodeToString([]byte("admin@example.com")) + "." + parts[1] + "." + parts[2],
+		"later expiry": parts[0] + "." + fmt.Sprint(time.Now().Add(24*time.Hour).Unix()) + "." + parts[2],
+		"not signed":   parts[0] + "." + parts[1],
+	} {
+		if email := p.session(request(value)); len(email) > 0 {
+			t.Errorf("%s: session = %q, want none", name, email)
+		}
+	}
+}
+
+func TestNewOIDCProviderInsecureTokenEndpoint(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"issuer": %q, "authorization_endpoint": "https://auth.example.com/authorize", "token_endpoint": %q}`, srv.URL, srv.URL+"/token")
+	}))
+	defer srv.Close()
+
+	if _, err := newOIDCProvider(context.Background(), srv.URL, "tripitcalb0t", "secret", "https://bot.example.com/oidc/callback"); err == nil {
+		t.Error("newOIDCProvider with a token endpoint over http succeeded, want an error")
+	}
+}
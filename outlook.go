@@ -0,0 +1,562 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const (
+	graphURL      = "https://graph.microsoft.com/v1.0"
+	graphLoginURL = "https://login.microsoftonline.com/"
+
+	// graphAppScope is the scope of app-only tokens, which get the
+	// application permissions granted to the app, like
+	// Calendars.ReadWrite.
+	graphAppScope = "https://graph.microsoft.com/.default"
+	// graphUserScopes are the scopes of tokens acting as the signed in
+	// user, which can be refreshed without signing in again.
+	graphUserScopes = "https://graph.microsoft.com/Calendars.ReadWrite offline_access"
+
+	// graphPropertyID is the single-value extended property the bot keeps
+	// the extended properties of its events in, as JSON, since Outlook
+	// events have nowhere else to keep them.
+	graphPropertyID = "String {5a2b7c1e-3f4d-4e8a-9b6c-0d1e2f3a4b5c} Name tripitcalb0t"
+
+	// graphTimeFormat is the format of the times in Graph events, which are
+	// in the timezone next to them.
+	graphTimeFormat = "2006-01-02T15:04:05.9999999"
+)
+
+// graphBackend is a Microsoft 365 or Outlook.com calendar, written to with
+// the Microsoft Graph API.
+type graphBackend struct {
+	client *http.Client
+	// calendarURL is the URL of the calendar in the Graph API.
+	calendarURL string
+	name        string
+}
+
+// graphError is an error response from the Graph API.
+type graphError struct {
+	method  string
+	url     string
+	code    int
+	status  string
+	message string
+}
+
+func (e *graphError) Error() string {
+	if len(e.message) > 0 {
+		return fmt.Sprintf("%s %s returned %s: %s", e.method, e.url, e.status, e.message)
+	}
+	return fmt.Sprintf("%s %s returned %s", e.method, e.url, e.status)
+}
+
+// graphEvent is an event in the Graph API.
+type graphEvent struct {
+	ID            string          `json:"id,omitempty"`
+	TransactionID string          `json:"transactionId,omitempty"`
+	Subject       string          `json:"subject"`
+	Body          *graphBody      `json:"body,omitempty"`
+	Start         *graphDateTime  `json:"start,omitempty"`
+	End           *graphDateTime  `json:"end,omitempty"`
+	IsAllDay      bool            `json:"isAllDay"`
+	Location      *graphLocation  `json:"location,omitempty"`
+	ShowAs        string          `json:"showAs,omitempty"`
+	Sensitivity   string          `json:"sensitivity,omitempty"`
+	Properties    []graphProperty `json:"singleValueExtendedProperties,omitempty"`
+}
+
+type graphBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type graphDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type graphLocation struct {
+	DisplayName string `json:"displayName"`
+}
+
+type graphProperty struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+// graphProperties are the extended properties and source of an event, kept
+// in the graphPropertyID property.
+type graphProperties struct {
+	Private map[string]string `json:"private,omitempty"`
+	Shared  map[string]string `json:"shared,omitempty"`
+	Source  string            `json:"source,omitempty"`
+}
+
+// newGraphBackend returns the backend for the calendar of the user, or of
+// the signed in user if user is empty, with the ID, or their default calendar
+// if id is empty.
+func newGraphBackend(client *http.Client, user, id string) *graphBackend {
+	u, name := graphURL+"/me", "outlook calendar"
+	if len(user) > 0 {
+		u, name = graphURL+"/users/"+url.PathEscape(user), "outlook calendar of "+user
+	}
+	if len(id) > 0 {
+		return &graphBackend{client: client, calendarURL: u + "/calendars/" + url.PathEscape(id), name: name + " " + id}
+	}
+	return &graphBackend{client: client, calendarURL: u + "/calendar", name: name}
+}
+
+func (g *graphBackend) String() string {
+	return g.name
+}
+
+// do calls the Graph API with the body as JSON and decodes the response
+// into v.
+func (g *graphBackend) do(ctx context.Context, method, u string, body interface{}, v interface{}) error {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, u, &buf)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	// Get descriptions back as we wrote them, not as HTML.
+	req.Header.Set("Prefer", `outlook.body-content-type="text"`)
+
+	resp, err := g.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return &graphError{method: method, url: u, code: resp.StatusCode, status: resp.Status, message: e.Error.Message}
+	}
+
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return fmt.Errorf("decoding the response to %s %s failed: %v", method, u, err)
+		}
+	}
+	return nil
+}
+
+// List returns the events in the calendar the bot manages.
+func (g *graphBackend) List(ctx context.Context) ([]*calendar.Event, error) {
+	q := url.Values{}
+	q.Set("$filter", fmt.Sprintf("singleValueExtendedProperties/Any(ep: ep/id eq '%s' and ep/value ne null)", graphPropertyID))
+	q.Set("$expand", fmt.Sprintf("singleValueExtendedProperties($filter=id eq '%s')", graphPropertyID))
+	q.Set("$top", "100")
+	next := g.calendarURL + "/events?" + q.Encode()
+
+	var events []*calendar.Event
+	for len(next) > 0 {
+		var page struct {
+			Value    []graphEvent `json:"value"`
+			NextLink string       `json:"@odata.nextLink"`
+		}
+		if err := g.do(ctx, http.MethodGet, next, nil, &page); err != nil {
+			return nil, fmt.Errorf("getting events from %s failed: %w", g, err)
+		}
+		for _, ge := range page.Value {
+			if e := ge.event(); privateProperty(e, propertyManaged) == "true" {
+				events = append(events, e)
+			}
+		}
+		next = page.NextLink
+	}
+	payloads.dump("outlook calendar events", events)
+	return events, nil
+}
+
+// Create adds the event. The transaction ID makes Graph ignore the create if
+// it is retried.
+func (g *graphBackend) Create(ctx context.Context, key string, e *calendar.Event) (*calendar.Event, error) {
+	payloads.dump("inserting outlook calendar event", e)
+	ge := newGraphEvent(e)
+	ge.TransactionID = key + "." + privateProperty(e, propertyHash)
+	var created graphEvent
+	if err := g.do(ctx, http.MethodPost, g.calendarURL+"/events", ge, &created); err != nil {
+		return nil, fmt.Errorf("inserting outlook calendar event for segment %s failed: %w", key, err)
+	}
+	return created.event(), nil
+}
+
+// Update reads the event, sets the fields in the patch, and writes it back,
+// since the extended properties of the event are kept in a single property
+// that has to be merged.
+func (g *graphBackend) Update(ctx context.Context, key, id string, patch *calendar.Event) error {
+	payloads.dump("patching outlook calendar event", patch)
+	q := url.Values{}
+	q.Set("$expand", fmt.Sprintf("singleValueExtendedProperties($filter=id eq '%s')", graphPropertyID))
+	var current graphEvent
+	if err := g.do(ctx, http.MethodGet, g.calendarURL+"/events/"+url.PathEscape(id)+"?"+q.Encode(), nil, &current); err != nil {
+		return fmt.Errorf("updating outlook calendar event %s failed: %w", id, err)
+	}
+
+	e := mergeEventPatch(current.event(), patch)
+	if err := g.do(ctx, http.MethodPatch, g.calendarURL+"/events/"+url.PathEscape(id), newGraphEvent(e), nil); err != nil {
+		return fmt.Errorf("updating outlook calendar event %s failed: %w", id, err)
+	}
+	return nil
+}
+
+// Delete deletes the event.
+func (g *graphBackend) Delete(ctx context.Context, key, id string) error {
+	if err := g.do(ctx, http.MethodDelete, g.calendarURL+"/events/"+url.PathEscape(id), nil, nil); err != nil && !isNotFound(err) {
+		return fmt.Errorf("removing outlook calendar event %s failed: %w", id, err)
+	}
+	return nil
+}
+
+// check checks that the credentials work and that we can read the calendar.
+func (g *graphBackend) check(ctx context.Context) error {
+	return g.do(ctx, http.MethodGet, g.calendarURL, nil, nil)
+}
+
+// newGraphEvent converts the calendar event to a Graph event. Times are
+// written in UTC.
+func newGraphEvent(e *calendar.Event) *graphEvent {
+	ge := &graphEvent{
+		Subject:     e.Summary,
+		Body:        &graphBody{ContentType: "text", Content: e.Description},
+		Location:    &graphLocation{DisplayName: e.Location},
+		ShowAs:      "busy",
+		Sensitivity: "normal",
+	}
+	for _, t := range []struct {
+		from *calendar.EventDateTime
+		to   **graphDateTime
+	}{{e.Start, &ge.Start}, {e.End, &ge.End}} {
+		if t.from == nil {
+			continue
+		}
+		if len(t.from.Date) > 0 {
+			ge.IsAllDay = true
+			*t.to = &graphDateTime{DateTime: t.from.Date + "T00:00:00", TimeZone: "UTC"}
+			continue
+		}
+		*t.to = &graphDateTime{DateTime: eventTime(*t.from).UTC().Format(graphTimeFormat), TimeZone: "UTC"}
+	}
+	if e.Transparency == "transparent" {
+		ge.ShowAs = "free"
+	}
+	if e.Visibility == "private" || e.Visibility == "confidential" {
+		ge.Sensitivity = "private"
+	}
+
+	var props graphProperties
+	if e.ExtendedProperties != nil {
+		props.Private, props.Shared = e.ExtendedProperties.Private, e.ExtendedProperties.Shared
+	}
+	if e.Source != nil {
+		props.Source = e.Source.Url
+	}
+	b, _ := json.Marshal(props)
+	ge.Properties = []graphProperty{{ID: graphPropertyID, Value: string(b)}}
+	return ge
+}
+
+// event converts the Graph event to a calendar event.
+func (ge graphEvent) event() *calendar.Event {
+	e := &calendar.Event{
+		Id:      ge.ID,
+		Summary: ge.Subject,
+	}
+	if ge.Body != nil {
+		e.Description = ge.Body.Content
+	}
+	if ge.Location != nil {
+		e.Location = ge.Location.DisplayName
+	}
+	if ge.ShowAs == "free" {
+		e.Transparency = "transparent"
+	}
+	if ge.Sensitivity == "private" || ge.Sensitivity == "confidential" {
+		e.Visibility = "private"
+	}
+	e.Start, e.End = ge.Start.eventDateTime(ge.IsAllDay), ge.End.eventDateTime(ge.IsAllDay)
+
+	for _, p := range ge.Properties {
+		if p.ID != graphPropertyID {
+			continue
+		}
+		var props graphProperties
+		if err := json.Unmarshal([]byte(p.Value), &props); err != nil {
+			continue
+		}
+		e.ExtendedProperties = &calendar.EventExtendedProperties{Private: props.Private, Shared: props.Shared}
+		if len(props.Source) > 0 {
+			e.Source = &calendar.EventSource{Title: "TripIt", Url: props.Source}
+		}
+	}
+	return e
+}
+
+// eventDateTime converts the start or end of a Graph event.
+func (t *graphDateTime) eventDateTime(allDay bool) *calendar.EventDateTime {
+	if t == nil || len(t.DateTime) < 10 {
+		return nil
+	}
+	if allDay {
+		return &calendar.EventDateTime{Date: t.DateTime[:10]}
+	}
+	loc, err := time.LoadLocation(t.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	v, err := time.ParseInLocation(graphTimeFormat, t.DateTime, loc)
+	if err != nil {
+		return nil
+	}
+	return &calendar.EventDateTime{DateTime: v.Format(time.RFC3339)}
+}
+
+// graphToken is a token response from the Microsoft identity platform.
+type graphToken struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// token returns the OAuth2 token of the response.
+func (t graphToken) token() *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		TokenType:    t.TokenType,
+		Expiry:       time.Now().Add(time.Duration(t.ExpiresIn) * time.Second),
+	}
+}
+
+// postGraphLogin posts the form to the endpoint of the tenant on the
+// Microsoft identity platform, like token, and decodes the response into v.
+// Errors the endpoint explains, like a pending device login, are decoded
+// too, for the caller to check.
+func postGraphLogin(ctx context.Context, endpoint string, form url.Values, v interface{}) error {
+	u := graphLoginURL + url.PathEscape(outlookTenant) + "/oauth2/v2.0/" + endpoint
+	req, err := http.NewRequest(http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("signing in to microsoft failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("signing in to microsoft returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding the microsoft sign in response failed: %v", err)
+	}
+	return nil
+}
+
+// graphAppTokenSource gets app-only tokens with the client secret.
+type graphAppTokenSource struct {
+	ctx context.Context
+}
+
+func (s graphAppTokenSource) Token() (*oauth2.Token, error) {
+	var t graphToken
+	if err := postGraphLogin(s.ctx, "token", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {outlookClientID},
+		"client_secret": {outlookClientSecret},
+		"scope":         {graphAppScope},
+	}, &t); err != nil {
+		return nil, err
+	}
+	if len(t.Error) > 0 {
+		return nil, fmt.Errorf("signing in to microsoft failed: %s", t.ErrorDescription)
+	}
+	return t.token(), nil
+}
+
+// savingTokenSource saves the tokens of the source to the file when they
+// change, since Microsoft hands out a new refresh token with every access
+// token.
+type savingTokenSource struct {
+	src  oauth2.TokenSource
+	path string
+
+	mu   sync.Mutex
+	last string
+}
+
+func (s *savingTokenSource) Token() (*oauth2.Token, error) {
+	t, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.AccessToken != s.last {
+		if err := writeGraphToken(s.path, t); err != nil {
+			return nil, err
+		}
+		s.last = t.AccessToken
+	}
+	return t, nil
+}
+
+// writeGraphToken writes the token to the file.
+func writeGraphToken(path string, t *oauth2.Token) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("creating outlook token directory failed: %v", err)
+	}
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		return fmt.Errorf("writing outlook token file %s failed: %v", path, err)
+	}
+	return nil
+}
+
+// newGraphClient returns an HTTP client for the Graph API, that signs in as
+// the app with the client secret if there is one, or as the user who signed
+// in with outlook login otherwise.
+func newGraphClient(ctx context.Context) (*http.Client, error) {
+	if len(outlookClientSecret) > 0 {
+		return oauth2.NewClient(ctx, oauth2.ReuseTokenSource(nil, graphAppTokenSource{ctx: ctx})), nil
+	}
+
+	b, err := ioutil.ReadFile(outlookTokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading outlook token file %s failed, sign in with outlook login first: %v", outlookTokenFile, err)
+	}
+	var t oauth2.Token
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, fmt.Errorf("parsing outlook token file %s failed: %v", outlookTokenFile, err)
+	}
+
+	conf := &oauth2.Config{
+		ClientID: outlookClientID,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  graphLoginURL + url.PathEscape(outlookTenant) + "/oauth2/v2.0/authorize",
+			TokenURL: graphLoginURL + url.PathEscape(outlookTenant) + "/oauth2/v2.0/token",
+		},
+		Scopes: strings.Fields(graphUserScopes),
+	}
+	src := &savingTokenSource{src: conf.TokenSource(ctx, &t), path: outlookTokenFile, last: t.AccessToken}
+	return oauth2.NewClient(ctx, src), nil
+}
+
+const outlookHelp = `Sign in to an Outlook calendar.`
+
+const outlookLongHelp = `Sign in to an Outlook calendar.
+
+Signs in to Microsoft 365 or Outlook.com with a device code, for syncing to
+your own calendar without a client secret. Open the link it prints on any
+device, enter the code, and sign in. The token is saved to --outlook-token-file
+and refreshed as the bot runs.
+
+  tripitcalb0t --outlook-client-id <app-id> outlook login`
+
+func (cmd *outlookCommand) Name() string      { return "outlook" }
+func (cmd *outlookCommand) Args() string      { return "login" }
+func (cmd *outlookCommand) ShortHelp() string { return outlookHelp }
+func (cmd *outlookCommand) LongHelp() string  { return outlookLongHelp }
+func (cmd *outlookCommand) Hidden() bool      { return false }
+
+func (cmd *outlookCommand) Register(fs *flag.FlagSet) {}
+
+type outlookCommand struct{}
+
+func (cmd *outlookCommand) Run(ctx context.Context, args []string) error {
+	if len(args) < 1 || args[0] != "login" {
+		return errors.New("usage: outlook login")
+	}
+	if len(outlookClientID) < 1 {
+		fatal(exitCodeConfig, errors.New("pass the application ID of the app registration with --outlook-client-id"))
+	}
+
+	var code struct {
+		DeviceCode       string `json:"device_code"`
+		Message          string `json:"message"`
+		ExpiresIn        int    `json:"expires_in"`
+		Interval         int    `json:"interval"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := postGraphLogin(ctx, "devicecode", url.Values{
+		"client_id": {outlookClientID},
+		"scope":     {graphUserScopes},
+	}, &code); err != nil {
+		return err
+	}
+	if len(code.Error) > 0 {
+		return fmt.Errorf("signing in to microsoft failed: %s", code.ErrorDescription)
+	}
+	fmt.Fprintln(os.Stderr, code.Message)
+
+	interval := time.Duration(code.Interval) * time.Second
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+
+		var t graphToken
+		if err := postGraphLogin(ctx, "token", url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"client_id":   {outlookClientID},
+			"device_code": {code.DeviceCode},
+		}, &t); err != nil {
+			return err
+		}
+		switch t.Error {
+		case "":
+			if err := writeGraphToken(outlookTokenFile, t.token()); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Signed in, saved the token to %s\n", outlookTokenFile)
+			return nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return fmt.Errorf("signing in to microsoft failed: %s", t.ErrorDescription)
+		}
+	}
+	return errors.New("the device code expired before you signed in, run outlook login again")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// fakeGraph is an Outlook calendar in memory, with just enough of the Graph
+// API for graphBackend. It lists one event a page, to page through them.
+type fakeGraph struct {
+	mu     sync.Mutex
+	url    string
+	events map[string]graphEvent
+	nextID int
+}
+
+func (f *fakeGraph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": {"code": "InvalidAuthenticationToken", "message": "Access token is empty."}}`)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/me/calendar/events")
+	id = strings.TrimPrefix(id, "/")
+
+	switch {
+	case r.Method == http.MethodGet && len(id) < 1:
+		ids := make([]string, 0, len(f.events))
+		for id := range f.events {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		skip, _ := strconv.Atoi(r.URL.Query().Get("$skip"))
+		page := map[string]interface{}{"value": []graphEvent{}}
+		if skip < len(ids) {
+			page["value"] = []graphEvent{f.events[ids[skip]]}
+		}
+		if skip+1 < len(ids) {
+			page["@odata.nextLink"] = fmt.Sprintf("%s/me/calendar/events?$skip=%d", f.url, skip+1)
+		}
+		json.NewEncoder(w).Encode(page)
+	case r.Method == http.MethodPost && len(id) < 1:
+		var ge graphEvent
+		if err := json.NewDecoder(r.Body).Decode(&ge); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.nextID++
+		ge.ID = fmt.Sprintf("AAMk%d", f.nextID)
+		f.events[ge.ID] = ge
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(ge)
+	case r.Method == http.MethodGet:
+		ge, ok := f.events[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ErrorItemNotFound", "message": "The specified object was not found in the store."}}`)
+			return
+		}
+		json.NewEncoder(w).Encode(ge)
+	case r.Method == http.MethodPatch:
+		if _, ok := f.events[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var ge graphEvent
+		if err := json.NewDecoder(r.Body).Decode(&ge); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		ge.ID = id
+		f.events[id] = ge
+		json.NewEncoder(w).Encode(ge)
+	case r.Method == http.MethodDelete:
+		if _, ok := f.events[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.events, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// bearerTransport adds the bearer token to every request.
+type bearerTransport struct {
+	token string
+}
+
+func (t bearerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+t.token)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// newFakeGraphBackend returns a backend for the calendar of the fake, with
+// the token.
+func newFakeGraphBackend(t *testing.T, token string) (*graphBackend, *fakeGraph) {
+	t.Helper()
+	fake := &fakeGraph{events: map[string]graphEvent{
+		// An event the bot does not manage is left out.
+		"AAMk0": {ID: "AAMk0", Subject: "Dentist", Start: &graphDateTime{DateTime: "2030-07-09T09:00:00.0000000", TimeZone: "UTC"}},
+	}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	fake.url = srv.URL
+
+	client := &http.Client{Transport: bearerTransport{token: token}}
+	return &graphBackend{client: client, calendarURL: srv.URL + "/me/calendar", name: "outlook calendar"}, fake
+}
+
+func TestGraphBackend(t *testing.T) {
+	ctx := context.Background()
+	g, fake := newFakeGraphBackend(t, "token")
+
+	departs := time.Date(2030, time.July, 10, 9, 0, 0, 0, time.UTC)
+	flight := newCalendarEvent(hashEvent(departs), "San Francisco International Airport")
+	flight.Visibility = "private"
+	stay := hashEvent(departs)
+	stay.SegmentID, stay.Title, stay.AllDay = "hotel", "Hotel in Newark", true
+	stay.Start = calendar.EventDateTime{Date: "2030-07-10"}
+	stay.End = calendar.EventDateTime{Date: "2030-07-12"}
+
+	var ids []string
+	for _, e := range []*calendar.Event{flight, newCalendarEvent(stay, "")} {
+		created, err := g.Create(ctx, privateProperty(e, propertySegmentID), e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(created.Id) < 1 {
+			t.Fatalf("created %+v without an ID", created)
+		}
+		ids = append(ids, created.Id)
+	}
+
+	events, err := g.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("List = %d events, want the 2 the bot manages from every page", len(events))
+	}
+	byID := map[string]*calendar.Event{}
+	for _, e := range events {
+		byID[e.Id] = e
+	}
+
+	got := byID[ids[0]]
+	if got == nil || got.Summary != flight.Summary || got.Location != flight.Location || got.Description != flight.Description {
+		t.Fatalf("listed flight %+v, want it read back as written", got)
+	}
+	if !eventTime(*got.Start).Equal(departs) || got.Visibility != "private" {
+		t.Errorf("listed flight starts %s with visibility %q, want %s and private", got.Start.DateTime, got.Visibility, departs)
+	}
+	if privateProperty(got, propertySegmentID) != "segment" || got.Source == nil || got.Source.Url != flight.Source.Url {
+		t.Errorf("listed flight %+v, want its extended properties and source kept", got)
+	}
+	if hotel := byID[ids[1]]; hotel == nil || hotel.Start.Date != "2030-07-10" || hotel.End.Date != "2030-07-12" || hotel.Transparency != "transparent" {
+		t.Errorf("listed hotel %+v, want an all-day event that does not make us busy", hotel)
+	}
+
+	if err := g.Update(ctx, "segment", ids[0], &calendar.Event{Summary: "Flight to Boston (UA 123)"}); err != nil {
+		t.Fatal(err)
+	}
+	updated := fake.events[ids[0]].event()
+	if updated.Summary != "Flight to Boston (UA 123)" || updated.Location != flight.Location || privateProperty(updated, propertySegmentID) != "segment" {
+		t.Errorf("after Update the event is %+v, want only the summary changed", updated)
+	}
+
+	if err := g.Delete(ctx, "segment", ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.events[ids[0]]; ok {
+		t.Error("Delete left the event")
+	}
+	// Deleting an event that is already gone is not an error.
+	if err := g.Delete(ctx, "segment", ids[0]); err != nil {
+		t.Errorf("Delete of a missing event failed: %v", err)
+	}
+	if err := g.Update(ctx, "segment", ids[0], &calendar.Event{Summary: "Gone"}); !isNotFound(err) {
+		t.Errorf("Update of a missing event = %v, want not found", err)
+	}
+}
+
+func TestGraphBackendErrors(t *testing.T) {
+	g, _ := newFakeGraphBackend(t, "expired")
+
+	_, err := g.List(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "Access token is empty.") {
+		t.Errorf("List with a bad token = %v, want the error from Graph", err)
+	}
+}
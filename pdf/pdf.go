@@ -0,0 +1,241 @@
+// Package pdf writes simple text documents as PDF: headings and wrapped
+// paragraphs set in the standard Helvetica fonts, flowed over as many pages as
+// they need. The standard fonts are built into every PDF reader, so nothing
+// is embedded and the files stay small.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PageSize is the size of the pages of a document and the margins and text
+// size that suit it, in points.
+type PageSize struct {
+	Width    int
+	Height   int
+	Margin   int
+	FontSize float64
+}
+
+// Page sizes.
+var (
+	// Letter is a US Letter page.
+	Letter = PageSize{Width: 612, Height: 792, Margin: 54, FontSize: 10}
+	// Card is the size of a credit card, small enough to fit in a wallet.
+	Card = PageSize{Width: 243, Height: 153, Margin: 10, FontSize: 6}
+)
+
+type font struct {
+	name   string
+	widths []int // widths of the characters from 32 to 126, in 1/1000 em
+}
+
+var (
+	helvetica = font{name: "F1", widths: []int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}}
+	helveticaBold = font{name: "F2", widths: []int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}}
+)
+
+// width returns the width of the text set in the font at size, in points.
+func (f font) width(text []byte, size float64) float64 {
+	var w int
+	for _, c := range text {
+		if c >= 32 && c <= 126 {
+			w += f.widths[c-32]
+		} else {
+			w += 556
+		}
+	}
+	return float64(w) * size / 1000
+}
+
+// Document is a PDF document being written.
+type Document struct {
+	title string
+	size  PageSize
+	pages []*bytes.Buffer
+	y     float64
+}
+
+// New returns an empty document with the given title on Letter pages.
+func New(title string) *Document {
+	return NewSize(title, Letter)
+}
+
+// NewSize returns an empty document with the given title and page size.
+func NewSize(title string, size PageSize) *Document {
+	return &Document{title: title, size: size}
+}
+
+// Heading adds a large bold heading.
+func (d *Document) Heading(text string) {
+	d.space(d.size.FontSize * 0.8)
+	d.paragraph(helveticaBold, d.size.FontSize*1.6, text)
+	d.space(d.size.FontSize * 0.4)
+}
+
+// Subheading adds a small bold heading.
+func (d *Document) Subheading(text string) {
+	d.space(d.size.FontSize * 0.6)
+	d.paragraph(helveticaBold, d.size.FontSize*1.1, text)
+	d.space(d.size.FontSize * 0.2)
+}
+
+// Text adds a paragraph of text, wrapped to the width of the page. Each line
+// of the text starts a new line.
+func (d *Document) Text(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		d.paragraph(helvetica, d.size.FontSize, line)
+	}
+}
+
+// Space adds a blank line.
+func (d *Document) Space() {
+	d.space(d.size.FontSize)
+}
+
+func (d *Document) space(h float64) {
+	if len(d.pages) > 0 {
+		d.y -= h
+	}
+}
+
+// paragraph sets the text in the font at size, wrapping it at word
+// boundaries and starting a new page when the current one is full.
+func (d *Document) paragraph(f font, size float64, text string) {
+	leading := size * 1.3
+	maxWidth := float64(d.size.Width - 2*d.size.Margin)
+
+	words := bytes.Fields(encode(text))
+	if len(words) < 1 {
+		d.line(f, size, leading, nil)
+		return
+	}
+
+	var line []byte
+	for _, word := range words {
+		candidate := word
+		if len(line) > 0 {
+			candidate = append(append(append([]byte{}, line...), ' '), word...)
+		}
+		if len(line) > 0 && f.width(candidate, size) > maxWidth {
+			d.line(f, size, leading, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	d.line(f, size, leading, line)
+}
+
+// line sets a single line of text.
+func (d *Document) line(f font, size, leading float64, text []byte) {
+	if len(d.pages) < 1 || d.y-leading < float64(d.size.Margin) {
+		d.pages = append(d.pages, &bytes.Buffer{})
+		d.y = float64(d.size.Height - d.size.Margin)
+	}
+	d.y -= leading
+
+	if len(text) < 1 {
+		return
+	}
+	page := d.pages[len(d.pages)-1]
+	fmt.Fprintf(page, "BT /%s %g Tf %d %.2f Td (%s) Tj ET\n", f.name, size, d.size.Margin, d.y, escape(text))
+}
+
+// encode converts the text to the WinAnsi encoding of the standard fonts,
+// replacing characters it does not have.
+func encode(text string) []byte {
+	b := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r == '\t':
+			b = append(b, ' ')
+		case r < 32:
+		case r < 127, r >= 160 && r <= 255:
+			b = append(b, byte(r))
+		case r == '€':
+			b = append(b, 128)
+		case r == '–', r == '—':
+			b = append(b, '-')
+		case r == '‘', r == '’':
+			b = append(b, '\'')
+		case r == '“', r == '”':
+			b = append(b, '"')
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+// escape escapes the text for a PDF string literal.
+func escape(text []byte) []byte {
+	var b bytes.Buffer
+	for _, c := range text {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.Bytes()
+}
+
+// WriteTo writes the document to w as a PDF file.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) < 1 {
+		d.pages = append(d.pages, &bytes.Buffer{})
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// The catalog, the page tree, the fonts, and the document info come
+	// first, then a page and its contents for every page.
+	const firstPage = 6
+	var kids []string
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPage+2*i))
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	obj(fmt.Sprintf("<< /Title (%s) /Producer (tripitcalb0t) >>", escape(encode(d.title))))
+	for i, page := range d.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", d.size.Width, d.size.Height, firstPage+2*i+1))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.Bytes()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
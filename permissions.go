@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// permissionProblem is a file holding credentials or itinerary data that
+// other users can read, or that someone else owns.
+type permissionProblem struct {
+	path   string
+	mode   os.FileMode
+	reason string
+	// fixable is true if chmod can fix the problem.
+	fixable bool
+}
+
+func (p permissionProblem) String() string {
+	return fmt.Sprintf("%s (%s) %s", p.path, p.mode.Perm(), p.reason)
+}
+
+// sensitivePaths returns the files and directories that hold credentials or
+// itinerary data.
+func sensitivePaths() []string {
+	paths := []string{credsDir, configFile, googleCalendarKeyfile, companionKeyfile, outlookTokenFile, usersFile, logFile}
+	if len(stateFile) > 0 {
+		paths = append(paths, filepath.Dir(stateFile), stateFile, historyDir())
+		for i := 1; i < len(calendarBackendNames()); i++ {
+			paths = append(paths, backendStateFile(i))
+		}
+		if len(companionCalendar) > 0 {
+			paths = append(paths, companionStateFile())
+		}
+	}
+	return paths
+}
+
+// checkPermissions returns the problems with the permissions of the paths
+// that exist, like SSH does for keys: nobody but their owner, who must be
+// us, may read them.
+func checkPermissions(paths []string) []permissionProblem {
+	if !permissionsSupported {
+		return nil
+	}
+
+	var problems []permissionProblem
+	seen := map[string]bool{}
+	for _, path := range paths {
+		if len(path) < 1 || seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		// Like SSH, files may also belong to root. Running as root, who
+		// owns them does not matter.
+		if uid, ok := fileOwner(fi); ok && uid != os.Getuid() && uid != 0 && os.Getuid() != 0 {
+			problems = append(problems, permissionProblem{path: path, mode: fi.Mode(), reason: fmt.Sprintf("is owned by uid %d, not by us (uid %d)", uid, os.Getuid())})
+		}
+		// Other users may list a directory, but not change what is in it.
+		switch {
+		case fi.IsDir() && fi.Mode().Perm()&0022 != 0:
+			problems = append(problems, permissionProblem{path: path, mode: fi.Mode(), reason: "can be changed by other users", fixable: true})
+		case !fi.IsDir() && fi.Mode().Perm()&0077 != 0:
+			problems = append(problems, permissionProblem{path: path, mode: fi.Mode(), reason: "can be read or changed by other users", fixable: true})
+		}
+	}
+	return problems
+}
+
+// enforcePermissions checks the permissions of the sensitive files. It fixes
+// what it can if fix is set, and returns an error for the problems left if
+// strict is set, or warns about them otherwise.
+func enforcePermissions(strict, fix bool) error {
+	problems := checkPermissions(sensitivePaths())
+	if fix {
+		for _, p := range problems {
+			if !p.fixable {
+				continue
+			}
+			mode := p.mode.Perm() &^ 0077
+			if p.mode.IsDir() {
+				mode = p.mode.Perm() &^ 0022
+			}
+			if err := os.Chmod(p.path, mode); errors.Is(err, syscall.EROFS) {
+				// Like a keyfile mounted read-only into a container,
+				// which has to be fixed where it comes from.
+				logrus.Warnf("cannot fix the permissions of %s on a read-only file system", p.path)
+				continue
+			} else if err != nil {
+				return fmt.Errorf("fixing the permissions of %s failed: %v", p.path, err)
+			}
+			logrus.Infof("Fixed the permissions of %s to %s", p.path, mode)
+		}
+		problems = checkPermissions(sensitivePaths())
+	}
+	if len(problems) < 1 {
+		return nil
+	}
+
+	if !strict {
+		for _, p := range problems {
+			logrus.Warnf("INSECURE: %s", p)
+		}
+		return nil
+	}
+
+	var lines []string
+	for _, p := range problems {
+		lines = append(lines, p.String())
+	}
+	return fmt.Errorf("refusing to start, files with credentials or itineraries are not private: %s; run with --fix-permissions to make them private to their owner, or --strict-permissions=false to only warn", strings.Join(lines, "; "))
+}
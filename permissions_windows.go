@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "os"
+
+// permissionsSupported is false on Windows, where files are protected by
+// ACLs rather than Unix permissions.
+const permissionsSupported = false
+
+func fileOwner(fi os.FileInfo) (int, bool) {
+	return 0, false
+}
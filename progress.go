@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// progress reports the progress of long operations. On a terminal it draws a
+// progress bar, otherwise it logs the percentage done every 10%.
+type progress struct {
+	w     io.Writer
+	tty   bool
+	label string
+	total int
+	done  int
+
+	logged int
+}
+
+// newProgress returns a progress reporter for total steps written to stderr.
+func newProgress(label string, total int) *progress {
+	return &progress{
+		w:     os.Stderr,
+		tty:   isTerminal(os.Stderr),
+		label: label,
+		total: total,
+	}
+}
+
+// step marks one more step as done and reports it.
+func (p *progress) step() {
+	p.done++
+
+	percent := 100
+	if p.total > 0 {
+		percent = 100 * p.done / p.total
+	}
+
+	if !p.tty {
+		if percent/10 > p.logged/10 || p.done == p.total {
+			logrus.Infof("%s: %d%% (%d/%d)", p.label, percent, p.done, p.total)
+			p.logged = percent
+		}
+		return
+	}
+
+	const width = 40
+	filled := width * percent / 100
+	fmt.Fprintf(p.w, "\r%s [%s%s] %d/%d", p.label, strings.Repeat("=", filled), strings.Repeat(" ", width-filled), p.done, p.total)
+	if p.done >= p.total {
+		fmt.Fprintln(p.w)
+	}
+}
+
+// stop ends the progress bar early, so the next line is not drawn over it.
+func (p *progress) stop() {
+	if p.tty && p.done < p.total {
+		fmt.Fprintln(p.w)
+	}
+}
+
+// isTerminal returns true if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+// imminentWindow is how far ahead travel counts as imminent.
+const imminentWindow = 72 * time.Hour
+
+// Priorities of the work in a sync, most important first.
+const (
+	// priorityImminent is travel that is under way or starts within the
+	// imminent window.
+	priorityImminent = iota
+	// priorityPending is work left over from a run that ran out of quota.
+	priorityPending
+	// priorityUpcoming is the rest of the future travel.
+	priorityUpcoming
+	// priorityPast is travel that is over.
+	priorityPast
+)
+
+// prioritize returns the TripIt events in the order they should be synced:
+// imminent travel, then work left pending, then upcoming travel soonest first,
+// then past travel most recent first.
+func prioritize(trips []tripit.Event, pending []string, now time.Time) []tripit.Event {
+	isPending := map[string]bool{}
+	for _, id := range pending {
+		isPending[id] = true
+	}
+
+	priority := func(trip tripit.Event) int {
+		start, end := eventTime(trip.Start), eventTime(trip.End)
+		switch {
+		case end.After(now) && start.Before(now.Add(imminentWindow)):
+			return priorityImminent
+		case isPending[trip.SegmentID]:
+			return priorityPending
+		case start.After(now):
+			return priorityUpcoming
+		}
+		return priorityPast
+	}
+
+	queue := make([]tripit.Event, len(trips))
+	copy(queue, trips)
+	sort.SliceStable(queue, func(i, j int) bool {
+		pi, pj := priority(queue[i]), priority(queue[j])
+		if pi != pj {
+			return pi < pj
+		}
+		si, sj := eventTime(queue[i].Start), eventTime(queue[j].Start)
+		if pi == priorityPast {
+			return si.After(sj)
+		}
+		return si.Before(sj)
+	})
+
+	return queue
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// quotaPause is kept in the state while writes are paused because we ran out
+// of Google Calendar quota.
+type quotaPause struct {
+	// Until is when we expect the quota to have reset.
+	Until time.Time `json:"until"`
+	// Pending holds the segment IDs we did not get to write, so they go
+	// first once the quota resets.
+	Pending []string `json:"pending,omitempty"`
+}
+
+// quotaResetTime returns when we expect the quota that the error ran out of to
+// reset. We wait as long as Google asks in Retry-After if it does, otherwise
+// the daily quota resets at midnight Pacific time and rate limits reset
+// within minutes.
+func quotaResetTime(err error, now time.Time) time.Time {
+	var e *googleapi.Error
+	if errors.As(err, &e) {
+		if secs, err := strconv.Atoi(e.Header.Get("Retry-After")); err == nil && secs > 0 {
+			return now.Add(time.Duration(secs) * time.Second)
+		}
+		if t, err := http.ParseTime(e.Header.Get("Retry-After")); err == nil && t.After(now) {
+			return t
+		}
+		for _, item := range e.Errors {
+			if item.Reason == "quotaExceeded" || item.Reason == "dailyLimitExceeded" {
+				pacific, lerr := time.LoadLocation("America/Los_Angeles")
+				if lerr != nil {
+					pacific = time.FixedZone("PST", -8*60*60)
+				}
+				t := now.In(pacific)
+				return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, pacific)
+			}
+		}
+	}
+	return now.Add(10 * time.Minute)
+}
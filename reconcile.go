@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const reconcileHelp = `Cross-check TripIt, the state file, and the calendar.`
+
+const reconcileLongHelp = `Cross-check TripIt, the state file, and the calendar.
+
+Reports each inconsistency by class:
+
+  missing    the state has an event that is not in the calendar
+  unknown    the calendar has an event for a segment TripIt does not know
+  untracked  the calendar has an event for a segment the state does not know
+  unsynced   TripIt has a segment that has no event in the calendar
+
+Pass the classes to fix to --repair. Missing events are forgotten, unknown
+events are deleted, untracked events are added to the state, and unsynced
+segments are synced. Unsynced segments of past trips are only synced with
+--past.`
+
+// Classes of inconsistencies found by reconcile.
+const (
+	reconcileMissing   = "missing"
+	reconcileUnknown   = "unknown"
+	reconcileUntracked = "untracked"
+	reconcileUnsynced  = "unsynced"
+)
+
+func (cmd *reconcileCommand) Name() string      { return "reconcile" }
+func (cmd *reconcileCommand) Args() string      { return "" }
+func (cmd *reconcileCommand) ShortHelp() string { return reconcileHelp }
+func (cmd *reconcileCommand) LongHelp() string  { return reconcileLongHelp }
+func (cmd *reconcileCommand) Hidden() bool      { return false }
+
+func (cmd *reconcileCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.repair, "repair", "", "Comma separated classes of inconsistencies to repair (missing, unknown, untracked, unsynced)")
+}
+
+type reconcileCommand struct {
+	repair string
+}
+
+// inconsistency is a single disagreement between TripIt, the state, and the calendar.
+type inconsistency struct {
+	class     string
+	segmentID string
+	eventID   string
+	detail    string
+}
+
+func (cmd *reconcileCommand) Run(ctx context.Context, args []string) error {
+	repair := map[string]bool{}
+	for _, class := range strings.Split(cmd.repair, ",") {
+		class = strings.TrimSpace(class)
+		switch class {
+		case "":
+		case reconcileMissing, reconcileUnknown, reconcileUntracked, reconcileUnsynced:
+			repair[class] = true
+		default:
+			fatal(exitCodeConfig, fmt.Errorf("repair must be a list of missing, unknown, untracked, or unsynced, got %q", class))
+		}
+	}
+
+	tripitClient, err := newTripItClient()
+	if err != nil {
+		fatal(exitCodeConfig, err)
+	}
+	if err := validateCalendarFlags(); err != nil {
+		fatal(exitCodeConfig, err)
+	}
+	backends, err := getCalendarBackends(ctx)
+	if err != nil {
+		fatal(exitCodeCalendarAuth, err)
+	}
+	// The state file is the first calendar's, so that is the one to
+	// check. The others are synced from it.
+	backend := backends[0]
+
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+
+	// Get both past and future events so that events for trips that have
+	// ended are not reported as unknown.
+	trips, truncated, err := getTripItEvents(ctx, tripitClient, "true")
+	if err != nil {
+		return fmt.Errorf("getting tripit events failed: %v", err)
+	}
+	// The events of trips on the pages we did not get look unknown, so
+	// do not delete them.
+	if truncated && repair[reconcileUnknown] {
+		return fmt.Errorf("cannot repair unknown events with only the first %d pages of trips from TripIt, raise --tripit-max-pages to get them all", tripitMaxPages)
+	}
+	events, err := listAllEvents(ctx, backend)
+	if err != nil {
+		return err
+	}
+
+	found := findInconsistencies(trips, st, events)
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "CLASS\tSEGMENT\tEVENT\tDETAIL")
+	for _, i := range found {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", i.class, i.segmentID, i.eventID, i.detail)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(repair) < 1 {
+		return nil
+	}
+
+	var resync bool
+	for _, i := range found {
+		if !repair[i.class] {
+			continue
+		}
+
+		switch i.class {
+		case reconcileMissing:
+			st.forget(i.segmentID)
+		case reconcileUnknown:
+			if err := backend.Delete(ctx, i.segmentID, i.eventID); err != nil {
+				logrus.Errorf("deleting unknown event %s failed: %v", i.eventID, err)
+				continue
+			}
+			st.forget(i.segmentID)
+		case reconcileUntracked:
+			for _, e := range events {
+				if e.Id == i.eventID {
+					st.record(tripit.Event{
+						ID:        privateProperty(e, propertyTripID),
+						SegmentID: i.segmentID,
+					}, e.Id, privateProperty(e, propertyHash))
+				}
+			}
+		case reconcileUnsynced:
+			resync = true
+		}
+	}
+
+	if err := st.save(); err != nil {
+		return err
+	}
+
+	if resync {
+		res, err := runWithTimeout(ctx, tripitClient, backends, fmt.Sprintf("%v", past))
+		if err := res.write(os.Stdout, "text"); err != nil {
+			return err
+		}
+		return err
+	}
+
+	return nil
+}
+
+// findInconsistencies compares the TripIt events, the state, and the managed
+// calendar events and returns where they disagree.
+func findInconsistencies(trips []tripit.Event, st *syncState, events []*calendar.Event) []inconsistency {
+	var found []inconsistency
+
+	known := map[string]bool{}
+	for _, trip := range trips {
+		known[trip.SegmentID] = true
+	}
+
+	inCalendar := map[string]bool{}
+	bySegment := map[string]bool{}
+	for _, e := range events {
+		inCalendar[e.Id] = true
+
+		segmentID := privateProperty(e, propertySegmentID)
+		bySegment[segmentID] = true
+
+		if !known[segmentID] {
+			found = append(found, inconsistency{reconcileUnknown, segmentID, e.Id, e.Summary})
+			continue
+		}
+		if _, ok := st.Events[segmentID]; !ok {
+			found = append(found, inconsistency{reconcileUntracked, segmentID, e.Id, e.Summary})
+		}
+	}
+
+	for segmentID, se := range st.Events {
+		// Archived events may have moved to the archive calendar.
+		if !se.Archived && !inCalendar[se.EventID] {
+			found = append(found, inconsistency{reconcileMissing, segmentID, se.EventID, "synced " + se.Synced.Format("2006-01-02 15:04")})
+		}
+	}
+
+	for _, trip := range trips {
+		if !trip.Confirmed() || bySegment[trip.SegmentID] || st.archived(trip.SegmentID) {
+			continue
+		}
+		found = append(found, inconsistency{reconcileUnsynced, trip.SegmentID, "", trip.Title})
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].class < found[j].class
+	})
+
+	return found
+}
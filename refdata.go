@@ -0,0 +1,278 @@
+package main
+
+import (
+	"container/list"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/jessfraz/tripitcalb0t/countries"
+	"github.com/mmcloughlin/openflights"
+)
+
+// refdataFiles are the files in --refdata-dir that override the reference
+// data built into the binary.
+const (
+	airportsFile  = "airports.dat"
+	airlinesFile  = "airlines.dat"
+	countriesFile = "countries.json"
+)
+
+var (
+	// airportCache caches airport name lookups by IATA code.
+	airportCache = newLRUCache(256)
+
+	// airportOverrides and airlineOverrides are the airports and airlines
+	// from --refdata-dir, by IATA code. They are only set at startup.
+	airportOverrides = map[string]openflights.Airport{}
+	airlineOverrides = map[string]openflights.Airline{}
+
+	// airportIndex and airlineIndex are the positions of the airports and
+	// airlines in the dataset, by IATA code. They are built the first time
+	// one is looked up, so commands that never look one up do not build
+	// them, and they hold positions rather than copies of the records.
+	airportIndex     map[string]int
+	airportIndexOnce sync.Once
+	airlineIndex     map[string]int
+	airlineIndexOnce sync.Once
+)
+
+// getAirportName returns the name of the airport with the given IATA code or
+// an empty string if there is no match. The names looked up most recently
+// are kept in an LRU capped at --reference-cache-size.
+func getAirportName(code string) string {
+	if name, ok := airportCache.Get(code); ok {
+		return name
+	}
+
+	airport, _ := getAirport(code)
+	airportCache.Add(code, airport.Name)
+	return airport.Name
+}
+
+// getAirport returns the airport with the given IATA code.
+func getAirport(code string) (openflights.Airport, bool) {
+	if airport, ok := airportOverrides[code]; ok {
+		return airport, true
+	}
+	airportIndexOnce.Do(func() {
+		airportIndex = make(map[string]int, len(openflights.Airports))
+		for i, airport := range openflights.Airports {
+			// The dataset leaves the code empty for airfields without
+			// one, and the first airport with a code wins.
+			if _, ok := airportIndex[airport.IATA]; len(airport.IATA) > 0 && !ok {
+				airportIndex[airport.IATA] = i
+			}
+		}
+	})
+	if i, ok := airportIndex[code]; ok {
+		return openflights.Airports[i], true
+	}
+	return openflights.Airport{}, false
+}
+
+// getAirlineCountry returns the country the airline with the given IATA code
+// is incorporated in, or an empty string if there is no match.
+func getAirlineCountry(code string) string {
+	if airline, ok := airlineOverrides[code]; ok {
+		return airline.Country
+	}
+	airlineIndexOnce.Do(func() {
+		airlineIndex = make(map[string]int, len(openflights.Airlines))
+		for i, airline := range openflights.Airlines {
+			if _, ok := airlineIndex[airline.IATA]; len(airline.IATA) > 0 && !ok {
+				airlineIndex[airline.IATA] = i
+			}
+		}
+	})
+	if i, ok := airlineIndex[code]; ok {
+		return openflights.Airlines[i].Country
+	}
+	return ""
+}
+
+// loadRefData reads the airports, airlines, and countries in the directory
+// that add to or replace the ones built into the binary, so the data can be
+// kept current without a new release or a network connection. Every file is
+// optional. Airports and airlines are in the format of the OpenFlights
+// airports.dat and airlines.dat, and countries a JSON array of countries.
+func loadRefData(dir string) error {
+	if len(dir) < 1 {
+		return nil
+	}
+
+	// The coordinates and altitude of airports are numbers.
+	airports, err := readOpenFlights(filepath.Join(dir, airportsFile), 9, 6, 7, 8)
+	if err != nil {
+		return err
+	}
+	for _, r := range airports {
+		if len(r[4]) != 3 {
+			continue
+		}
+		// readOpenFlights checked the numbers, an unknown one is
+		// left at zero like in the dataset.
+		lat, _ := strconv.ParseFloat(r[6], 64)
+		lon, _ := strconv.ParseFloat(r[7], 64)
+		alt, _ := strconv.ParseFloat(r[8], 64)
+		airportOverrides[r[4]] = openflights.Airport{
+			Name:      r[1],
+			City:      r[2],
+			Country:   r[3],
+			IATA:      r[4],
+			ICAO:      r[5],
+			Latitude:  lat,
+			Longitude: lon,
+			Altitude:  alt,
+		}
+	}
+
+	airlines, err := readOpenFlights(filepath.Join(dir, airlinesFile), 7)
+	if err != nil {
+		return err
+	}
+	for _, r := range airlines {
+		if len(r[3]) != 2 {
+			continue
+		}
+		airlineOverrides[r[3]] = openflights.Airline{
+			Name:     r[1],
+			Alias:    r[2],
+			IATA:     r[3],
+			ICAO:     r[4],
+			Callsign: r[5],
+			Country:  r[6],
+		}
+	}
+
+	path := filepath.Join(dir, countriesFile)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading countries file %s failed: %v", path, err)
+	}
+	var cs []countries.Country
+	if err := json.Unmarshal(b, &cs); err != nil {
+		return fmt.Errorf("parsing countries file %s failed: %v", path, err)
+	}
+	for _, c := range cs {
+		if len(c.Name) < 1 {
+			return fmt.Errorf("every country in countries file %s needs a name", path)
+		}
+		countries.Set(c)
+	}
+	return nil
+}
+
+// readOpenFlights reads the records of an OpenFlights data file, with \N for
+// the fields it does not know, that have at least fields fields, and a number
+// in each of the numbers fields they know. A missing file has no records.
+func readOpenFlights(path string, fields int, numbers ...int) ([][]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	var records [][]string
+	for n := 1; ; n++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s failed: %v", path, err)
+		}
+		if len(record) < fields {
+			return nil, fmt.Errorf("line %d of %s has %d fields, want at least %d", n, path, len(record), fields)
+		}
+		for i := range record {
+			if record[i] == `\N` {
+				record[i] = ""
+			}
+		}
+		for _, i := range numbers {
+			if len(record[i]) < 1 {
+				continue
+			}
+			if _, err := strconv.ParseFloat(record[i], 64); err != nil {
+				return nil, fmt.Errorf("line %d of %s has %q in field %d, want a number", n, path, record[i], i+1)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// lruCache is a size-bounded least recently used cache of string values.
+type lruCache struct {
+	mu    sync.Mutex
+	max   int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value string
+}
+
+// newLRUCache returns a cache that holds at most max entries.
+func newLRUCache(max int) *lruCache {
+	return &lruCache{
+		max:   max,
+		ll:    list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+// Get returns the value for key and whether it was in the cache.
+func (c *lruCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+// Add adds the value for key to the cache, evicting the least recently used
+// entry if the cache is full.
+func (c *lruCache) Add(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.max < 1 {
+		return
+	}
+
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*lruEntry).value = value
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value})
+	if c.ll.Len() > c.max {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
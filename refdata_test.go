@@ -0,0 +1,179 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mmcloughlin/openflights"
+)
+
+// writeRefData writes the files to a temporary directory and returns it.
+func writeRefData(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// resetRefData clears the reference data overrides after the test.
+func resetRefData(t *testing.T) {
+	t.Cleanup(func() {
+		airportOverrides = map[string]openflights.Airport{}
+		airlineOverrides = map[string]openflights.Airline{}
+	})
+}
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(2)
+	c.Add("SFO", "San Francisco International Airport")
+	c.Add("JFK", "John F Kennedy International Airport")
+
+	// Getting SFO makes JFK the least recently used.
+	if name, ok := c.Get("SFO"); !ok || name != "San Francisco International Airport" {
+		t.Errorf("Get(SFO) = %q, %t, want the name", name, ok)
+	}
+	c.Add("MUC", "Munich Airport")
+
+	if _, ok := c.Get("JFK"); ok {
+		t.Error("Get(JFK) found the least recently used entry, want it evicted")
+	}
+	for _, code := range []string{"SFO", "MUC"} {
+		if _, ok := c.Get(code); !ok {
+			t.Errorf("Get(%s) found nothing, want it kept", code)
+		}
+	}
+
+	// Adding a key again replaces its value without evicting anything.
+	c.Add("MUC", "Flughafen München")
+	if name, _ := c.Get("MUC"); name != "Flughafen München" {
+		t.Errorf("Get(MUC) = %q, want the new value", name)
+	}
+	if _, ok := c.Get("SFO"); !ok {
+		t.Error("Get(SFO) found nothing after replacing MUC, want it kept")
+	}
+	if n := c.ll.Len(); n != 2 {
+		t.Errorf("cache holds %d entries, want 2", n)
+	}
+}
+
+func TestLRUCacheDisabled(t *testing.T) {
+	c := newLRUCache(0)
+	c.Add("SFO", "San Francisco International Airport")
+	if _, ok := c.Get("SFO"); ok {
+		t.Error("Get(SFO) found an entry in a cache of size 0")
+	}
+}
+
+func TestReadOpenFlights(t *testing.T) {
+	dir := writeRefData(t, map[string]string{
+		"airports.dat": `1,"Munich Airport","Munich","Germany","MUC","EDDM",48.353802,11.7861,1487
+2,"Unknown Field","Nowhere","Germany",\N,\N,\N,\N,\N
+`,
+	})
+
+	got, err := readOpenFlights(filepath.Join(dir, "airports.dat"), 9, 6, 7, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"1", "Munich Airport", "Munich", "Germany", "MUC", "EDDM", "48.353802", "11.7861", "1487"},
+		{"2", "Unknown Field", "Nowhere", "Germany", "", "", "", "", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readOpenFlights = %v, want %v", got, want)
+	}
+}
+
+func TestReadOpenFlightsMissing(t *testing.T) {
+	got, err := readOpenFlights(filepath.Join(t.TempDir(), "airports.dat"), 9)
+	if err != nil || got != nil {
+		t.Errorf("readOpenFlights of a missing file = %v, %v, want no records", got, err)
+	}
+}
+
+func TestReadOpenFlightsInvalid(t *testing.T) {
+	testcases := []struct {
+		name string
+		data string
+	}{
+		{name: "short record", data: `1,"Munich Airport","Munich","Germany","MUC"` + "\n"},
+		{name: "bad latitude", data: `1,"Munich Airport","Munich","Germany","MUC","EDDM",north,11.7861,1487` + "\n"},
+		{name: "bad altitude on line 2", data: `1,"Munich Airport","Munich","Germany","MUC","EDDM",48.353802,11.7861,1487
+2,"Frankfurt Airport","Frankfurt","Germany","FRA","EDDF",50.0333,8.5706,high
+`},
+	}
+
+	for _, tc := range testcases {
+		dir := writeRefData(t, map[string]string{"airports.dat": tc.data})
+		if got, err := readOpenFlights(filepath.Join(dir, "airports.dat"), 9, 6, 7, 8); err == nil {
+			t.Errorf("%s: readOpenFlights = %v, want an error", tc.name, got)
+		}
+	}
+}
+
+func TestLoadRefData(t *testing.T) {
+	resetRefData(t)
+	dir := writeRefData(t, map[string]string{
+		"airports.dat": `1,"Test Airport","Testville","Testland","ZZT","ZZZT",1.5,2.5,100
+2,"No Code Airfield","Testville","Testland",\N,\N,1,2,3
+`,
+		"airlines.dat": `1,"Test Air",\N,"Z9","ZZA","TESTAIR","Testland"
+`,
+	})
+
+	if err := loadRefData(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	airport, ok := getAirport("ZZT")
+	want := openflights.Airport{Name: "Test Airport", City: "Testville", Country: "Testland", IATA: "ZZT", ICAO: "ZZZT", Latitude: 1.5, Longitude: 2.5, Altitude: 100}
+	if !ok || airport != want {
+		t.Errorf("getAirport(ZZT) = %+v, %t, want %+v", airport, ok, want)
+	}
+	if len(airportOverrides) != 1 {
+		t.Errorf("loaded %d airports, want the one without an IATA code left out", len(airportOverrides))
+	}
+	if country := getAirlineCountry("Z9"); country != "Testland" {
+		t.Errorf("getAirlineCountry(Z9) = %q, want Testland", country)
+	}
+}
+
+func TestLoadRefDataInvalid(t *testing.T) {
+	resetRefData(t)
+
+	testcases := map[string]map[string]string{
+		"bad coordinate": {airportsFile: `1,"Test Airport","Testville","Testland","ZZT","ZZZT",1.5,east,100` + "\n"},
+		"short airline":  {airlinesFile: `1,"Test Air"` + "\n"},
+		"bad countries":  {countriesFile: `{"name": "Testland"}`},
+		"nameless":       {countriesFile: `[{"code": "ZZ"}]`},
+	}
+
+	for name, files := range testcases {
+		if err := loadRefData(writeRefData(t, files)); err == nil {
+			t.Errorf("%s: loadRefData succeeded, want an error", name)
+		}
+	}
+}
+
+func TestGetAirport(t *testing.T) {
+	if airport, ok := getAirport("SFO"); !ok || airport.City != "San Francisco" {
+		t.Errorf("getAirport(SFO) = %+v, %t, want San Francisco", airport, ok)
+	}
+	if name := getAirportName("SFO"); name != "San Francisco International Airport" {
+		t.Errorf("getAirportName(SFO) = %q", name)
+	}
+	for _, code := range []string{"", "Z9Z"} {
+		if airport, ok := getAirport(code); ok {
+			t.Errorf("getAirport(%q) = %+v, want no airport", code, airport)
+		}
+	}
+	if country := getAirlineCountry("LH"); country != "Germany" {
+		t.Errorf("getAirlineCountry(LH) = %q, want Germany", country)
+	}
+}
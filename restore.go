@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+)
+
+const restoreHelp = `Rebuild the calendar and the state file from TripIt.`
+
+const restoreLongHelp = `Rebuild the calendar and the state file from TripIt.
+
+For when the calendar or the state file was lost. Every flight in TripIt that
+departs after --since gets its event back, events that are still in the
+calendar are reused, and the state file is replaced with a new one.
+
+The state file is saved after every trip, so if the restore is interrupted,
+runs out of Google Calendar quota, or crashes, running it again picks up
+where it left off. Pass --restart to start over instead.`
+
+func (cmd *restoreCommand) Name() string      { return "restore" }
+func (cmd *restoreCommand) Args() string      { return "" }
+func (cmd *restoreCommand) ShortHelp() string { return restoreHelp }
+func (cmd *restoreCommand) LongHelp() string  { return restoreLongHelp }
+func (cmd *restoreCommand) Hidden() bool      { return false }
+
+func (cmd *restoreCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.since, "since", "", "Only restore flights departing on or after this year or date (ex. 2018, 2018-06-01)")
+	fs.BoolVar(&cmd.yes, "yes", false, "Do not ask for confirmation")
+	fs.BoolVar(&cmd.restart, "restart", false, "Start over instead of resuming an interrupted restore")
+}
+
+type restoreCommand struct {
+	since   string
+	yes     bool
+	restart bool
+}
+
+func (cmd *restoreCommand) Run(ctx context.Context, args []string) error {
+	var since time.Time
+	if len(cmd.since) > 0 {
+		var err error
+		since, err = parseSince(cmd.since)
+		if err != nil {
+			fatal(exitCodeConfig, err)
+		}
+	}
+
+	tripitClient, err := newTripItClient()
+	if err != nil {
+		fatal(exitCodeConfig, err)
+	}
+	backend := getPrimaryBackend(ctx)
+
+	all, _, err := getTripItEvents(ctx, tripitClient, "true")
+	if err != nil {
+		return fmt.Errorf("getting tripit events failed: %v", err)
+	}
+	var trips []tripit.Event
+	for _, trip := range all {
+		if !trip.Confirmed() || eventTime(trip.Start).Before(since) {
+			continue
+		}
+		trips = append(trips, trip)
+	}
+
+	existing, err := listAllEvents(ctx, backend)
+	if err != nil {
+		return err
+	}
+
+	// Resume an interrupted restore if there is one.
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+	resume := st.Restore != nil && !cmd.restart
+	if resume {
+		if st.Restore.Since != cmd.since {
+			fatal(exitCodeConfig, fmt.Errorf("an interrupted restore with --since %q is in progress, pass the same --since to resume it or --restart to start over", st.Restore.Since))
+		}
+
+		var remaining []tripit.Event
+		for _, trip := range trips {
+			if _, ok := st.Events[trip.SegmentID]; !ok {
+				remaining = append(remaining, trip)
+			}
+		}
+		trips = remaining
+	} else {
+		st = newState(stateFile)
+		st.Restore = &restoreCheckpoint{
+			Since:   cmd.since,
+			Started: time.Now().UTC(),
+		}
+	}
+
+	question := fmt.Sprintf("Restore %d flights to %s and replace the state file %s?", len(trips), backend, stateFile)
+	if resume {
+		question = fmt.Sprintf("Resume the restore started at %s and restore the remaining %d flights to %s?", st.Restore.Started.Format(time.RFC1123), len(trips), backend)
+	}
+	if !cmd.yes && !confirm(question) {
+		return nil
+	}
+
+	// Stop on ^C, but keep what we restored so far.
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(c)
+	go func() {
+		select {
+		case <-c:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	res := newSyncResult()
+	res.Events = len(trips)
+	p := newProgress("Restoring", len(trips))
+	for i, trip := range trips {
+		if ctx.Err() != nil {
+			p.stop()
+			return fmt.Errorf("restore interrupted after %d of %d flights, run it again to continue", p.done, p.total)
+		}
+
+		action, err := syncEvent(ctx, backend, st, existing, trip)
+		p.step()
+		if isQuotaExceeded(err) {
+			p.stop()
+			return fmt.Errorf("calendar quota exhausted after %d of %d flights, run the restore again once the quota resets: %v", p.done, p.total, err)
+		}
+		if err != nil {
+			logrus.Error(err)
+			res.fail(trip, err)
+		} else {
+			switch action {
+			case syncCreated:
+				res.Created++
+			case syncUpdated:
+				res.Updated++
+			default:
+				res.Unchanged++
+			}
+		}
+
+		// Checkpoint once every flight of the trip is done, so an
+		// interrupted restore picks up from the next trip.
+		if i == len(trips)-1 || trips[i+1].ID != trip.ID {
+			if err := st.save(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The restore is complete.
+	st.Restore = nil
+	if err := st.save(); err != nil {
+		return err
+	}
+
+	err = res.finish(nil)
+	if err := res.write(os.Stdout, output); err != nil {
+		return err
+	}
+	return err
+}
+
+// parseSince parses a year or a date.
+func parseSince(s string) (time.Time, error) {
+	for _, layout := range []string{"2006", "2006-01", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("since must be a year or a date like 2018 or 2018-06-01, got %q", s)
+}
+
+// confirm asks the question on stderr and returns true if the answer is yes.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+// syncResult holds the outcome of a single sync run.
+type syncResult struct {
+	Started   time.Time        `json:"started"`
+	Duration  string           `json:"duration"`
+	Events    int              `json:"events"`
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Unchanged int              `json:"unchanged"`
+	Removed   int              `json:"removed"`
+	Archived  int              `json:"archived"`
+	Pruned    int              `json:"pruned"`
+	Skipped   int              `json:"skipped"`
+	Failed    int              `json:"failed"`
+	Pending   int              `json:"pending"`
+	Awaiting  int              `json:"awaiting"`
+	Traveling bool             `json:"traveling"`
+	Errors    []syncEventError `json:"errors,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// syncEventError describes why a single TripIt event failed to sync, or
+// without a trip and segment, why a whole calendar did.
+type syncEventError struct {
+	TripID    string `json:"tripID"`
+	SegmentID string `json:"segmentID"`
+	Error     string `json:"error"`
+}
+
+func newSyncResult() *syncResult {
+	return &syncResult{Started: time.Now()}
+}
+
+// fail records that the given TripIt event failed to sync.
+func (r *syncResult) fail(trip tripit.Event, err error) {
+	r.Failed++
+	r.Errors = append(r.Errors, syncEventError{
+		TripID:    trip.ID,
+		SegmentID: trip.SegmentID,
+		Error:     err.Error(),
+	})
+}
+
+// finish records the duration of the run and any error that stopped it and
+// returns the error for the run as a whole.
+func (r *syncResult) finish(err error) error {
+	r.Duration = time.Since(r.Started).String()
+
+	if err != nil {
+		r.Error = err.Error()
+		return err
+	}
+
+	if r.Failed > 0 {
+		return &partialSyncError{failed: r.Failed, total: r.Events}
+	}
+
+	return nil
+}
+
+// write writes the result to w in the given output format.
+func (r *syncResult) write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "", "text":
+		_, err := fmt.Fprintf(w, "events: %d, created: %d, updated: %d, unchanged: %d, removed: %d, archived: %d, pruned: %d, skipped: %d, failed: %d, pending: %d, awaiting: %d, duration: %s\n",
+			r.Events, r.Created, r.Updated, r.Unchanged, r.Removed, r.Archived, r.Pruned, r.Skipped, r.Failed, r.Pending, r.Awaiting, r.Duration)
+		return err
+	}
+
+	return fmt.Errorf("unknown output format %q", format)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const pruneHelp = `Delete events older than the retention period.`
+
+const pruneLongHelp = `Delete events older than the retention period.
+
+Deletes the events the bot created that ended more than --retention-years
+ago, from the calendar and the archive calendar. The bot does this after
+every run when --retention-years is set, this command does it once and can
+show what it would delete with --dry-run.`
+
+func (cmd *pruneCommand) Name() string      { return "prune" }
+func (cmd *pruneCommand) Args() string      { return "" }
+func (cmd *pruneCommand) ShortHelp() string { return pruneHelp }
+func (cmd *pruneCommand) LongHelp() string  { return pruneLongHelp }
+func (cmd *pruneCommand) Hidden() bool      { return false }
+
+func (cmd *pruneCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "Only report the events that would be deleted, do not delete them (also set by the global --dry-run)")
+}
+
+type pruneCommand struct {
+	dryRun bool
+}
+
+func (cmd *pruneCommand) Run(ctx context.Context, args []string) error {
+	if retentionYears < 1 {
+		fatal(exitCodeConfig, errors.New("retention-years must be set to prune events"))
+	}
+	// Pruning lists events by when they end, which only Google Calendar
+	// can do, like after a sync.
+	google := requireGoogle(getPrimaryBackend(ctx), "prune")
+
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+
+	cmd.dryRun = cmd.dryRun || dryRun
+	pruned, err := pruneOldEvents(ctx, google.service, st, retentionCutoff(time.Now()), cmd.dryRun)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "ENDED\tEVENT\tSUMMARY")
+	for _, e := range pruned {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", eventTime(*e.End).Format("2006-01-02"), e.Id, e.Summary)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if cmd.dryRun {
+		fmt.Println("Dry run, no events were deleted.")
+		return nil
+	}
+	fmt.Printf("Deleted %d events.\n", len(pruned))
+	return st.save()
+}
+
+// retentionCutoff returns the time before which events are deleted.
+func retentionCutoff(now time.Time) time.Time {
+	return now.AddDate(-retentionYears, 0, 0)
+}
+
+// pruneOldEvents deletes the events the bot manages that ended before the
+// cutoff from the calendar and the archive calendar, and returns them. With
+// dryRun nothing is deleted.
+func pruneOldEvents(ctx context.Context, gcalClient *calendar.Service, st *syncState, cutoff time.Time, dryRun bool) ([]*calendar.Event, error) {
+	calendars := []string{calendarName}
+	if len(archiveCalendar) > 0 {
+		calendars = append(calendars, archiveCalendar)
+	}
+
+	var pruned []*calendar.Event
+	for _, cal := range calendars {
+		// Only events that start before the cutoff can have ended before it.
+		call := gcalClient.Events.List(cal).
+			PrivateExtendedProperty(propertyManaged + "=true").
+			SingleEvents(true).
+			TimeMax(cutoff.Format(time.RFC3339)).
+			MaxResults(2500).
+			Context(ctx)
+		err := call.Pages(ctx, func(page *calendar.Events) error {
+			for _, e := range page.Items {
+				if e.End == nil || !eventTime(*e.End).Before(cutoff) {
+					continue
+				}
+				if !dryRun {
+					if err := gcalClient.Events.Delete(cal, e.Id).Context(ctx).Do(); err != nil && !isNotFound(err) {
+						logrus.Errorf("deleting google calendar event %s older than the retention period failed: %v", e.Id, err)
+						continue
+					}
+					st.forget(privateProperty(e, propertySegmentID))
+				}
+				pruned = append(pruned, e)
+			}
+			return nil
+		})
+		if err != nil {
+			return pruned, fmt.Errorf("listing events older than the retention period in google calendar %s failed: %v", cal, err)
+		}
+	}
+
+	return pruned, nil
+}
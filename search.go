@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const searchHelp = `Search the travel history in the state file.`
+
+const searchLongHelp = `Search the travel history in the state file.
+
+Every word of the query must match a flight. Words that look like dates match
+the departure date: 2023, 2023-07, or 2023-07-04, and ranges like
+2023-01..2023-06. Any other word matches the airports, airline, flight
+number, confirmation number, or title.
+
+  tripitcalb0t search "EWR 2023"
+  tripitcalb0t search "UA 2022-01..2022-06"
+
+This only reads the state file, so it is fast and does not need any
+credentials.`
+
+func (cmd *searchCommand) Name() string      { return "search" }
+func (cmd *searchCommand) Args() string      { return "<query>" }
+func (cmd *searchCommand) ShortHelp() string { return searchHelp }
+func (cmd *searchCommand) LongHelp() string  { return searchLongHelp }
+func (cmd *searchCommand) Hidden() bool      { return false }
+
+func (cmd *searchCommand) Register(fs *flag.FlagSet) {}
+
+type searchCommand struct{}
+
+func (cmd *searchCommand) Run(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return errors.New("pass a query to search for")
+	}
+
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+
+	results := searchState(st, strings.Fields(strings.Join(args, " ")))
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "DEPARTS\tFROM\tTO\tFLIGHT\tCONFIRMATION\tTRIP")
+	for _, se := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", se.Start.Format("2006-01-02 15:04"), se.Origin, se.Destination, se.FlightNumber, se.ConfirmationNumber, se.TripID)
+	}
+	return w.Flush()
+}
+
+// searchState returns the events in the state that match every word of the
+// query, oldest first.
+func searchState(st *syncState, query []string) []*stateEvent {
+	var results []*stateEvent
+	for _, se := range st.Events {
+		match := true
+		for _, word := range query {
+			if !matchesWord(se, word) {
+				match = false
+				break
+			}
+		}
+		if match {
+			results = append(results, se)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Start.Before(results[j].Start)
+	})
+
+	return results
+}
+
+// matchesWord returns true if the word of a search query matches the event.
+func matchesWord(se *stateEvent, word string) bool {
+	date := se.Start.Format("2006-01-02")
+	if from, to, ok := dateRange(word); ok {
+		return date >= from && date[:len(to)] <= to
+	}
+	if isDate(word) {
+		return strings.HasPrefix(date, word)
+	}
+
+	word = strings.ToLower(word)
+	for _, field := range []string{se.Origin, se.Destination, se.Airline, se.FlightNumber, se.ConfirmationNumber, se.Title} {
+		if strings.Contains(strings.ToLower(field), word) {
+			return true
+		}
+	}
+	return false
+}
+
+// dateRange parses a range of dates like 2023-01..2023-06.
+func dateRange(word string) (string, string, bool) {
+	parts := strings.SplitN(word, "..", 2)
+	if len(parts) != 2 || !isDate(parts[0]) || !isDate(parts[1]) {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// isDate returns true if the word is a year, a month, or a day like 2023,
+// 2023-07, or 2023-07-04.
+func isDate(word string) bool {
+	for _, layout := range []string{"2006", "2006-01", "2006-01-02"} {
+		if _, err := time.Parse(layout, word); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+)
+
+// secretEnv returns the flags that hold secrets, and the environment
+// variables they can also be set with. Unlike the other flags, their
+// defaults are not read from the environment when the flags are defined,
+// since the help output would print them, but only after the flags are
+// parsed.
+func secretEnv() map[*string]string {
+	return map[*string]string{
+		&tripitPassword:      "TRIPIT_PASSWORD",
+		&mqttPassword:        "MQTT_PASSWORD",
+		&slackToken:          "SLACK_TOKEN",
+		&slackSigningSecret:  "SLACK_SIGNING_SECRET",
+		&googleChatWebhook:   "GOOGLE_CHAT_WEBHOOK",
+		&teamsWebhook:        "TEAMS_WEBHOOK",
+		&shareSecret:         "SHARE_SECRET",
+		&oidcClientSecret:    "OIDC_CLIENT_SECRET",
+		&caldavPassword:      "CALDAV_PASSWORD",
+		&outlookClientSecret: "OUTLOOK_CLIENT_SECRET",
+	}
+}
+
+// secretsFromEnv sets the secrets that were not passed as flags from the
+// environment.
+func secretsFromEnv() {
+	for p, env := range secretEnv() {
+		if len(*p) < 1 {
+			*p = os.Getenv(env)
+		}
+	}
+}
+
+// zero overwrites the buffer, so a secret does not linger in memory after it
+// was used.
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// Metrics the bot exposes on /metrics.
+var (
+	registry = metrics.NewRegistry()
+
+	metricSyncStale   = registry.NewGauge("tripitcalb0t_sync_stale", "Whether no sync has succeeded within the stale threshold.")
+	metricLastSuccess = registry.NewGauge("tripitcalb0t_last_success_timestamp_seconds", "Unix time of the last successful sync.")
+
+	metricSyncFailures        = registry.NewCounter("tripitcalb0t_sync_failures_total", "Syncs that failed, or only partly succeeded.", "result")
+	metricConsecutiveFailures = registry.NewGauge("tripitcalb0t_consecutive_failures", "Syncs that failed in a row since the last one that did not.")
+
+	metricThrottled = registry.NewCounter("tripitcalb0t_throttled_total", "Requests the TripIt or Google Calendar API rate limited.", "api")
+
+	metricFlightsCreated = registry.NewCounter("tripitcalb0t_flights_created_total", "Flight events created in the calendar.", "airline", "origin", "destination")
+
+	metricEventsFetched = registry.NewCounter("tripitcalb0t_tripit_events_fetched_total", "Events fetched from TripIt, counted every sync.")
+	metricEventChanges  = registry.NewCounter("tripitcalb0t_events_total", "Calendar events created, updated, and removed.", "action")
+
+	metricAPIErrors       = registry.NewCounter("tripitcalb0t_api_errors_total", "Requests to the TripIt or Google Calendar API that failed.", "api")
+	metricRequestDuration = registry.NewHistogram("tripitcalb0t_request_duration_seconds", "How long requests to the TripIt and Google Calendar APIs took.", metrics.DefaultBuckets, "api")
+)
+
+// observeRequest records a request to the API in the metrics. A status code
+// of zero is a request that failed without one.
+func observeRequest(api string, statusCode int, took time.Duration) {
+	metricRequestDuration.Observe(took.Seconds(), api)
+	if statusCode < 200 || statusCode >= 400 {
+		metricAPIErrors.Inc(api)
+	}
+}
+
+// observeSync records what a sync did in the metrics.
+func observeSync(res *syncResult) {
+	metricEventsFetched.Add(float64(res.Events))
+	metricEventChanges.Add(float64(res.Created), "created")
+	metricEventChanges.Add(float64(res.Updated), "updated")
+	metricEventChanges.Add(float64(res.Removed), "removed")
+}
+
+// instrumentedTransport records the requests made through it in the metrics.
+type instrumentedTransport struct {
+	api  string
+	base http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	statusCode := 0
+	if err == nil {
+		statusCode = resp.StatusCode
+	}
+	observeRequest(t.api, statusCode, time.Since(start))
+	return resp, err
+}
+
+// instrumentClient makes the client record its requests to the API in the
+// metrics.
+func instrumentClient(c *http.Client, api string) *http.Client {
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c.Transport = &instrumentedTransport{api: api, base: base}
+	return c
+}
+
+// wedgedAfter returns how long a run can go on before /healthz reports the
+// bot as wedged: a minute past the run timeout, for the run to save its
+// state and stop, or never without a run timeout.
+func wedgedAfter(runTimeout time.Duration) time.Duration {
+	if runTimeout <= 0 {
+		return 0
+	}
+	return runTimeout + time.Minute
+}
+
+// metricsHandler writes the metrics in the Prometheus text exposition format.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	registry.WritePrometheus(w)
+}
+
+// anyAccount lets every account through to h.
+func anyAccount(h http.HandlerFunc) func(http.ResponseWriter, *http.Request, *account) {
+	return func(w http.ResponseWriter, r *http.Request, a *account) {
+		h(w, r)
+	}
+}
+
+// newMetricsMux returns the handlers of the --metrics-addr server, which only
+// serves the metrics. The metrics tell where you fly, so if there are
+// accounts, only they can read them, with their token.
+func newMetricsMux(accounts []account) *http.ServeMux {
+	mux := http.NewServeMux()
+	if len(accounts) > 0 {
+		mux.HandleFunc("/metrics", withAccount(accounts, nil, anyAccount(metricsHandler)))
+	} else {
+		mux.HandleFunc("/metrics", metricsHandler)
+	}
+	return mux
+}
+
+// newServeMux returns the handlers of the bot's HTTP server. The web UI and
+// API are only served when there are accounts to use them with, who sign in
+// with OpenID Connect if oidc is set.
+func newServeMux(wd *watchdog, accounts []account, oidc *oidcProvider) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if err := wd.healthy(time.Now(), wedgedAfter(runTimeout)); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := wd.ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	// The metrics tell where and when you fly, so they need an account
+	// like the web UI if there are any.
+	if len(accounts) > 0 {
+		mux.HandleFunc("/metrics", withAccount(accounts, oidc, anyAccount(metricsHandler)))
+		mux.HandleFunc("/api/metrics", withAccount(accounts, oidc, anyAccount(apiMetricsHandler(wd))))
+	} else {
+		mux.HandleFunc("/metrics", metricsHandler)
+		mux.HandleFunc("/api/metrics", apiMetricsHandler(wd))
+	}
+
+	if len(slackSigningSecret) > 0 {
+		mux.HandleFunc("/slack/command", slackCommandHandler)
+		mux.HandleFunc("/slack/actions", slackActionsHandler)
+	}
+
+	// Share links only work when they can be verified.
+	if len(shareSecret) > 0 {
+		mux.HandleFunc("/share/", shareHandler(shareSecret))
+	}
+
+	if len(accounts) > 0 {
+		mux.HandleFunc("/", withAccount(accounts, oidc, webHandler))
+		mux.HandleFunc("/api/trips", withAccount(accounts, oidc, apiTripsHandler))
+		mux.HandleFunc("/api/countdown", withAccount(accounts, oidc, apiCountdownHandler))
+		mux.HandleFunc("/api/countdown/", withAccount(accounts, oidc, apiCountdownHandler))
+	}
+	if oidc != nil {
+		mux.HandleFunc(oidc.callbackPath(), oidc.callback)
+	}
+
+	return mux
+}
+
+// serveHTTP serves the handler on addr in the background.
+func serveHTTP(addr string, h http.Handler) {
+	go func() {
+		logrus.Infof("Serving HTTP on %s", addr)
+		if err := http.ListenAndServe(addr, h); err != nil {
+			logrus.Fatalf("serving HTTP on %s failed: %v", addr, err)
+		}
+	}()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetricsNeedAccount(t *testing.T) {
+	accounts := []account{{Name: "jess", Token: "token", All: true}}
+	wd := newWatchdog(time.Hour)
+
+	testcases := []struct {
+		name   string
+		mux    *http.ServeMux
+		path   string
+		bearer string
+		want   int
+	}{
+		{name: "metrics without a token", mux: newServeMux(wd, accounts, nil), path: "/metrics", want: http.StatusUnauthorized},
+		{name: "metrics with a bad token", mux: newServeMux(wd, accounts, nil), path: "/metrics", bearer: "other", want: http.StatusUnauthorized},
+		{name: "metrics with a token", mux: newServeMux(wd, accounts, nil), path: "/metrics", bearer: "token", want: http.StatusOK},
+		{name: "api metrics without a token", mux: newServeMux(wd, accounts, nil), path: "/api/metrics", want: http.StatusUnauthorized},
+		{name: "api metrics with a token", mux: newServeMux(wd, accounts, nil), path: "/api/metrics?token=token", want: http.StatusOK},
+		{name: "metrics address without a token", mux: newMetricsMux(accounts), path: "/metrics", want: http.StatusUnauthorized},
+		{name: "metrics address with a token", mux: newMetricsMux(accounts), path: "/metrics", bearer: "token", want: http.StatusOK},
+		{name: "metrics without accounts", mux: newServeMux(wd, nil, nil), path: "/metrics", want: http.StatusOK},
+		{name: "api metrics without accounts", mux: newServeMux(wd, nil, nil), path: "/api/metrics", want: http.StatusOK},
+		{name: "metrics address without accounts", mux: newMetricsMux(nil), path: "/metrics", want: http.StatusOK},
+	}
+	for _, tc := range testcases {
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if len(tc.bearer) > 0 {
+			r.Header.Set("Authorization", "Bearer "+tc.bearer)
+		}
+		w := httptest.NewRecorder()
+		tc.mux.ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Errorf("%s: %s returned %d, want %d", tc.name, tc.path, w.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+)
+
+// shareSignature returns the signature of a share link to the segment that
+// expires at the given unix time.
+func shareSignature(secret, segmentID string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%d", segmentID, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// shareLink returns a link to the details of the segment that stops working
+// at expires.
+func shareLink(base, secret, segmentID string, expires time.Time) string {
+	v := url.Values{}
+	v.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	v.Set("sig", shareSignature(secret, segmentID, expires.Unix()))
+	return strings.TrimSuffix(base, "/") + "/share/" + url.PathEscape(segmentID) + "?" + v.Encode()
+}
+
+// verifyShare checks the signature and expiry of a share link.
+func verifyShare(secret, segmentID, expires, sig string, now time.Time) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errors.New("the link is not valid")
+	}
+	if !hmac.Equal([]byte(sig), []byte(shareSignature(secret, segmentID, exp))) {
+		return errors.New("the link is not valid")
+	}
+	if now.Unix() >= exp {
+		return errors.New("the link has expired")
+	}
+	return nil
+}
+
+// findShared returns the event to share: the segment with the given ID, the
+// next departure of the flight with the given number, or the next departure
+// if the query is empty.
+func findShared(events []tripit.Event, query string, now time.Time) (*tripit.Event, error) {
+	if len(query) < 1 {
+		_, next := nextDeparture(events, now)
+		if next == nil {
+			return nil, errors.New("there are no upcoming flights")
+		}
+		return next, nil
+	}
+
+	var found *tripit.Event
+	for i := range events {
+		e := &events[i]
+		if !e.IsFlight() {
+			continue
+		}
+		if e.SegmentID == query {
+			return e, nil
+		}
+		if !strings.EqualFold(strings.Replace(e.FlightNumber, " ", "", -1), strings.Replace(query, " ", "", -1)) {
+			continue
+		}
+		start := eventTime(e.Start)
+		if eventTime(e.End).Before(now) {
+			continue
+		}
+		if found == nil || start.Before(eventTime(found.Start)) {
+			found = e
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no upcoming flight or segment matches %q", query)
+	}
+	return found, nil
+}
+
+// flightStatusText describes the flight status for people, or returns an
+// empty string if there is nothing to say.
+func flightStatusText(status tripit.FlightStatusCode) string {
+	switch status {
+	case tripit.FlightStatusOnTime:
+		return "On time"
+	case tripit.FlightStatusInFlightOnTime:
+		return "In the air, on time"
+	case tripit.FlightStatusArrivedOnTime:
+		return "Landed on time"
+	case tripit.FlightStatusCancelled:
+		return "Cancelled"
+	case tripit.FlightStatusDelayed, tripit.FlightStatusPossiblyDelayed:
+		return "Delayed"
+	case tripit.FlightStatusInFlightLate, tripit.FlightStatusInFlightPossiblyLate:
+		return "In the air, late"
+	case tripit.FlightStatusArrivedLate, tripit.FlightStatusArrivedPossiblyLate:
+		return "Landed late"
+	case tripit.FlightStatusDiverted:
+		return "Diverted"
+	}
+	return ""
+}
+
+// sharePage is the page a share link shows: where and when the flight leaves
+// and lands, and nothing else from the itinerary.
+var sharePage = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Flight}}</title>
+<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 30em; padding: 0 1em; }
+dt { color: #666; font-size: 0.9em; margin-top: 1em; }
+dd { font-size: 1.2em; margin: 0; }
+</style>
+</head>
+<body>
+<h1>{{.Flight}}</h1>
+<p>{{.From}} to {{.To}}</p>
+<dl>
+<dt>Departs</dt><dd>{{.Departs}}</dd>
+{{if .Terminal}}<dt>Terminal</dt><dd>{{.Terminal}}</dd>{{end}}
+{{if .Gate}}<dt>Gate</dt><dd>{{.Gate}}</dd>{{end}}
+<dt>Arrives</dt><dd>{{.Arrives}}</dd>
+{{if .Status}}<dt>Status</dt><dd>{{.Status}}</dd>{{end}}
+</dl>
+<p><small>Updated {{.Updated}}. This link stops working {{.Expires}}.</small></p>
+</body>
+</html>
+`))
+
+// shareHandler serves the pages of share links, with the details of the
+// segment from the last itinerary fetched.
+func shareHandler(secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		segmentID := strings.TrimPrefix(r.URL.Path, "/share/")
+		q := r.URL.Query()
+		if err := verifyShare(secret, segmentID, q.Get("expires"), q.Get("sig"), time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		st, err := loadState(stateFile)
+		if err != nil {
+			logrus.Errorf("reading state for share link failed: %v", err)
+			http.Error(w, "the flight is not available right now", http.StatusInternalServerError)
+			return
+		}
+		var e *tripit.Event
+		if st.Snapshot != nil {
+			for i := range st.Snapshot.Events {
+				if st.Snapshot.Events[i].SegmentID == segmentID {
+					e = &st.Snapshot.Events[i]
+				}
+			}
+		}
+		if e == nil {
+			http.Error(w, "the flight is no longer in the itinerary", http.StatusNotFound)
+			return
+		}
+
+		exp, _ := strconv.ParseInt(q.Get("expires"), 10, 64)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		if err := sharePage.Execute(w, map[string]string{
+			"Flight":   strings.TrimSpace(e.Airline + " " + e.FlightNumber),
+			"From":     e.AirportCode,
+			"To":       firstNonEmpty(e.DestinationCity, e.DestinationCode),
+			"Departs":  formatZoned(e.Start),
+			"Arrives":  formatZoned(e.End),
+			"Terminal": e.Terminal,
+			"Gate":     e.Gate,
+			"Status":   flightStatusText(e.Status),
+			"Updated":  st.Snapshot.Fetched.Format(time.RFC1123),
+			"Expires":  time.Unix(exp, 0).Format(time.RFC1123),
+		}); err != nil {
+			logrus.Errorf("writing share page failed: %v", err)
+		}
+	}
+}
+
+const shareHelp = `Print a link to the details of a flight that expires.`
+
+const shareLongHelp = `Print a link to the details of a flight that expires.
+
+The link shows when and where the flight departs and lands, its terminal and
+gate, and its status, and nothing else from the itinerary, so you can text it
+to whoever is meeting you at the airport. It is served by the bot's HTTP
+server, see --http-addr, from the last itinerary fetched, so it stays up to
+date with gate changes until it expires.
+
+Pass a segment ID, see diff, or a flight number to share its next departure.
+Without an argument the next flight is shared.
+
+  tripitcalb0t share --expires 12h UA123`
+
+func (cmd *shareCommand) Name() string      { return "share" }
+func (cmd *shareCommand) Args() string      { return "[segment-id|flight]" }
+func (cmd *shareCommand) ShortHelp() string { return shareHelp }
+func (cmd *shareCommand) LongHelp() string  { return shareLongHelp }
+func (cmd *shareCommand) Hidden() bool      { return false }
+
+func (cmd *shareCommand) Register(fs *flag.FlagSet) {
+	fs.DurationVar(&cmd.expires, "expires", 24*time.Hour, "How long the link works for")
+}
+
+type shareCommand struct {
+	expires time.Duration
+}
+
+func (cmd *shareCommand) Run(ctx context.Context, args []string) error {
+	if len(shareSecret) < 1 || len(shareURL) < 1 {
+		fatal(exitCodeConfig, errors.New("share links need share-secret and share-url"))
+	}
+	if cmd.expires <= 0 {
+		fatal(exitCodeConfig, fmt.Errorf("expires must be positive, got %s", cmd.expires))
+	}
+
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+	if st.Snapshot == nil {
+		return errors.New("the itinerary has not been fetched yet, run fetch first")
+	}
+
+	now := time.Now()
+	e, err := findShared(st.Snapshot.Events, strings.Join(args, " "), now)
+	if err != nil {
+		return err
+	}
+
+	expires := now.Add(cmd.expires)
+	fmt.Println(shareLink(shareURL, shareSecret, e.SegmentID, expires))
+	logrus.Infof("Link to %s departing %s works until %s", e.Title, formatZoned(e.Start), expires.Format("Mon Jan 2 15:04"))
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyShare(t *testing.T) {
+	now := time.Date(2030, time.July, 1, 12, 0, 0, 0, time.UTC)
+	expires := now.Add(time.Hour).Unix()
+	sig := shareSignature("secret", "segment", expires)
+	exp := strconv.FormatInt(expires, 10)
+
+	if err := verifyShare("secret", "segment", exp, sig, now); err != nil {
+		t.Fatalf("verifyShare of a valid link failed: %v", err)
+	}
+
+	testcases := []struct {
+		name      string
+		secret    string
+		segmentID string
+		expires   string
+		sig       string
+	}{
+		{name: "tampered segment", secret: "secret", segmentID: "other", expires: exp, sig: sig},
+		{name: "tampered expiry", secret: "secret", segmentID: "segment", expires: strconv.FormatInt(expires+86400, 10), sig: sig},
+		{name: "bad expiry", secret: "secret", segmentID: "segment", expires: "tomorrow", sig: sig},
+		{name: "other secret", secret: "other", segmentID: "segment", expires: exp, sig: sig},
+		{name: "no signature", secret: "secret", segmentID: "segment", expires: exp},
+	}
+	for _, tc := range testcases {
+		if err := verifyShare(tc.secret, tc.segmentID, tc.expires, tc.sig, now); err == nil {
+			t.Errorf("%s: verifyShare succeeded, want an error", tc.name)
+		}
+	}
+
+	if err := verifyShare("secret", "segment", exp, sig, now.Add(time.Hour)); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("verifyShare of an expired link = %v, want it expired", err)
+	}
+}
+
+func TestShareLink(t *testing.T) {
+	now := time.Date(2030, time.July, 1, 12, 0, 0, 0, time.UTC)
+	link := shareLink("https://example.com/", "secret", "seg/ment", now.Add(time.Hour))
+
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.EscapedPath() != "/share/seg%2Fment" {
+		t.Errorf("share link path = %s, want the segment escaped", u.EscapedPath())
+	}
+	q := u.Query()
+	if err := verifyShare("secret", "seg/ment", q.Get("expires"), q.Get("sig"), now); err != nil {
+		t.Errorf("verifyShare of the link from shareLink failed: %v", err)
+	}
+}
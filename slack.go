@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	slackAPI = "https://slack.com/api"
+
+	slackEmojiFlying = ":airplane:"
+	slackEmojiTrip   = ":hotel:"
+)
+
+// slackStatus is a Slack user's custom status.
+type slackStatus struct {
+	Text       string `json:"status_text"`
+	Emoji      string `json:"status_emoji"`
+	Expiration int64  `json:"status_expiration"`
+}
+
+// ours returns true if the status is one the bot sets, so we know we are
+// allowed to change or clear it.
+func (s slackStatus) ours() bool {
+	return (s.Emoji == slackEmojiFlying && strings.HasPrefix(s.Text, "Flying to ")) ||
+		(s.Emoji == slackEmojiTrip && strings.HasPrefix(s.Text, "In "))
+}
+
+// wantedSlackStatus returns the Slack status we want at now: flying during a
+// flight segment, at the trip's location during a trip, and no status
+// otherwise.
+func wantedSlackStatus(events []tripit.Event, trips []tripit.Trip, now time.Time) slackStatus {
+	for _, e := range events {
+		if !e.IsFlight() {
+			continue
+		}
+		start, end := eventTime(e.Start), eventTime(e.End)
+		if start.IsZero() || end.IsZero() || now.Before(start) || !now.Before(end) {
+			continue
+		}
+
+		return slackStatus{
+			Text:       fmt.Sprintf("Flying to %s until %s", e.DestinationCode, formatClock(end)),
+			Emoji:      slackEmojiFlying,
+			Expiration: end.Unix(),
+		}
+	}
+
+	today := now.Format("2006-01-02")
+	for _, trip := range trips {
+		// Trip dates are plain dates, so compare them as strings.
+		if len(trip.PrimaryLocation) < 1 || today < trip.StartDate || today > trip.EndDate {
+			continue
+		}
+
+		return slackStatus{
+			Text:  "In " + trip.PrimaryLocation,
+			Emoji: slackEmojiTrip,
+		}
+	}
+
+	return slackStatus{}
+}
+
+// formatClock formats the time as 5pm or 5:30pm.
+func formatClock(t time.Time) string {
+	if t.Minute() == 0 {
+		return t.Format("3pm")
+	}
+	return t.Format("3:04pm")
+}
+
+// updateSlackStatus sets the Slack status we want for the user. It never
+// overwrites a status the user set themselves and only clears ours.
+func updateSlackStatus(ctx context.Context, token string, want slackStatus) error {
+	var get struct {
+		Profile slackStatus `json:"profile"`
+	}
+	if err := slackCall(ctx, token, "users.profile.get", nil, &get); err != nil {
+		return err
+	}
+
+	current := get.Profile
+	if len(current.Text) > 0 && !current.ours() {
+		logrus.Debugf("not changing slack status %q set by the user", current.Text)
+		return nil
+	}
+	if current.Text == want.Text && current.Emoji == want.Emoji {
+		return nil
+	}
+
+	return slackCall(ctx, token, "users.profile.set", map[string]interface{}{"profile": want}, nil)
+}
+
+// slackCall calls the Slack Web API method and decodes the response into v.
+func slackCall(ctx context.Context, token, method string, body interface{}, v interface{}) error {
+	httpMethod := http.MethodGet
+	var buf bytes.Buffer
+	if body != nil {
+		httpMethod = http.MethodPost
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(httpMethod, slackAPI+"/"+method, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("calling slack %s failed: %v", method, err)
+	}
+	defer resp.Body.Close()
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("decoding slack %s response failed: %v", method, err)
+	}
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return fmt.Errorf("decoding slack %s response failed: %v", method, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("slack %s failed: %s", method, result.Error)
+	}
+
+	if v != nil {
+		return json.Unmarshal(raw, v)
+	}
+	return nil
+}
+
+// syncSlackStatus sets the Slack status for the flight events and the trips
+// they belong to.
+func syncSlackStatus(ctx context.Context, tripitClient *tripit.Client, events []tripit.Event) error {
+	trips, err := listTrips(ctx, tripitClient, "false")
+	if err != nil {
+		return err
+	}
+
+	return updateSlackStatus(ctx, slackToken, wantedSlackStatus(events, trips, time.Now()))
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// slackMaxSkew is how old a signed request from Slack can be, so a
+	// captured one cannot be replayed later.
+	slackMaxSkew = 5 * time.Minute
+
+	// slackResponseURL is where Slack's response URLs for interactive
+	// messages point.
+	slackResponseURL = "https://hooks.slack.com/"
+)
+
+const botUsage = `Ask me about your travel with:
+next, when your next flight departs
+trip <trip-id>, the itinerary of a trip
+sync, to sync TripIt to the calendar now
+approvals, the changes waiting for approval`
+
+// botReply is the answer to a chat command, and the segment IDs of the
+// changes waiting for approval to offer buttons for.
+type botReply struct {
+	Text      string
+	Proposals []string
+}
+
+// botAnswer answers a chat command from the itinerary of the last sync.
+func botAnswer(text string, now time.Time) botReply {
+	fields := strings.Fields(strings.ToLower(text))
+	if len(fields) < 1 {
+		return botReply{Text: botUsage}
+	}
+
+	if fields[0] == "sync" {
+		if requestSync() {
+			return botReply{Text: "Syncing TripIt to the calendar now."}
+		}
+		return botReply{Text: "A sync is already about to start."}
+	}
+
+	st, err := loadState(stateFile)
+	if err != nil {
+		logrus.Errorf("reading state for a chat command failed: %v", err)
+		return botReply{Text: "The itinerary is not available right now."}
+	}
+	var events []tripit.Event
+	if st.Snapshot != nil {
+		events = st.Snapshot.Events
+	}
+
+	switch fields[0] {
+	case "next":
+		return botReply{Text: nextFlightText(events, now)}
+	case "trip":
+		if len(fields) < 2 {
+			return botReply{Text: "Which trip? Ask for trip <trip-id>."}
+		}
+		return botReply{Text: tripText(events, fields[1])}
+	case "approvals":
+		if !approve {
+			return botReply{Text: "Changes do not need approval, the bot is not running with --approve."}
+		}
+		pending := st.Approvals.pendingProposals()
+		if len(pending) < 1 {
+			return botReply{Text: "There are no changes waiting for approval."}
+		}
+		return botReply{Text: fmt.Sprintf("%d changes are waiting for approval.", len(pending)), Proposals: pending}
+	}
+	return botReply{Text: botUsage}
+}
+
+// nextFlightText describes the flight we are on, or the next one to depart.
+func nextFlightText(events []tripit.Event, now time.Time) string {
+	current, next := nextDeparture(events, now)
+	switch {
+	case current != nil:
+		end := eventTime(current.End)
+		return fmt.Sprintf("You are on %s, landing in %s at %s, in %s.", current.Title, current.DestinationCode, end.Format("Mon Jan 2 15:04 MST"), shortCountdown(end.Sub(now)))
+	case next != nil:
+		start := eventTime(next.Start)
+		return fmt.Sprintf("Your next flight is %s, departing %s at %s, in %s.", next.Title, next.AirportCode, start.Format("Mon Jan 2 15:04 MST"), shortCountdown(start.Sub(now)))
+	}
+	return "You have no upcoming flights."
+}
+
+// tripText lists the events of the trip, soonest first.
+func tripText(events []tripit.Event, id string) string {
+	var trip []tripit.Event
+	for _, e := range events {
+		if e.ID == id {
+			trip = append(trip, e)
+		}
+	}
+	if len(trip) < 1 {
+		return fmt.Sprintf("There is no trip %s in the itinerary.", id)
+	}
+	sort.SliceStable(trip, func(i, j int) bool {
+		return eventTime(trip[i].Start).Before(eventTime(trip[j].Start))
+	})
+
+	lines := []string{firstNonEmpty(trip[0].TripName, "Trip "+id)}
+	for _, e := range trip {
+		start := eventTime(e.Start)
+		when := start.Format("Mon Jan 2 15:04")
+		if e.AllDay {
+			when = start.Format("Mon Jan 2")
+		}
+		line := when + "  " + e.Title
+		if len(e.ConfirmationNumber) > 0 {
+			line += ", confirmation " + e.ConfirmationNumber
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// verifySlackRequest checks that the request was signed by Slack with the
+// signing secret in the last few minutes and returns its body.
+func verifySlackRequest(r *http.Request, secret string, now time.Time) ([]byte, error) {
+	ts := r.Header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, errors.New("the request has no timestamp")
+	}
+	if skew := now.Sub(time.Unix(sec, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return nil, errors.New("the request is too old")
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":"))
+	mac.Write(body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(r.Header.Get("X-Slack-Signature"))) {
+		return nil, errors.New("the request signature is not valid")
+	}
+	return body, nil
+}
+
+// slackUserAllowed returns true if the Slack user is in --slack-users. No
+// one is allowed if it is empty.
+func slackUserAllowed(userID string) bool {
+	if len(userID) < 1 {
+		return false
+	}
+	for _, u := range strings.Split(slackUsers, ",") {
+		if strings.TrimSpace(u) == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// slackMessage is a reply to Slack, with blocks for the approval buttons.
+type slackMessage struct {
+	ResponseType    string        `json:"response_type,omitempty"`
+	ReplaceOriginal bool          `json:"replace_original,omitempty"`
+	Text            string        `json:"text"`
+	Blocks          []interface{} `json:"blocks,omitempty"`
+}
+
+// newSlackMessage renders the reply, with approve and reject buttons for
+// every change waiting for approval.
+func newSlackMessage(reply botReply, a *approvals) slackMessage {
+	msg := slackMessage{ResponseType: "ephemeral", Text: reply.Text}
+	if len(reply.Proposals) < 1 {
+		return msg
+	}
+
+	msg.Blocks = append(msg.Blocks, slackSection(reply.Text))
+	for _, id := range reply.Proposals {
+		p, ok := a.proposal(id)
+		if !ok {
+			continue
+		}
+		msg.Blocks = append(msg.Blocks,
+			slackSection(fmt.Sprintf("*%s* %s\nstarts %s", p.Action, p.Title, p.Start.Local().Format("Mon Jan 2 15:04"))),
+			map[string]interface{}{
+				"type": "actions",
+				"elements": []interface{}{
+					slackButton("Approve", "approve", "primary", id),
+					slackButton("Reject", "reject", "danger", id),
+				},
+			})
+	}
+	return msg
+}
+
+func slackSection(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "section",
+		"text": map[string]string{"type": "mrkdwn", "text": text},
+	}
+}
+
+func slackButton(text, actionID, style, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":      "button",
+		"text":      map[string]string{"type": "plain_text", "text": text},
+		"action_id": actionID,
+		"style":     style,
+		"value":     value,
+	}
+}
+
+// decideProposal queues approving or rejecting the change to the segment
+// for the next sync, which it asks for now, and returns what it did.
+func decideProposal(segmentID string, approved bool) string {
+	st, err := loadState(stateFile)
+	if err != nil {
+		logrus.Errorf("reading state for an approval failed: %v", err)
+		return "The changes waiting for approval are not available right now."
+	}
+	p, ok := st.Approvals.proposal(segmentID)
+	if !ok {
+		return fmt.Sprintf("There is no change to segment %s waiting for approval.", segmentID)
+	}
+
+	queueDecision(segmentID, approved)
+	requestSync()
+	if approved {
+		return fmt.Sprintf("Approved the change to %s %s, it is written to the calendar now.", p.Action, p.Title)
+	}
+	return fmt.Sprintf("Rejected the change to %s %s.", p.Action, p.Title)
+}
+
+// slackCommandHandler answers the /tripit slash command.
+func slackCommandHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := verifySlackRequest(r, slackSigningSecret, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "the request is not a form", http.StatusBadRequest)
+		return
+	}
+
+	var msg slackMessage
+	if !slackUserAllowed(form.Get("user_id")) {
+		msg = slackMessage{ResponseType: "ephemeral", Text: "You are not allowed to use this bot."}
+	} else {
+		reply := botAnswer(form.Get("text"), time.Now())
+		var a *approvals
+		if len(reply.Proposals) > 0 {
+			if st, err := loadState(stateFile); err == nil {
+				a = st.Approvals
+			}
+		}
+		msg = newSlackMessage(reply, a)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		logrus.Warnf("writing slack command response failed: %v", err)
+	}
+}
+
+// slackActionsHandler handles the approve and reject buttons.
+func slackActionsHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := verifySlackRequest(r, slackSigningSecret, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "the request is not a form", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Type string `json:"type"`
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+		ResponseURL string `json:"response_url"`
+		Actions     []struct {
+			ActionID string `json:"action_id"`
+			Value    string `json:"value"`
+		} `json:"actions"`
+	}
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil {
+		http.Error(w, "the payload is not valid", http.StatusBadRequest)
+		return
+	}
+	// Acknowledge the action either way, Slack shows an error otherwise.
+	w.WriteHeader(http.StatusOK)
+	if payload.Type != "block_actions" || len(payload.Actions) < 1 {
+		return
+	}
+
+	text := "You are not allowed to use this bot."
+	if slackUserAllowed(payload.User.ID) {
+		action := payload.Actions[0]
+		switch action.ActionID {
+		case "approve", "reject":
+			text = decideProposal(action.Value, action.ActionID == "approve")
+		default:
+			return
+		}
+	}
+
+	if !strings.HasPrefix(payload.ResponseURL, slackResponseURL) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := postSlackResponse(ctx, payload.ResponseURL, slackMessage{ReplaceOriginal: true, Text: text}); err != nil {
+		logrus.Warnf("responding to slack action failed: %v", err)
+	}
+}
+
+// postSlackResponse posts the message to the response URL of an
+// interactive message.
+func postSlackResponse(ctx context.Context, responseURL string, msg slackMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, responseURL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack returned status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slackRequest returns a slash command request with the body, signed with
+// the secret at the time.
+func slackRequest(secret, body string, signed time.Time) *http.Request {
+	ts := strconv.FormatInt(signed.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+
+	r := httptest.NewRequest(http.MethodPost, "/slack/command", strings.NewReader(body))
+	r.Header.Set("X-Slack-Request-Timestamp", ts)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestVerifySlackRequest(t *testing.T) {
+	now := time.Date(2030, time.July, 1, 12, 0, 0, 0, time.UTC)
+	body := "command=%2Ftripit&text=next&user_id=U123"
+
+	got, err := verifySlackRequest(slackRequest("secret", body, now), "secret", now)
+	if err != nil {
+		t.Fatalf("verifySlackRequest of a valid request failed: %v", err)
+	}
+	if string(got) != body {
+		t.Errorf("verifySlackRequest = %q, want the body %q", got, body)
+	}
+
+	tampered := slackRequest("secret", body, now)
+	tampered.Body = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body+"&user_id=U999")).Body
+	noSignature := slackRequest("secret", body, now)
+	noSignature.Header.Del("X-Slack-Signature")
+	noTimestamp := slackRequest("secret", body, now)
+	noTimestamp.Header.Del("X-Slack-Request-Timestamp")
+	replayed := slackRequest("secret", body, now)
+	replayed.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(now.Add(time.Minute).Unix(), 10))
+
+	for name, r := range map[string]*http.Request{
+		"other secret":  slackRequest("other", body, now),
+		"tampered":      tampered,
+		"no signature":  noSignature,
+		"no timestamp":  noTimestamp,
+		"new timestamp": replayed,
+		"old":           slackRequest("secret", body, now.Add(-slackMaxSkew-time.Second)),
+		"future":        slackRequest("secret", body, now.Add(slackMaxSkew+time.Second)),
+	} {
+		if _, err := verifySlackRequest(r, "secret", now); err == nil {
+			t.Errorf("%s: verifySlackRequest succeeded, want an error", name)
+		}
+	}
+}
+
+func TestSlackUserAllowed(t *testing.T) {
+	users := slackUsers
+	t.Cleanup(func() { slackUsers = users })
+
+	slackUsers = ""
+	if slackUserAllowed("U123") {
+		t.Error("slackUserAllowed without --slack-users allowed a user, want no one allowed")
+	}
+
+	slackUsers = "U123, U456"
+	for user, want := range map[string]bool{"U123": true, "U456": true, "U789": false, "": false} {
+		if got := slackUserAllowed(user); got != want {
+			t.Errorf("slackUserAllowed(%q) = %t, want %t", user, got, want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+// snapshot is the itinerary as we last fetched it from TripIt. The write
+// phase works from it, so it can be diffed or replayed without asking TripIt
+// again.
+type snapshot struct {
+	Fetched time.Time      `json:"fetched"`
+	Past    bool           `json:"past,omitempty"`
+	Events  []tripit.Event `json:"events"`
+
+	// Started is when the fetch started, which is what a sync of it that
+	// succeeds moves the high-water mark to. Full is when the last fetch
+	// of the whole itinerary started, the snapshot is updated with only
+	// the trips that changed in between.
+	Started time.Time `json:"started,omitempty"`
+	Full    time.Time `json:"full,omitempty"`
+
+	// Truncated is set when TripIt had more pages of trips than
+	// --tripit-max-pages, so trips that are not in the snapshot may still
+	// be in TripIt.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// errNoSnapshot is returned by commands that need a snapshot when there is none.
+var errNoSnapshot = errors.New("there is no snapshot of the itinerary in the state file, run fetch or a sync first")
+
+const fetchHelp = `Fetch the itinerary from TripIt without writing to the calendar.`
+
+const fetchLongHelp = `Fetch the itinerary from TripIt without writing to the calendar.
+
+Saves a snapshot of the itinerary to the state file. Use diff to see what
+writing it would change, and replay to write it.`
+
+func (cmd *fetchCommand) Name() string      { return "fetch" }
+func (cmd *fetchCommand) Args() string      { return "" }
+func (cmd *fetchCommand) ShortHelp() string { return fetchHelp }
+func (cmd *fetchCommand) LongHelp() string  { return fetchLongHelp }
+func (cmd *fetchCommand) Hidden() bool      { return false }
+
+func (cmd *fetchCommand) Register(fs *flag.FlagSet) {}
+
+type fetchCommand struct{}
+
+func (cmd *fetchCommand) Run(ctx context.Context, args []string) error {
+	tripitClient, err := newTripItClient()
+	if err != nil {
+		fatal(exitCodeConfig, err)
+	}
+
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+
+	trips, err := fetchPhase(ctx, tripitClient, st, fmt.Sprintf("%v", past))
+	if err != nil {
+		return err
+	}
+	if err := st.save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Fetched %d events from TripIt\n", len(trips))
+	return nil
+}
+
+const diffHelp = `Show what writing the last snapshot would change in the calendar.`
+
+const diffLongHelp = `Show what writing the last snapshot would change in the calendar.
+
+Compares the itinerary last fetched from TripIt with the calendar without
+writing anything. Run fetch first to diff against the current itinerary.
+
+  create    the segment has no event in the calendar yet
+  update    the event in the calendar is out of date
+  missing   the segment is gone from TripIt and will be removed after
+            --removal-grace-runs runs`
+
+func (cmd *diffCommand) Name() string      { return "diff" }
+func (cmd *diffCommand) Args() string      { return "" }
+func (cmd *diffCommand) ShortHelp() string { return diffHelp }
+func (cmd *diffCommand) LongHelp() string  { return diffLongHelp }
+func (cmd *diffCommand) Hidden() bool      { return false }
+
+func (cmd *diffCommand) Register(fs *flag.FlagSet) {}
+
+type diffCommand struct{}
+
+func (cmd *diffCommand) Run(ctx context.Context, args []string) error {
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+	if st.Snapshot == nil {
+		return errNoSnapshot
+	}
+
+	backend := getPrimaryBackend(ctx)
+	existing, err := backend.List(ctx)
+	if err != nil {
+		return err
+	}
+	if g, ok := backend.(*googleBackend); ok {
+		existing, err = addLegacyEvents(ctx, g.service, g.calendarID, existing, st.Snapshot.Events)
+		if err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintf(w, "Snapshot fetched %s\n\n", st.Snapshot.Fetched.Local().Format(time.RFC1123))
+	fmt.Fprintln(w, "ACTION\tDEPARTS\tSEGMENT\tTITLE")
+
+	present := map[string]bool{}
+	for _, trip := range st.Snapshot.Events {
+		present[trip.SegmentID] = true
+		if !trip.Confirmed() || st.archived(trip.SegmentID) {
+			continue
+		}
+
+		action, _, _, err := planEvent(existing, trip)
+		if err != nil {
+			return err
+		}
+		if action == syncUnchanged {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", action, eventTime(trip.Start).Format("2006-01-02 15:04"), trip.SegmentID, trip.Title)
+	}
+
+	now := time.Now()
+	for _, se := range st.Events {
+		if present[se.SegmentID] || se.Archived || se.End.IsZero() || se.End.Before(now) {
+			continue
+		}
+		fmt.Fprintf(w, "missing\t%s\t%s\t%s\n", se.Start.Format("2006-01-02 15:04"), se.SegmentID, se.Title)
+	}
+
+	return w.Flush()
+}
+
+const replayHelp = `Write the last snapshot to the calendar without fetching from TripIt.`
+
+const replayLongHelp = `Write the last snapshot to the calendar without fetching from TripIt.
+
+Runs the write phase of a sync against the itinerary last fetched from
+TripIt. Useful to retry writes that failed, or after undo, without needing
+TripIt credentials. A replay does not count towards removing segments that
+went missing from TripIt.`
+
+func (cmd *replayCommand) Name() string      { return "replay" }
+func (cmd *replayCommand) Args() string      { return "" }
+func (cmd *replayCommand) ShortHelp() string { return replayHelp }
+func (cmd *replayCommand) LongHelp() string  { return replayLongHelp }
+func (cmd *replayCommand) Hidden() bool      { return false }
+
+func (cmd *replayCommand) Register(fs *flag.FlagSet) {}
+
+type replayCommand struct{}
+
+func (cmd *replayCommand) Run(ctx context.Context, args []string) error {
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+	if st.Snapshot == nil {
+		return errNoSnapshot
+	}
+
+	res, err := replay(ctx, getPrimaryBackend(ctx), st)
+	if serr := st.save(); serr != nil && err == nil {
+		err = serr
+	}
+	if werr := res.write(os.Stdout, output); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// replay runs the write phase for the snapshot in the state.
+func replay(ctx context.Context, backend calendarBackend, st *syncState) (*syncResult, error) {
+	res := newSyncResult()
+
+	pending, paused := resumeAfterQuota(st)
+	if paused {
+		res.Pending = len(st.Quota.Pending)
+		return res, res.finish(fmt.Errorf("google calendar quota exhausted, writes are paused until %s", st.Quota.Until.Format(time.RFC1123)))
+	}
+
+	trips := st.Snapshot.Events
+	var proposed []string
+	if approve {
+		trips, proposed = gateChanges(st, trips, time.Now())
+		res.Awaiting = len(st.Approvals.pendingProposals())
+	}
+
+	existing, err := backend.List(ctx)
+	if err != nil {
+		return res, res.finish(err)
+	}
+	if g, ok := backend.(*googleBackend); ok {
+		existing, err = addLegacyEvents(ctx, g.service, g.calendarID, existing, trips)
+		if err != nil {
+			return res, res.finish(err)
+		}
+	}
+
+	announce(ctx, st, trips, append(proposed, writePhase(ctx, backend, st, existing, trips, pending, false, res)...), res)
+	return res, res.finish(nil)
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// syncState is what the bot remembers between runs about the events it has
+// synced, keyed by TripIt segment ID. The calendar remains the source of
+// truth for matching events, the state lets us notice when the two disagree.
+type syncState struct {
+	mu   sync.Mutex
+	path string
+
+	Events map[string]*stateEvent `json:"events"`
+
+	// Warned holds when we sent each one-off warning, so we only send it once.
+	Warned map[string]time.Time `json:"warned,omitempty"`
+
+	// Checklist holds when each checklist item was checked off, keyed by
+	// trip ID and item.
+	Checklist map[string]time.Time `json:"checklist,omitempty"`
+
+	// Approvals holds the changes waiting for approval with --approve, and
+	// the version of every segment approved for the calendars.
+	Approvals *approvals `json:"approvals,omitempty"`
+
+	// Restore is set while a restore is in progress, so an interrupted
+	// restore can resume from the last trip it finished.
+	Restore *restoreCheckpoint `json:"restore,omitempty"`
+
+	// Quota is set while writes are paused because we ran out of Google
+	// Calendar quota.
+	Quota *quotaPause `json:"quota,omitempty"`
+
+	// Throttled is when TripIt asked us to wait until before asking it
+	// again, after it rate limited us.
+	Throttled time.Time `json:"throttled,omitempty"`
+
+	// Vacation is the ID of the trip we turned the Gmail vacation responder
+	// on for, so we know to turn it off again once we are back.
+	Vacation string `json:"vacation,omitempty"`
+
+	// Snapshot is the itinerary from the last fetch from TripIt.
+	Snapshot *snapshot `json:"snapshot,omitempty"`
+
+	// HighWater is when the fetch of the last sync that succeeded started.
+	// With --incremental, we only ask TripIt for the trips changed since.
+	HighWater time.Time `json:"highWater,omitempty"`
+
+	// LastWrite holds the calendar writes of the last write phase that
+	// wrote anything, so they can be undone.
+	LastWrite *writeJournal `json:"lastWrite,omitempty"`
+
+	// writes collects the writes of the write phase in progress.
+	writes *writeJournal
+
+	// decided is the decisions file applied to the approvals, removed
+	// once the state is saved.
+	decided string
+}
+
+// restoreCheckpoint describes a restore in progress.
+type restoreCheckpoint struct {
+	Since   string    `json:"since"`
+	Started time.Time `json:"started"`
+}
+
+// stateEvent is a single synced event.
+type stateEvent struct {
+	TripID    string    `json:"tripID"`
+	SegmentID string    `json:"segmentID"`
+	EventID   string    `json:"eventID"`
+	Title     string    `json:"title,omitempty"`
+	Hash      string    `json:"hash"`
+	Synced    time.Time `json:"synced"`
+	Start     time.Time `json:"start,omitempty"`
+	End       time.Time `json:"end,omitempty"`
+
+	// Details of the flight, kept so we can search our travel history
+	// without asking TripIt.
+	Origin             string `json:"origin,omitempty"`
+	Destination        string `json:"destination,omitempty"`
+	Airline            string `json:"airline,omitempty"`
+	FlightNumber       string `json:"flightNumber,omitempty"`
+	ConfirmationNumber string `json:"confirmationNumber,omitempty"`
+
+	// Archived is true once the trip has ended and we stopped syncing it.
+	Archived bool `json:"archived,omitempty"`
+
+	// Missing counts the consecutive runs the segment has been absent from
+	// TripIt. The event is pending removal while it is greater than zero.
+	Missing int `json:"missing,omitempty"`
+}
+
+// newState returns an empty state that is saved to the file at path.
+func newState(path string) *syncState {
+	return &syncState{
+		path:   path,
+		Events: map[string]*stateEvent{},
+		Warned: map[string]time.Time{},
+	}
+}
+
+// loadState reads the state from the file at path. A missing file is an empty
+// state. An empty path returns a state that is never saved.
+func loadState(path string) (*syncState, error) {
+	s := newState(path)
+	if len(path) < 1 {
+		return s, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading state file %s failed: %v", path, err)
+	}
+
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("parsing state file %s failed: %v", path, err)
+	}
+	if s.Events == nil {
+		s.Events = map[string]*stateEvent{}
+	}
+	if s.Warned == nil {
+		s.Warned = map[string]time.Time{}
+	}
+
+	return s, nil
+}
+
+// save writes the state back to its file.
+func (s *syncState) save() error {
+	if len(s.path) < 1 {
+		return nil
+	}
+
+	s.mu.Lock()
+	b, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("creating state directory failed: %v", err)
+	}
+
+	// Write to a temporary file and rename it so we never leave a
+	// half-written state file behind.
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return fmt.Errorf("writing state file %s failed: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("renaming state file %s failed: %v", tmp, err)
+	}
+
+	s.mu.Lock()
+	decided := s.decided
+	s.decided = ""
+	s.mu.Unlock()
+	if len(decided) > 0 {
+		if err := os.Remove(decided); err != nil && !os.IsNotExist(err) {
+			logrus.Warnf("removing applied decisions file %s failed: %v", decided, err)
+		}
+	}
+	return nil
+}
+
+// record remembers that the TripIt event is synced to the calendar event.
+func (s *syncState) record(trip tripit.Event, eventID, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Events[trip.SegmentID] = &stateEvent{
+		TripID:    trip.ID,
+		SegmentID: trip.SegmentID,
+		EventID:   eventID,
+		Title:     trip.Title,
+		Hash:      hash,
+		Synced:    time.Now().UTC(),
+		Start:     eventTime(trip.Start),
+		End:       eventTime(trip.End),
+
+		Origin:             trip.AirportCode,
+		Destination:        trip.DestinationCode,
+		Airline:            trip.Airline,
+		FlightNumber:       trip.FlightNumber,
+		ConfirmationNumber: trip.ConfirmationNumber,
+	}
+}
+
+// markMissing counts another run for every upcoming event whose segment is
+// not in the given TripIt events and returns the events that have been
+// missing for at least graceRuns consecutive runs. TripIt sometimes leaves
+// trips out of a response, so we wait a few runs before trusting that a trip
+// is really gone. Events that have ended are left alone since TripIt stops
+// listing trips once they are over.
+func (s *syncState) markMissing(trips []tripit.Event, graceRuns int) []*stateEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	present := map[string]bool{}
+	for _, trip := range trips {
+		present[trip.SegmentID] = true
+	}
+
+	var due []*stateEvent
+	now := time.Now()
+	for _, se := range s.Events {
+		if present[se.SegmentID] {
+			se.Missing = 0
+			continue
+		}
+		if se.End.IsZero() || se.End.Before(now) {
+			continue
+		}
+
+		se.Missing++
+		if se.Missing >= graceRuns {
+			due = append(due, se)
+		}
+	}
+
+	return due
+}
+
+// adoptCalendarEvents adds the events the bot manages in the calendar that
+// the state does not know, with the runs they have been missing from TripIt
+// for as their event says, and returns those runs by segment ID. Without a
+// state file this is how the runs are counted from one sync to the next.
+func (s *syncState) adoptCalendarEvents(events []*calendar.Event) map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	adopted := map[string]int{}
+	for _, e := range events {
+		id := privateProperty(e, propertySegmentID)
+		if len(id) < 1 || e.Start == nil || e.End == nil {
+			continue
+		}
+		if _, ok := s.Events[id]; ok {
+			continue
+		}
+		missing, _ := strconv.Atoi(privateProperty(e, propertyMissing))
+		s.Events[id] = &stateEvent{
+			TripID:    privateProperty(e, propertyTripID),
+			SegmentID: id,
+			EventID:   e.Id,
+			Title:     e.Summary,
+			Hash:      privateProperty(e, propertyHash),
+			Start:     eventTime(*e.Start),
+			End:       eventTime(*e.End),
+			Missing:   missing,
+		}
+		adopted[id] = missing
+	}
+	return adopted
+}
+
+// eventTime returns the time of a calendar event start or end, or the zero
+// time if it cannot be parsed.
+func eventTime(t calendar.EventDateTime) time.Time {
+	if len(t.DateTime) > 0 {
+		if v, err := time.Parse(time.RFC3339, t.DateTime); err == nil {
+			return v
+		}
+	}
+	if len(t.Date) > 0 {
+		if v, err := time.Parse("2006-01-02", t.Date); err == nil {
+			return v
+		}
+	}
+	return time.Time{}
+}
+
+// forget removes the segment from the state.
+func (s *syncState) forget(segmentID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.Events, segmentID)
+}
+
+// warnOnce returns true the first time it is called for the key and false
+// after that, so a warning is only sent once.
+func (s *syncState) warnOnce(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.Warned[key]; ok {
+		return false
+	}
+	s.Warned[key] = time.Now().UTC()
+	return true
+}
+
+// warnEvery returns true if no warning was sent for the key in the last
+// interval, so a reminder is sent at most once an interval.
+func (s *syncState) warnEvery(key string, interval time.Duration, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.Warned[key]; ok && now.Sub(last) < interval {
+		return false
+	}
+	s.Warned[key] = now.UTC()
+	return true
+}
+
+// checked returns true if the checklist item of the trip was checked off.
+func (s *syncState) checked(tripID, item string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.Checklist[tripID+"/"+item]
+	return ok
+}
+
+// check checks the checklist item of the trip off, or unchecks it.
+func (s *syncState) check(tripID, item string, done bool, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !done {
+		delete(s.Checklist, tripID+"/"+item)
+		return
+	}
+	if s.Checklist == nil {
+		s.Checklist = map[string]time.Time{}
+	}
+	s.Checklist[tripID+"/"+item] = now.UTC()
+}
+
+// archived returns true if the segment belongs to an archived trip.
+func (s *syncState) archived(segmentID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	se, ok := s.Events[segmentID]
+	return ok && se.Archived
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+func TestMarkMissing(t *testing.T) {
+	future := time.Now().AddDate(0, 1, 0)
+	st := newState("")
+	st.Events = map[string]*stateEvent{
+		"present":  {SegmentID: "present", End: future, Missing: 2},
+		"missing":  {SegmentID: "missing", End: future},
+		"almost":   {SegmentID: "almost", End: future, Missing: 1},
+		"over":     {SegmentID: "over", End: time.Now().AddDate(0, -1, 0)},
+		"no-times": {SegmentID: "no-times"},
+	}
+	trips := []tripit.Event{{SegmentID: "present"}}
+
+	due := st.markMissing(trips, 2)
+	if len(due) != 1 || due[0].SegmentID != "almost" {
+		t.Errorf("markMissing = %v, want only the segment missing for the grace runs", due)
+	}
+
+	want := map[string]int{"present": 0, "missing": 1, "almost": 2, "over": 0, "no-times": 0}
+	for id, missing := range want {
+		if got := st.Events[id].Missing; got != missing {
+			t.Errorf("%s is missing for %d runs, want %d", id, got, missing)
+		}
+	}
+
+	// The next run it is gone too.
+	due = st.markMissing(trips, 2)
+	var ids []string
+	for _, se := range due {
+		ids = append(ids, se.SegmentID)
+	}
+	sort.Strings(ids)
+	if len(ids) != 2 || ids[0] != "almost" || ids[1] != "missing" {
+		t.Errorf("markMissing on the next run = %v, want almost and missing", ids)
+	}
+}
+
+func TestAdoptCalendarEvents(t *testing.T) {
+	managed := func(segmentID, missing string) *calendar.Event {
+		e := &calendar.Event{
+			Id:      "event-" + segmentID,
+			Summary: "Flight to Newark (UA 123)",
+			Start:   &calendar.EventDateTime{DateTime: "2030-07-10T09:00:00Z"},
+			End:     &calendar.EventDateTime{DateTime: "2030-07-10T14:00:00Z"},
+			ExtendedProperties: &calendar.EventExtendedProperties{Private: map[string]string{
+				propertyTripID:    "trip",
+				propertySegmentID: segmentID,
+				propertyHash:      "hash-" + segmentID,
+			}},
+		}
+		if len(missing) > 0 {
+			e.ExtendedProperties.Private[propertyMissing] = missing
+		}
+		return e
+	}
+
+	st := newState("")
+	st.Events["known"] = &stateEvent{SegmentID: "known", Missing: 1}
+	events := []*calendar.Event{
+		managed("known", "2"),
+		managed("new", ""),
+		managed("gone", "2"),
+		{Id: "not-ours", Start: &calendar.EventDateTime{Date: "2030-07-10"}, End: &calendar.EventDateTime{Date: "2030-07-11"}},
+	}
+
+	adopted := st.adoptCalendarEvents(events)
+	if len(adopted) != 2 || adopted["new"] != 0 || adopted["gone"] != 2 {
+		t.Errorf("adoptCalendarEvents = %v, want new with 0 runs and gone with 2", adopted)
+	}
+	if st.Events["known"].Missing != 1 {
+		t.Errorf("known is missing for %d runs, want the state to win over the event", st.Events["known"].Missing)
+	}
+
+	se := st.Events["gone"]
+	if se == nil {
+		t.Fatal("gone was not adopted")
+	}
+	if se.EventID != "event-gone" || se.TripID != "trip" || se.Hash != "hash-gone" || se.Missing != 2 {
+		t.Errorf("adopted %+v, want it filled in from the event", se)
+	}
+	if want := time.Date(2030, time.July, 10, 14, 0, 0, 0, time.UTC); !se.End.Equal(want) {
+		t.Errorf("adopted event ends %s, want %s", se.End, want)
+	}
+	if _, ok := st.Events["not-ours"]; ok || len(st.Events) != 3 {
+		t.Errorf("adopted %d events, want the event the bot does not manage left out", len(st.Events))
+	}
+}
@@ -0,0 +1,741 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/notify"
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// incrementalSkew is how far before the high-water mark we ask TripIt for
+// changed trips, in case our clock and TripIt's disagree.
+const incrementalSkew = 5 * time.Minute
+
+// throttleWait is how long we pause when TripIt rate limits us without
+// saying for how long.
+const throttleWait = 10 * time.Minute
+
+// The first sync checkpoints the state file after this many segments or
+// this long since the last checkpoint, whichever comes first. Segments are
+// synced by how soon they are, not trip by trip, so the checkpoints cannot
+// follow trips.
+const (
+	checkpointSegments = 25
+	checkpointInterval = 30 * time.Second
+)
+
+// syncNow asks the daemon to sync right away instead of waiting for the
+// next tick.
+var syncNow = make(chan struct{}, 1)
+
+// requestSync asks the daemon for a sync, and returns false if one was
+// already asked for.
+func requestSync() bool {
+	select {
+	case syncNow <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// run syncs TripIt to the calendars in two phases. The fetch phase gets the
+// itinerary from TripIt and snapshots it to the state, the write phase diffs
+// the snapshot against each calendar and writes the changes. The first
+// calendar keeps its events in the state file, and every other calendar in
+// a state file of its own, since each calendar gives its events their own
+// IDs.
+func run(ctx context.Context, tripitClient *tripit.Client, backends []calendarBackend, pastFilter string) (*syncResult, error) {
+	res := newSyncResult()
+	backend := backends[0]
+
+	st, err := loadState(stateFile)
+	if err != nil {
+		return res, res.finish(err)
+	}
+	defer func() {
+		if err := st.save(); err != nil {
+			logrus.Warnf("saving state failed: %v", err)
+		}
+	}()
+
+	// Do not touch the calendar while we wait for the quota to reset.
+	pending, paused := resumeAfterQuota(st)
+	if paused {
+		res.Pending = len(st.Quota.Pending)
+		return res, res.finish(nil)
+	}
+
+	// Do not ask TripIt again until it stops rate limiting us.
+	if now := time.Now(); now.Before(st.Throttled) {
+		logrus.Infof("tripit is rate limiting us, pausing the sync until %s", st.Throttled.Format(time.RFC1123))
+		return res, res.finish(nil)
+	}
+
+	// Get the existing events from Google calendar and the events from TripIt
+	// at the same time, since neither depends on the other.
+	var (
+		wg        sync.WaitGroup
+		events    []*calendar.Event
+		trips     []tripit.Event
+		eventsErr error
+		tripsErr  error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		events, eventsErr = backend.List(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		trips, tripsErr = fetchPhase(ctx, tripitClient, st, pastFilter)
+	}()
+	wg.Wait()
+
+	if eventsErr != nil {
+		return res, res.finish(eventsErr)
+	}
+	if wait, ok := tripit.IsRateLimited(tripsErr); ok {
+		// Pause instead of failing, and pick up once TripIt lets us.
+		if wait <= 0 {
+			wait = throttleWait
+		}
+		st.Throttled = time.Now().Add(wait).UTC()
+		logrus.Warnf("tripit is rate limiting us, pausing the sync until %s: %v", st.Throttled.Format(time.RFC1123), tripsErr)
+		return res, res.finish(nil)
+	}
+	if tripsErr != nil {
+		return res, res.finish(tripsErr)
+	}
+
+	// Hold back the changes nobody approved yet from every calendar.
+	var proposed []string
+	if approve {
+		trips, proposed = gateChanges(st, trips, time.Now())
+		res.Awaiting = len(st.Approvals.pendingProposals())
+	}
+
+	if g, ok := backend.(*googleBackend); ok {
+		events, err = addLegacyEvents(ctx, g.service, g.calendarID, events, trips)
+		if err != nil {
+			return res, res.finish(err)
+		}
+	}
+
+	// If TripIt had more pages than we fetched, the trips on them are not
+	// gone, so only count segments as missing with all of them.
+	complete := !st.Snapshot.Truncated
+	announce(ctx, st, trips, append(proposed, writePhase(ctx, backend, st, events, trips, pending, complete, res)...), res)
+
+	// Write the same events to the other calendars. Their changes are
+	// the same as the first calendar's, so they are not announced again.
+	// A calendar we could not sync to at all counts as a single failure,
+	// so the run is partial rather than failed.
+	for i, b := range backends[1:] {
+		if err := syncMirror(ctx, b, backendStateFile(i+1), trips, complete, res); err != nil {
+			logrus.Error(err)
+			res.Failed++
+			res.Errors = append(res.Errors, syncEventError{Error: err.Error()})
+		}
+	}
+
+	// Mirror the trips we take with our companion to their calendar.
+	if len(companionCalendar) > 0 {
+		syncCompanion(ctx, trips, complete, res)
+	}
+
+	// Only fetch what changed from now on if everything was fetched and
+	// written.
+	if res.Failed == 0 && st.Quota == nil && complete {
+		st.HighWater = st.Snapshot.Started
+	}
+
+	if len(slackToken) > 0 {
+		if err := syncSlackStatus(ctx, tripitClient, trips); err != nil {
+			logrus.Warnf("updating slack status failed: %v", err)
+		}
+	}
+
+	if len(vacationResponder) > 0 {
+		if err := syncVacationResponder(ctx, tripitClient, st); err != nil {
+			logrus.Warnf("updating the vacation responder failed: %v", err)
+		}
+	}
+
+	return res, res.finish(nil)
+}
+
+// syncMirror runs the write phase for another calendar we sync to, with the
+// state in the file at path, and adds the events that failed to the result.
+// Complete is passed on to writePhase.
+func syncMirror(ctx context.Context, backend calendarBackend, path string, trips []tripit.Event, complete bool, res *syncResult) error {
+	st, err := loadState(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := st.save(); err != nil {
+			logrus.Warnf("saving state for %s failed: %v", backend, err)
+		}
+	}()
+
+	pending, paused := resumeAfterQuota(st)
+	if paused {
+		return nil
+	}
+
+	existing, err := backend.List(ctx)
+	if err != nil {
+		return fmt.Errorf("syncing to %s failed: %w", backend, err)
+	}
+	if g, ok := backend.(*googleBackend); ok {
+		existing, err = addLegacyEvents(ctx, g.service, g.calendarID, existing, trips)
+		if err != nil {
+			return fmt.Errorf("syncing to %s failed: %w", backend, err)
+		}
+	}
+
+	mres := newSyncResult()
+	writePhase(ctx, backend, st, existing, trips, pending, complete, mres)
+	logrus.Infof("synced %s: %d created, %d updated, %d unchanged, %d removed, %d failed", backend, mres.Created, mres.Updated, mres.Unchanged, mres.Removed, mres.Failed)
+	res.Failed += mres.Failed
+	res.Errors = append(res.Errors, mres.Errors...)
+	return nil
+}
+
+// resumeAfterQuota returns whether writes are still paused because we ran out
+// of Google Calendar quota. Once the quota has reset it clears the pause and
+// returns the segments we did not get to write.
+func resumeAfterQuota(st *syncState) ([]string, bool) {
+	q := st.Quota
+	if q == nil {
+		return nil, false
+	}
+	if time.Now().Before(q.Until) {
+		logrus.Debugf("google calendar quota exhausted, writes are paused until %s", q.Until.Format(time.RFC1123))
+		return nil, true
+	}
+
+	logrus.Infof("google calendar quota has reset, resuming with %d pending events", len(q.Pending))
+	st.Quota = nil
+	return q.Pending, false
+}
+
+// fetchPhase gets the events from TripIt and snapshots them to the state, so
+// the write phase can be replayed without asking TripIt again. With
+// --incremental, it only gets the trips that changed since the high-water
+// mark and updates the snapshot with them, unless it is time for a full
+// fetch.
+func fetchPhase(ctx context.Context, tripitClient *tripit.Client, st *syncState, pastFilter string) ([]tripit.Event, error) {
+	started := time.Now().UTC()
+	st.mu.Lock()
+	prev, highWater := st.Snapshot, st.HighWater
+	st.mu.Unlock()
+
+	var (
+		trips     []tripit.Event
+		truncated bool
+		err       error
+		full      = started
+	)
+	if since, ok := incrementalSince(prev, highWater, pastFilter, started); ok {
+		var changed []tripit.Event
+		changed, truncated, err = getTripItEvents(ctx, tripitClient, pastFilter, tripit.Filter{
+			Type:  tripit.FilterModifiedSince,
+			Value: strconv.FormatInt(since.Unix(), 10),
+		})
+		logrus.Debugf("fetched %d events of trips changed in tripit since %s", len(changed), since.Format(time.RFC3339))
+		trips, full = mergeChangedTrips(prev.Events, changed), prev.Full
+		truncated = truncated || prev.Truncated
+	} else {
+		trips, truncated, err = getTripItEvents(ctx, tripitClient, pastFilter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting tripit events failed: %w", err)
+	}
+	payloads.dump("tripit events", trips)
+
+	snap := &snapshot{
+		Fetched:   time.Now().UTC(),
+		Past:      pastFilter == "true",
+		Events:    trips,
+		Started:   started,
+		Full:      full,
+		Truncated: truncated,
+	}
+	st.mu.Lock()
+	st.Snapshot = snap
+	st.mu.Unlock()
+
+	// Keep the snapshot in the history if the itinerary changed.
+	if err := saveHistory(snap); err != nil {
+		logrus.Warnf("saving itinerary history failed: %v", err)
+	}
+
+	return trips, nil
+}
+
+// incrementalSince returns when to ask TripIt for the trips changed since,
+// if we can update the previous snapshot instead of fetching everything: we
+// have synced successfully, the snapshot is of the same trips, and the last
+// full fetch, which notices deleted trips, was less than --full-fetch ago.
+func incrementalSince(prev *snapshot, highWater time.Time, pastFilter string, now time.Time) (time.Time, bool) {
+	if !incremental || prev == nil || highWater.IsZero() || prev.Full.IsZero() {
+		return time.Time{}, false
+	}
+	if prev.Past != (pastFilter == "true") || now.Sub(prev.Full) >= fullFetch {
+		return time.Time{}, false
+	}
+	return highWater.Add(-incrementalSkew), true
+}
+
+// mergeChangedTrips returns the events with those of the changed trips
+// replaced by their new events.
+func mergeChangedTrips(events, changed []tripit.Event) []tripit.Event {
+	ids := map[string]bool{}
+	for _, e := range changed {
+		ids[e.ID] = true
+	}
+	merged := make([]tripit.Event, 0, len(events)+len(changed))
+	for _, e := range events {
+		if !ids[e.ID] {
+			merged = append(merged, e)
+		}
+	}
+	return append(merged, changed...)
+}
+
+// listCalendarEvents returns the events in the calendar the bot manages from
+// the last four years on. The events are looked up by the private extended
+// property the bot sets on every event it creates, so the calendar itself
+// keys events to their TripIt segment and a sync never creates an event twice.
+func listCalendarEvents(ctx context.Context, gcalClient *calendar.Service, calendarName string) ([]*calendar.Event, error) {
+	t := time.Now().AddDate(-4, 0, 0).Format(time.RFC3339)
+	var events []*calendar.Event
+	call := gcalClient.Events.List(calendarName).
+		PrivateExtendedProperty(propertyManaged + "=true").
+		ShowDeleted(false).
+		SingleEvents(true).
+		TimeMin(t).
+		MaxResults(2500).
+		Context(ctx)
+	err := call.Pages(ctx, func(page *calendar.Events) error {
+		events = append(events, page.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting events from google calendar %s failed: %v", calendarName, err)
+	}
+	payloads.dump("google calendar events", events)
+	return events, nil
+}
+
+// addLegacyEvents adds the flight events created before the bot stored
+// extended properties to the events, so they are updated, and get the
+// properties, instead of being created again. They can only be found by
+// searching, so we only search if a segment has no managed event.
+func addLegacyEvents(ctx context.Context, gcalClient *calendar.Service, calendarName string, events []*calendar.Event, trips []tripit.Event) ([]*calendar.Event, error) {
+	managed := map[string]bool{}
+	for _, e := range events {
+		managed[privateProperty(e, propertySegmentID)] = true
+	}
+	missing := false
+	for _, trip := range trips {
+		if !managed[trip.SegmentID] && trip.Status != tripit.FlightStatusCancelled {
+			missing = true
+			break
+		}
+	}
+	if !missing {
+		return events, nil
+	}
+
+	t := time.Now().AddDate(-4, 0, 0).Format(time.RFC3339)
+	legacy, err := gcalClient.Events.List(calendarName).ShowDeleted(false).SingleEvents(true).TimeMin(t).OrderBy("startTime").Q("Flight").MaxResults(2500).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("searching for flight events in google calendar %s failed: %v", calendarName, err)
+	}
+	for _, e := range legacy.Items {
+		// Events marked as no longer managed are left alone.
+		if e.ExtendedProperties == nil || e.ExtendedProperties.Private == nil {
+			events = append(events, e)
+		} else if _, ok := e.ExtendedProperties.Private[propertyManaged]; !ok {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// writePhase writes the TripIt events to the calendar, given the events that
+// are already in it, fills in the result, and returns the changes to
+// announce. Only when the trips are complete, a new look at all of TripIt,
+// do segments missing from them count towards removing them. A replay of a
+// snapshot is not a new look, and a fetch cut off at --tripit-max-pages is
+// not all of it.
+//
+// Checking other calendars for meetings, archiving, and pruning only work
+// with Google Calendar.
+func writePhase(ctx context.Context, backend calendarBackend, st *syncState, existing []*calendar.Event, trips []tripit.Event, pending []string, complete bool, res *syncResult) []string {
+	res.Events = len(trips)
+	google, _ := backend.(*googleBackend)
+	st.beginWrites()
+	defer st.commitWrites()
+
+	// Sync imminent travel first, so it is always fresh even if we run out
+	// of quota or time before we get to the rest.
+	trips = prioritize(trips, pending, time.Now())
+
+	// Changes to announce to chat tools once we are done.
+	var announcements []string
+
+	// The first sync over a large history can take a while, so report how
+	// far along it is.
+	var p *progress
+	if len(st.Events) < 1 {
+		p = newProgress("Syncing", len(trips))
+	}
+
+	// Iterate over the trip and see if we already have a matching calendar event.
+	// If not make one and/or update the old one.
+	lastCheckpoint := time.Now()
+	for i, trip := range trips {
+		if p != nil {
+			p.step()
+
+			// Checkpoint the first sync as it goes, so a crash does
+			// not lose its progress.
+			if i > 0 && (i%checkpointSegments == 0 || time.Since(lastCheckpoint) >= checkpointInterval) {
+				if err := st.save(); err != nil {
+					logrus.Warnf("saving state failed: %v", err)
+				}
+				lastCheckpoint = time.Now()
+			}
+		}
+
+		if st.archived(trip.SegmentID) {
+			logrus.Debugf("skipping segment %s of archived trip %s", trip.SegmentID, trip.ID)
+			res.Skipped++
+			continue
+		}
+
+		if !trip.Confirmed() {
+			logrus.Warnf("skipping segment %s of trip %s that has no confirmation number: %s", trip.SegmentID, trip.ID, trip.Title)
+			res.Skipped++
+			continue
+		}
+
+		// Flights TripIt says were cancelled are taken off the calendar
+		// rather than synced.
+		if trip.Status == tripit.FlightStatusCancelled {
+			matching := findMatchingEvent(existing, trip.SegmentID)
+			if matching == nil || privateProperty(matching, propertyManaged) == "false" {
+				res.Skipped++
+				continue
+			}
+			if err := cancelEvent(ctx, backend, trip.SegmentID, matching.Id, trip.Title); err != nil {
+				logrus.Errorf("segment %s: %v", trip.SegmentID, err)
+				res.fail(trip, err)
+				continue
+			}
+			logrus.Infof("cancelled event %s in %s for cancelled flight %s", matching.Id, backend, trip.Title)
+			st.forget(trip.SegmentID)
+			announcements = append(announcements, "Cancelled: "+trip.Title)
+			res.Removed++
+			continue
+		}
+
+		action, err := syncEvent(ctx, backend, st, existing, trip)
+		if isQuotaExceeded(err) {
+			metricThrottled.Inc("google")
+			// Stop writing, and pick up where we left off once the
+			// quota resets instead of failing every write until then.
+			q := &quotaPause{Until: quotaResetTime(err, time.Now())}
+			for _, t := range trips[i:] {
+				q.Pending = append(q.Pending, t.SegmentID)
+			}
+			st.Quota = q
+			res.Pending = len(q.Pending)
+			msg := fmt.Sprintf("Google Calendar quota exhausted, pausing writes until %s with %d events pending", q.Until.Format(time.RFC1123), len(q.Pending))
+			logrus.Warn(msg)
+			announcements = append(announcements, msg)
+			break
+		}
+		if err != nil {
+			logrus.Error(err)
+			res.fail(trip, err)
+			continue
+		}
+		switch action {
+		case syncCreated:
+			if trip.Type == eventTypeWorkingHours {
+				announcements = append(announcements, workingHoursSuggestion(trip))
+			} else {
+				announcements = append(announcements, fmt.Sprintf("New: %s, %s", trip.Title, eventTime(trip.Start).Format("Mon Jan 2 3:04pm")))
+			}
+			res.Created++
+			// We see the booking before anyone else does, so warn about
+			// the meetings it collides with while they can be moved.
+			if trip.IsFlight() && len(busyCalendars) > 0 && google != nil {
+				meetings, err := busyConflicts(ctx, google.service, trip)
+				if err != nil {
+					logrus.Warn(err)
+				}
+				for _, m := range meetings {
+					warning := conflictWarning(trip, m)
+					if declineMeetings {
+						declined, err := declineMeeting(ctx, trip, m)
+						if err != nil {
+							logrus.Warn(err)
+						}
+						if declined {
+							warning = fmt.Sprintf("Declined: %q at %s in %s for %s", m.Event.Summary, meetingStart(trip, m), m.CalendarID, trip.Title)
+						}
+					}
+					logrus.Warn(warning)
+					announcements = append(announcements, warning)
+				}
+			}
+		case syncUpdated:
+			res.Updated++
+		default:
+			res.Unchanged++
+		}
+	}
+
+	// Remove the events of trips that have been gone from TripIt for long
+	// enough that it is not just a flaky response. Like archiving and
+	// pruning below, this writes to the calendar, so skip it if we ran out
+	// of quota.
+	var (
+		gone    []*stateEvent
+		adopted map[string]int
+	)
+	if st.Quota == nil && complete {
+		if len(st.path) < 1 {
+			adopted = st.adoptCalendarEvents(existing)
+		}
+		gone = st.markMissing(trips, removalGraceRuns)
+	}
+	for _, se := range gone {
+		if err := cancelEvent(ctx, backend, se.SegmentID, se.EventID, se.Title); err != nil {
+			logrus.Errorf("segment %s: %v", se.SegmentID, err)
+			continue
+		}
+		logrus.Infof("cancelled event %s in %s for segment %s after it was missing from TripIt for %d runs", se.EventID, backend, se.SegmentID, se.Missing)
+		st.forget(se.SegmentID)
+		announcements = append(announcements, "Cancelled: "+se.Title)
+		res.Removed++
+	}
+
+	// Without a state file, keep the count on the events themselves.
+	for id, before := range adopted {
+		st.mu.Lock()
+		se, ok := st.Events[id]
+		st.mu.Unlock()
+		if !ok || se.Missing == before {
+			continue
+		}
+		patch := &calendar.Event{
+			ExtendedProperties: &calendar.EventExtendedProperties{
+				Private: map[string]string{propertyMissing: strconv.Itoa(se.Missing)},
+			},
+		}
+		if err := backend.Update(ctx, id, se.EventID, patch); err != nil {
+			logrus.Errorf("segment %s: counting the runs it is missing from TripIt failed: %v", id, err)
+		}
+	}
+
+	// Stop tracking trips that are over.
+	if archive && st.Quota == nil && google != nil {
+		res.Archived = archiveEndedTrips(ctx, google.service, google.calendarID, st)
+	}
+
+	// Delete events older than the retention period.
+	if retentionYears > 0 && st.Quota == nil && google != nil {
+		pruned, err := pruneOldEvents(ctx, google.service, st, retentionCutoff(time.Now()), false)
+		if err != nil {
+			logrus.Error(err)
+		}
+		res.Pruned = len(pruned)
+	}
+
+	return announcements
+}
+
+// announce warns about double bookings, trips without travel insurance, and
+// checklist items that are due, publishes whether we are traveling, and sends
+// the changes a write phase made to the chat tools.
+func announce(ctx context.Context, st *syncState, trips []tripit.Event, announcements []string, res *syncResult) {
+	// Warn about double bookings a week before they depart.
+	for _, warning := range warnDuplicateBookings(st, trips) {
+		logrus.Warn(warning)
+		announcements = append(announcements, warning)
+	}
+	if len(insuranceFile) > 0 {
+		for _, warning := range warnInsurance(st, trips, time.Now()) {
+			logrus.Warn(warning)
+			announcements = append(announcements, warning)
+		}
+	}
+	if checklist {
+		announcements = append(announcements, checklistReminders(st, trips, time.Now())...)
+	}
+
+	// Let automation know if we are on a flight right now.
+	status := currentTravelStatus(trips, time.Now())
+	res.Traveling = status.Traveling
+	if err := publishTravelStatus(ctx, status); err != nil {
+		logrus.Warnf("publishing travel status failed: %v", err)
+	}
+	if n := getNotifier(); n != nil && len(announcements) > 0 {
+		if err := n.Notify(ctx, notify.Message{
+			Title: "Travel updates",
+			Text:  strings.Join(announcements, "\n"),
+		}); err != nil {
+			logrus.Warnf("announcing travel updates failed: %v", err)
+		}
+	}
+}
+
+// syncAction is what syncEvent did for a TripIt event.
+type syncAction int
+
+const (
+	syncUnchanged syncAction = iota
+	syncCreated
+	syncUpdated
+)
+
+func (a syncAction) String() string {
+	switch a {
+	case syncCreated:
+		return "create"
+	case syncUpdated:
+		return "update"
+	}
+	return "unchanged"
+}
+
+// planEvent works out what syncing the TripIt event would do without writing
+// anything. It returns the action, the event as it should be in the calendar,
+// and the existing event it matched, if any.
+func planEvent(existing []*calendar.Event, trip tripit.Event) (syncAction, *calendar.Event, *calendar.Event, error) {
+	matchingEvent := findMatchingEvent(existing, trip.SegmentID)
+
+	location, err := eventLocation(trip)
+	if err != nil {
+		return syncUnchanged, nil, nil, err
+	}
+
+	event := newCalendarEvent(trip, location)
+	if descriptionFooter {
+		event.Description = addFooter(event.Description, time.Now())
+	}
+
+	if matchingEvent == nil {
+		return syncCreated, event, nil, nil
+	}
+
+	// Skip the update if nothing changed since we last wrote the event,
+	// including whether it should have a footer.
+	hasFooter := strings.Contains(matchingEvent.Description, footerSeparator)
+	if privateProperty(matchingEvent, propertyHash) == privateProperty(event, propertyHash) && hasFooter == descriptionFooter {
+		return syncUnchanged, event, matchingEvent, nil
+	}
+
+	// Update a copy of our matching event, so the original is left as it
+	// was in case we need to undo the update.
+	updated := *matchingEvent
+	updated.Summary = event.Summary
+	updated.Description = event.Description
+	updated.Start = event.Start
+	updated.End = event.End
+	updated.Location = event.Location
+	updated.Source = event.Source
+	updated.Visibility = event.Visibility
+	updated.ExtendedProperties = &calendar.EventExtendedProperties{}
+	if matchingEvent.ExtendedProperties != nil {
+		updated.ExtendedProperties.Private = mergeProperties(matchingEvent.ExtendedProperties.Private, event.ExtendedProperties.Private)
+		updated.ExtendedProperties.Shared = mergeProperties(matchingEvent.ExtendedProperties.Shared, event.ExtendedProperties.Shared)
+	} else {
+		updated.ExtendedProperties.Private = mergeProperties(nil, event.ExtendedProperties.Private)
+		updated.ExtendedProperties.Shared = mergeProperties(nil, event.ExtendedProperties.Shared)
+	}
+
+	return syncUpdated, &updated, matchingEvent, nil
+}
+
+// eventPatch returns a patch of the fields of the event the bot owns, so
+// updating it leaves whatever else was changed in the calendar, like
+// reminders, colors, or guests, alone even if it changed since we listed the
+// events. Fields the bot cleared are sent empty so they are cleared in the
+// calendar too.
+func eventPatch(e *calendar.Event) *calendar.Event {
+	return &calendar.Event{
+		Summary:            e.Summary,
+		Description:        e.Description,
+		Start:              e.Start,
+		End:                e.End,
+		Location:           e.Location,
+		Source:             e.Source,
+		Visibility:         e.Visibility,
+		Transparency:       e.Transparency,
+		ExtendedProperties: e.ExtendedProperties,
+		ForceSendFields:    []string{"Summary", "Description", "Location"},
+	}
+}
+
+// mergeProperties returns a new map with the properties of a overwritten by
+// those of b.
+func mergeProperties(a, b map[string]string) map[string]string {
+	m := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		m[k] = v
+	}
+	for k, v := range b {
+		m[k] = v
+	}
+	return m
+}
+
+// syncEvent creates or updates the calendar event for the TripIt event,
+// matching it against the existing events, and records it in the state.
+func syncEvent(ctx context.Context, backend calendarBackend, st *syncState, existing []*calendar.Event, trip tripit.Event) (syncAction, error) {
+	action, event, matchingEvent, err := planEvent(existing, trip)
+	if err != nil {
+		return action, err
+	}
+	hash := privateProperty(event, propertyHash)
+
+	switch action {
+	case syncCreated:
+		// No event was found for this trip, let's create one.
+		created, err := backend.Create(ctx, trip.SegmentID, event)
+		if err != nil {
+			return syncUnchanged, err
+		}
+		st.journal(trip.SegmentID, created.Id, nil)
+		st.record(trip, created.Id, hash)
+		if trip.IsFlight() {
+			metricFlightsCreated.Inc(trip.AirlineCode, trip.AirportCode, trip.DestinationCode)
+		}
+	case syncUpdated:
+		if err := backend.Update(ctx, trip.SegmentID, event.Id, eventPatch(event)); err != nil {
+			return syncUnchanged, err
+		}
+		st.journal(trip.SegmentID, event.Id, matchingEvent)
+		st.record(trip, event.Id, hash)
+	default:
+		logrus.Debugf("event %s in %s for segment %s is up to date", matchingEvent.Id, backend, trip.SegmentID)
+		st.record(trip, matchingEvent.Id, hash)
+	}
+
+	return action, nil
+}
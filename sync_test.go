@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+func TestWritePhaseIncomplete(t *testing.T) {
+	grace := removalGraceRuns
+	removalGraceRuns = 2
+	t.Cleanup(func() { removalGraceRuns = grace })
+
+	ctx := context.Background()
+	backend := &memoryBackend{}
+	st := newState("")
+	departs := time.Now().AddDate(0, 1, 0).Truncate(time.Minute)
+	first, second := hashEvent(departs), hashEvent(departs.AddDate(0, 0, 3))
+	second.SegmentID = "later-page"
+
+	res := newSyncResult()
+	writePhase(ctx, backend, st, nil, []tripit.Event{first, second}, nil, true, res)
+	if res.Created != 2 {
+		t.Fatalf("created %d events, want 2", res.Created)
+	}
+
+	// TripIt had more pages than we fetched, so the segment on the later
+	// page is not missing, however many runs it is left out.
+	for run := 0; run < 3; run++ {
+		existing, err := backend.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = newSyncResult()
+		writePhase(ctx, backend, st, existing, []tripit.Event{first}, nil, false, res)
+		if res.Removed != 0 {
+			t.Fatalf("run %d removed %d events from a truncated fetch, want none", run, res.Removed)
+		}
+		if se := st.Events["later-page"]; se == nil || se.Missing != 0 {
+			t.Fatalf("run %d: the segment on a later page is %+v, want it kept and not missing", run, se)
+		}
+	}
+
+	// A complete fetch without it counts it as missing.
+	existing, err := backend.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePhase(ctx, backend, st, existing, []tripit.Event{first}, nil, true, newSyncResult())
+	if se := st.Events["later-page"]; se == nil || se.Missing != 1 {
+		t.Errorf("the segment gone from a complete fetch is %+v, want it missing for 1 run", se)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/mqtt"
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+// travelStatus says whether we are in the middle of a flight right now, for
+// automation that mutes notifications while we travel.
+type travelStatus struct {
+	Traveling bool       `json:"traveling"`
+	Title     string     `json:"title,omitempty"`
+	SegmentID string     `json:"segmentID,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	Updated   time.Time  `json:"updated"`
+}
+
+// currentTravelStatus returns the travel status at now for the TripIt events.
+func currentTravelStatus(trips []tripit.Event, now time.Time) travelStatus {
+	status := travelStatus{Updated: now.UTC()}
+	for _, trip := range trips {
+		if !trip.IsFlight() {
+			continue
+		}
+		start, end := eventTime(trip.Start), eventTime(trip.End)
+		if start.IsZero() || end.IsZero() || now.Before(start) || !now.Before(end) {
+			continue
+		}
+
+		status.Traveling = true
+		status.Title = trip.Title
+		status.SegmentID = trip.SegmentID
+		status.Until = &end
+		break
+	}
+	return status
+}
+
+// publishTravelStatus writes the travel status to the traveling file and
+// publishes it to the MQTT topic, if they are configured.
+func publishTravelStatus(ctx context.Context, status travelStatus) error {
+	b, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	if len(travelingFile) > 0 {
+		tmp := travelingFile + ".tmp"
+		if err := ioutil.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+			return fmt.Errorf("writing traveling file %s failed: %v", tmp, err)
+		}
+		if err := os.Rename(tmp, travelingFile); err != nil {
+			return fmt.Errorf("renaming traveling file %s failed: %v", tmp, err)
+		}
+	}
+
+	if len(mqttBroker) > 0 {
+		hostname, _ := os.Hostname()
+		client := mqtt.New(mqttBroker, "tripitcalb0t-"+hostname, mqttUsername, mqttPassword)
+		// Retain the message so subscribers get the current status as soon
+		// as they connect.
+		if err := client.Publish(ctx, mqttTopic, b, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
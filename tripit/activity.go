@@ -0,0 +1,89 @@
+package tripit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const (
+	activityDescriptionFormat = `[Activity] %s
+%s
+%s
+
+Booking Site (%s) Confirmation # %s
+Supplier (%s) Confirmation # %s
+Phone: %s
+
+View and/or edit details of this activity [%s]: https://www.tripit.com/%s
+
+View and/or edit details of this trip: https://www.tripit.com/trip/show/id/%s`
+
+	// activityEventDuration is how long we block for an activity TripIt
+	// does not know the end of.
+	activityEventDuration = time.Hour
+)
+
+// GetActivityAsEvents returns an Event for the activity in the given
+// activity object, like a tour, a concert, or a meeting.
+func (a Activity) GetActivityAsEvents() ([]Event, error) {
+	start, err := a.StartDateTime.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("parsing StartDateTime for tripID -> %s, activity -> %s failed: %v", a.TripID, a.ID, err)
+	}
+
+	// TripIt only has the time the activity ends, on the day it starts.
+	end := start.Add(activityEventDuration)
+	if len(a.EndTime) > 0 {
+		endDateTime := a.StartDateTime
+		endDateTime.Time = a.EndTime
+		if t, err := endDateTime.Parse(); err == nil {
+			if !t.After(start) {
+				t = t.AddDate(0, 0, 1)
+			}
+			end = t
+		}
+	}
+
+	name := firstNonEmpty(a.DisplayName, a.SupplierName)
+	address := a.Address.String()
+
+	description := fmt.Sprintf(activityDescriptionFormat,
+		name,
+		a.LocationName,
+		address,
+		a.BookingSiteName,
+		a.BookingSiteConfNum,
+		a.SupplierName,
+		a.SupplierConfNum,
+		a.SupplierPhone,
+		a.ID,
+		strings.TrimPrefix(a.RelativeURL, "/"),
+		a.TripID)
+
+	location := address
+	if len(a.LocationName) > 0 {
+		location = strings.TrimSuffix(a.LocationName+", "+address, ", ")
+	}
+
+	return []Event{{
+		Type:        EventTypeActivity,
+		Title:       name,
+		Description: description,
+		Start: calendar.EventDateTime{
+			DateTime: start.Format(time.RFC3339),
+			TimeZone: a.StartDateTime.Timezone,
+		},
+		End: calendar.EventDateTime{
+			DateTime: end.Format(time.RFC3339),
+			TimeZone: a.StartDateTime.Timezone,
+		},
+		ID:                 a.TripID,
+		SegmentID:          a.ID,
+		ConfirmationNumber: firstNonEmpty(a.SupplierConfNum, a.BookingSiteConfNum),
+		DestinationCity:    a.Address.City,
+		Location:           location,
+	}}, nil
+}
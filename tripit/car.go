@@ -0,0 +1,119 @@
+package tripit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const (
+	carDescriptionFormat = `[Car] %s %s
+%s
+%s
+
+Booking Site (%s) Confirmation # %s
+Supplier (%s) Confirmation # %s
+Phone: %s
+Hours: %s
+
+Car: %s %s
+
+View and/or edit details of this rental [%s]: https://www.tripit.com/%s
+
+View and/or edit details of this trip: https://www.tripit.com/trip/show/id/%s`
+
+	// carEventDuration is how long we block for picking up or dropping off
+	// a rental car.
+	carEventDuration = 30 * time.Minute
+)
+
+// GetCarAsEvents returns an Event for picking up and one for dropping off the
+// rental car in the given car object.
+func (c Car) GetCarAsEvents() ([]Event, error) {
+	var confirmationNumber string
+	if c.SupplierConfNum != "" {
+		confirmationNumber = c.SupplierConfNum
+	} else if c.BookingSiteConfNum != "" {
+		confirmationNumber = c.BookingSiteConfNum
+	}
+
+	agency := firstNonEmpty(c.SupplierName, c.DisplayName, "rental")
+
+	// Cars are mostly dropped off where they were picked up, and TripIt
+	// leaves the drop-off location empty then.
+	endName, endAddress := c.EndLocationName, c.EndLocationAddress
+	if len(endName) < 1 && len(endAddress.String()) < 1 {
+		endName, endAddress = c.StartLocationName, c.StartLocationAddress
+	}
+
+	var events []Event
+	for _, stop := range []struct {
+		what    string
+		prep    string
+		id      string
+		field   string
+		dt      DateTime
+		name    string
+		address Address
+		phone   string
+		hours   string
+	}{
+		{"Pick up", "from", c.ID + "-pickup", "StartDateTime", c.StartDateTime, c.StartLocationName, c.StartLocationAddress, c.StartLocationPhone, c.StartLocationHours},
+		{"Drop off", "at", c.ID + "-dropoff", "EndDateTime", c.EndDateTime, endName, endAddress, c.EndLocationPhone, c.EndLocationHours},
+	} {
+		t, err := stop.dt.Parse()
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s for tripID -> %s, car -> %s failed: %v", stop.field, c.TripID, c.ID, err)
+		}
+
+		location := stop.address.String()
+		if len(stop.name) > 0 && !strings.Contains(location, stop.name) {
+			location = strings.TrimSuffix(stop.name+", "+location, ", ")
+		}
+
+		title := fmt.Sprintf("%s %s car", stop.what, agency)
+		if place := firstNonEmpty(stop.name, stop.address.City); len(place) > 0 {
+			title += " " + stop.prep + " " + place
+		}
+
+		description := fmt.Sprintf(carDescriptionFormat,
+			stop.what,
+			agency,
+			stop.name,
+			stop.address.String(),
+			c.BookingSiteName,
+			c.BookingSiteConfNum,
+			c.SupplierName,
+			c.SupplierConfNum,
+			stop.phone,
+			stop.hours,
+			c.CarType,
+			c.CarDescription,
+			c.ID,
+			strings.TrimPrefix(c.RelativeURL, "/"),
+			c.TripID)
+
+		events = append(events, Event{
+			Type:        EventTypeCar,
+			Title:       title,
+			Description: description,
+			Start: calendar.EventDateTime{
+				DateTime: t.Format(time.RFC3339),
+				TimeZone: stop.dt.Timezone,
+			},
+			End: calendar.EventDateTime{
+				DateTime: t.Add(carEventDuration).Format(time.RFC3339),
+				TimeZone: stop.dt.Timezone,
+			},
+			ID:                 c.TripID,
+			SegmentID:          stop.id,
+			ConfirmationNumber: confirmationNumber,
+			DestinationCity:    stop.address.City,
+			Location:           location,
+		})
+	}
+
+	return events, nil
+}
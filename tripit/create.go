@@ -1,10 +1,11 @@
 package tripit
 
 import (
+	"context"
 	"net/http"
 )
 
 // Create takes a Request object and creates it.
-func (c *Client) Create(req Request) (*Response, error) {
-	return c.doRequest(http.MethodPost, "v1/create", req)
+func (c *Client) Create(ctx context.Context, req Request) (*Response, error) {
+	return c.doRequest(ctx, http.MethodPost, "v1/create", req)
 }
@@ -0,0 +1,129 @@
+package tripit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const (
+	cruiseDescriptionFormat = `[Cruise] %s
+%s
+%s
+
+Booking Site (%s) Confirmation # %s
+Supplier (%s) Confirmation # %s
+Phone: %s
+
+Ship: %s
+Cabin: %s %s
+Dining: %s
+
+View and/or edit details of this cruise [%s]: https://www.tripit.com/%s
+
+View and/or edit details of this trip: https://www.tripit.com/trip/show/id/%s`
+
+	// cruiseEventDuration is how long we block for boarding or leaving the
+	// ship when TripIt only knows when it starts.
+	cruiseEventDuration = time.Hour
+)
+
+// GetCruiseSegmentsAsEvents returns an Event for each port of the given cruise
+// object: embarking at the first, a port day at every port of call, and
+// disembarking at the last. Ports without times get all-day events.
+func (c Cruise) GetCruiseSegmentsAsEvents() ([]Event, error) {
+	events := []Event{}
+
+	ship := firstNonEmpty(c.ShipName, c.SupplierName, c.DisplayName, "ship")
+	for i, segment := range c.Segments {
+		port := firstNonEmpty(segment.LocationName, segment.LocationAddress.City, segment.LocationAddress.String())
+
+		var title string
+		switch {
+		case i == 0:
+			title = fmt.Sprintf("Embark %s at %s", ship, port)
+		case i == len(c.Segments)-1:
+			title = fmt.Sprintf("Disembark %s at %s", ship, port)
+		default:
+			title = "Port of call: " + port
+		}
+
+		start, end, allDay, err := cruiseSegmentTimes(segment)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the times for tripID -> %s, cruise segment -> %s at %s failed: %v", c.TripID, segment.ID, port, err)
+		}
+
+		description := fmt.Sprintf(cruiseDescriptionFormat,
+			title,
+			segment.LocationName,
+			segment.LocationAddress.String(),
+			c.BookingSiteName,
+			c.BookingSiteConfNum,
+			c.SupplierName,
+			c.SupplierConfNum,
+			c.SupplierPhone,
+			c.ShipName,
+			c.CabinType,
+			c.CabinNumber,
+			c.Dining,
+			c.ID,
+			strings.TrimPrefix(c.RelativeURL, "/"),
+			c.TripID)
+
+		location := segment.LocationName
+		if address := segment.LocationAddress.String(); len(address) > 0 && address != location {
+			location = strings.TrimPrefix(location+", "+address, ", ")
+		}
+
+		events = append(events, Event{
+			Type:               EventTypeCruise,
+			Title:              strings.TrimSpace(title),
+			Description:        description,
+			Start:              start,
+			End:                end,
+			ID:                 c.TripID,
+			SegmentID:          firstNonEmpty(segment.ID, fmt.Sprintf("%s-%d", c.ID, i)),
+			ConfirmationNumber: firstNonEmpty(c.SupplierConfNum, c.BookingSiteConfNum),
+			DestinationCity:    segment.LocationAddress.City,
+			Location:           location,
+			AllDay:             allDay,
+		})
+	}
+
+	return events, nil
+}
+
+// cruiseSegmentTimes returns the start and end of the event for a port, and
+// whether it is an all-day event because TripIt has no times for it.
+func cruiseSegmentTimes(segment CruiseSegment) (calendar.EventDateTime, calendar.EventDateTime, bool, error) {
+	if len(segment.StartDateTime.Time) < 1 {
+		day, err := time.Parse("2006-01-02", segment.StartDateTime.Date)
+		if err != nil {
+			return calendar.EventDateTime{}, calendar.EventDateTime{}, false, err
+		}
+		last := day
+		if d, err := time.Parse("2006-01-02", segment.EndDateTime.Date); err == nil && d.After(day) {
+			last = d
+		}
+		// All-day events end the day after their last day.
+		return calendar.EventDateTime{Date: day.Format("2006-01-02")},
+			calendar.EventDateTime{Date: last.AddDate(0, 0, 1).Format("2006-01-02")},
+			true, nil
+	}
+
+	start, err := segment.StartDateTime.Parse()
+	if err != nil {
+		return calendar.EventDateTime{}, calendar.EventDateTime{}, false, err
+	}
+	end, endTimezone := start.Add(cruiseEventDuration), segment.StartDateTime.Timezone
+	if len(segment.EndDateTime.Time) > 0 {
+		if d, err := segment.EndDateTime.Parse(); err == nil && d.After(start) {
+			end, endTimezone = d, segment.EndDateTime.Timezone
+		}
+	}
+	return calendar.EventDateTime{DateTime: start.Format(time.RFC3339), TimeZone: segment.StartDateTime.Timezone},
+		calendar.EventDateTime{DateTime: end.Format(time.RFC3339), TimeZone: endTimezone},
+		false, nil
+}
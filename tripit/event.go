@@ -34,17 +34,85 @@ View and/or edit details of this flight [%s]: https://www.tripit.com/%s
 View and/or edit details of this trip: https://www.tripit.com/trip/show/id/%s`
 )
 
+// TripURL returns the URL of the trip with the given ID on the TripIt website.
+func TripURL(id string) string {
+	return "https://www.tripit.com/trip/show/id/" + id
+}
+
+// The types of TripIt objects an Event can be for.
+const (
+	EventTypeTrip       = "trip"
+	EventTypeFlight     = "flight"
+	EventTypeLodging    = "lodging"
+	EventTypeCar        = "car"
+	EventTypeRail       = "rail"
+	EventTypeTransport  = "transport"
+	EventTypeCruise     = "cruise"
+	EventTypeRestaurant = "restaurant"
+	EventTypeActivity   = "activity"
+)
+
 // Event holds the data we will use when creating calendar events for flights, activities, and other
 // TripIt API objects.
 type Event struct {
+	// Type is the type of TripIt object the event is for. Events saved
+	// before we knew about other objects have no type and are flights.
+	Type               string
 	Title              string
 	Description        string
 	AirportCode        string
+	DestinationCode    string
+	DestinationCity    string
+	Airline            string
+	FlightNumber       string
 	Start              calendar.EventDateTime
 	End                calendar.EventDateTime
 	ID                 string
 	SegmentID          string
 	ConfirmationNumber string
+	// TripName is the name of the trip the event belongs to.
+	TripName string
+	// Private is true if the trip the event belongs to is private in TripIt.
+	Private bool
+	// Tags are the tags of the trip the event belongs to.
+	Tags []string
+	// Kind is whether the trip the event belongs to is for business,
+	// personal, or a weekend away.
+	Kind string `json:",omitempty"`
+
+	// AirlineCode is the IATA code of the airline operating the flight.
+	AirlineCode string
+	// Status is the flight status, only known for flights monitored by
+	// TripIt Pro.
+	Status FlightStatusCode
+	// ScheduledArrival and EstimatedArrival are the arrival times from the
+	// flight status, or the zero time if they are not known. Once the
+	// flight has arrived the estimate is the actual arrival time.
+	ScheduledArrival time.Time
+	EstimatedArrival time.Time
+	// DivertedTo is the airport the flight was diverted to, if any.
+	DivertedTo string
+	// Terminal and Gate are where the flight departs from, if known.
+	Terminal string
+	Gate     string
+	// ServiceClass is the class of service of the flight, like Economy.
+	ServiceClass string `json:",omitempty"`
+	// Location is where the event takes place, for events that are not
+	// flights. Flights are located by their airport.
+	Location string
+	// AllDay is true for events that span whole days, like hotel stays.
+	AllDay bool
+}
+
+// IsFlight returns true if the event is for a flight.
+func (e Event) IsFlight() bool {
+	return e.Type == "" || e.Type == EventTypeFlight
+}
+
+// Confirmed returns true if the event has a confirmation number, or is for
+// the trip itself, which has none.
+func (e Event) Confirmed() bool {
+	return len(e.ConfirmationNumber) > 0 || e.Type == EventTypeTrip
 }
 
 // GetFlightSegmentsAsEvents returns an Event object for each of the
@@ -118,18 +186,50 @@ func (f Flight) GetFlightSegmentsAsEvents() ([]Event, error) {
 			confirmationNumber = f.BookingSiteConfNum
 		}
 
+		// Get the flight status, if TripIt monitors the flight.
+		var scheduledArrival, estimatedArrival time.Time
+		if len(segment.Status.ScheduledArrivalDateTime.Date) > 0 {
+			scheduledArrival, _ = segment.Status.ScheduledArrivalDateTime.Parse()
+		}
+		if len(segment.Status.EstimatedArrivalDateTime.Date) > 0 {
+			estimatedArrival, _ = segment.Status.EstimatedArrivalDateTime.Parse()
+		}
+
 		// Append the event to our events array.
 		events = append(events, Event{
+			Type:               EventTypeFlight,
 			Title:              fmt.Sprintf("Flight to %s (%s %s)", segment.EndCityName, airlineCode, flightNumber),
 			Description:        description,
 			AirportCode:        segment.StartAirportCode,
+			DestinationCode:    segment.EndAirportCode,
+			DestinationCity:    segment.EndCityName,
+			Airline:            airlineName,
+			FlightNumber:       strings.TrimSpace(airlineCode + " " + flightNumber),
 			Start:              start,
 			End:                end,
 			ID:                 f.TripID,
 			SegmentID:          segment.ID,
 			ConfirmationNumber: confirmationNumber,
+			AirlineCode:        airlineCode,
+			Status:             segment.Status.FlightStatus,
+			ScheduledArrival:   scheduledArrival,
+			EstimatedArrival:   estimatedArrival,
+			DivertedTo:         segment.Status.DivertedAirportCode,
+			Terminal:           firstNonEmpty(segment.Status.DepartureTerminal, segment.StartTerminal),
+			Gate:               firstNonEmpty(segment.Status.DepartureGate, segment.StartGate),
+			ServiceClass:       segment.ServiceClass,
 		})
 	}
 
 	return events, nil
 }
+
+// firstNonEmpty returns the first of the values that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if len(v) > 0 {
+			return v
+		}
+	}
+	return ""
+}
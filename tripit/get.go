@@ -1,13 +1,14 @@
 package tripit
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 )
 
 // GetActivity returns the specific activity for the given id.
-func (c *Client) GetActivity(id string, filters ...Filter) (Activity, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeActivity, id, formatFilters(filters)), nil)
+func (c *Client) GetActivity(ctx context.Context, id string, filters ...Filter) (Activity, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeActivity, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Activity{}, err
 	}
@@ -22,8 +23,8 @@ func (c *Client) GetActivity(id string, filters ...Filter) (Activity, error) {
 }
 
 // GetCar returns the specific car for the given id.
-func (c *Client) GetCar(id string, filters ...Filter) (Car, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeCar, id, formatFilters(filters)), nil)
+func (c *Client) GetCar(ctx context.Context, id string, filters ...Filter) (Car, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeCar, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Car{}, err
 	}
@@ -38,8 +39,8 @@ func (c *Client) GetCar(id string, filters ...Filter) (Car, error) {
 }
 
 // GetCruise returns the specific cruise for the given id.
-func (c *Client) GetCruise(id string, filters ...Filter) (Cruise, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeCruise, id, formatFilters(filters)), nil)
+func (c *Client) GetCruise(ctx context.Context, id string, filters ...Filter) (Cruise, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeCruise, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Cruise{}, err
 	}
@@ -54,8 +55,8 @@ func (c *Client) GetCruise(id string, filters ...Filter) (Cruise, error) {
 }
 
 // GetDirections returns the specific directions for the given id.
-func (c *Client) GetDirections(id string, filters ...Filter) (Direction, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeDirections, id, formatFilters(filters)), nil)
+func (c *Client) GetDirections(ctx context.Context, id string, filters ...Filter) (Direction, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeDirections, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Direction{}, err
 	}
@@ -70,8 +71,8 @@ func (c *Client) GetDirections(id string, filters ...Filter) (Direction, error)
 }
 
 // GetFlight returns the specific flight for the given id.
-func (c *Client) GetFlight(id string, filters ...Filter) (Flight, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeFlight, id, formatFilters(filters)), nil)
+func (c *Client) GetFlight(ctx context.Context, id string, filters ...Filter) (Flight, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeFlight, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Flight{}, err
 	}
@@ -86,8 +87,8 @@ func (c *Client) GetFlight(id string, filters ...Filter) (Flight, error) {
 }
 
 // GetLodging returns the specific lodging for the given id.
-func (c *Client) GetLodging(id string, filters ...Filter) (Lodging, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeLodging, id, formatFilters(filters)), nil)
+func (c *Client) GetLodging(ctx context.Context, id string, filters ...Filter) (Lodging, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeLodging, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Lodging{}, err
 	}
@@ -102,8 +103,8 @@ func (c *Client) GetLodging(id string, filters ...Filter) (Lodging, error) {
 }
 
 // GetMap returns the specific map for the given id.
-func (c *Client) GetMap(id string, filters ...Filter) (Map, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeMap, id, formatFilters(filters)), nil)
+func (c *Client) GetMap(ctx context.Context, id string, filters ...Filter) (Map, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeMap, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Map{}, err
 	}
@@ -118,8 +119,8 @@ func (c *Client) GetMap(id string, filters ...Filter) (Map, error) {
 }
 
 // GetNote returns the specific note for the given id.
-func (c *Client) GetNote(id string, filters ...Filter) (Note, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeNote, id, formatFilters(filters)), nil)
+func (c *Client) GetNote(ctx context.Context, id string, filters ...Filter) (Note, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeNote, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Note{}, err
 	}
@@ -134,8 +135,8 @@ func (c *Client) GetNote(id string, filters ...Filter) (Note, error) {
 }
 
 // GetPointsProgram returns the specific points program for the given id.
-func (c *Client) GetPointsProgram(id string, filters ...Filter) (PointsProgram, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypePointsProgram, id, formatFilters(filters)), nil)
+func (c *Client) GetPointsProgram(ctx context.Context, id string, filters ...Filter) (PointsProgram, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypePointsProgram, id, formatFilters(filters)), nil)
 	if err != nil {
 		return PointsProgram{}, err
 	}
@@ -150,8 +151,8 @@ func (c *Client) GetPointsProgram(id string, filters ...Filter) (PointsProgram,
 }
 
 // GetProfile returns the specific profile for the given id.
-func (c *Client) GetProfile(id string, filters ...Filter) (Profile, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeProfile, id, formatFilters(filters)), nil)
+func (c *Client) GetProfile(ctx context.Context, id string, filters ...Filter) (Profile, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeProfile, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Profile{}, err
 	}
@@ -166,8 +167,8 @@ func (c *Client) GetProfile(id string, filters ...Filter) (Profile, error) {
 }
 
 // GetRail returns the specific rail for the given id.
-func (c *Client) GetRail(id string, filters ...Filter) (Rail, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeRail, id, formatFilters(filters)), nil)
+func (c *Client) GetRail(ctx context.Context, id string, filters ...Filter) (Rail, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeRail, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Rail{}, err
 	}
@@ -182,8 +183,8 @@ func (c *Client) GetRail(id string, filters ...Filter) (Rail, error) {
 }
 
 // GetRestaurant returns the specific restaurant for the given id.
-func (c *Client) GetRestaurant(id string, filters ...Filter) (Restaurant, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeRestaurant, id, formatFilters(filters)), nil)
+func (c *Client) GetRestaurant(ctx context.Context, id string, filters ...Filter) (Restaurant, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeRestaurant, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Restaurant{}, err
 	}
@@ -198,8 +199,8 @@ func (c *Client) GetRestaurant(id string, filters ...Filter) (Restaurant, error)
 }
 
 // GetTransport returns the specific transport for the given id.
-func (c *Client) GetTransport(id string, filters ...Filter) (Transport, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeTransport, id, formatFilters(filters)), nil)
+func (c *Client) GetTransport(ctx context.Context, id string, filters ...Filter) (Transport, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeTransport, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Transport{}, err
 	}
@@ -214,8 +215,8 @@ func (c *Client) GetTransport(id string, filters ...Filter) (Transport, error) {
 }
 
 // GetTrip returns the specific trip for the given id.
-func (c *Client) GetTrip(id string, filters ...Filter) (Trip, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeTrip, id, formatFilters(filters)), nil)
+func (c *Client) GetTrip(ctx context.Context, id string, filters ...Filter) (Trip, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeTrip, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Trip{}, err
 	}
@@ -230,8 +231,8 @@ func (c *Client) GetTrip(id string, filters ...Filter) (Trip, error) {
 }
 
 // GetWeather returns the specific weather information for the given id.
-func (c *Client) GetWeather(id string, filters ...Filter) (Weather, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeWeather, id, formatFilters(filters)), nil)
+func (c *Client) GetWeather(ctx context.Context, id string, filters ...Filter) (Weather, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeWeather, id, formatFilters(filters)), nil)
 	if err != nil {
 		return Weather{}, err
 	}
@@ -244,3 +245,19 @@ func (c *Client) GetWeather(id string, filters ...Filter) (Weather, error) {
 	// Return the object.
 	return resp.Weather[0], nil
 }
+
+// GetTripWithObjects returns the response for the given trip id, which holds
+// the trip along with all of its objects.
+func (c *Client) GetTripWithObjects(ctx context.Context, id string) (*Response, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf(EndpointFormatGetObject, TypeTrip, id, formatFilters([]Filter{{Type: FilterIncludeObjects, Value: "true"}})), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if we didn't get a result and return an error if true.
+	if len(resp.Trips) <= 0 {
+		return nil, fmt.Errorf("get trip id %s returned an empty result", id)
+	}
+
+	return resp, nil
+}
@@ -1,26 +1,110 @@
 package tripit
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 )
 
 // ListTrips returns a list of trips and other object data depending on the filters passed.
-func (c *Client) ListTrips(filters ...Filter) (*Response, error) {
-	return c.doRequest(http.MethodGet, fmt.Sprintf("%s/%s", ListTripsEndpoint, formatFilters(filters)), nil)
+func (c *Client) ListTrips(ctx context.Context, filters ...Filter) (*Response, error) {
+	return c.doRequest(ctx, http.MethodGet, fmt.Sprintf("%s/%s", ListTripsEndpoint, formatFilters(filters)), nil)
+}
+
+// ListAllTrips returns the trips and other object data of every page, up to
+// maxPages of them, depending on the filters passed. Zero maxPages gets
+// every page. The response is marked Truncated if there were more pages.
+func (c *Client) ListAllTrips(ctx context.Context, maxPages int, filters ...Filter) (*Response, error) {
+	return c.listAll(ctx, ListTripsEndpoint, maxPages, filters)
 }
 
 // ListObjects returns a list of objects and other data depending on the filters passed.
-func (c *Client) ListObjects(filters ...Filter) (*Response, error) {
-	return c.doRequest(http.MethodGet, fmt.Sprintf("%s/%s", ListObjectsEndpoint, formatFilters(filters)), nil)
+func (c *Client) ListObjects(ctx context.Context, filters ...Filter) (*Response, error) {
+	return c.doRequest(ctx, http.MethodGet, fmt.Sprintf("%s/%s", ListObjectsEndpoint, formatFilters(filters)), nil)
+}
+
+// ListAllObjects returns the objects and other data of every page, up to
+// maxPages of them, depending on the filters passed. Zero maxPages gets every
+// page. The response is marked Truncated if there were more pages.
+func (c *Client) ListAllObjects(ctx context.Context, maxPages int, filters ...Filter) (*Response, error) {
+	return c.listAll(ctx, ListObjectsEndpoint, maxPages, filters)
 }
 
 // ListPointsPrograms returns a list of points programs depending on the filters passed.
-func (c *Client) ListPointsPrograms(filters ...Filter) ([]PointsProgram, error) {
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf("%s/%s", ListPointsProgramsEndpoint, formatFilters(filters)), nil)
+func (c *Client) ListPointsPrograms(ctx context.Context, filters ...Filter) ([]PointsProgram, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf("%s/%s", ListPointsProgramsEndpoint, formatFilters(filters)), nil)
 	if err != nil {
 		return nil, err
 	}
 
 	return resp.PointsPrograms, nil
 }
+
+// listAll requests the pages of the list endpoint one after another and
+// merges them into a single response. A page number in the filters is
+// replaced with the page we ask for.
+func (c *Client) listAll(ctx context.Context, endpoint string, maxPages int, filters []Filter) (*Response, error) {
+	var query []Filter
+	for _, f := range filters {
+		if f.Type != FilterPageNum {
+			query = append(query, f)
+		}
+	}
+
+	all := &Response{}
+	for page := 1; ; page++ {
+		resp, err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf("%s/%s", endpoint, formatFilters(append(query, Filter{
+			Type:  FilterPageNum,
+			Value: strconv.Itoa(page),
+		}))), nil)
+		if err != nil {
+			return nil, fmt.Errorf("getting page %d failed: %w", page, err)
+		}
+		all.merge(resp)
+
+		// TripIt leaves out the pages when there is nothing to list.
+		if len(resp.MaxPage) < 1 {
+			return all, nil
+		}
+		maxPage, err := strconv.Atoi(resp.MaxPage)
+		if err != nil {
+			return nil, fmt.Errorf("parsing max_page %q failed: %v", resp.MaxPage, err)
+		}
+		if page >= maxPage {
+			return all, nil
+		}
+		if maxPages > 0 && page >= maxPages {
+			all.Truncated = true
+			return all, nil
+		}
+	}
+}
+
+// merge adds the objects of another page of a list to the response.
+func (r *Response) merge(page *Response) {
+	r.Timestamp = page.Timestamp
+	r.NumBytes += page.NumBytes
+	r.Errors = append(r.Errors, page.Errors...)
+	r.Warnings = append(r.Warnings, page.Warnings...)
+
+	r.Activities = append(r.Activities, page.Activities...)
+	r.Flights = append(r.Flights, page.Flights...)
+	r.Cars = append(r.Cars, page.Cars...)
+	r.Cruises = append(r.Cruises, page.Cruises...)
+	r.Directions = append(r.Directions, page.Directions...)
+	r.Lodging = append(r.Lodging, page.Lodging...)
+	r.Maps = append(r.Maps, page.Maps...)
+	r.Notes = append(r.Notes, page.Notes...)
+	r.Rails = append(r.Rails, page.Rails...)
+	r.Restaurants = append(r.Restaurants, page.Restaurants...)
+	r.Transports = append(r.Transports, page.Transports...)
+	r.Trips = append(r.Trips, page.Trips...)
+	r.Weather = append(r.Weather, page.Weather...)
+	r.PointsPrograms = append(r.PointsPrograms, page.PointsPrograms...)
+	r.Profiles = append(r.Profiles, page.Profiles...)
+
+	r.PageNum = page.PageNum
+	r.PageSize = page.PageSize
+	r.MaxPage = page.MaxPage
+}
@@ -0,0 +1,98 @@
+package tripit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const lodgingDescriptionFormat = `[Lodging] %s
+%s
+
+Check-in: %s
+Check-out: %s
+
+Booking Site (%s) Confirmation # %s
+Supplier (%s) Confirmation # %s
+Phone: %s
+
+Room: %s
+
+View and/or edit details of this stay [%s]: https://www.tripit.com/%s
+
+View and/or edit details of this trip: https://www.tripit.com/trip/show/id/%s`
+
+// String returns the address on a single line.
+func (a Address) String() string {
+	if len(a.Address) > 0 {
+		return a.Address
+	}
+	var parts []string
+	for _, p := range []string{a.Addr1, a.Addr2, a.City, strings.TrimSpace(a.State + " " + a.Zip), a.Country} {
+		if len(p) > 0 {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GetLodgingAsEvents returns an all-day Event for the stay in the given
+// lodging object, from the day of check-in through the day of check-out.
+func (l Lodging) GetLodgingAsEvents() ([]Event, error) {
+	if len(l.StartDateTime.Date) < 1 || len(l.EndDateTime.Date) < 1 {
+		return nil, fmt.Errorf("lodging for tripID -> %s, id -> %s has no check-in or check-out date", l.TripID, l.ID)
+	}
+	checkIn, err := time.Parse("2006-01-02", l.StartDateTime.Date)
+	if err != nil {
+		return nil, fmt.Errorf("parsing StartDateTime for tripID -> %s, lodging -> %s failed: %v", l.TripID, l.ID, err)
+	}
+	checkOut, err := time.Parse("2006-01-02", l.EndDateTime.Date)
+	if err != nil {
+		return nil, fmt.Errorf("parsing EndDateTime for tripID -> %s, lodging -> %s failed: %v", l.TripID, l.ID, err)
+	}
+
+	name := firstNonEmpty(l.SupplierName, l.DisplayName)
+	address := l.Address.String()
+
+	var confirmationNumber string
+	if l.SupplierConfNum != "" {
+		confirmationNumber = l.SupplierConfNum
+	} else if l.BookingSiteConfNum != "" {
+		confirmationNumber = l.BookingSiteConfNum
+	}
+
+	description := fmt.Sprintf(lodgingDescriptionFormat,
+		name,
+		address,
+		strings.TrimSpace(l.StartDateTime.Date+" "+l.StartDateTime.Time),
+		strings.TrimSpace(l.EndDateTime.Date+" "+l.EndDateTime.Time),
+		l.BookingSiteName,
+		l.BookingSiteConfNum,
+		l.SupplierName,
+		l.SupplierConfNum,
+		l.SupplierPhone,
+		l.RoomType,
+		l.ID,
+		strings.TrimPrefix(l.RelativeURL, "/"),
+		l.TripID)
+
+	// All-day events end the day after their last day.
+	start := calendar.EventDateTime{Date: checkIn.Format("2006-01-02")}
+	end := calendar.EventDateTime{Date: checkOut.AddDate(0, 0, 1).Format("2006-01-02")}
+
+	return []Event{{
+		Type:               EventTypeLodging,
+		Title:              "Stay at " + name,
+		Description:        description,
+		Start:              start,
+		End:                end,
+		ID:                 l.TripID,
+		SegmentID:          l.ID,
+		ConfirmationNumber: confirmationNumber,
+		DestinationCity:    l.Address.City,
+		Location:           address,
+		AllDay:             true,
+	}}, nil
+}
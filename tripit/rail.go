@@ -0,0 +1,101 @@
+package tripit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const railDescriptionFormat = `[Rail] %s to %s
+%s
+
+Booking Site (%s) Confirmation # %s
+Supplier (%s) Confirmation # %s
+Record Locator # %s
+
+Train: %s %s %s
+Class: %s
+Coach: %s Seats: %s
+
+Arrive -> %s
+%s
+
+View and/or edit details of this train [%s]: https://www.tripit.com/%s
+
+View and/or edit details of this trip: https://www.tripit.com/trip/show/id/%s`
+
+// GetRailSegmentsAsEvents returns an Event for each of the rail segments in
+// the given rail object.
+func (r Rail) GetRailSegmentsAsEvents() ([]Event, error) {
+	events := []Event{}
+
+	for _, segment := range r.Segments {
+		startDate, err := segment.StartDateTime.Parse()
+		if err != nil {
+			return nil, fmt.Errorf("parsing StartDateTime for tripID -> %s, rail segment -> %s, from %s -> %s failed: %v", r.TripID, segment.ID, segment.StartStationName, segment.EndStationName, err)
+		}
+		endDate, err := segment.EndDateTime.Parse()
+		if err != nil {
+			return nil, fmt.Errorf("parsing EndDateTime for tripID -> %s, rail segment -> %s, from %s -> %s failed: %v", r.TripID, segment.ID, segment.StartStationName, segment.EndStationName, err)
+		}
+
+		carrier := firstNonEmpty(segment.CarrierName, r.SupplierName)
+		train := strings.TrimSpace(carrier + " " + segment.TrainNumber)
+
+		description := fmt.Sprintf(railDescriptionFormat,
+			segment.StartStationName,
+			segment.EndStationName,
+			startDate.Format(time.RFC1123Z),
+			r.BookingSiteName,
+			r.BookingSiteConfNum,
+			r.SupplierName,
+			r.SupplierConfNum,
+			r.RecordLocator,
+			carrier,
+			segment.TrainType,
+			segment.TrainNumber,
+			segment.ServiceClass,
+			segment.CoachNumber,
+			segment.Seats,
+			segment.EndStationName,
+			endDate.Format(time.RFC1123Z),
+			segment.ID,
+			strings.TrimPrefix(r.RelativeURL, "/"),
+			r.TripID)
+
+		confirmationNumber := firstNonEmpty(segment.ConfirmationNum, r.SupplierConfNum, r.BookingSiteConfNum)
+
+		title := "Train to " + firstNonEmpty(segment.EndStationName, segment.EndStationAddress.City)
+		if len(train) > 0 {
+			title += " (" + train + ")"
+		}
+
+		location := segment.StartStationName
+		if address := segment.StartStationAddress.String(); len(address) > 0 {
+			location = strings.TrimPrefix(location+", "+address, ", ")
+		}
+
+		events = append(events, Event{
+			Type:        EventTypeRail,
+			Title:       title,
+			Description: description,
+			Start: calendar.EventDateTime{
+				DateTime: startDate.Format(time.RFC3339),
+				TimeZone: segment.StartDateTime.Timezone,
+			},
+			End: calendar.EventDateTime{
+				DateTime: endDate.Format(time.RFC3339),
+				TimeZone: segment.EndDateTime.Timezone,
+			},
+			ID:                 r.TripID,
+			SegmentID:          segment.ID,
+			ConfirmationNumber: confirmationNumber,
+			DestinationCity:    segment.EndStationAddress.City,
+			Location:           location,
+		})
+	}
+
+	return events, nil
+}
@@ -0,0 +1,87 @@
+package tripit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const (
+	restaurantDescriptionFormat = `[Restaurant] %s
+%s
+
+Booking Site (%s) Confirmation # %s
+Supplier (%s) Confirmation # %s
+Phone: %s
+
+Party of %s
+Cuisine: %s
+Dress code: %s
+
+View and/or edit details of this reservation [%s]: https://www.tripit.com/%s
+
+View and/or edit details of this trip: https://www.tripit.com/trip/show/id/%s`
+
+	// restaurantEventDuration is how long we block for a reservation, since
+	// TripIt only knows when it starts.
+	restaurantEventDuration = 2 * time.Hour
+)
+
+// GetRestaurantAsEvents returns an Event for the reservation in the given
+// restaurant object.
+func (r Restaurant) GetRestaurantAsEvents() ([]Event, error) {
+	start, err := r.DateTime.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("parsing DateTime for tripID -> %s, restaurant -> %s failed: %v", r.TripID, r.ID, err)
+	}
+
+	name := firstNonEmpty(r.SupplierName, r.DisplayName)
+	address := r.Address.String()
+
+	description := fmt.Sprintf(restaurantDescriptionFormat,
+		name,
+		address,
+		r.BookingSiteName,
+		r.BookingSiteConfNum,
+		r.SupplierName,
+		r.SupplierConfNum,
+		r.SupplierPhone,
+		r.NumberPatrons,
+		r.Cuisine,
+		r.DressCode,
+		r.ID,
+		strings.TrimPrefix(r.RelativeURL, "/"),
+		r.TripID)
+
+	return []Event{{
+		Type:        EventTypeRestaurant,
+		Title:       meal(start) + " at " + name,
+		Description: description,
+		Start: calendar.EventDateTime{
+			DateTime: start.Format(time.RFC3339),
+			TimeZone: r.DateTime.Timezone,
+		},
+		End: calendar.EventDateTime{
+			DateTime: start.Add(restaurantEventDuration).Format(time.RFC3339),
+			TimeZone: r.DateTime.Timezone,
+		},
+		ID:                 r.TripID,
+		SegmentID:          r.ID,
+		ConfirmationNumber: firstNonEmpty(r.SupplierConfNum, r.BookingSiteConfNum),
+		DestinationCity:    r.Address.City,
+		Location:           strings.TrimSuffix(name+", "+address, ", "),
+	}}, nil
+}
+
+// meal returns the meal a reservation at t is most likely for.
+func meal(t time.Time) string {
+	switch h := t.Hour(); {
+	case h < 11:
+		return "Breakfast"
+	case h < 16:
+		return "Lunch"
+	}
+	return "Dinner"
+}
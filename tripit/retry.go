@@ -0,0 +1,124 @@
+package tripit
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy says how to retry requests that fail with a network error, a
+// server error TripIt may recover from, or because TripIt is rate limiting
+// us, in which case we wait at least as long as it asks. Only GET requests are retried, since
+// retrying a create could create the object twice.
+type RetryPolicy struct {
+	// MaxAttempts is the most times a request is made, one or less means
+	// it is not retried.
+	MaxAttempts int
+	// Backoff is how long to wait before the first retry. The wait doubles
+	// with every retry, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter is the fraction of every wait, from zero to one, that is
+	// random, so clients that failed together do not retry together.
+	Jitter float64
+}
+
+// DefaultRetryPolicy is the retry policy of new clients.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	Backoff:     time.Second,
+	MaxBackoff:  30 * time.Second,
+	Jitter:      0.5,
+}
+
+// SetRetryPolicy sets how the client retries failed requests.
+func (c *Client) SetRetryPolicy(p RetryPolicy) {
+	c.retry = p
+}
+
+// OnThrottle sets a function that is called with how long TripIt asked us to
+// wait, zero if it did not say, every time it rate limits a request.
+func (c *Client) OnThrottle(f func(retryAfter time.Duration)) {
+	c.onThrottle = f
+}
+
+// OnRequest sets a function that is called after every request to TripIt,
+// retries included, with its status code, zero if it failed without one, and
+// how long it took.
+func (c *Client) OnRequest(f func(method string, statusCode int, took time.Duration)) {
+	c.onRequest = f
+}
+
+// IsRateLimited returns true if the error is TripIt rate limiting us, and
+// how long it asked us to wait if it did.
+func IsRateLimited(err error) (time.Duration, bool) {
+	var e *APIError
+	if !errors.As(err, &e) || e.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	return e.RetryAfter, true
+}
+
+// parseRetryAfter parses a Retry-After header, either seconds or an HTTP
+// date, into how long to wait from now.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if len(header) < 1 {
+		return 0
+	}
+	if secs, err := strconv.Atoi(header); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// delay returns how long to wait before the retry after the attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(p.Jitter * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// retryable returns true if the request may succeed if made again.
+func retryable(ctx context.Context, method string, resp *http.Response, err error) bool {
+	if method != http.MethodGet || ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// sleep waits for d, or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+package tripit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.July, 10, 12, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		header string
+		want   time.Duration
+	}{
+		{header: "", want: 0},
+		{header: "0", want: 0},
+		{header: "120", want: 2 * time.Minute},
+		{header: "-5", want: 0},
+		{header: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second},
+		{header: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+		{header: "soon", want: 0},
+	}
+
+	for _, tc := range testcases {
+		if got := parseRetryAfter(tc.header, now); got != tc.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	testcases := []struct {
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{policy: RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}, attempt: 1, want: time.Second},
+		{policy: RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}, attempt: 2, want: 2 * time.Second},
+		{policy: RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}, attempt: 3, want: 4 * time.Second},
+		{policy: RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}, attempt: 4, want: 5 * time.Second},
+		{policy: RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}, attempt: 40, want: 5 * time.Second},
+		{policy: RetryPolicy{Backoff: time.Second}, attempt: 5, want: 16 * time.Second},
+	}
+
+	for _, tc := range testcases {
+		if got := tc.policy.delay(tc.attempt); got != tc.want {
+			t.Errorf("%+v.delay(%d) = %s, want %s", tc.policy, tc.attempt, got, tc.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelayJitter(t *testing.T) {
+	p := RetryPolicy{Backoff: 4 * time.Second, MaxBackoff: 30 * time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if got := p.delay(1); got < 2*time.Second || got > 4*time.Second {
+			t.Fatalf("delay(1) with jitter 0.5 = %s, want between 2s and 4s", got)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testcases := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		status int
+		err    error
+		want   bool
+	}{
+		{name: "network error", ctx: context.Background(), method: http.MethodGet, err: errors.New("connection reset"), want: true},
+		{name: "rate limited", ctx: context.Background(), method: http.MethodGet, status: http.StatusTooManyRequests, want: true},
+		{name: "server error", ctx: context.Background(), method: http.MethodGet, status: http.StatusServiceUnavailable, want: true},
+		{name: "not found", ctx: context.Background(), method: http.MethodGet, status: http.StatusNotFound, want: false},
+		{name: "unauthorized", ctx: context.Background(), method: http.MethodGet, status: http.StatusUnauthorized, want: false},
+		{name: "create", ctx: context.Background(), method: http.MethodPost, status: http.StatusServiceUnavailable, want: false},
+		{name: "canceled", ctx: canceled, method: http.MethodGet, err: errors.New("connection reset"), want: false},
+	}
+
+	for _, tc := range testcases {
+		var resp *http.Response
+		if tc.err == nil {
+			resp = &http.Response{StatusCode: tc.status}
+		}
+		if got := retryable(tc.ctx, tc.method, resp, tc.err); got != tc.want {
+			t.Errorf("%s: retryable = %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestIsRateLimited(t *testing.T) {
+	testcases := []struct {
+		name      string
+		err       error
+		wantAfter time.Duration
+		want      bool
+	}{
+		{name: "rate limited", err: &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}, wantAfter: time.Minute, want: true},
+		{name: "wrapped", err: fmt.Errorf("listing trips failed: %w", &APIError{StatusCode: http.StatusTooManyRequests}), want: true},
+		{name: "server error", err: &APIError{StatusCode: http.StatusInternalServerError}, want: false},
+		{name: "other error", err: errors.New("connection reset"), want: false},
+	}
+
+	for _, tc := range testcases {
+		after, ok := IsRateLimited(tc.err)
+		if ok != tc.want || after != tc.wantAfter {
+			t.Errorf("%s: IsRateLimited = %s, %t, want %s, %t", tc.name, after, ok, tc.wantAfter, tc.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package tripit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const (
+	transportDescriptionFormat = `[Transport] %s to %s
+Pick up: %s
+%s
+
+Booking Site (%s) Confirmation # %s
+Supplier (%s) Confirmation # %s
+Phone: %s
+
+Carrier: %s
+Vehicle: %s
+Passengers: %s
+
+Drop off -> %s
+
+View and/or edit details of this transfer [%s]: https://www.tripit.com/%s
+
+View and/or edit details of this trip: https://www.tripit.com/trip/show/id/%s`
+
+	// transportEventDuration is how long we block for a transfer TripIt does
+	// not know the end of.
+	transportEventDuration = time.Hour
+)
+
+// GetTransportSegmentsAsEvents returns an Event for each of the segments in
+// the given transport object, like airport shuttles, private transfers, car
+// services, and ferries.
+func (t Transport) GetTransportSegmentsAsEvents() ([]Event, error) {
+	events := []Event{}
+
+	for _, segment := range t.Segments {
+		startDate, err := segment.StartDateTime.Parse()
+		if err != nil {
+			return nil, fmt.Errorf("parsing StartDateTime for tripID -> %s, transport segment -> %s, from %s -> %s failed: %v", t.TripID, segment.ID, segment.StartLocationName, segment.EndLocationName, err)
+		}
+		endDate := startDate.Add(transportEventDuration)
+		endTimezone := segment.StartDateTime.Timezone
+		if len(segment.EndDateTime.Date) > 0 && len(segment.EndDateTime.Time) > 0 {
+			if d, err := segment.EndDateTime.Parse(); err == nil && d.After(startDate) {
+				endDate, endTimezone = d, segment.EndDateTime.Timezone
+			}
+		}
+
+		from := firstNonEmpty(segment.StartLocationName, segment.StartLocationAddress.String())
+		to := firstNonEmpty(segment.EndLocationName, segment.EndLocationAddress.String())
+		carrier := firstNonEmpty(segment.CarrierName, t.SupplierName)
+
+		description := fmt.Sprintf(transportDescriptionFormat,
+			from,
+			to,
+			startDate.Format(time.RFC1123Z),
+			segment.StartLocationAddress.String(),
+			t.BookingSiteName,
+			t.BookingSiteConfNum,
+			t.SupplierName,
+			t.SupplierConfNum,
+			t.SupplierPhone,
+			carrier,
+			segment.VehicleDescription,
+			segment.NumberPassengers,
+			segment.EndLocationAddress.String(),
+			segment.ID,
+			strings.TrimPrefix(t.RelativeURL, "/"),
+			t.TripID)
+
+		kind := "Transfer"
+		if segment.DetailTypeCode == TransportDetailTypeFerry {
+			kind = "Ferry"
+		}
+		title := kind
+		if len(to) > 0 {
+			title += " to " + to
+		}
+		if len(carrier) > 0 {
+			title += " (" + carrier + ")"
+		}
+
+		location := segment.StartLocationName
+		if address := segment.StartLocationAddress.String(); len(address) > 0 && address != location {
+			location = strings.TrimPrefix(location+", "+address, ", ")
+		}
+
+		events = append(events, Event{
+			Type:        EventTypeTransport,
+			Title:       title,
+			Description: description,
+			Start: calendar.EventDateTime{
+				DateTime: startDate.Format(time.RFC3339),
+				TimeZone: segment.StartDateTime.Timezone,
+			},
+			End: calendar.EventDateTime{
+				DateTime: endDate.Format(time.RFC3339),
+				TimeZone: endTimezone,
+			},
+			ID:                 t.TripID,
+			SegmentID:          segment.ID,
+			ConfirmationNumber: firstNonEmpty(segment.ConfirmationNum, t.SupplierConfNum, t.BookingSiteConfNum),
+			DestinationCity:    segment.EndLocationAddress.City,
+			Location:           location,
+		})
+	}
+
+	return events, nil
+}
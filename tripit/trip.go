@@ -0,0 +1,59 @@
+package tripit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const tripDescriptionFormat = `[Trip] %s
+%s
+
+%s to %s
+
+View and/or edit details of this trip: https://www.tripit.com/trip/show/id/%s`
+
+// GetTripAsEvents returns an all-day Event spanning the whole of the given
+// trip, like the banners in TripIt's own calendar feed.
+func (t Trip) GetTripAsEvents() ([]Event, error) {
+	if len(t.StartDate) < 1 || len(t.EndDate) < 1 {
+		return nil, fmt.Errorf("trip -> %s has no start or end date", t.ID)
+	}
+	start, err := time.Parse("2006-01-02", t.StartDate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing start_date for tripID -> %s failed: %v", t.ID, err)
+	}
+	end, err := time.Parse("2006-01-02", t.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing end_date for tripID -> %s failed: %v", t.ID, err)
+	}
+
+	location := firstNonEmpty(t.PrimaryLocation, t.PrimaryLocationAddress.String())
+	title := firstNonEmpty(t.DisplayName, "Trip to "+location)
+
+	description := fmt.Sprintf(tripDescriptionFormat,
+		title,
+		location,
+		start.Format("Mon Jan 2, 2006"),
+		end.Format("Mon Jan 2, 2006"),
+		t.ID)
+	if d := strings.TrimSpace(t.Description); len(d) > 0 {
+		description = strings.Replace(description, "\n\n", "\n\n"+d+"\n\n", 1)
+	}
+
+	return []Event{{
+		Type:        EventTypeTrip,
+		Title:       title,
+		Description: description,
+		// All-day events end the day after their last day.
+		Start:           calendar.EventDateTime{Date: start.Format("2006-01-02")},
+		End:             calendar.EventDateTime{Date: end.AddDate(0, 0, 1).Format("2006-01-02")},
+		ID:              t.ID,
+		SegmentID:       "trip-" + t.ID,
+		DestinationCity: t.PrimaryLocationAddress.City,
+		Location:        location,
+		AllDay:          true,
+	}}, nil
+}
@@ -2,12 +2,14 @@ package tripit
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -16,6 +18,37 @@ import (
 type Client struct {
 	username string
 	password string
+	retry    RetryPolicy
+
+	onThrottle func(retryAfter time.Duration)
+	onRequest  func(method string, statusCode int, took time.Duration)
+}
+
+// String keeps the password out of anything that prints the client.
+func (c *Client) String() string {
+	return fmt.Sprintf("tripit.Client{username: %q, password: <redacted>}", c.username)
+}
+
+// GoString keeps the password out of anything that prints the client with %#v.
+func (c *Client) GoString() string {
+	return c.String()
+}
+
+// APIError is returned when the TripIt API responds with a status code other than OK.
+type APIError struct {
+	Method     string
+	URI        string
+	StatusCode int
+	Message    string
+	Body       string
+	// RetryAfter is how long TripIt asked us to wait before trying again,
+	// if it did.
+	RetryAfter time.Duration
+}
+
+// Error returns the string representation of the error.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s request to %s returned status code %d: message -> %s\nbody -> %s", e.Method, e.URI, e.StatusCode, e.Message, e.Body)
 }
 
 // New creates a new TripIt API client.
@@ -23,10 +56,11 @@ func New(username, password string) *Client {
 	return &Client{
 		username: username,
 		password: password,
+		retry:    DefaultRetryPolicy,
 	}
 }
 
-func (c *Client) doRequest(method, endpoint string, data interface{}) (*Response, error) {
+func (c *Client) doRequest(ctx context.Context, method, endpoint string, data interface{}) (*Response, error) {
 	client := http.DefaultClient
 
 	// Encode data if we are passed an object.
@@ -39,20 +73,49 @@ func (c *Client) doRequest(method, endpoint string, data interface{}) (*Response
 		}
 	}
 
-	// Create the request.
 	uri := fmt.Sprintf("%s/%s/%s/format/json", APIUri, APIVersion, strings.Trim(endpoint, "/"))
-	req, err := http.NewRequest(method, uri, b)
-	if err != nil {
-		return nil, fmt.Errorf("creating %s request to %s failed: %v", method, uri, err)
-	}
 
-	// Set the basic auth credentials.
-	req.SetBasicAuth(c.username, c.password)
+	// Do the request, retrying network and server errors.
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		var err error
+		start := time.Now()
+		resp, err = c.send(ctx, client, method, uri, b.Bytes())
+		if c.onRequest != nil {
+			statusCode := 0
+			if err == nil {
+				statusCode = resp.StatusCode
+			}
+			c.onRequest(method, statusCode, time.Since(start))
+		}
+		var retryAfter time.Duration
+		if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			if c.onThrottle != nil {
+				c.onThrottle(retryAfter)
+			}
+		}
+		// Leave waits longer than our longest backoff to the caller, who
+		// can pause rather than hold up the request.
+		if attempt >= c.retry.MaxAttempts || !retryable(ctx, method, resp, err) || (c.retry.MaxBackoff > 0 && retryAfter > c.retry.MaxBackoff) {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("status code %d", resp.StatusCode)
+		}
 
-	// Do the request.
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("performing %s request to %s failed: %v", method, uri, err)
+		delay := c.retry.delay(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		logrus.Warnf("%s request to %s failed, retrying in %s: %v", method, uri, delay, err)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, fmt.Errorf("performing %s request to %s failed: %v", method, uri, err)
+		}
 	}
 	defer resp.Body.Close()
 
@@ -76,19 +139,23 @@ func (c *Client) doRequest(method, endpoint string, data interface{}) (*Response
 			message = "The OAuth Consumer is not yet confirmed. The most common situation in which this happens is when a new account that authorizes an API client hasn't been confirmed before the API client attempts to execute a read operation on the API (e.g. /v1/list/trip)."
 		case http.StatusNotFound: // 404
 			message = "Either the resource URL or the object the client was requesting either does not exist or the user the client was authenticated and does not have permission to operate on the object."
+		case http.StatusTooManyRequests: // 429
+			message = "The client made too many requests and TripIt is rate limiting it. Wait for Retry-After before trying again."
 		case http.StatusInternalServerError: // 500
 			message = "Something catastrophic happened while the TripIt platform was trying to complete the request. A 500 error is a pretty serious and catastrophic problem that should be reported to the TripIt engineering team through support@tripit.com."
 		case http.StatusServiceUnavailable: // 503
 			message = "The TripIt API is currently undergoing maintenance and is not available."
 		}
 
-		return nil, fmt.Errorf("%s request to %s returned status code %d: message -> %s\nbody -> %s", method, uri, resp.StatusCode, message, string(body))
+		return nil, &APIError{
+			Method:     method,
+			URI:        uri,
+			StatusCode: resp.StatusCode,
+			Message:    message,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
-	/*body, _ := ioutil.ReadAll(resp.Body)
-	var out bytes.Buffer
-	json.Indent(&out, body, "", "  ")
-	logrus.Fatalf("body: %s", string(body))*/
-
 	// Decode the response into a TripIt Response object.
 	var r Response
 	if err := decodeResponse(resp, &r); err != nil {
@@ -111,6 +178,26 @@ func (c *Client) doRequest(method, endpoint string, data interface{}) (*Response
 	return &r, nil
 }
 
+// send makes a single request.
+func (c *Client) send(ctx context.Context, client *http.Client, method, uri string, body []byte) (*http.Response, error) {
+	// Create the request.
+	req, err := http.NewRequest(method, uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s request to %s failed: %v", method, uri, err)
+	}
+	req = req.WithContext(ctx)
+
+	// Set the basic auth credentials.
+	req.SetBasicAuth(c.username, c.password)
+
+	// Do the request.
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("performing %s request to %s failed: %v", method, uri, err)
+	}
+	return resp, nil
+}
+
 func decodeResponse(resp *http.Response, v interface{}) error {
 	// Copy buffer and change "@attributes" to "_attributes" since the json package doesn't support "@".
 	buf := new(bytes.Buffer)
@@ -3,6 +3,7 @@ package tripit
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -51,6 +52,10 @@ type Response struct {
 	PageNum  string `json:"page_num,omitempty"`
 	PageSize string `json:"page_size,omitempty"`
 	MaxPage  string `json:"max_page,omitempty"`
+
+	// Truncated is set by ListAllTrips and ListAllObjects when they stopped
+	// at their maximum number of pages before the last.
+	Truncated bool `json:"-"`
 }
 
 // Error is returned from TripIt on error conditions.
@@ -219,36 +224,60 @@ func (p *Cruises) UnmarshalJSON(b []byte) error {
 
 // Cruise contains information about cruises.
 type Cruise struct {
-	ID                   string          `json:"id,omitempty"`                        // optional, read-only
-	TripID               string          `json:"trip_id,omitempty"`                   // optional
-	IsClientTraveler     bool            `json:"is_client_traveler,string,omitempty"` // optional, read-only
-	RelativeURL          string          `json:"relative_url,omitempty"`              // optional, read-only
-	DisplayName          string          `json:"display_name,omitempty"`              // optional
-	Images               []Image         `json:"Image,omitempty"`                     // optional
-	CancellationDateTime DateTime        `json:"CancellationDateTime,omitempty"`      // optional
-	BookingDate          string          `json:"booking_date,omitempty"`              // optional, xs:date
-	BookingRate          string          `json:"booking_rate,omitempty"`              // optional
-	BookingSiteConfNum   string          `json:"booking_site_conf_num,omitempty"`     // optional
-	BookingSiteName      string          `json:"booking_site_name,omitempty"`         // optional
-	BookingSitePhone     string          `json:"booking_site_phone,omitempty"`        // optional
-	BookingSiteURL       string          `json:"booking_site_url,omitempty"`          // optional
-	RecordLocator        string          `json:"record_locator,omitempty"`            // optional
-	SupplierConfNum      string          `json:"supplier_conf_num,omitempty"`         // optional
-	SupplierContact      string          `json:"supplier_contact,omitempty"`          // optional
-	SupplierEmailAddress string          `json:"supplier_email_address,omitempty"`    // optional
-	SupplierName         string          `json:"supplier_name,omitempty"`             // optional
-	SupplierPhone        string          `json:"supplier_phone,omitempty"`            // optional
-	SupplierURL          string          `json:"supplier_url,omitempty"`              // optional
-	IsPurchased          bool            `json:"is_purchased,string,omitempty"`       // optional
-	Notes                string          `json:"notes,omitempty"`                     // optional
-	Restrictions         string          `json:"restrictions,omitempty"`              // optional
-	TotalCost            string          `json:"total_cost,omitempty"`                // optional
-	Segments             []CruiseSegment `json:"Segment,omitempty"`
-	Travelers            Travelers       `json:"Traveler,omitempty"`     // optional
-	CabinNumber          string          `json:"cabin_number,omitempty"` // optional
-	CabinType            string          `json:"cabin_type,omitempty"`   // optional
-	Dining               string          `json:"dining,omitempty"`       // optional
-	ShipName             string          `json:"ship_name,omitempty"`    // optional
+	ID                   string         `json:"id,omitempty"`                        // optional, read-only
+	TripID               string         `json:"trip_id,omitempty"`                   // optional
+	IsClientTraveler     bool           `json:"is_client_traveler,string,omitempty"` // optional, read-only
+	RelativeURL          string         `json:"relative_url,omitempty"`              // optional, read-only
+	DisplayName          string         `json:"display_name,omitempty"`              // optional
+	Images               []Image        `json:"Image,omitempty"`                     // optional
+	CancellationDateTime DateTime       `json:"CancellationDateTime,omitempty"`      // optional
+	BookingDate          string         `json:"booking_date,omitempty"`              // optional, xs:date
+	BookingRate          string         `json:"booking_rate,omitempty"`              // optional
+	BookingSiteConfNum   string         `json:"booking_site_conf_num,omitempty"`     // optional
+	BookingSiteName      string         `json:"booking_site_name,omitempty"`         // optional
+	BookingSitePhone     string         `json:"booking_site_phone,omitempty"`        // optional
+	BookingSiteURL       string         `json:"booking_site_url,omitempty"`          // optional
+	RecordLocator        string         `json:"record_locator,omitempty"`            // optional
+	SupplierConfNum      string         `json:"supplier_conf_num,omitempty"`         // optional
+	SupplierContact      string         `json:"supplier_contact,omitempty"`          // optional
+	SupplierEmailAddress string         `json:"supplier_email_address,omitempty"`    // optional
+	SupplierName         string         `json:"supplier_name,omitempty"`             // optional
+	SupplierPhone        string         `json:"supplier_phone,omitempty"`            // optional
+	SupplierURL          string         `json:"supplier_url,omitempty"`              // optional
+	IsPurchased          bool           `json:"is_purchased,string,omitempty"`       // optional
+	Notes                string         `json:"notes,omitempty"`                     // optional
+	Restrictions         string         `json:"restrictions,omitempty"`              // optional
+	TotalCost            string         `json:"total_cost,omitempty"`                // optional
+	Segments             CruiseSegments `json:"Segment,omitempty"`
+	Travelers            Travelers      `json:"Traveler,omitempty"`     // optional
+	CabinNumber          string         `json:"cabin_number,omitempty"` // optional
+	CabinType            string         `json:"cabin_type,omitempty"`   // optional
+	Dining               string         `json:"dining,omitempty"`       // optional
+	ShipName             string         `json:"ship_name,omitempty"`    // optional
+}
+
+// CruiseSegments is a group of CruiseSegment objects.
+type CruiseSegments []CruiseSegment
+
+// UnmarshalJSON builds the vector from the JSON in b.
+func (p *CruiseSegments) UnmarshalJSON(b []byte) error {
+	var arr *[]CruiseSegment
+	arr = (*[]CruiseSegment)(p)
+	*arr = nil
+	err := json.Unmarshal(b, arr)
+	if err != nil {
+		*arr = make([]CruiseSegment, 1)
+		err := json.Unmarshal(b, &(*arr)[0])
+		if err != nil {
+			if err2, ok := err.(*json.UnmarshalTypeError); ok && err2.Value == "null" {
+				*arr = (*arr)[0:0]
+			} else {
+				return err
+			}
+		}
+
+	}
+	return nil
 }
 
 // CruiseSegment contains details about indivual cruise segments.
@@ -861,6 +890,36 @@ type Trip struct {
 	ClosenessMatches       ClosenessMatches `json:"ClosenessMatches,omitempty" xml:"ClosenessMatches"`                 // optional, ClosenessMatches are read-only
 	Invitees               Invitees         `json:"TripInvitees,omitempty" xml:"TripInvitees"`                         // optional, Invitees are read-only
 	Remarks                Remarks          `json:"TripCrsRemarks,omitempty" xml:"TripCrsRemarks"`                     // optional, Remarks are read-only
+	Purposes               TripPurposes     `json:"TripPurposes,omitempty" xml:"TripPurposes"`                         // optional
+}
+
+// TripPurposes holds the purpose of a trip.
+type TripPurposes struct {
+	PurposeTypeCode string `json:"purpose_type_code,omitempty" xml:"purpose_type_code"`        // optional, values: B (business), L (leisure)
+	IsAutoGenerated bool   `json:"is_auto_generated,string,omitempty" xml:"is_auto_generated"` // optional, read-only
+}
+
+// Tags returns the tags for the trip: its purpose and any #hashtags in its
+// description.
+func (t Trip) Tags() []string {
+	var tags []string
+	switch t.Purposes.PurposeTypeCode {
+	case "":
+	case "B":
+		tags = append(tags, "business")
+	case "L":
+		tags = append(tags, "leisure")
+	default:
+		tags = append(tags, strings.ToLower(t.Purposes.PurposeTypeCode))
+	}
+
+	for _, word := range strings.Fields(t.Description) {
+		if tag := strings.ToLower(strings.Trim(word, "#.,;:!?()")); strings.HasPrefix(word, "#") && len(tag) > 0 {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
 }
 
 // Weather contains information about the weather at a particular destination. Weather is read-only.
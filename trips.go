@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+)
+
+const tripsHelp = `List trips from TripIt.`
+
+const tripsLongHelp = `List trips from TripIt.
+
+Pass --when to only list the trips on at some point then, said the way you
+would say it: today, friday, next week, this month, in 2 weeks, the next 10
+days, last 30 days, july, 2024-07, or a range like 2024-07-01..2024-07-15.
+
+  tripitcalb0t trips list --when "next month"
+
+This only talks to TripIt, so it does not need a Google Calendar keyfile.`
+
+func (cmd *tripsCommand) Name() string      { return "trips" }
+func (cmd *tripsCommand) Args() string      { return "list" }
+func (cmd *tripsCommand) ShortHelp() string { return tripsHelp }
+func (cmd *tripsCommand) LongHelp() string  { return tripsLongHelp }
+func (cmd *tripsCommand) Hidden() bool      { return false }
+
+func (cmd *tripsCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.when, "when", "", "Only list the trips on then (ex. today, next week, july, 2024-07-01..2024-07-15)")
+}
+
+type tripsCommand struct {
+	when string
+}
+
+func (cmd *tripsCommand) Run(ctx context.Context, args []string) error {
+	if len(args) < 1 || args[0] != "list" {
+		return errors.New("usage: trips list")
+	}
+
+	// Flags may also come after the subcommand.
+	fs := flag.NewFlagSet("trips list", flag.ExitOnError)
+	fs.StringVar(&cmd.when, "when", cmd.when, "")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var from, to time.Time
+	pastFilter := fmt.Sprintf("%v", past)
+	if len(cmd.when) > 0 {
+		var err error
+		now := time.Now()
+		from, to, err = parseWhen(cmd.when, now)
+		if err != nil {
+			fatal(exitCodeConfig, err)
+		}
+		// TripIt only lists the trips that are over when asked to.
+		if from.Before(now) {
+			pastFilter = "true"
+		}
+	}
+
+	tripitClient, err := newTripItClient()
+	if err != nil {
+		fatal(exitCodeConfig, err)
+	}
+
+	trips, err := listTrips(ctx, tripitClient, pastFilter)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTART\tEND\tKIND\tNAME\tLOCATION")
+	for _, trip := range trips {
+		if len(cmd.when) > 0 && !tripDuring(trip, from, to) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", trip.ID, trip.StartDate, trip.EndDate, classifyTrip(trip), trip.DisplayName, trip.PrimaryLocation)
+	}
+	return w.Flush()
+}
+
+// tripDuring returns true if the trip is on for at least a day from from
+// until to.
+func tripDuring(trip tripit.Trip, from, to time.Time) bool {
+	// Trip dates are plain dates, so compare them as strings.
+	return trip.StartDate < to.Format("2006-01-02") && trip.EndDate >= from.Format("2006-01-02")
+}
+
+// listTrips returns the trips from TripIt without their objects.
+func listTrips(ctx context.Context, tripitClient *tripit.Client, pastFilter string) ([]tripit.Trip, error) {
+	resp, err := tripitClient.ListAllTrips(ctx, tripitMaxPages,
+		tripit.Filter{
+			Type:  tripit.FilterPast,
+			Value: pastFilter,
+		},
+		tripit.Filter{
+			Type:  tripit.FilterPageSize,
+			Value: "25",
+		})
+	if err != nil {
+		return nil, fmt.Errorf("listing trips from TripIt failed: %w", err)
+	}
+	if resp.Truncated {
+		logrus.Warnf("TripIt has more than %d pages of trips, only listing the first %d, raise --tripit-max-pages to get them all", tripitMaxPages, tripitMaxPages)
+	}
+
+	trips := []tripit.Trip(resp.Trips)
+
+	if pastFilter == "true" {
+		// Get future trips as well.
+		more, err := listTrips(ctx, tripitClient, "false")
+		if err != nil {
+			return nil, err
+		}
+
+		return append(trips, more...), nil
+	}
+
+	return trips, nil
+}
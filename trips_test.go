@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+)
+
+func TestTripDuring(t *testing.T) {
+	// July 2024.
+	from := time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	testcases := []struct {
+		start string
+		end   string
+		want  bool
+	}{
+		{start: "2024-07-10", end: "2024-07-14", want: true},
+		{start: "2024-06-28", end: "2024-07-01", want: true},
+		{start: "2024-07-31", end: "2024-08-03", want: true},
+		{start: "2024-06-20", end: "2024-06-30", want: false},
+		{start: "2024-08-01", end: "2024-08-05", want: false},
+	}
+
+	for _, tc := range testcases {
+		trip := tripit.Trip{StartDate: tc.start, EndDate: tc.end}
+		if got := tripDuring(trip, from, to); got != tc.want {
+			t.Errorf("tripDuring(%s..%s) = %t, want %t", tc.start, tc.end, got, tc.want)
+		}
+	}
+}
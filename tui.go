@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// ANSI escape sequences for drawing the terminal UI.
+const (
+	ansiClear      = "\x1b[H\x1b[2J"
+	ansiAltScreen  = "\x1b[?1049h\x1b[?25l"
+	ansiMainScreen = "\x1b[?25h\x1b[?1049l"
+	ansiReverse    = "\x1b[7m"
+	ansiBold       = "\x1b[1m"
+	ansiDim        = "\x1b[2m"
+	ansiReset      = "\x1b[0m"
+)
+
+// itineraryTrip is a trip and its events from the itinerary.
+type itineraryTrip struct {
+	ID     string
+	Name   string
+	Start  time.Time
+	End    time.Time
+	Events []tripit.Event
+}
+
+// upcomingTrips groups the events by trip and returns the trips that have not
+// ended yet, soonest first.
+func upcomingTrips(events []tripit.Event, now time.Time) []itineraryTrip {
+	byID := map[string]*itineraryTrip{}
+	var trips []*itineraryTrip
+	for _, e := range events {
+		if !e.IsFlight() {
+			continue
+		}
+		start, end := eventTime(e.Start), eventTime(e.End)
+		t, ok := byID[e.ID]
+		if !ok {
+			t = &itineraryTrip{ID: e.ID, Name: e.TripName, Start: start, End: end}
+			if len(t.Name) < 1 {
+				t.Name = "Trip to " + e.DestinationCity
+			}
+			byID[e.ID] = t
+			trips = append(trips, t)
+		}
+		if start.Before(t.Start) {
+			t.Start = start
+		}
+		if end.After(t.End) {
+			t.End = end
+		}
+		t.Events = append(t.Events, e)
+	}
+
+	var upcoming []itineraryTrip
+	for _, t := range trips {
+		if t.End.Before(now) {
+			continue
+		}
+		sort.SliceStable(t.Events, func(i, j int) bool {
+			return eventTime(t.Events[i].Start).Before(eventTime(t.Events[j].Start))
+		})
+		upcoming = append(upcoming, *t)
+	}
+	sort.SliceStable(upcoming, func(i, j int) bool {
+		return upcoming[i].Start.Before(upcoming[j].Start)
+	})
+
+	return upcoming
+}
+
+// formatZoned formats the time in its own timezone, naming the zone.
+func formatZoned(t calendar.EventDateTime) string {
+	tm := eventTime(t)
+	if tm.IsZero() {
+		return ""
+	}
+	s := tm.Format("Mon Jan 2 15:04")
+	if len(t.TimeZone) > 0 {
+		return s + " " + t.TimeZone
+	}
+	return s + " " + tm.Format("-07:00")
+}
+
+const tuiHelp = `Browse upcoming trips in an interactive terminal view.`
+
+const tuiLongHelp = `Browse upcoming trips in an interactive terminal view.
+
+Shows the upcoming trips from the last itinerary fetched from TripIt, with
+every time in the timezone it happens in.
+
+  up/down, k/j   move between trips
+  enter, l       show the flights of the trip
+  esc, h         go back to the trips
+  f              fetch the itinerary from TripIt
+  s              sync to the calendar
+  q              quit`
+
+func (cmd *tuiCommand) Name() string      { return "tui" }
+func (cmd *tuiCommand) Args() string      { return "" }
+func (cmd *tuiCommand) ShortHelp() string { return tuiHelp }
+func (cmd *tuiCommand) LongHelp() string  { return tuiLongHelp }
+func (cmd *tuiCommand) Hidden() bool      { return false }
+
+func (cmd *tuiCommand) Register(fs *flag.FlagSet) {}
+
+type tuiCommand struct{}
+
+// tui is the state of the terminal UI.
+type tui struct {
+	trips    []itineraryTrip
+	fetched  time.Time
+	selected int
+	open     bool
+	busy     bool
+	status   string
+}
+
+func (cmd *tuiCommand) Run(ctx context.Context, args []string) error {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return errors.New("tui needs a terminal")
+	}
+
+	ui := &tui{}
+	if err := ui.load(); err != nil {
+		return err
+	}
+	if ui.fetched.IsZero() {
+		ui.status = "No itinerary yet, press f to fetch it from TripIt"
+	}
+
+	restore, err := rawTerminal()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	// Keep log lines from scribbling over the screen.
+	logrus.SetOutput(ioutil.Discard)
+	defer logrus.SetOutput(os.Stderr)
+
+	fmt.Print(ansiAltScreen)
+	defer fmt.Print(ansiMainScreen)
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+	done := make(chan string, 1)
+
+	for {
+		ui.render(os.Stdout, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-done:
+			ui.busy = false
+			ui.status = msg
+			if err := ui.load(); err != nil {
+				ui.status = err.Error()
+			}
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			switch key {
+			case "q", "\x03":
+				return nil
+			case "k", "\x1b[A":
+				if !ui.open && ui.selected > 0 {
+					ui.selected--
+				}
+			case "j", "\x1b[B":
+				if !ui.open && ui.selected < len(ui.trips)-1 {
+					ui.selected++
+				}
+			case "l", "\r", "\n", "\x1b[C":
+				ui.open = len(ui.trips) > 0
+			case "h", "\x1b", "\x7f", "\x1b[D":
+				ui.open = false
+			case "f", "s":
+				if ui.busy {
+					continue
+				}
+				ui.busy = true
+				if key == "f" {
+					ui.status = "Fetching the itinerary from TripIt..."
+					go func() { done <- tuiFetch(ctx) }()
+				} else {
+					ui.status = "Syncing to the calendar..."
+					go func() { done <- tuiSync(ctx) }()
+				}
+			}
+		}
+	}
+}
+
+// load reads the trips from the snapshot in the state file.
+func (ui *tui) load() error {
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+	if st.Snapshot == nil {
+		return nil
+	}
+
+	ui.fetched = st.Snapshot.Fetched
+	ui.trips = upcomingTrips(st.Snapshot.Events, time.Now())
+	if ui.selected >= len(ui.trips) {
+		ui.selected = len(ui.trips) - 1
+	}
+	if ui.selected < 0 {
+		ui.selected = 0
+	}
+	return nil
+}
+
+// render draws the screen. The terminal is in raw mode, so lines end with
+// \r\n.
+func (ui *tui) render(w io.Writer, now time.Time) {
+	var b strings.Builder
+	b.WriteString(ansiClear)
+
+	if ui.open && ui.selected < len(ui.trips) {
+		t := ui.trips[ui.selected]
+		fmt.Fprintf(&b, "%s%s%s\r\n", ansiBold, t.Name, ansiReset)
+		fmt.Fprintf(&b, "%s to %s\r\n\r\n", t.Start.Format("Mon Jan 2"), t.End.Format("Mon Jan 2"))
+		for _, e := range t.Events {
+			fmt.Fprintf(&b, "%s%s%s\r\n", ansiBold, e.Title, ansiReset)
+			fmt.Fprintf(&b, "  Departs %s  %s\r\n", e.AirportCode, formatZoned(e.Start))
+			fmt.Fprintf(&b, "  Arrives %s  %s\r\n", e.DestinationCode, formatZoned(e.End))
+			if len(e.ConfirmationNumber) > 0 {
+				fmt.Fprintf(&b, "  Confirmation %s\r\n", e.ConfirmationNumber)
+			}
+			b.WriteString("\r\n")
+		}
+	} else {
+		fmt.Fprintf(&b, "%sUpcoming trips%s\r\n\r\n", ansiBold, ansiReset)
+		if len(ui.trips) < 1 {
+			b.WriteString("No upcoming trips.\r\n")
+		}
+		for i, t := range ui.trips {
+			line := fmt.Sprintf(" %-12s %-12s %-40s %d flights ", t.Start.Format("Mon Jan 2"), t.End.Format("Mon Jan 2"), t.Name, len(t.Events))
+			if i == ui.selected {
+				line = ansiReverse + line + ansiReset
+			}
+			b.WriteString(line + "\r\n")
+		}
+	}
+
+	b.WriteString("\r\n" + ansiDim)
+	if !ui.fetched.IsZero() {
+		fmt.Fprintf(&b, "Fetched %s ago. ", now.Sub(ui.fetched).Truncate(time.Minute))
+	}
+	b.WriteString("j/k move, enter open, esc back, f fetch, s sync, q quit" + ansiReset + "\r\n")
+	if len(ui.status) > 0 {
+		b.WriteString(ui.status + "\r\n")
+	}
+
+	io.WriteString(w, b.String())
+}
+
+// tuiFetch fetches the itinerary from TripIt and returns the status to show.
+func tuiFetch(ctx context.Context) string {
+	tripitClient, err := newTripItClient()
+	if err != nil {
+		return err.Error()
+	}
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err.Error()
+	}
+	trips, err := fetchPhase(ctx, tripitClient, st, fmt.Sprintf("%v", past))
+	if err != nil {
+		return err.Error()
+	}
+	if err := st.save(); err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("Fetched %d events from TripIt", len(trips))
+}
+
+// tuiSync syncs to the calendar and returns the status to show.
+func tuiSync(ctx context.Context) string {
+	tripitClient, err := newTripItClient()
+	if err != nil {
+		return err.Error()
+	}
+	if err := validateCalendarFlags(); err != nil {
+		return err.Error()
+	}
+	backends, err := getCalendarBackends(ctx)
+	if err != nil {
+		return err.Error()
+	}
+
+	res, err := runWithTimeout(ctx, tripitClient, backends, fmt.Sprintf("%v", past))
+	if err != nil {
+		return "Sync failed: " + err.Error()
+	}
+	return fmt.Sprintf("Synced: %d created, %d updated, %d unchanged", res.Created, res.Updated, res.Unchanged)
+}
+
+// readKeys sends the key presses read from r to keys, closing it when r is
+// done.
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		keys <- string(buf[:n])
+	}
+}
+
+// rawTerminal puts the terminal on stdin into raw mode, so we get every key
+// press as it happens, and returns a function to restore it.
+func rawTerminal() (func(), error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("getting the terminal state failed: %v", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("putting the terminal in raw mode failed: %v", err)
+	}
+	return func() {
+		stty(strings.TrimSpace(state))
+	}, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// writeJournal records the calendar writes of a write phase.
+type writeJournal struct {
+	At     time.Time      `json:"at"`
+	Writes []journalEntry `json:"writes"`
+}
+
+// journalEntry is a single event the write phase created or updated.
+type journalEntry struct {
+	SegmentID string `json:"segmentID"`
+	EventID   string `json:"eventID"`
+	// Before is the event as it was before it was updated, or nil if the
+	// event was created.
+	Before *calendar.Event `json:"before,omitempty"`
+}
+
+// beginWrites starts journaling the writes of a write phase.
+func (s *syncState) beginWrites() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writes = &writeJournal{At: time.Now().UTC()}
+}
+
+// journal records a write to the calendar. It does nothing outside of a write
+// phase.
+func (s *syncState) journal(segmentID, eventID string, before *calendar.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writes == nil {
+		return
+	}
+	s.writes.Writes = append(s.writes.Writes, journalEntry{
+		SegmentID: segmentID,
+		EventID:   eventID,
+		Before:    before,
+	})
+}
+
+// commitWrites keeps the journal of the write phase as the last write, unless
+// it did not write anything.
+func (s *syncState) commitWrites() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writes != nil && len(s.writes.Writes) > 0 {
+		s.LastWrite = s.writes
+	}
+	s.writes = nil
+}
+
+const undoHelp = `Undo the calendar writes of the last sync.`
+
+const undoLongHelp = `Undo the calendar writes of the last sync.
+
+Deletes the events the last sync that wrote anything created, and puts the
+events it updated back the way they were. Events it removed are not brought
+back, use restore for that.
+
+The next sync writes the same changes again unless the itinerary in TripIt
+changed, so fix the trip in TripIt or stop the bot first.`
+
+func (cmd *undoCommand) Name() string      { return "undo" }
+func (cmd *undoCommand) Args() string      { return "" }
+func (cmd *undoCommand) ShortHelp() string { return undoHelp }
+func (cmd *undoCommand) LongHelp() string  { return undoLongHelp }
+func (cmd *undoCommand) Hidden() bool      { return false }
+
+func (cmd *undoCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.yes, "yes", false, "Do not ask for confirmation")
+}
+
+type undoCommand struct {
+	yes bool
+}
+
+func (cmd *undoCommand) Run(ctx context.Context, args []string) error {
+	st, err := loadState(stateFile)
+	if err != nil {
+		return err
+	}
+	j := st.LastWrite
+	if j == nil {
+		return fmt.Errorf("there are no calendar writes in the state file %s to undo", stateFile)
+	}
+
+	backend := getPrimaryBackend(ctx)
+
+	question := fmt.Sprintf("Undo %d writes to %s made at %s?", len(j.Writes), backend, j.At.Local().Format(time.RFC1123))
+	if !cmd.yes && !confirm(question) {
+		return nil
+	}
+
+	// Undo the newest writes first and keep the ones that failed, so
+	// running undo again retries them.
+	var failed []journalEntry
+	for i := len(j.Writes) - 1; i >= 0; i-- {
+		entry := j.Writes[i]
+		if err := undoWrite(ctx, backend, st, entry); err != nil {
+			logrus.Error(err)
+			failed = append([]journalEntry{entry}, failed...)
+		}
+	}
+
+	if len(failed) > 0 {
+		st.LastWrite.Writes = failed
+	} else {
+		st.LastWrite = nil
+	}
+	if err := st.save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Undid %d of %d writes\n", len(j.Writes)-len(failed), len(j.Writes))
+	if len(failed) > 0 {
+		return fmt.Errorf("undoing %d writes failed, run undo again to retry them", len(failed))
+	}
+	return nil
+}
+
+// undoWrite deletes the event the entry created or puts back the event it
+// updated, and updates the state to match.
+func undoWrite(ctx context.Context, backend calendarBackend, st *syncState, entry journalEntry) error {
+	if entry.Before == nil {
+		if err := backend.Delete(ctx, entry.SegmentID, entry.EventID); err != nil && !isNotFound(err) {
+			return fmt.Errorf("deleting event %s for segment %s from %s failed: %v", entry.EventID, entry.SegmentID, backend, err)
+		}
+		st.forget(entry.SegmentID)
+		return nil
+	}
+
+	before := *entry.Before
+	if err := restoreEvent(ctx, backend, entry.SegmentID, entry.EventID, before); err != nil {
+		return fmt.Errorf("restoring event %s for segment %s in %s failed: %v", entry.EventID, entry.SegmentID, backend, err)
+	}
+
+	st.mu.Lock()
+	if se, ok := st.Events[entry.SegmentID]; ok {
+		se.Hash = privateProperty(&before, propertyHash)
+	}
+	st.mu.Unlock()
+	return nil
+}
+
+// restoreEvent puts the event back the way it was before. Google Calendar
+// replaces the event, the other calendars only patch it, so the fields the
+// sync set that were empty before are cleared explicitly.
+func restoreEvent(ctx context.Context, backend calendarBackend, key, id string, before calendar.Event) error {
+	if g, ok := backend.(*googleBackend); ok {
+		// The event has moved on since, so let Google pick the sequence
+		// number.
+		before.Sequence = 0
+		_, err := g.service.Events.Update(g.calendarID, id, &before).Context(ctx).Do(sendUpdates(sendUpdatesUpdate))
+		return err
+	}
+	before.ForceSendFields = append(before.ForceSendFields, "Summary", "Description", "Location", "Visibility", "Transparency")
+	return backend.Update(ctx, key, id, &before)
+}
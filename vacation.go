@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// gmailSettingsScope lets a token change the Gmail settings of a user,
+	// like the vacation responder, but not read or send mail.
+	gmailSettingsScope = "https://www.googleapis.com/auth/gmail.settings.basic"
+
+	gmailVacationURL = "https://gmail.googleapis.com/gmail/v1/users/me/settings/vacation"
+)
+
+// vacationSettings are the Gmail vacation responder settings.
+type vacationSettings struct {
+	EnableAutoReply       bool   `json:"enableAutoReply"`
+	ResponseSubject       string `json:"responseSubject,omitempty"`
+	ResponseBodyPlainText string `json:"responseBodyPlainText,omitempty"`
+	RestrictToContacts    bool   `json:"restrictToContacts,omitempty"`
+	RestrictToDomain      bool   `json:"restrictToDomain,omitempty"`
+	// EndTime is when Gmail stops replying on its own, in milliseconds
+	// since the epoch, so the responder is off after the trip even if the
+	// bot is not running.
+	EndTime int64 `json:"endTime,string,omitempty"`
+}
+
+// isPersonalTrip returns true if the trip is classified as a personal or
+// weekend trip.
+func isPersonalTrip(trip tripit.Trip) bool {
+	return classifyTrip(trip) != tripKindBusiness
+}
+
+// currentPersonalTrip returns the personal trip we are on at now, or nil if
+// there is none.
+func currentPersonalTrip(trips []tripit.Trip, now time.Time) *tripit.Trip {
+	today := now.Format("2006-01-02")
+	for i, trip := range trips {
+		// Trip dates are plain dates, so compare them as strings.
+		if !isPersonalTrip(trip) || today < trip.StartDate || today > trip.EndDate {
+			continue
+		}
+		return &trips[i]
+	}
+	return nil
+}
+
+// vacationFor returns the vacation responder settings for the trip, from the
+// subject and message templates.
+func vacationFor(trip tripit.Trip) vacationSettings {
+	end, _ := time.ParseInLocation("2006-01-02", trip.EndDate, time.Local)
+	back := end.AddDate(0, 0, 1)
+	r := strings.NewReplacer(
+		"{trip}", trip.DisplayName,
+		"{kind}", classifyTrip(trip),
+		"{location}", trip.PrimaryLocation,
+		"{end}", end.Format("Monday, January 2"),
+		"{back}", back.Format("Monday, January 2"),
+	)
+	return vacationSettings{
+		EnableAutoReply:       true,
+		ResponseSubject:       r.Replace(vacationSubject),
+		ResponseBodyPlainText: r.Replace(vacationMessage),
+		EndTime:               back.UnixNano() / int64(time.Millisecond),
+	}
+}
+
+// syncVacationResponder turns the Gmail vacation responder on for the first
+// day of a personal trip, and off again once we are back. It never changes a
+// responder the user turned on themselves.
+func syncVacationResponder(ctx context.Context, tripitClient *tripit.Client, st *syncState) error {
+	trips, err := listTrips(ctx, tripitClient, "false")
+	if err != nil {
+		return err
+	}
+	trip := currentPersonalTrip(trips, time.Now())
+	if trip != nil && st.Vacation == trip.ID {
+		return nil
+	}
+	if trip == nil && len(st.Vacation) < 1 {
+		return nil
+	}
+
+	client, err := newGmailClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if trip == nil {
+		if err := gmailCall(ctx, client, http.MethodPut, vacationSettings{EnableAutoReply: false}, nil); err != nil {
+			return err
+		}
+		logrus.Infof("turned off the vacation responder of %s after trip %s", vacationResponder, st.Vacation)
+		st.Vacation = ""
+		return nil
+	}
+
+	if len(st.Vacation) < 1 {
+		var current vacationSettings
+		if err := gmailCall(ctx, client, http.MethodGet, nil, &current); err != nil {
+			return err
+		}
+		if current.EnableAutoReply {
+			logrus.Debugf("not changing the vacation responder of %s turned on by the user", vacationResponder)
+			return nil
+		}
+	}
+
+	if err := gmailCall(ctx, client, http.MethodPut, vacationFor(*trip), nil); err != nil {
+		return err
+	}
+	logrus.Infof("turned on the vacation responder of %s for trip %s", vacationResponder, trip.DisplayName)
+	st.Vacation = trip.ID
+	return nil
+}
+
+// newGmailClient returns an HTTP client that acts as the vacation responder
+// user. The service account needs domain-wide delegation for the Gmail
+// settings scope, which is separate from the calendar scope.
+func newGmailClient(ctx context.Context) (*http.Client, error) {
+	data, err := readGoogleKeyfile()
+	if err != nil {
+		return nil, err
+	}
+	defer zero(data)
+
+	conf, err := google.JWTConfigFromJSON(data, gmailSettingsScope)
+	if err != nil {
+		return nil, fmt.Errorf("creating gmail token source from file %s failed: %v", googleCalendarKeyfile, err)
+	}
+	conf.Subject = vacationResponder
+	return conf.Client(ctx), nil
+}
+
+// gmailCall calls the vacation settings endpoint of the Gmail API with the
+// body and decodes the response into v.
+func gmailCall(ctx context.Context, client *http.Client, method string, body interface{}, v interface{}) error {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, gmailVacationURL, &buf)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("calling the gmail vacation settings failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("calling the gmail vacation settings returned %s", resp.Status)
+	}
+
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return fmt.Errorf("decoding the gmail vacation settings failed: %v", err)
+		}
+	}
+	return nil
+}
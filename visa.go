@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/countries"
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// eventTypeVisa is the type of the events reminding us to apply for a visa.
+// They are made by the bot, not TripIt.
+const eventTypeVisa = "visa"
+
+// parsePassports parses the --passport countries, names or ISO 3166 codes,
+// into the countries.
+func parsePassports(s string) ([]countries.Country, error) {
+	var passports []countries.Country
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if len(p) < 1 {
+			continue
+		}
+		c, ok := countries.Lookup(p)
+		if !ok {
+			return nil, fmt.Errorf("passport %q is not a country the bot knows", p)
+		}
+		passports = append(passports, c)
+	}
+	return passports, nil
+}
+
+// visaNeed is what it takes to enter a country on the trip.
+type visaNeed struct {
+	country     string
+	requirement countries.Requirement
+}
+
+// visaNeeds returns what it takes to enter each country the trip goes to
+// on the easiest of the passports, leaving out the countries we are free to
+// go to and those the requirements are not known for.
+func visaNeeds(trip tripit.Trip, flights []tripit.Event, passports []countries.Country) []visaNeed {
+	seen := map[string]bool{}
+	var needs []visaNeed
+	for _, s := range countryStays(trip, flights) {
+		country := countries.Name(s.country)
+		if seen[country] {
+			continue
+		}
+		seen[country] = true
+
+		var (
+			best  countries.Requirement
+			found bool
+		)
+		for _, p := range passports {
+			r, ok := countries.VisaRequirement(p.Code, country)
+			if ok && (!found || r.Easier(best)) {
+				best, found = r, true
+			}
+		}
+		if found && best.Kind != countries.EntryFreeMovement {
+			needs = append(needs, visaNeed{country: country, requirement: best})
+		}
+	}
+	return needs
+}
+
+// visaEvents adds what it takes to enter the countries each trip goes to,
+// on our --passport, to the description of the event spanning the trip, and
+// returns an all-day event --visa-reminder before the trip to apply for the
+// visas and travel authorizations it needs.
+func visaEvents(events []tripit.Event, trips map[string]tripit.Trip, passports []countries.Country) []tripit.Event {
+	flights := tripFlights(events)
+	needs := map[string][]visaNeed{}
+	var ids []string
+	for id, trip := range trips {
+		needs[id] = visaNeeds(trip, flights[id], passports)
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for i := range events {
+		e := &events[i]
+		if e.Type != tripit.EventTypeTrip || len(needs[e.ID]) < 1 {
+			continue
+		}
+		var lines []string
+		for _, n := range needs[e.ID] {
+			lines = append(lines, fmt.Sprintf("- %s: %s", n.country, n.requirement))
+		}
+		e.Description += "\n\nEntry requirements on your passport, check them with the destination's government before you go:\n" + strings.Join(lines, "\n")
+	}
+
+	var out []tripit.Event
+	for _, id := range ids {
+		trip := trips[id]
+		var apply []string
+		for _, n := range needs[id] {
+			if n.requirement.NeedsApplying() {
+				apply = append(apply, fmt.Sprintf("- %s: %s", n.country, n.requirement))
+			}
+		}
+		start, err := time.Parse("2006-01-02", trip.StartDate)
+		if len(apply) < 1 || err != nil {
+			continue
+		}
+
+		day := start.Add(-visaReminder)
+		title := firstNonEmpty(trip.DisplayName, "Trip to "+trip.PrimaryLocation)
+		out = append(out, tripit.Event{
+			Type:  eventTypeVisa,
+			Title: "Apply for visas for " + title,
+			Description: fmt.Sprintf("%s starts %s. You likely need to apply for these before you go, which can take weeks:\n%s",
+				title, start.Format("Mon Jan 2, 2006"), strings.Join(apply, "\n")),
+			// All-day events end the day after their last day.
+			Start:           calendar.EventDateTime{Date: day.Format("2006-01-02")},
+			End:             calendar.EventDateTime{Date: day.AddDate(0, 0, 1).Format("2006-01-02")},
+			ID:              trip.ID,
+			SegmentID:       "trip-" + trip.ID + "-visa",
+			DestinationCity: trip.PrimaryLocationAddress.City,
+			TripName:        trip.DisplayName,
+			Private:         trip.IsPrivate,
+			Tags:            trip.Tags(),
+			Kind:            classifyTrip(trip),
+			AllDay:          true,
+		})
+	}
+	return out
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+)
+
+const watchHelp = `Keep a live display of the next departure and the sync status.`
+
+const watchLongHelp = `Keep a live display of the next departure and the sync status.
+
+Shows the next flight with a countdown to departure and its terminal and
+gate, or how long until landing while in the air, along with when the
+itinerary was last fetched and synced. Made to be left running in a
+terminal or tmux pane on travel days.
+
+The display follows the state file, so run it next to the bot. Pass
+--fetch-interval to have it fetch the itinerary from TripIt itself when the
+bot is not running.`
+
+func (cmd *watchCommand) Name() string      { return "watch" }
+func (cmd *watchCommand) Args() string      { return "" }
+func (cmd *watchCommand) ShortHelp() string { return watchHelp }
+func (cmd *watchCommand) LongHelp() string  { return watchLongHelp }
+func (cmd *watchCommand) Hidden() bool      { return false }
+
+func (cmd *watchCommand) Register(fs *flag.FlagSet) {
+	fs.DurationVar(&cmd.fetchInterval, "fetch-interval", 0, "How often to fetch the itinerary from TripIt, 0 to only follow the state file (ex. 5m)")
+}
+
+type watchCommand struct {
+	fetchInterval time.Duration
+}
+
+func (cmd *watchCommand) Run(ctx context.Context, args []string) error {
+	if !isTerminal(os.Stdout) {
+		return errors.New("watch needs a terminal")
+	}
+
+	var tripitClient *tripit.Client
+	if cmd.fetchInterval > 0 {
+		var err error
+		tripitClient, err = newTripItClient()
+		if err != nil {
+			fatal(exitCodeConfig, err)
+		}
+	}
+
+	// Keep log lines from scribbling over the screen.
+	logrus.SetOutput(ioutil.Discard)
+	defer logrus.SetOutput(os.Stderr)
+
+	fmt.Print(ansiAltScreen)
+	defer fmt.Print(ansiMainScreen)
+
+	var (
+		st        *syncState
+		loaded    time.Time
+		lastFetch time.Time
+		fetchErr  error
+		stateErr  error
+	)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		now := time.Now()
+
+		if tripitClient != nil && now.Sub(lastFetch) >= cmd.fetchInterval {
+			lastFetch = now
+			fetchErr = watchFetch(ctx, tripitClient)
+			loaded = time.Time{}
+		}
+
+		// The state only changes when the bot runs, so there is no
+		// need to read it every second.
+		if now.Sub(loaded) >= 5*time.Second {
+			var s *syncState
+			s, stateErr = loadState(stateFile)
+			if stateErr == nil {
+				st = s
+			}
+			loaded = now
+		}
+
+		renderWatch(os.Stdout, st, firstError(fetchErr, stateErr), now)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+		}
+	}
+}
+
+// watchFetch fetches the itinerary from TripIt into the state file.
+func watchFetch(ctx context.Context, tripitClient *tripit.Client) error {
+	st, err := loadState(stateFile)
+	if err != nil {
+		return fmt.Errorf("reading state failed: %v", err)
+	}
+	if _, err := fetchPhase(ctx, tripitClient, st, fmt.Sprintf("%v", past)); err != nil {
+		return fmt.Errorf("fetching the itinerary failed: %v", err)
+	}
+	return st.save()
+}
+
+// renderWatch draws the watch display for the state at now.
+func renderWatch(w io.Writer, st *syncState, err error, now time.Time) {
+	var b strings.Builder
+	b.WriteString(ansiClear)
+
+	var events []tripit.Event
+	if st != nil && st.Snapshot != nil {
+		events = st.Snapshot.Events
+	}
+
+	current, next := nextDeparture(events, now)
+	switch {
+	case current != nil:
+		fmt.Fprintf(&b, "%sIn the air%s\n", ansiBold, ansiReset)
+		fmt.Fprintf(&b, "  %s\n", current.Title)
+		fmt.Fprintf(&b, "  %s -> %s, lands %s\n", current.AirportCode, current.DestinationCode, formatZoned(current.End))
+		fmt.Fprintf(&b, "  %sLands in %s%s\n\n", ansiBold, formatCountdown(eventTime(current.End).Sub(now)), ansiReset)
+	case next == nil:
+		b.WriteString("No upcoming flights.\n\n")
+	}
+	if next != nil {
+		fmt.Fprintf(&b, "%sNext departure%s\n", ansiBold, ansiReset)
+		fmt.Fprintf(&b, "  %s\n", next.Title)
+		fmt.Fprintf(&b, "  %s -> %s, departs %s\n", next.AirportCode, next.DestinationCode, formatZoned(next.Start))
+		if gate := joinNonEmpty("  ", labeled("Terminal", next.Terminal), labeled("Gate", next.Gate)); len(gate) > 0 {
+			fmt.Fprintf(&b, "  %s\n", gate)
+		}
+		if len(next.ConfirmationNumber) > 0 {
+			fmt.Fprintf(&b, "  Confirmation %s\n", next.ConfirmationNumber)
+		}
+		fmt.Fprintf(&b, "  %sDeparts in %s%s\n\n", ansiBold, formatCountdown(eventTime(next.Start).Sub(now)), ansiReset)
+	}
+
+	fmt.Fprintf(&b, "%sSync%s\n", ansiBold, ansiReset)
+	if st == nil || st.Snapshot == nil {
+		b.WriteString("  The itinerary has not been fetched yet\n")
+	} else {
+		fmt.Fprintf(&b, "  Itinerary fetched %s ago\n", formatCountdown(now.Sub(st.Snapshot.Fetched)))
+	}
+	if synced := lastSynced(st); !synced.IsZero() {
+		fmt.Fprintf(&b, "  Calendar synced %s ago\n", formatCountdown(now.Sub(synced)))
+	}
+	if st != nil && st.Quota != nil && now.Before(st.Quota.Until) {
+		fmt.Fprintf(&b, "  Writes paused until %s, out of Google Calendar quota\n", st.Quota.Until.Local().Format("Mon Jan 2 15:04"))
+	}
+	if st != nil && now.Before(st.Throttled) {
+		fmt.Fprintf(&b, "  Sync paused until %s, TripIt is rate limiting us\n", st.Throttled.Local().Format("Mon Jan 2 15:04"))
+	}
+	if err != nil {
+		fmt.Fprintf(&b, "  %v\n", err)
+	}
+
+	fmt.Fprintf(&b, "\n%s%s%s\n", ansiDim, now.Format("Mon Jan 2 15:04:05 MST"), ansiReset)
+
+	io.WriteString(w, b.String())
+}
+
+// nextDeparture returns the flight we are on at now, if any, and the next
+// flight to depart after now.
+func nextDeparture(events []tripit.Event, now time.Time) (*tripit.Event, *tripit.Event) {
+	var current, next *tripit.Event
+	for i := range events {
+		e := &events[i]
+		if !e.IsFlight() {
+			continue
+		}
+		start, end := eventTime(e.Start), eventTime(e.End)
+		if !now.Before(start) && now.Before(end) {
+			current = e
+		}
+		if start.After(now) && (next == nil || start.Before(eventTime(next.Start))) {
+			next = e
+		}
+	}
+	return current, next
+}
+
+// lastSynced returns when an event was last synced to the calendar.
+func lastSynced(st *syncState) time.Time {
+	var last time.Time
+	if st == nil {
+		return last
+	}
+	for _, se := range st.Events {
+		if se.Synced.After(last) {
+			last = se.Synced
+		}
+	}
+	return last
+}
+
+// formatCountdown formats the duration like 2d 3h 04m 05s.
+func formatCountdown(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	d = d.Truncate(time.Second)
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh %02dm %02ds", days, h, m, s)
+	case h > 0:
+		return fmt.Sprintf("%dh %02dm %02ds", h, m, s)
+	}
+	return fmt.Sprintf("%dm %02ds", m, s)
+}
+
+// firstError returns the first of the errors that is not nil.
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/notify"
+	"github.com/sirupsen/logrus"
+)
+
+// watchdog notices when no sync has succeeded for too long, so the bot does
+// not fail silently. While the syncs are stale the bot reports itself as not
+// ready, sets the stale metric, and sends an alert.
+type watchdog struct {
+	mu          sync.Mutex
+	threshold   time.Duration
+	lastSuccess time.Time
+	isStale     bool
+
+	// validated is set once our credentials worked, and credentialsErr is
+	// why they did not until then.
+	validated      bool
+	credentialsErr error
+
+	// running is when the run in progress started, zero between runs.
+	running time.Time
+}
+
+// newWatchdog returns a watchdog that considers the syncs stale once none has
+// succeeded for the threshold. The clock starts now.
+func newWatchdog(threshold time.Duration) *watchdog {
+	return &watchdog{
+		threshold:   threshold,
+		lastSuccess: time.Now(),
+	}
+}
+
+// success records that a sync succeeded at t.
+func (w *watchdog) success(t time.Time) {
+	w.mu.Lock()
+	w.lastSuccess = t
+	w.validated = true
+	w.mu.Unlock()
+
+	metricLastSuccess.Set(float64(t.Unix()))
+}
+
+// stale returns true if no sync has succeeded within the threshold, and the
+// time of the last one that did.
+func (w *watchdog) stale() (bool, time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.isStale, w.lastSuccess
+}
+
+// validate records whether our credentials worked when we checked them.
+// Once they have, a failed check does not take that back, since the syncs
+// going stale report that.
+func (w *watchdog) validate(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.validated = w.validated || err == nil
+	w.credentialsErr = err
+}
+
+// ready returns an error saying why the bot is not ready: our credentials
+// have not worked yet, or the syncs are stale.
+func (w *watchdog) ready() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch {
+	case !w.validated && w.credentialsErr != nil:
+		return fmt.Errorf("the credentials did not work: %v", w.credentialsErr)
+	case !w.validated:
+		return errors.New("the credentials have not been checked yet")
+	case w.isStale:
+		return fmt.Errorf("no sync has succeeded since %s", w.lastSuccess.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// begin records that a run started at t, and end that it finished.
+func (w *watchdog) begin(t time.Time) {
+	w.mu.Lock()
+	w.running = t
+	w.mu.Unlock()
+}
+
+func (w *watchdog) end() {
+	w.mu.Lock()
+	w.running = time.Time{}
+	w.mu.Unlock()
+}
+
+// healthy returns an error if the run in progress has gone on for longer
+// than the limit at now, which --run-timeout should have stopped it before,
+// so the bot is wedged. A limit of zero never does.
+func (w *watchdog) healthy(now time.Time, limit time.Duration) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if limit > 0 && !w.running.IsZero() && now.Sub(w.running) > limit {
+		return fmt.Errorf("the run that started at %s is stuck", w.running.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// check updates whether the syncs are stale at now and alerts when that
+// changes.
+func (w *watchdog) check(ctx context.Context, now time.Time) {
+	w.mu.Lock()
+	stale := now.Sub(w.lastSuccess) > w.threshold
+	changed := stale != w.isStale
+	w.isStale = stale
+	last := w.lastSuccess
+	w.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	var msg notify.Message
+	if stale {
+		metricSyncStale.Set(1)
+		msg = notify.Message{
+			Title: "tripitcalb0t is not syncing",
+			Text:  fmt.Sprintf("No sync has succeeded since %s.", last.Format(time.RFC1123)),
+		}
+		logrus.Errorf("no sync has succeeded since %s", last.Format(time.RFC1123))
+	} else {
+		metricSyncStale.Set(0)
+		msg = notify.Message{
+			Title: "tripitcalb0t is syncing again",
+			Text:  fmt.Sprintf("A sync succeeded at %s.", last.Format(time.RFC1123)),
+		}
+		logrus.Infof("syncs are succeeding again")
+	}
+
+	if n := getNotifier(); n != nil {
+		if err := n.Notify(ctx, msg); err != nil {
+			logrus.Warnf("sending watchdog alert failed: %v", err)
+		}
+	}
+}
+
+// run checks the watchdog every period until the context is done.
+func (w *watchdog) run(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			w.check(ctx, now)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	"github.com/sirupsen/logrus"
+)
+
+// webTrip is an upcoming trip as the web UI and API show it.
+type webTrip struct {
+	ID      string      `json:"id"`
+	Name    string      `json:"name"`
+	Start   time.Time   `json:"start"`
+	End     time.Time   `json:"end"`
+	Flights []webFlight `json:"flights"`
+
+	Checklist []checklistItem `json:"checklist,omitempty"`
+}
+
+// webFlight is a flight as the web UI and API show it.
+type webFlight struct {
+	SegmentID    string    `json:"segmentID"`
+	Title        string    `json:"title"`
+	Flight       string    `json:"flight"`
+	From         string    `json:"from"`
+	To           string    `json:"to"`
+	Departs      time.Time `json:"departs"`
+	Arrives      time.Time `json:"arrives"`
+	DepartsLocal string    `json:"departsLocal"`
+	ArrivesLocal string    `json:"arrivesLocal"`
+	Terminal     string    `json:"terminal,omitempty"`
+	Gate         string    `json:"gate,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	Confirmation string    `json:"confirmation,omitempty"`
+}
+
+// webTrips returns the upcoming trips in the events, with their checklists
+// from the templates and the state.
+func webTrips(events []tripit.Event, now time.Time, templates []checklistTemplate, st *syncState) []webTrip {
+	trips := []webTrip{}
+	for _, t := range upcomingTrips(events, now) {
+		wt := webTrip{ID: t.ID, Name: t.Name, Start: t.Start, End: t.End}
+		if len(templates) > 0 {
+			wt.Checklist = tripChecklist(t, events, templates, st)
+		}
+		for _, e := range t.Events {
+			wt.Flights = append(wt.Flights, webFlight{
+				SegmentID:    e.SegmentID,
+				Title:        e.Title,
+				Flight:       e.FlightNumber,
+				From:         e.AirportCode,
+				To:           e.DestinationCode,
+				Departs:      eventTime(e.Start),
+				Arrives:      eventTime(e.End),
+				DepartsLocal: formatZoned(e.Start),
+				ArrivesLocal: formatZoned(e.End),
+				Terminal:     e.Terminal,
+				Gate:         e.Gate,
+				Status:       flightStatusText(e.Status),
+				Confirmation: e.ConfirmationNumber,
+			})
+		}
+		trips = append(trips, wt)
+	}
+	return trips
+}
+
+// accountTrips returns the upcoming trips the account may see from the last
+// itinerary fetched, and when it was fetched.
+func accountTrips(a *account) ([]webTrip, time.Time, error) {
+	st, err := loadState(stateFile)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if st.Snapshot == nil {
+		return []webTrip{}, time.Time{}, nil
+	}
+	var templates []checklistTemplate
+	if checklist {
+		templates, err = loadChecklistTemplates(checklistFile)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+	return webTrips(a.visible(st.Snapshot.Events), time.Now(), templates, st), st.Snapshot.Fetched, nil
+}
+
+var webPage = template.Must(template.New("web").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Upcoming trips</title>
+<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 50em; padding: 0 1em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { padding: 0.3em 0.6em 0.3em 0; text-align: left; vertical-align: top; }
+th { color: #666; font-size: 0.8em; font-weight: normal; }
+small { color: #666; }
+</style>
+</head>
+<body>
+<h1>Upcoming trips</h1>
+{{range .Trips}}
+<h2>{{.Name}}</h2>
+<p>{{.Start.Format "Mon Jan 2"}} to {{.End.Format "Mon Jan 2"}}</p>
+<table>
+<tr><th>Flight</th><th>Route</th><th>Departs</th><th>Arrives</th><th>Terminal</th><th>Gate</th><th>Status</th></tr>
+{{range .Flights}}<tr><td>{{.Flight}}</td><td>{{.From}} to {{.To}}</td><td>{{.DepartsLocal}}</td><td>{{.ArrivesLocal}}</td><td>{{.Terminal}}</td><td>{{.Gate}}</td><td>{{.Status}}</td></tr>
+{{end}}</table>
+{{if .Checklist}}<h3>Checklist</h3>
+<ul>
+{{range .Checklist}}<li>{{if .Done}}<s>{{.Item}}</s>{{else}}{{.Item}}{{end}} <small>due {{.Due.Format "Mon Jan 2"}}</small></li>
+{{end}}</ul>
+{{end}}{{else}}
+<p>No upcoming trips.</p>
+{{end}}
+<p><small>Signed in as {{.Name}}.{{if not .Fetched.IsZero}} Updated {{.Fetched.Format "Mon Jan 2 15:04 MST"}}.{{end}}</small></p>
+</body>
+</html>
+`))
+
+// webHandler serves the web UI, the upcoming trips the account may see.
+func webHandler(w http.ResponseWriter, r *http.Request, a *account) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	trips, fetched, err := accountTrips(a)
+	if err != nil {
+		logrus.Errorf("reading state for the web UI failed: %v", err)
+		http.Error(w, "the trips are not available right now", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := webPage.Execute(w, map[string]interface{}{
+		"Name":    a.Name,
+		"Trips":   trips,
+		"Fetched": fetched,
+	}); err != nil {
+		logrus.Errorf("writing the web UI failed: %v", err)
+	}
+}
+
+// apiTripsHandler serves the upcoming trips the account may see as JSON.
+func apiTripsHandler(w http.ResponseWriter, r *http.Request, a *account) {
+	trips, _, err := accountTrips(a)
+	if err != nil {
+		logrus.Errorf("reading state for the API failed: %v", err)
+		http.Error(w, "the trips are not available right now", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(trips); err != nil {
+		logrus.Errorf("writing the API response failed: %v", err)
+	}
+}
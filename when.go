@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// whenUnits are the spans of time parseWhen knows, by name.
+var whenUnits = map[string]string{
+	"day": "day", "days": "day",
+	"week": "week", "weeks": "week",
+	"month": "month", "months": "month",
+	"year": "year", "years": "year",
+}
+
+// parseWhen parses a day or span of days said the way people say them, like
+// friday, next month, in 2 weeks, the next 10 days, july, 2024-07, or a
+// range like 2024-07-01..2024-07-15, relative to now. It returns the first
+// day and the day after the last, at midnight where now is.
+func parseWhen(s string, now time.Time) (time.Time, time.Time, error) {
+	s = strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	if i := strings.Index(s, ".."); i >= 0 {
+		from, _, err := parseWhen(s[:i], now)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		_, to, err := parseWhen(s[i+2:], now)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if !to.After(from) {
+			return time.Time{}, time.Time{}, fmt.Errorf("%q ends before it starts", s)
+		}
+		return from, to, nil
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := func(t time.Time) (time.Time, time.Time, error) {
+		return t, t.AddDate(0, 0, 1), nil
+	}
+
+	switch s {
+	case "today":
+		return day(today)
+	case "tomorrow":
+		return day(today.AddDate(0, 0, 1))
+	case "yesterday":
+		return day(today.AddDate(0, 0, -1))
+	}
+
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		t, err := time.ParseInLocation(layout, s, now.Location())
+		if err != nil {
+			continue
+		}
+		switch layout {
+		case "2006-01":
+			return t, t.AddDate(0, 1, 0), nil
+		case "2006":
+			return t, t.AddDate(1, 0, 0), nil
+		}
+		return day(t)
+	}
+
+	words := strings.Fields(s)
+	if len(words) > 0 && words[0] == "the" {
+		words = words[1:]
+	}
+	switch {
+	case len(words) == 1:
+		if wd, ok := parseWeekday(words[0]); ok {
+			// The coming one, today included.
+			return day(today.AddDate(0, 0, (int(wd)-int(today.Weekday())+7)%7))
+		}
+		if m, ok := parseMonth(words[0]); ok {
+			// The coming one, this one included.
+			year := today.Year()
+			if m < today.Month() {
+				year++
+			}
+			return spanOf("month", time.Date(year, m, 1, 0, 0, 0, 0, now.Location()))
+		}
+	case len(words) == 2 && (words[0] == "this" || words[0] == "next" || words[0] == "last"):
+		step := map[string]int{"this": 0, "next": 1, "last": -1}[words[0]]
+		if wd, ok := parseWeekday(words[1]); ok {
+			if step == 0 {
+				return day(today.AddDate(0, 0, (int(wd)-int(today.Weekday())+7)%7))
+			}
+			// The first one after today, or the last one before it.
+			d := (int(wd)-int(today.Weekday())+6)%7 + 1
+			if step < 0 {
+				d = -((int(today.Weekday())-int(wd)+6)%7 + 1)
+			}
+			return day(today.AddDate(0, 0, d))
+		}
+		if unit, ok := whenUnits[words[1]]; ok && unit != "day" {
+			return spanOf(unit, addUnits(unit, today, step))
+		}
+	case len(words) == 3 && words[0] == "in":
+		n, err := strconv.Atoi(words[1])
+		unit, ok := whenUnits[words[2]]
+		if err == nil && ok {
+			return spanOf(unit, addUnits(unit, today, n))
+		}
+	case len(words) == 3 && (words[0] == "next" || words[0] == "last" || words[0] == "past"):
+		n, err := strconv.Atoi(words[1])
+		unit, ok := whenUnits[words[2]]
+		if err == nil && ok && n > 0 {
+			if words[0] == "next" {
+				return today, addSpan(unit, today, n), nil
+			}
+			return addSpan(unit, today, -n), today.AddDate(0, 0, 1), nil
+		}
+	}
+
+	return time.Time{}, time.Time{}, fmt.Errorf("cannot tell when %q is, try today, friday, next month, in 2 weeks, the next 10 days, july, 2024-07-04, or 2024-07-01..2024-07-15", s)
+}
+
+// spanOf returns the day, week starting on Monday, month, or year that t
+// falls in.
+func spanOf(unit string, t time.Time) (time.Time, time.Time, error) {
+	switch unit {
+	case "week":
+		start := t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7), nil
+	case "month":
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 1, 0), nil
+	case "year":
+		start := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(1, 0, 0), nil
+	}
+	return t, t.AddDate(0, 0, 1), nil
+}
+
+// addUnits adds n days, weeks, months, or years to t. Months and years are
+// added from the first of the month, so January 31 plus a month is in
+// February.
+func addUnits(unit string, t time.Time, n int) time.Time {
+	switch unit {
+	case "week":
+		return t.AddDate(0, 0, 7*n)
+	case "month":
+		return time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	case "year":
+		return time.Date(t.Year()+n, t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+	return t.AddDate(0, 0, n)
+}
+
+// addSpan adds n days, weeks, months, or years to t.
+func addSpan(unit string, t time.Time, n int) time.Time {
+	switch unit {
+	case "week":
+		return t.AddDate(0, 0, 7*n)
+	case "month":
+		return t.AddDate(0, n, 0)
+	case "year":
+		return t.AddDate(n, 0, 0)
+	}
+	return t.AddDate(0, 0, n)
+}
+
+// parseWeekday parses a day of the week, like friday or fri.
+func parseWeekday(s string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || (len(s) >= 3 && strings.HasPrefix(name, s)) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// parseMonth parses a month, like july or jul.
+func parseMonth(s string) (time.Month, bool) {
+	for m := time.January; m <= time.December; m++ {
+		name := strings.ToLower(m.String())
+		if s == name || (len(s) >= 3 && strings.HasPrefix(name, s)) {
+			return m, true
+		}
+	}
+	return 0, false
+}
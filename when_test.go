@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWhen(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2024, time.July, 10, 15, 30, 0, 0, time.UTC)
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	testcases := []struct {
+		when string
+		from string
+		to   string
+	}{
+		{when: "today", from: "2024-07-10", to: "2024-07-11"},
+		{when: "tomorrow", from: "2024-07-11", to: "2024-07-12"},
+		{when: "yesterday", from: "2024-07-09", to: "2024-07-10"},
+		{when: "friday", from: "2024-07-12", to: "2024-07-13"},
+		{when: "fri", from: "2024-07-12", to: "2024-07-13"},
+		{when: "wednesday", from: "2024-07-10", to: "2024-07-11"},
+		{when: "monday", from: "2024-07-15", to: "2024-07-16"},
+		{when: "this friday", from: "2024-07-12", to: "2024-07-13"},
+		{when: "next friday", from: "2024-07-12", to: "2024-07-13"},
+		{when: "next wednesday", from: "2024-07-17", to: "2024-07-18"},
+		{when: "last friday", from: "2024-07-05", to: "2024-07-06"},
+		{when: "this week", from: "2024-07-08", to: "2024-07-15"},
+		{when: "next week", from: "2024-07-15", to: "2024-07-22"},
+		{when: "last week", from: "2024-07-01", to: "2024-07-08"},
+		{when: "this month", from: "2024-07-01", to: "2024-08-01"},
+		{when: "next month", from: "2024-08-01", to: "2024-09-01"},
+		{when: "  Next   Month ", from: "2024-08-01", to: "2024-09-01"},
+		{when: "next year", from: "2025-01-01", to: "2026-01-01"},
+		{when: "in 2 weeks", from: "2024-07-22", to: "2024-07-29"},
+		{when: "in 3 days", from: "2024-07-13", to: "2024-07-14"},
+		{when: "in 6 months", from: "2025-01-01", to: "2025-02-01"},
+		{when: "the next 10 days", from: "2024-07-10", to: "2024-07-20"},
+		{when: "next 2 weeks", from: "2024-07-10", to: "2024-07-24"},
+		{when: "last 30 days", from: "2024-06-10", to: "2024-07-11"},
+		{when: "past 2 months", from: "2024-05-10", to: "2024-07-11"},
+		{when: "july", from: "2024-07-01", to: "2024-08-01"},
+		{when: "june", from: "2025-06-01", to: "2025-07-01"},
+		{when: "2024", from: "2024-01-01", to: "2025-01-01"},
+		{when: "2024-07", from: "2024-07-01", to: "2024-08-01"},
+		{when: "2024-07-04", from: "2024-07-04", to: "2024-07-05"},
+		{when: "2024-07-01..2024-07-15", from: "2024-07-01", to: "2024-07-16"},
+		{when: "today..next friday", from: "2024-07-10", to: "2024-07-13"},
+	}
+
+	for _, tc := range testcases {
+		from, to, err := parseWhen(tc.when, now)
+		if err != nil {
+			t.Errorf("parseWhen(%q) failed: %v", tc.when, err)
+			continue
+		}
+		if !from.Equal(date(tc.from)) || !to.Equal(date(tc.to)) {
+			t.Errorf("parseWhen(%q) = %s..%s, want %s..%s", tc.when, from.Format("2006-01-02"), to.Format("2006-01-02"), tc.from, tc.to)
+		}
+	}
+}
+
+func TestParseWhenInvalid(t *testing.T) {
+	now := time.Date(2024, time.July, 10, 15, 30, 0, 0, time.UTC)
+
+	for _, when := range []string{
+		"",
+		"someday",
+		"in two weeks",
+		"next 0 days",
+		"in 2 fortnights",
+		"2024-07-15..2024-07-01",
+		"2024-13",
+		"fr",
+	} {
+		if from, to, err := parseWhen(when, now); err == nil {
+			t.Errorf("parseWhen(%q) = %s..%s, want an error", when, from, to)
+		}
+	}
+}
+
+func TestParseWhenLocation(t *testing.T) {
+	loc := time.FixedZone("UTC-7", -7*60*60)
+	// Still the 9th where we are, though it is the 10th in UTC.
+	now := time.Date(2024, time.July, 9, 22, 0, 0, 0, loc)
+
+	from, to, err := parseWhen("today", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, time.July, 9, 0, 0, 0, 0, loc); !from.Equal(want) {
+		t.Errorf("parseWhen(today) starts at %s, want %s", from, want)
+	}
+	if want := time.Date(2024, time.July, 10, 0, 0, 0, 0, loc); !to.Equal(want) {
+		t.Errorf("parseWhen(today) ends at %s, want %s", to, want)
+	}
+}
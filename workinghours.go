@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessfraz/tripitcalb0t/tripit"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+const (
+	// eventTypeWorkingHours is the type of the events suggesting adjusted
+	// working hours. They are made by the bot, not TripIt.
+	eventTypeWorkingHours = "working-hours"
+
+	// workingHoursMinDays is how many days we need to spend in another
+	// timezone before adjusting working hours is worth suggesting.
+	workingHoursMinDays = 2
+)
+
+// workingDay is the start and end of the working day.
+type workingDay struct {
+	start, end time.Duration
+}
+
+// parseWorkingHours parses working hours like 09:00-17:00.
+func parseWorkingHours(s string) (workingDay, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return workingDay{}, fmt.Errorf("working hours must look like 09:00-17:00, got %q", s)
+	}
+	var d workingDay
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return workingDay{}, fmt.Errorf("working hours must look like 09:00-17:00, got %q", s)
+		}
+		v := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		if i == 0 {
+			d.start = v
+		} else {
+			d.end = v
+		}
+	}
+	if d.end <= d.start {
+		return workingDay{}, fmt.Errorf("working hours must end after they start, got %q", s)
+	}
+	return d, nil
+}
+
+// homeLocation returns the timezone we work in at home.
+func homeLocation() (*time.Location, error) {
+	if len(homeTimezone) < 1 {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(homeTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("loading home-timezone %q failed: %v", homeTimezone, err)
+	}
+	return loc, nil
+}
+
+// awayStay is a stretch of a trip spent in a timezone other than home's,
+// from landing in it until flying out of it.
+type awayStay struct {
+	arrival   tripit.Event
+	arrive    time.Time
+	departure time.Time
+	cities    []string
+}
+
+// workingHoursEvents returns an all-day event for every stretch of the trips
+// in the events spent for multiple days in a timezone other than home, that
+// suggests adjusting the calendar's working hours so colleagues schedule
+// meetings when we are working. Only flights move us across timezones, and
+// a trip without a flight out of a timezone has no end to the stay there.
+func workingHoursEvents(events []tripit.Event, home *time.Location, day workingDay) []tripit.Event {
+	byTrip := map[string][]tripit.Event{}
+	var ids []string
+	for _, e := range events {
+		if !e.IsFlight() {
+			continue
+		}
+		if _, ok := byTrip[e.ID]; !ok {
+			ids = append(ids, e.ID)
+		}
+		byTrip[e.ID] = append(byTrip[e.ID], e)
+	}
+
+	var out []tripit.Event
+	for _, id := range ids {
+		flights := byTrip[id]
+		sort.SliceStable(flights, func(i, j int) bool {
+			return eventTime(flights[i].Start).Before(eventTime(flights[j].Start))
+		})
+
+		var stays []*awayStay
+		var current *awayStay
+		for i := 0; i+1 < len(flights); i++ {
+			arrive, depart := eventTime(flights[i].End), eventTime(flights[i+1].Start)
+			if arrive.IsZero() || depart.IsZero() {
+				current = nil
+				continue
+			}
+			_, offset := arrive.Zone()
+			_, homeOffset := arrive.In(home).Zone()
+			if offset == homeOffset {
+				current = nil
+				continue
+			}
+			// Flying on within the same timezone carries on the stay.
+			if current != nil {
+				if _, o := current.arrive.Zone(); o == offset {
+					current.departure = depart
+					current.cities = appendCity(current.cities, flights[i].DestinationCity)
+					continue
+				}
+			}
+			current = &awayStay{
+				arrival:   flights[i],
+				arrive:    arrive,
+				departure: depart,
+				cities:    appendCity(nil, flights[i].DestinationCity),
+			}
+			stays = append(stays, current)
+		}
+
+		for _, s := range stays {
+			if e, ok := s.event(home, day); ok {
+				out = append(out, e)
+			}
+		}
+	}
+	return out
+}
+
+// event returns the event suggesting adjusted working hours for the stay, or
+// false if the stay is too short to bother.
+func (s *awayStay) event(home *time.Location, day workingDay) (tripit.Event, bool) {
+	first := time.Date(s.arrive.Year(), s.arrive.Month(), s.arrive.Day(), 0, 0, 0, 0, s.arrive.Location())
+	departure := s.departure.In(s.arrive.Location())
+	last := time.Date(departure.Year(), departure.Month(), departure.Day(), 0, 0, 0, 0, s.arrive.Location())
+	if last.Sub(first) < workingHoursMinDays*24*time.Hour {
+		return tripit.Event{}, false
+	}
+
+	zone := utcOffset(s.arrive)
+	_, offset := s.arrive.Zone()
+	_, homeOffset := s.arrive.In(home).Zone()
+	diff := time.Duration(offset-homeOffset) * time.Second
+	direction := "ahead of"
+	if diff < 0 {
+		direction, diff = "behind", -diff
+	}
+
+	start, end := first.Add(day.start).In(home), first.Add(day.end).In(home)
+	description := fmt.Sprintf(`You are in %s (%s) from %s to %s, %s %s home (%s).
+
+Working %s to %s there is %s to %s at home. Consider changing your working hours in Google Calendar for these days, so colleagues schedule meetings when you are working.`,
+		strings.Join(s.cities, ", "),
+		zone,
+		first.Format("Mon Jan 2"),
+		last.Format("Mon Jan 2"),
+		formatHours(diff),
+		direction,
+		home.String(),
+		first.Add(day.start).Format("15:04"),
+		first.Add(day.end).Format("15:04"),
+		start.Format("15:04"),
+		end.Format("15:04"))
+
+	return tripit.Event{
+		Type:               eventTypeWorkingHours,
+		Title:              fmt.Sprintf("Adjusted working hours (%s)", zone),
+		Description:        description,
+		Start:              calendar.EventDateTime{Date: first.Format("2006-01-02")},
+		End:                calendar.EventDateTime{Date: last.AddDate(0, 0, 1).Format("2006-01-02")},
+		ID:                 s.arrival.ID,
+		SegmentID:          s.arrival.SegmentID + "-working-hours",
+		ConfirmationNumber: s.arrival.ConfirmationNumber,
+		DestinationCity:    s.arrival.DestinationCity,
+		TripName:           s.arrival.TripName,
+		Private:            s.arrival.Private,
+		Tags:               s.arrival.Tags,
+		Location:           strings.Join(s.cities, ", "),
+		AllDay:             true,
+	}, true
+}
+
+// workingHoursSuggestion returns the announcement for a new event suggesting
+// adjusted working hours.
+func workingHoursSuggestion(e tripit.Event) string {
+	last := eventTime(e.End).AddDate(0, 0, -1)
+	zone := strings.TrimSuffix(strings.TrimPrefix(e.Title, "Adjusted working hours ("), ")")
+	return fmt.Sprintf("Consider adjusting your working hours to %s for %s from %s to %s", zone, e.Location, eventTime(e.Start).Format("Mon Jan 2"), last.Format("Mon Jan 2"))
+}
+
+// utcOffset names the offset of the time from UTC, like UTC+09:00.
+func utcOffset(t time.Time) string {
+	return "UTC" + t.Format("-07:00")
+}
+
+// formatHours formats a difference between timezones, like 9 hours or 5:30
+// hours.
+func formatHours(d time.Duration) string {
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	if m > 0 {
+		return fmt.Sprintf("%d:%02d hours", h, m)
+	}
+	if h == 1 {
+		return "1 hour"
+	}
+	return fmt.Sprintf("%d hours", h)
+}
+
+func appendCity(cities []string, city string) []string {
+	if len(city) < 1 || containsString(cities, city) {
+		return cities
+	}
+	return append(cities, city)
+}